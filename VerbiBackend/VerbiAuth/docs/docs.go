@@ -15,6 +15,36 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/auth": {
+            "get": {
+                "description": "Validates an access token and returns userId",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Validates an access token",
+                "operationId": "validate",
+                "responses": {
+                    "200": {
+                        "description": "Successful validation",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ValidateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/auth/code": {
             "get": {
                 "description": "Resends confirmation code to user's email",
@@ -350,6 +380,62 @@ const docTemplate = `{
                 }
             }
         },
+        "/internal/users": {
+            "get": {
+                "security": [
+                    {
+                        "ServiceToken": []
+                    }
+                ],
+                "description": "Returns id and username of the user with exactly the given username or email, e.g. when a document is shared with them. Only other Verbi services can call it with their service token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Internal"
+                ],
+                "summary": "Finds a user for another service",
+                "operationId": "findUser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Username or email",
+                        "name": "login",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/responses.FindUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/profile": {
             "get": {
                 "security": [
@@ -479,10 +565,10 @@ const docTemplate = `{
         "requests.ChangeUsernameRequest": {
             "type": "object",
             "required": [
-                "newUsername"
+                "new_username"
             ],
             "properties": {
-                "newUsername": {
+                "new_username": {
                     "type": "string"
                 }
             }
@@ -537,6 +623,17 @@ const docTemplate = `{
                 }
             }
         },
+        "responses.FindUserResponse": {
+            "type": "object",
+            "properties": {
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
         "responses.GetUserInfoResponse": {
             "type": "object",
             "properties": {
@@ -569,6 +666,14 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "responses.ValidateResponse": {
+            "type": "object",
+            "properties": {
+                "user_id": {
+                    "type": "integer"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -577,6 +682,12 @@ const docTemplate = `{
             "type": "apiKey",
             "name": "Authorization",
             "in": "header"
+        },
+        "ServiceToken": {
+            "description": "SERVICE_TOKEN shared by the Verbi services",
+            "type": "apiKey",
+            "name": "Service-Token",
+            "in": "header"
         }
     }
 }`
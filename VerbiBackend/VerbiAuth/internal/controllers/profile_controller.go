@@ -121,6 +121,36 @@ func (c *ProfileController) GetUserInfo(ctx *gin.Context) {
 	})
 }
 
+// FindUser endpoint for other Verbi services to find a user by username or email
+// @Summary Finds a user for another service
+// @Description Returns id and username of the user with exactly the given username or email, e.g. when a document is shared with them. Only other Verbi services can call it with their service token
+// @Tags Internal
+// @ID findUser
+// @Accept json
+// @Produce json
+// @Param login query string true "Username or email"
+// @Success 200 {object} responses.FindUserResponse "OK"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security ServiceToken
+// @Router /internal/users [get]
+func (c *ProfileController) FindUser(ctx *gin.Context) {
+	login := ctx.Query("login")
+	if login == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "login is required"})
+		return
+	}
+
+	findUserResponse, err := c.profileService.FindUser(login)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, findUserResponse)
+}
+
 // DeleteAccount endpoint deletes all user info from the database
 // @Summary Handles account deletion
 // @Description Deletes all user info from the database
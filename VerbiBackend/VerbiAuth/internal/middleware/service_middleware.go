@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"os"
+)
+
+// ServiceTokenHeader is the header other Verbi services send SERVICE_TOKEN in
+const ServiceTokenHeader = "Service-Token"
+
+// ServiceMiddleware lets through only requests of other Verbi services, which send SERVICE_TOKEN in the Service-Token
+// header. All requests are refused while SERVICE_TOKEN is not set
+func ServiceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := os.Getenv("SERVICE_TOKEN")
+		token := c.GetHeader(ServiceTokenHeader)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid service token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,7 @@
+package responses
+
+// FindUserResponse represents public data of a user found by username or email
+type FindUserResponse struct {
+	UserId   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
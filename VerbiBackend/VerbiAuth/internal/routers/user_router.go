@@ -8,7 +8,7 @@ import (
 	"time"
 )
 
-// SetupRoutes sets up the routes for auth and profile management actions
+// SetupRoutes sets up the routes for auth and profile management actions and for other Verbi services
 func SetupRoutes(r *gin.Engine, authController *controllers.AuthController, profileController *controllers.ProfileController) {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
@@ -41,4 +41,11 @@ func SetupRoutes(r *gin.Engine, authController *controllers.AuthController, prof
 		profileGroup.PUT("/", profileController.ChangeUsername)
 		profileGroup.DELETE("/", profileController.DeleteAccount)
 	}
+
+	// lookups of other users are only open to other Verbi services, so users can not enumerate accounts
+	internalGroup := api.Group("/internal")
+	internalGroup.Use(middleware.ServiceMiddleware())
+	{
+		internalGroup.GET("/users", profileController.FindUser)
+	}
 }
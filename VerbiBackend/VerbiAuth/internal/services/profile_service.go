@@ -4,6 +4,7 @@ import (
 	"VerbiAuth/internal/models/responses"
 	"VerbiAuth/internal/repositories"
 	"errors"
+	"strings"
 )
 
 // ProfileService to handle actions related to account management
@@ -50,6 +51,21 @@ func (s *ProfileService) GetUserInfo(userId uint) (*responses.GetUserInfoRespons
 	return &response, nil
 }
 
+// FindUser method to find a user by exact username or email, which is told apart by the @ sign
+func (s *ProfileService) FindUser(login string) (*responses.FindUserResponse, error) {
+	login = strings.TrimSpace(login)
+	findUser := s.UserRepository.GetUserByUsername
+	if strings.Contains(login, "@") {
+		findUser = s.UserRepository.GetUserByEmail
+	}
+
+	user, err := findUser(login)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return &responses.FindUserResponse{UserId: user.ID, Username: user.Username}, nil
+}
+
 // DeleteAccount function to delete profile from the app
 func (s *ProfileService) DeleteAccount(userId uint) error {
 	user, err := s.UserRepository.GetUserById(userId)
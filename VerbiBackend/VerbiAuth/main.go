@@ -27,6 +27,11 @@ import (
 // @in header
 // @name Authorization
 // @description `Bearer <your_access_token>`
+
+// @securityDefinitions.apikey ServiceToken
+// @in header
+// @name Service-Token
+// @description SERVICE_TOKEN shared by the Verbi services
 func main() {
 	err := config.LoadEnv()
 	if err != nil {
@@ -0,0 +1,39 @@
+package middleware_test
+
+import (
+	"VerbiAuth/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve sends a request with the service token to a route behind ServiceMiddleware and returns the status
+func serve(token string) int {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/internal/users", middleware.ServiceMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/internal/users?login=someone", nil)
+	if token != "" {
+		request.Header.Set(middleware.ServiceTokenHeader, token)
+	}
+	router.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+// TestServiceMiddleware tests that only requests with the service token get through
+func TestServiceMiddleware(t *testing.T) {
+	t.Setenv("SERVICE_TOKEN", "")
+	assert.Equal(t, http.StatusUnauthorized, serve(""), "nothing gets through without a configured token")
+
+	t.Setenv("SERVICE_TOKEN", "secret")
+	assert.Equal(t, http.StatusOK, serve("secret"))
+	assert.Equal(t, http.StatusUnauthorized, serve(""))
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer secret"))
+	assert.Equal(t, http.StatusUnauthorized, serve("wrong"))
+}
@@ -112,3 +112,30 @@ func TestDeleteAccount(t *testing.T) {
 	err = profileService.DeleteAccount(999)
 	assert.Error(t, err)
 }
+
+// TestFindUser tests finding a user by username or email
+func TestFindUser(t *testing.T) {
+	db, err := setupTestProfileDB()
+	assert.NoError(t, err)
+	profileService, err := setupProfileService(db)
+	assert.NoError(t, err)
+
+	user := &models.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password",
+	}
+	err = profileService.UserRepository.CreateUser(user)
+	assert.NoError(t, err)
+
+	byUsername, err := profileService.FindUser("testuser")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, byUsername.UserId)
+
+	byEmail, err := profileService.FindUser(" test@example.com ")
+	assert.NoError(t, err)
+	assert.Equal(t, "testuser", byEmail.Username)
+
+	_, err = profileService.FindUser("test@example.org")
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package clients
+
+import (
+	"VerbiDocuments/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrUserNotFound is returned when VerbiAuth has no user with the requested username or email
+var ErrUserNotFound = errors.New("user not found")
+
+// authRequestTimeout limits requests to VerbiAuth
+const authRequestTimeout = 10 * time.Second
+
+// AuthClient finds users through the internal API of VerbiAuth, implements interfaces.UserDirectory
+type AuthClient struct {
+	BaseURL      string
+	ServiceToken string
+	Client       *http.Client
+}
+
+// NewAuthClient creates an AuthClient for the VerbiAuth API at baseURL, e.g. http://auth:8080/api/v1,
+// authenticating with the token shared by the Verbi services
+func NewAuthClient(baseURL, serviceToken string) *AuthClient {
+	return &AuthClient{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		ServiceToken: serviceToken,
+		Client:       &http.Client{Timeout: authRequestTimeout},
+	}
+}
+
+// FindUser returns the user with exactly the given username or email
+func (c *AuthClient) FindUser(login string) (*models.UserInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/internal/users?login="+url.QueryEscape(login), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Service-Token", c.ServiceToken)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach auth service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrUserNotFound
+	default:
+		return nil, fmt.Errorf("auth service responded with status %d", resp.StatusCode)
+	}
+
+	user := new(models.UserInfo)
+	err = json.NewDecoder(resp.Body).Decode(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode auth service response: %w", err)
+	}
+	if user.UserId == 0 {
+		return nil, errors.New("auth service returned a user without id")
+	}
+	return user, nil
+}
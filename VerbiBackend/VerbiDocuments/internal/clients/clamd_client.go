@@ -0,0 +1,85 @@
+package clients
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of chunks the content is streamed to clamd in
+const clamdChunkSize = 64 * 1024
+
+// ClamdClient scans files with a ClamAV daemon using the INSTREAM command, implements interfaces.Scanner
+type ClamdClient struct {
+	Address string
+	Timeout time.Duration
+}
+
+// NewClamdClient creates a ClamdClient for the clamd TCP socket at address, e.g. clamav:3310.
+// The timeout limits connecting and every following read or write
+func NewClamdClient(address string, timeout time.Duration) *ClamdClient {
+	return &ClamdClient{
+		Address: address,
+		Timeout: timeout,
+	}
+}
+
+// Scan streams the content to clamd and returns the name of the found threat, empty if the content is clean
+func (c *ClamdClient) Scan(reader io.Reader) (string, error) {
+	conn, err := net.DialTimeout("tcp", c.Address, c.Timeout)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach clamd: %w", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(c.Timeout))
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return "", fmt.Errorf("failed to send scan command: %w", err)
+	}
+
+	chunk := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := reader.Read(chunk)
+		if n > 0 {
+			_ = conn.SetDeadline(time.Now().Add(c.Timeout))
+			binary.BigEndian.PutUint32(size, uint32(n))
+			_, err = conn.Write(append(size, chunk[:n]...))
+			if err != nil {
+				return "", fmt.Errorf("failed to stream content to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", readErr
+		}
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(c.Timeout))
+	_, err = conn.Write([]byte{0, 0, 0, 0})
+	if err != nil {
+		return "", fmt.Errorf("failed to stream content to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	reply = strings.TrimSpace(strings.TrimSuffix(reply, "\x00"))
+
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd failed to scan: %s", reply)
+	}
+}
@@ -0,0 +1,154 @@
+package clients
+
+import (
+	"VerbiDocuments/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a URL is not an http or https URL or leads to an address outside the public internet
+var ErrForbiddenAddress = errors.New("address not allowed")
+
+// ErrDownloadRefused is returned when the remote server refuses the request for good, e.g. with 404 Not Found
+var ErrDownloadRefused = errors.New("download refused")
+
+// ErrDownloadTooLarge is returned when the remote file exceeds the size limit of the client
+var ErrDownloadTooLarge = errors.New("remote file is too large")
+
+// DownloadClient downloads files over HTTP with a limit of their size and of the time the whole download takes,
+// implements interfaces.Downloader. Unless private networks are allowed, it only connects to public addresses,
+// so users cannot make the service reach internal hosts
+type DownloadClient struct {
+	Client  *http.Client
+	MaxSize int64
+}
+
+// NewDownloadClient creates a DownloadClient giving up on downloads after timeout and on files larger than maxSize bytes
+func NewDownloadClient(timeout time.Duration, maxSize int64, allowPrivateNetworks bool) *DownloadClient {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if !allowPrivateNetworks {
+		dialer.Control = rejectPrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// connecting through a proxy would bypass the address check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &DownloadClient{
+		Client:  &http.Client{Timeout: timeout, Transport: transport},
+		MaxSize: maxSize,
+	}
+}
+
+// nonPublicNetworks are global unicast networks net.IP does not report as private that lead to non-public hosts:
+// the shared address space of carrier-grade NAT and the NAT64 prefix, which translates to any IPv4 address
+var nonPublicNetworks = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+// mustParseCIDR parses the network or panics
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// rejectPrivateAddress refuses connections to loopback, private, link-local, carrier-grade NAT, NAT64
+// and other non-public addresses. It runs for the resolved address of every connection, redirects included
+func rejectPrivateAddress(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	for _, denied := range nonPublicNetworks {
+		if denied.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+		}
+	}
+	return nil
+}
+
+// Download requests the file at the URL
+func (c *DownloadClient) Download(ctx context.Context, rawURL string) (*models.RemoteFile, io.ReadCloser, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, nil, fmt.Errorf("%w: only http and https URLs can be downloaded", ErrForbiddenAddress)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "VerbiDocuments")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download %s: %w", parsed.Redacted(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("server responded with status %d", resp.StatusCode)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			err = fmt.Errorf("%w: %v", ErrDownloadRefused, err)
+		}
+		return nil, nil, err
+	}
+	if c.MaxSize > 0 && resp.ContentLength > c.MaxSize {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("%w: %d bytes exceed the limit of %d bytes", ErrDownloadTooLarge, resp.ContentLength, c.MaxSize)
+	}
+
+	file := &models.RemoteFile{
+		Url:      resp.Request.URL.String(),
+		FileName: path.Base(resp.Request.URL.Path),
+		Size:     resp.ContentLength,
+	}
+	if file.FileName == "/" || file.FileName == "." {
+		file.FileName = ""
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		file.FileName = path.Base(params["filename"])
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		file.ContentType = mediaType
+	}
+
+	if c.MaxSize <= 0 {
+		return file, resp.Body, nil
+	}
+	return file, &limitedBody{ReadCloser: resp.Body, remaining: c.MaxSize}, nil
+}
+
+// limitedBody fails reading the response body once it exceeds the remaining number of bytes
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+// Read reads from the body until the limit is exceeded
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n, ErrDownloadTooLarge
+	}
+	return n, err
+}
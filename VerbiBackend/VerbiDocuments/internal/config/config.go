@@ -1,7 +1,12 @@
 package config
 
 import (
+	"VerbiDocuments/internal/clients"
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/interfaces"
 	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
 	"errors"
 	"fmt"
 	"github.com/gliderlabs/ssh"
@@ -12,8 +17,14 @@ import (
 	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// sftpUserIdKey is the key of the authenticated user id in the ssh session context
+const sftpUserIdKey = "user_id"
+
 // LoadEnv function to get variables from .env file
 func LoadEnv() error {
 	err := godotenv.Load()
@@ -25,33 +36,358 @@ func LoadEnv() error {
 	return nil
 }
 
-func SetupSftpServer(db *gorm.DB) error {
-	repository := repositories.NewSftpRepository(db)
+// SetupBlobStore creates the documents storage driver selected by the STORAGE_DRIVER variable.
+// Identical files are stored once by deduplicating on top of the driver
+func SetupBlobStore(db *gorm.DB) (*storage.DedupBlobStore, error) {
+	var store interfaces.BlobStore
+	var err error
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		root := os.Getenv("STORAGE_ROOT")
+		if root == "" {
+			root = "/home/verbi/uploads"
+		}
+		store, err = storage.NewLocalBlobStore(root)
+	case "s3":
+		store, err = storage.NewS3BlobStore(
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_USE_SSL") == "true",
+		)
+	default:
+		err = fmt.Errorf("unknown storage driver %s", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewDedupBlobStore(store, repositories.NewBlobRepository(db)), nil
+}
+
+// SetupUserDirectory creates the client of VerbiAuth at AUTH_SERVICE_URL used to find users to share documents with.
+// It authenticates with SERVICE_TOKEN, which must match the one VerbiAuth is configured with
+func SetupUserDirectory() (interfaces.UserDirectory, error) {
+	baseURL := os.Getenv("AUTH_SERVICE_URL")
+	if baseURL == "" {
+		return nil, errors.New("AUTH_SERVICE_URL is not set")
+	}
+	serviceToken := os.Getenv("SERVICE_TOKEN")
+	if serviceToken == "" {
+		return nil, errors.New("SERVICE_TOKEN is not set")
+	}
+	return clients.NewAuthClient(baseURL, serviceToken), nil
+}
+
+// ShareLinkSecret returns the SHARE_LINK_SECRET key signing public share links. Changing it invalidates all links
+func ShareLinkSecret() ([]byte, error) {
+	secret := os.Getenv("SHARE_LINK_SECRET")
+	if secret == "" {
+		return nil, errors.New("SHARE_LINK_SECRET is not set")
+	}
+	return []byte(secret), nil
+}
+
+// durationEnv parses a duration from the environment variable falling back to the default value
+func durationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return duration, nil
+}
+
+// int64Env parses an integer from the environment variable falling back to the default value
+func int64Env(name string, fallback int64) (int64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return number, nil
+}
+
+// SetupQuotaService creates the quota service with the default limits QUOTA_MAX_BYTES and QUOTA_MAX_DOCUMENTS,
+// unset or 0 does not limit. Storage usage of users is computed from their documents if it is not tracked yet
+func SetupQuotaService(db *gorm.DB) (*services.QuotaService, error) {
+	maxBytes, err := int64Env("QUOTA_MAX_BYTES", 0)
+	if err != nil {
+		return nil, err
+	}
+	maxDocuments, err := int64Env("QUOTA_MAX_DOCUMENTS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	quotaRepository := repositories.NewQuotaRepository(db)
+	err = quotaRepository.BackfillUsage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute storage usage: %w", err)
+	}
+	return services.NewQuotaService(quotaRepository, maxBytes, maxDocuments), nil
+}
+
+// SetupValidationService creates the validation of uploaded files. UPLOAD_ALLOWED_TYPES is a comma separated list
+// of allowed MIME types, PDF and EPUB by default. UPLOAD_MAX_FILE_SIZE in bytes and UPLOAD_MAX_PAGES limit documents,
+// unset or 0 does not limit. Files are scanned by clamd at CLAMAV_ADDRESS if it is set, waiting at most CLAMAV_TIMEOUT, 30s by default
+func SetupValidationService(blobStore interfaces.BlobStore) (*services.ValidationService, error) {
+	allowedMimeTypes := []string{extractors.MimeTypePdf, extractors.MimeTypeEpub}
+	if value := os.Getenv("UPLOAD_ALLOWED_TYPES"); value != "" {
+		allowedMimeTypes = strings.Split(strings.ReplaceAll(value, " ", ""), ",")
+	}
+	maxFileSize, err := int64Env("UPLOAD_MAX_FILE_SIZE", 0)
+	if err != nil {
+		return nil, err
+	}
+	maxPageCount, err := int64Env("UPLOAD_MAX_PAGES", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var scanner interfaces.Scanner
+	if address := os.Getenv("CLAMAV_ADDRESS"); address != "" {
+		timeout, err := durationEnv("CLAMAV_TIMEOUT", 30*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		scanner = clients.NewClamdClient(address, timeout)
+	}
+	return services.NewValidationService(blobStore, scanner, allowedMimeTypes, maxFileSize, int(maxPageCount)), nil
+}
+
+// SetupJobService creates the background job queue. Failed jobs are tried JOB_MAX_ATTEMPTS times, 5 by default,
+// waiting JOB_RETRY_DELAY, 10s by default, doubled after every attempt up to JOB_MAX_RETRY_DELAY, 1h by default.
+// Workers of a job type run at most as many jobs at once in every instance as JOB_CONCURRENCY allows, a comma separated
+// list of type=count pairs with a count for all other types without the type, 1 by default. Running jobs are leased for
+// JOB_LEASE, 10m by default, idle workers look for due jobs and busy ones for cancels every JOB_POLL_INTERVAL, 5s by
+// default. Finished jobs are deleted
+// after JOB_RETENTION, 168h by default, 0 keeps them
+func SetupJobService(db *gorm.DB) (*services.JobService, error) {
+	jobService := services.NewJobService(repositories.NewJobRepository(db))
+
+	maxAttempts, err := int64Env("JOB_MAX_ATTEMPTS", int64(jobService.MaxAttempts))
+	if err != nil {
+		return nil, err
+	}
+	if maxAttempts < 1 {
+		return nil, errors.New("invalid JOB_MAX_ATTEMPTS: must be at least 1")
+	}
+	jobService.MaxAttempts = int(maxAttempts)
+
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"JOB_RETRY_DELAY", &jobService.RetryDelay},
+		{"JOB_MAX_RETRY_DELAY", &jobService.MaxRetryDelay},
+		{"JOB_LEASE", &jobService.Lease},
+		{"JOB_POLL_INTERVAL", &jobService.PollInterval},
+		{"JOB_RETENTION", &jobService.Retention},
+	} {
+		*setting.value, err = durationEnv(setting.name, *setting.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if jobService.Lease <= 0 || jobService.PollInterval <= 0 {
+		return nil, errors.New("JOB_LEASE and JOB_POLL_INTERVAL must be positive")
+	}
+
+	if value := os.Getenv("JOB_CONCURRENCY"); value != "" {
+		for _, limit := range strings.Split(strings.ReplaceAll(value, " ", ""), ",") {
+			jobType, count, found := strings.Cut(limit, "=")
+			if !found {
+				jobType, count = "", limit
+			}
+			number, err := strconv.Atoi(count)
+			if err != nil || number < 0 {
+				return nil, fmt.Errorf("invalid JOB_CONCURRENCY: %s", value)
+			}
+			if jobType == "" {
+				jobService.DefaultConcurrency = number
+			} else {
+				jobService.Concurrency[jobType] = number
+			}
+		}
+	}
+	return jobService, nil
+}
+
+// SetupDownloadClient creates the client downloading imported files and OPDS feeds. Downloads larger than
+// IMPORT_MAX_FILE_SIZE bytes, 1 GiB by default, or taking longer than IMPORT_TIMEOUT, 10m by default, fail.
+// Only public internet addresses are reached unless IMPORT_ALLOW_PRIVATE_NETWORKS is true
+func SetupDownloadClient() (*clients.DownloadClient, error) {
+	maxFileSize, err := int64Env("IMPORT_MAX_FILE_SIZE", 1<<30)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := durationEnv("IMPORT_TIMEOUT", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	allowPrivateNetworks := os.Getenv("IMPORT_ALLOW_PRIVATE_NETWORKS") == "true"
+	return clients.NewDownloadClient(timeout, maxFileSize, allowPrivateNetworks), nil
+}
+
+// SetupProcessingService creates the processing pipeline of uploaded files, which registers its jobs in the job service
+func SetupProcessingService(
+	db *gorm.DB,
+	blobStore interfaces.BlobStore,
+	quotaService *services.QuotaService,
+	validationService *services.ValidationService,
+	jobService *services.JobService,
+) *services.ProcessingService {
+	documentRepository := repositories.NewDocumentRepository(db)
+	return services.NewProcessingService(
+		validationService,
+		services.NewMetadataService(documentRepository, quotaService, blobStore),
+		services.NewTextService(documentRepository, repositories.NewPageRepository(db), blobStore),
+		services.NewCoverService(documentRepository, blobStore),
+		services.NewVersionService(documentRepository, repositories.NewVersionRepository(db), blobStore),
+		jobService,
+	)
+}
+
+// SetupReconciler creates the storage reconciler. Orphan files younger than RECONCILE_GRACE_PERIOD, 24h by default, are kept
+func SetupReconciler(db *gorm.DB, blobStore interfaces.BlobStore) (*services.ReconcilerService, error) {
+	gracePeriod, err := durationEnv("RECONCILE_GRACE_PERIOD", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewReconcilerService(repositories.NewDocumentRepository(db), blobStore, gracePeriod), nil
+}
+
+// StartReconciler runs reconciliation every RECONCILE_INTERVAL, 1h by default, 0 disables periodic runs.
+// Found inconsistencies are only repaired when RECONCILE_REPAIR is true
+func StartReconciler(reconciler *services.ReconcilerService) error {
+	interval, err := durationEnv("RECONCILE_INTERVAL", time.Hour)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+	repair := os.Getenv("RECONCILE_REPAIR") == "true"
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := reconciler.Reconcile(repair)
+			if err != nil {
+				log.Printf("reconciliation failed: %v", err)
+				continue
+			}
+			log.Printf("reconciliation found %d orphan files and %d missing files", len(report.Orphans), len(report.MissingFiles))
+		}
+	}()
+	return nil
+}
+
+// StartVersionPruner applies the users' retention policies to document versions every VERSION_PRUNE_INTERVAL,
+// 1h by default, 0 disables periodic runs. Count limits are also applied on every upload, age limits need the periodic runs
+func StartVersionPruner(db *gorm.DB, blobStore interfaces.BlobStore) error {
+	interval, err := durationEnv("VERSION_PRUNE_INTERVAL", time.Hour)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+	documentRepository := repositories.NewDocumentRepository(db)
+	versionService := services.NewVersionService(documentRepository, repositories.NewVersionRepository(db), blobStore)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := versionService.PruneAllVersions(); err != nil {
+				log.Printf("version pruning failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// StartTrashPurger permanently deletes documents that are in the trash longer than TRASH_RETENTION, 720h by default,
+// every TRASH_PURGE_INTERVAL, 1h by default, 0 disables purging
+func StartTrashPurger(documentService *services.DocumentService) error {
+	retention, err := durationEnv("TRASH_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	interval, err := durationEnv("TRASH_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := documentService.PurgeTrash(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("trash purging failed: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("purged %d documents from the trash", purged)
+			}
+		}
+	}()
+	return nil
+}
+
+// SetupSftpServer starts the sftp server giving users access to their documents in the blob store
+func SetupSftpServer(
+	db *gorm.DB,
+	blobStore interfaces.BlobStore,
+	quotaService *services.QuotaService,
+	processingService *services.ProcessingService,
+) error {
+	sftpService := services.NewSftpService(
+		repositories.NewSftpRepository(db),
+		processingService.MetadataService.DocumentRepository,
+		repositories.NewShareRepository(db),
+		blobStore,
+		processingService,
+		quotaService,
+	)
 
 	sshServer := &ssh.Server{
 		Addr: fmt.Sprintf("0.0.0.0:%s", os.Getenv("SFTP_PORT")),
 		PasswordHandler: func(ctx ssh.Context, password string) bool {
-			username := ctx.User()
-			creds, err := repository.GetSftpCredentialsByUsername(username)
-			if err != nil {
-				log.Printf("Auth error for user %s: %v", username, err)
-				return false
+			userId, ok := sftpService.Authenticate(ctx.User(), password)
+			if ok {
+				ctx.SetValue(sftpUserIdKey, userId)
 			}
-			return creds.Password == password
+			return ok
 		},
 		SubsystemHandlers: map[string]ssh.SubsystemHandler{
 			"sftp": func(sess ssh.Session) {
-				server, err := sftp.NewServer(sess, sftp.WithDebug(os.Stderr))
-				if err != nil {
-					log.Printf("sftp start error: %v", err)
+				userId, ok := sess.Context().Value(sftpUserIdKey).(uint)
+				if !ok {
+					log.Printf("sftp session without authenticated user from %s", sess.RemoteAddr())
 					return
 				}
+
+				session := sftpService.OpenSession(userId)
+				server := sftp.NewRequestServer(sess, session.Handlers)
 				defer server.Close()
 				if err := server.Serve(); err != nil && err != io.EOF {
 					log.Printf("sftp serve error: %v", err)
 					return
 				}
-				log.Printf("client %s disconnected", sess.RemoteAddr())
+				log.Printf("client %s disconnected after %d uploads", sess.RemoteAddr(), session.Uploads())
 			},
 		},
 	}
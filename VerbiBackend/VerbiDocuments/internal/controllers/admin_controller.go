@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// AdminController provides maintenance endpoints for operators
+// @Tags Admin
+type AdminController struct {
+	ReconcilerService *services.ReconcilerService
+	QuotaService      *services.QuotaService
+}
+
+// NewAdminController creates a new AdminController
+func NewAdminController(reconcilerService *services.ReconcilerService, quotaService *services.QuotaService) *AdminController {
+	return &AdminController{
+		ReconcilerService: reconcilerService,
+		QuotaService:      quotaService,
+	}
+}
+
+// GetReconcileReport endpoint
+// @Summary Gives the last storage reconciliation report
+// @Description Returns files without documents and documents without files found by the last reconciliation
+// @Tags Admin
+// @ID getReconcileReport
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Success 200 {object} models.ReconcileReport
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Router /admin/reconcile [get]
+func (c *AdminController) GetReconcileReport(ctx *gin.Context) {
+	report := c.ReconcilerService.LastReport()
+	if report == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "reconciliation has not run yet"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// Reconcile endpoint
+// @Summary Runs storage reconciliation
+// @Description Checks the storage against the documents table. With repair, deletes orphan files older than the grace period and marks documents without files broken
+// @Tags Admin
+// @ID reconcile
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param repair query bool false "Repair found inconsistencies"
+// @Success 200 {object} models.ReconcileReport
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 500 {object} responses.ErrorResponse
+// @Router /admin/reconcile [post]
+func (c *AdminController) Reconcile(ctx *gin.Context) {
+	report, err := c.ReconcilerService.Reconcile(ctx.Query("repair") == "true")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// GetUserQuota endpoint
+// @Summary Gives the storage usage and quota of a user
+// @Description Returns the storage usage of the user together with their own quota or the default one. Zero means no limit
+// @Tags Admin
+// @ID getUserQuota
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param userId path uint true "User id"
+// @Success 200 {object} responses.GetUsageResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Router /admin/quotas/{userId} [get]
+func (c *AdminController) GetUserQuota(ctx *gin.Context) {
+	userId, ok := pathId(ctx, "userId", "user")
+	if !ok {
+		return
+	}
+
+	c.respondUsage(ctx, userId)
+}
+
+// UpdateUserQuota endpoint
+// @Summary Overrides the storage quota of a user
+// @Description Sets limits of the total size of document files and the number of documents of the user replacing the default quota. Zero means no limit. Documents the user already has are kept even if they exceed the new quota
+// @Tags Admin
+// @ID updateUserQuota
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param userId path uint true "User id"
+// @Param quota body requests.UpdateQuotaRequest true "Request body"
+// @Success 200 {object} responses.GetUsageResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Router /admin/quotas/{userId} [put]
+func (c *AdminController) UpdateUserQuota(ctx *gin.Context) {
+	userId, ok := pathId(ctx, "userId", "user")
+	if !ok {
+		return
+	}
+	req := new(requests.UpdateQuotaRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := c.QuotaService.SetQuota(userId, *req.MaxBytes, *req.MaxDocuments)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.respondUsage(ctx, userId)
+}
+
+// ResetUserQuota endpoint
+// @Summary Resets the storage quota of a user to the default one
+// @Description Deletes the quota override of the user, so the default limits apply again
+// @Tags Admin
+// @ID resetUserQuota
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param userId path uint true "User id"
+// @Success 200 {string} string "Quota reset"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Router /admin/quotas/{userId} [delete]
+func (c *AdminController) ResetUserQuota(ctx *gin.Context) {
+	userId, ok := pathId(ctx, "userId", "user")
+	if !ok {
+		return
+	}
+
+	err := c.QuotaService.ResetQuota(userId)
+	if errors.Is(err, services.ErrQuotaNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Quota reset"})
+}
+
+// respondUsage responds with the storage usage and the quota of the user
+func (c *AdminController) respondUsage(ctx *gin.Context, userId uint) {
+	usage, quota, err := c.QuotaService.GetUsage(userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetUsageResponse{
+		Bytes:        usage.Bytes,
+		MaxBytes:     quota.MaxBytes,
+		Documents:    usage.Documents,
+		MaxDocuments: quota.MaxDocuments,
+	})
+}
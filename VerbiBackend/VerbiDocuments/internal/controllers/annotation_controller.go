@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+)
+
+// AnnotationController provides endpoints for the user's highlights and notes in documents
+// @Tags Annotations
+type AnnotationController struct {
+	AnnotationService *services.AnnotationService
+}
+
+// NewAnnotationController creates a new AnnotationController
+func NewAnnotationController(annotationService *services.AnnotationService) *AnnotationController {
+	return &AnnotationController{
+		AnnotationService: annotationService,
+	}
+}
+
+// respondAnnotationError responds with the status matching the error of an annotation action and reports whether there was none
+func respondAnnotationError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrDocumentNotFound), errors.Is(err, services.ErrAnnotationNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAnnotationForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAnnotation):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// CreateAnnotation endpoint
+// @Summary Highlight text in the document
+// @Description Saves a highlight anchored by the page and the offsets of the text within the extracted page text, with an optional colour, note and the LLM response to a query about the text. Zero offsets refer to the whole page, the text is taken from the page if not given. Documents shared with the user need the annotate permission
+// @Tags Annotations
+// @ID createAnnotation
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param annotation body requests.CreateAnnotationRequest true "Request body"
+// @Success 201 {object} models.Annotation
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 403 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/annotations [post]
+func (c *AnnotationController) CreateAnnotation(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	req := new(requests.CreateAnnotationRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotation, err := c.AnnotationService.CreateAnnotation(middleware.UserId(ctx), id, req)
+	if !respondAnnotationError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, annotation)
+}
+
+// GetAnnotations endpoint
+// @Summary Gives the user's annotations of the document
+// @Description Returns the user's highlights in the document in reading order
+// @Tags Annotations
+// @ID getAnnotations
+// @Produce json
+// @Param id path uint true "Document id"
+// @Success 200 {object} responses.GetAnnotationsResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/annotations [get]
+func (c *AnnotationController) GetAnnotations(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+
+	annotations, err := c.AnnotationService.GetAnnotations(middleware.UserId(ctx), id)
+	if !respondAnnotationError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetAnnotationsResponse{Annotations: annotations})
+}
+
+// GetAnnotation endpoint
+// @Summary Gives the annotation
+// @Description Returns the user's highlight in the document
+// @Tags Annotations
+// @ID getAnnotation
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param annotationId path uint true "Annotation id"
+// @Success 200 {object} models.Annotation
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/annotations/{annotationId} [get]
+func (c *AnnotationController) GetAnnotation(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	annotationId, ok := pathId(ctx, "annotationId", "annotation")
+	if !ok {
+		return
+	}
+
+	annotation, err := c.AnnotationService.GetAnnotation(middleware.UserId(ctx), id, annotationId)
+	if !respondAnnotationError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, annotation)
+}
+
+// UpdateAnnotation endpoint
+// @Summary Edit the annotation
+// @Description Changes the colour, note or LLM response of the user's highlight. Omitted fields are left unchanged
+// @Tags Annotations
+// @ID updateAnnotation
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param annotationId path uint true "Annotation id"
+// @Param annotation body requests.UpdateAnnotationRequest true "Request body"
+// @Success 200 {object} models.Annotation
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 403 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/annotations/{annotationId} [patch]
+func (c *AnnotationController) UpdateAnnotation(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	annotationId, ok := pathId(ctx, "annotationId", "annotation")
+	if !ok {
+		return
+	}
+	req := new(requests.UpdateAnnotationRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotation, err := c.AnnotationService.UpdateAnnotation(middleware.UserId(ctx), id, annotationId, req)
+	if !respondAnnotationError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, annotation)
+}
+
+// DeleteAnnotation endpoint
+// @Summary Delete the annotation
+// @Description Deletes the user's highlight in the document
+// @Tags Annotations
+// @ID deleteAnnotation
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param annotationId path uint true "Annotation id"
+// @Success 200 {string} string "Annotation deleted"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 403 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/annotations/{annotationId} [delete]
+func (c *AnnotationController) DeleteAnnotation(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	annotationId, ok := pathId(ctx, "annotationId", "annotation")
+	if !ok {
+		return
+	}
+
+	err := c.AnnotationService.DeleteAnnotation(middleware.UserId(ctx), id, annotationId)
+	if !respondAnnotationError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Annotation deleted"})
+}
+
+// ExportAnnotations endpoint
+// @Summary Export notes on the document
+// @Description Returns the user's annotations of the document as a Markdown or JSON file grouped by chapter, or by page for pages without a chapter
+// @Tags Annotations
+// @ID exportAnnotations
+// @Produce json
+// @Produce text/markdown
+// @Param id path uint true "Document id"
+// @Param format query string false "File format, markdown by default" Enums(markdown, json)
+// @Success 200 {object} models.AnnotationExport
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/annotations/export [get]
+func (c *AnnotationController) ExportAnnotations(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	format := ctx.DefaultQuery("format", "markdown")
+	if format != "markdown" && format != "json" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "format must be markdown or json"})
+		return
+	}
+
+	export, err := c.AnnotationService.ExportAnnotations(middleware.UserId(ctx), id)
+	if !respondAnnotationError(ctx, err) {
+		return
+	}
+
+	if format == "json" {
+		ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Title + " notes.json"}))
+		ctx.JSON(http.StatusOK, export)
+		return
+	}
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Title + " notes.md"}))
+	ctx.Data(http.StatusOK, "text/markdown; charset=utf-8", services.AnnotationsMarkdown(export))
+}
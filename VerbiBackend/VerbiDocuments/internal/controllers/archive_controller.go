@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+)
+
+// maxArchiveSize is the largest accepted library archive in bytes, smaller storage quotas lower the limit
+const maxArchiveSize = 16 << 30
+
+// ArchiveController provides endpoints exporting the user's library as a portable archive and importing such archives
+// @Tags Library
+type ArchiveController struct {
+	ArchiveService *services.ArchiveService
+}
+
+// NewArchiveController creates a new ArchiveController
+func NewArchiveController(archiveService *services.ArchiveService) *ArchiveController {
+	return &ArchiveController{
+		ArchiveService: archiveService,
+	}
+}
+
+// ExportLibrary endpoint
+// @Summary Export the library as an archive
+// @Description Streams a ZIP archive with the files of all the user's documents outside the trash under files/{id}/ and manifest.json describing their metadata, tags, collections, reading progress, annotations and bookmarks
+// @Tags Library
+// @ID exportLibrary
+// @Produce application/zip
+// @Success 200 {file} file "Library archive"
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 500 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/export [get]
+func (c *ArchiveController) ExportLibrary(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
+	manifest, err := c.ArchiveService.PrepareExport(userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := fmt.Sprintf("library-%s.zip", manifest.ExportedAt.Format(time.DateOnly))
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	ctx.Status(http.StatusOK)
+	err = c.ArchiveService.WriteArchive(userId, manifest, ctx.Writer)
+	if err != nil {
+		// the archive is cut short without its central directory, so clients see it as broken
+		_ = ctx.Error(err)
+	}
+}
+
+// ImportLibrary endpoint
+// @Summary Import a library archive
+// @Description Recreates the documents of a library archive made by the export under the user's account with their metadata, tags, collections, reading progress, annotations and bookmarks. Collections matching existing ones by name and parent are merged. Documents whose file is already in the library, is missing from the archive, does not fit into the storage quota or is rejected are not imported and are reported as conflicts. Archives larger than the remaining storage quota plus 8 MiB for the manifest are refused
+// @Tags Library
+// @ID importLibrary
+// @Accept application/zip
+// @Produce json
+// @Param archive body string true "Library archive"
+// @Success 200 {object} models.ImportReport
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 413 {object} responses.ErrorResponse
+// @Failure 500 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/import [post]
+func (c *ArchiveController) ImportLibrary(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
+	limit, err := c.ArchiveService.AllowedArchiveSize(userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if limit < 0 || limit > maxArchiveSize {
+		limit = maxArchiveSize
+	}
+	if ctx.Request.ContentLength > limit {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "archive is too large"})
+		return
+	}
+
+	// ZIP archives are read from their end, so the upload is buffered in a temporary file
+	file, err := os.CreateTemp("", "library-*.zip")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, err := io.Copy(file, http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "archive is too large"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "failed to read archive"})
+		return
+	}
+
+	report, err := c.ArchiveService.ImportLibrary(userId, file, size)
+	if errors.Is(err, services.ErrInvalidArchive) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
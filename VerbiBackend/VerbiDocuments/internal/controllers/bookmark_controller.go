@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// BookmarkController provides endpoints for the user's bookmarks in documents
+// @Tags Bookmarks
+type BookmarkController struct {
+	BookmarkService *services.BookmarkService
+}
+
+// NewBookmarkController creates a new BookmarkController
+func NewBookmarkController(bookmarkService *services.BookmarkService) *BookmarkController {
+	return &BookmarkController{
+		BookmarkService: bookmarkService,
+	}
+}
+
+// respondBookmarkError responds with the status matching the error of a bookmark action and reports whether there was none
+func respondBookmarkError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrDocumentNotFound), errors.Is(err, services.ErrBookmarkNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidBookmark):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// CreateBookmark endpoint
+// @Summary Bookmark a location in the document
+// @Description Saves a named bookmark at the location, which has the format of the reading state location: a page number for PDF and an EPUB CFI for EPUB documents
+// @Tags Bookmarks
+// @ID createBookmark
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param bookmark body requests.CreateBookmarkRequest true "Request body"
+// @Success 201 {object} models.Bookmark
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/bookmarks [post]
+func (c *BookmarkController) CreateBookmark(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	req := new(requests.CreateBookmarkRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookmark, err := c.BookmarkService.CreateBookmark(middleware.UserId(ctx), id, req)
+	if !respondBookmarkError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, bookmark)
+}
+
+// GetBookmarks endpoint
+// @Summary Gives the user's bookmarks in the document
+// @Description Returns the user's bookmarks in the document ordered by creation
+// @Tags Bookmarks
+// @ID getBookmarks
+// @Produce json
+// @Param id path uint true "Document id"
+// @Success 200 {object} responses.GetBookmarksResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/bookmarks [get]
+func (c *BookmarkController) GetBookmarks(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+
+	bookmarks, err := c.BookmarkService.GetBookmarks(middleware.UserId(ctx), id)
+	if !respondBookmarkError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetBookmarksResponse{Bookmarks: bookmarks})
+}
+
+// UpdateBookmark endpoint
+// @Summary Edit the bookmark
+// @Description Changes the location or label of the user's bookmark. Omitted fields are left unchanged
+// @Tags Bookmarks
+// @ID updateBookmark
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param bookmarkId path uint true "Bookmark id"
+// @Param bookmark body requests.UpdateBookmarkRequest true "Request body"
+// @Success 200 {object} models.Bookmark
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/bookmarks/{bookmarkId} [patch]
+func (c *BookmarkController) UpdateBookmark(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	bookmarkId, ok := pathId(ctx, "bookmarkId", "bookmark")
+	if !ok {
+		return
+	}
+	req := new(requests.UpdateBookmarkRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookmark, err := c.BookmarkService.UpdateBookmark(middleware.UserId(ctx), id, bookmarkId, req)
+	if !respondBookmarkError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, bookmark)
+}
+
+// DeleteBookmark endpoint
+// @Summary Delete the bookmark
+// @Description Deletes the user's bookmark in the document
+// @Tags Bookmarks
+// @ID deleteBookmark
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param bookmarkId path uint true "Bookmark id"
+// @Success 200 {string} string "Bookmark deleted"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/bookmarks/{bookmarkId} [delete]
+func (c *BookmarkController) DeleteBookmark(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	bookmarkId, ok := pathId(ctx, "bookmarkId", "bookmark")
+	if !ok {
+		return
+	}
+
+	err := c.BookmarkService.DeleteBookmark(middleware.UserId(ctx), id, bookmarkId)
+	if !respondBookmarkError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Bookmark deleted"})
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// CollectionController provides endpoints for organizing the user's documents into nested collections
+// @Tags Collections
+type CollectionController struct {
+	CollectionService *services.CollectionService
+}
+
+// NewCollectionController creates a new CollectionController
+func NewCollectionController(collectionService *services.CollectionService) *CollectionController {
+	return &CollectionController{
+		CollectionService: collectionService,
+	}
+}
+
+// pathId parses an id path parameter and responds with an error if it is invalid
+func pathId(ctx *gin.Context, name, what string) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param(name), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + what + " id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondLibraryError responds with the status matching the error of a collection or tag action and reports whether there was none
+func respondLibraryError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrCollectionNotFound), errors.Is(err, services.ErrTagNotFound),
+		errors.Is(err, services.ErrDocumentNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCollection), errors.Is(err, services.ErrInvalidTag):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// GetCollections endpoint
+// @Summary Gives all user's collections
+// @Description Returns all collections of the user as a flat list, nesting is described by parent ids
+// @Tags Collections
+// @ID getCollections
+// @Produce json
+// @Success 200 {object} responses.GetCollectionsResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /collections [get]
+func (c *CollectionController) GetCollections(ctx *gin.Context) {
+	collections, err := c.CollectionService.GetCollections(middleware.UserId(ctx))
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetCollectionsResponse{Collections: collections})
+}
+
+// CreateCollection endpoint
+// @Summary Create a collection
+// @Description Creates a collection inside the parent collection or at the top level. Names are unique among siblings
+// @Tags Collections
+// @ID createCollection
+// @Accept json
+// @Produce json
+// @Param collection body requests.CreateCollectionRequest true "Request body"
+// @Success 201 {object} models.Collection
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /collections [post]
+func (c *CollectionController) CreateCollection(ctx *gin.Context) {
+	req := new(requests.CreateCollectionRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, err := c.CollectionService.CreateCollection(middleware.UserId(ctx), req.Name, req.ParentId)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, collection)
+}
+
+// UpdateCollection endpoint
+// @Summary Rename or move a collection
+// @Description Changes the name or the parent of the collection, parent id 0 moves it to the top level. Omitted fields are left unchanged
+// @Tags Collections
+// @ID updateCollection
+// @Accept json
+// @Produce json
+// @Param id path uint true "Collection id"
+// @Param collection body requests.UpdateCollectionRequest true "Request body"
+// @Success 200 {object} models.Collection
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /collections/{id} [patch]
+func (c *CollectionController) UpdateCollection(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "collection")
+	if !ok {
+		return
+	}
+
+	req := new(requests.UpdateCollectionRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, err := c.CollectionService.UpdateCollection(middleware.UserId(ctx), id, req.Name, req.ParentId)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, collection)
+}
+
+// DeleteCollection endpoint
+// @Summary Delete a collection
+// @Description Deletes the collection with all nested collections. Their documents are kept in the library by default or moved to the trash with documents=trash
+// @Tags Collections
+// @ID deleteCollection
+// @Produce json
+// @Param id path uint true "Collection id"
+// @Param documents query string false "What to do with documents of the collection, keep by default" Enums(keep, trash)
+// @Success 200 {string} string "Collection deleted successfully"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /collections/{id} [delete]
+func (c *CollectionController) DeleteCollection(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "collection")
+	if !ok {
+		return
+	}
+
+	documents := ctx.DefaultQuery("documents", services.KeepDocuments)
+	err := c.CollectionService.DeleteCollection(middleware.UserId(ctx), id, documents)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Collection successfully deleted"})
+}
+
+// AddDocument endpoint
+// @Summary Put a document into a collection
+// @Description Adds the document to the collection. A document may belong to several collections
+// @Tags Collections
+// @ID addDocumentToCollection
+// @Produce json
+// @Param id path uint true "Collection id"
+// @Param documentId path uint true "Document id"
+// @Success 200 {string} string "Document added successfully"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /collections/{id}/documents/{documentId} [put]
+func (c *CollectionController) AddDocument(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "collection")
+	if !ok {
+		return
+	}
+	documentId, ok := pathId(ctx, "documentId", "document")
+	if !ok {
+		return
+	}
+
+	err := c.CollectionService.AddDocument(middleware.UserId(ctx), id, documentId)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Document successfully added to collection"})
+}
+
+// RemoveDocument endpoint
+// @Summary Take a document out of a collection
+// @Description Removes the document from the collection, the document stays in the library
+// @Tags Collections
+// @ID removeDocumentFromCollection
+// @Produce json
+// @Param id path uint true "Collection id"
+// @Param documentId path uint true "Document id"
+// @Success 200 {string} string "Document removed successfully"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /collections/{id}/documents/{documentId} [delete]
+func (c *CollectionController) RemoveDocument(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "collection")
+	if !ok {
+		return
+	}
+	documentId, ok := pathId(ctx, "documentId", "document")
+	if !ok {
+		return
+	}
+
+	err := c.CollectionService.RemoveDocument(middleware.UserId(ctx), id, documentId)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Document successfully removed from collection"})
+}
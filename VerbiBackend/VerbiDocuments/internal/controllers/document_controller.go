@@ -1,38 +1,134 @@
 package controllers
 
 import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models"
 	"VerbiDocuments/internal/models/requests"
 	"VerbiDocuments/internal/models/responses"
 	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/thumbnails"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
-// DocumentController provides endpoints and handles HTTP requests related to actions with documents
+// DocumentController provides endpoints and handles HTTP requests related to actions with documents.
+// The user is always the one authenticated by the access token
 // @Tags Documents
 type DocumentController struct {
 	DocumentService *services.DocumentService
+	TextService     *services.TextService
+	CoverService    *services.CoverService
+	VersionService  *services.VersionService
 }
 
 // NewDocumentController creates a new DocumentController
-func NewDocumentController(documentService *services.DocumentService) *DocumentController {
+func NewDocumentController(
+	documentService *services.DocumentService,
+	textService *services.TextService,
+	coverService *services.CoverService,
+	versionService *services.VersionService,
+) *DocumentController {
 	return &DocumentController{
 		DocumentService: documentService,
+		TextService:     textService,
+		CoverService:    coverService,
+		VersionService:  versionService,
 	}
 }
 
+// coverMaxAge is the time in seconds clients may use a cached cover before revalidating it
+const coverMaxAge = 3600
+
+// maxIdempotencyKeyLength is the longest accepted Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// maxFileSize is the largest accepted document file in bytes
+const maxFileSize = 1 << 30
+
+// documentETag returns the entity tag of the document version
+func documentETag(document *models.Document) string {
+	return fmt.Sprintf(`"%d"`, document.Version)
+}
+
+// ifMatchVersion parses the If-Match header into the document version the request expects, zero if any version will do.
+// Responds with 412 if the header can't match any version
+func ifMatchVersion(ctx *gin.Context) (uint, bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(header, `"`), 10, 64)
+	if err != nil || version == 0 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": services.ErrVersionMismatch.Error()})
+		return 0, false
+	}
+	return uint(version), true
+}
+
+// respondDocumentError responds with the status matching the error of a document change and reports whether there was none
+func respondDocumentError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrDocumentNotFound), errors.Is(err, services.ErrVersionNotFound),
+		errors.Is(err, services.ErrTextNotExtracted):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVersionMismatch):
+		ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidUpdate):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrQuotaExceeded):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFileRejected):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// documentId parses the document id path parameter and responds with an error if it is invalid
+func documentId(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid document id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// versionNumber parses the version number path parameter and responds with an error if it is invalid
+func versionNumber(ctx *gin.Context) (int, bool) {
+	number, err := strconv.Atoi(ctx.Param("number"))
+	if err != nil || number < 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid version number"})
+		return 0, false
+	}
+	return number, true
+}
+
 // CreateDocument endpoint
 // @Summary Create a new document in library
-// @Description Saves document metadata and returns authorization credentials for sftp server
+// @Description Saves document metadata and returns authorization credentials for sftp server. Retries with the same Idempotency-Key within a day return the document created by the first request
 // @Tags Documents
 // @ID createDocument
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Unique key of the creation request"
 // @Param metadata body requests.CreateDocumentRequest true "Request body"
 // @Success 201 {object} responses.CredentialsResponse
 // @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 413 {object} responses.ErrorResponse
+// @Failure 422 {object} responses.ErrorResponse
+// @Security BearerAuth
 // @Router /documents [post]
 func (c *DocumentController) CreateDocument(ctx *gin.Context) {
 	req := new(requests.CreateDocumentRequest)
@@ -41,7 +137,25 @@ func (c *DocumentController) CreateDocument(ctx *gin.Context) {
 		return
 	}
 
-	response, err := c.DocumentService.CreateDocument(req.UserId, req.Title)
+	idempotencyKey := ctx.GetHeader("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+		return
+	}
+
+	response, err := c.DocumentService.CreateDocument(middleware.UserId(ctx), req.Title, idempotencyKey)
+	if errors.Is(err, services.ErrIdempotencyKeyReused) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrDocumentNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "document created with this idempotency key was deleted"})
+		return
+	}
+	if errors.Is(err, services.ErrQuotaExceeded) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -51,68 +165,605 @@ func (c *DocumentController) CreateDocument(ctx *gin.Context) {
 }
 
 // DeleteDocument endpoint
-// @Summary Delete the document from the user's library
-// @Description Deletes the document from the database and the sftp server
+// @Summary Move the document to the trash
+// @Description Hides the document from the library and the sftp server until it is restored. Documents stay in the trash for a configured period, 30 days by default, and are then deleted permanently
 // @Tags Documents
 // @ID deleteDocument
 // @Accept json
 // @Produce json
-// @Param userId path uint true "User id"
-// @Param documentId query string true "Document id"
-// @Success 200 {string} string "Document deleted successfully"
+// @Param id path uint true "Document id"
+// @Success 200 {string} string "Document moved to the trash"
 // @Failure 400 {object} responses.ErrorResponse
-// @Router /documents/{userId} [delete]
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id} [delete]
 func (c *DocumentController) DeleteDocument(ctx *gin.Context) {
-	userId := ctx.Param("userId")
-	documentId := ctx.Query("documentId")
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
 
-	userIdUint, err := strconv.ParseUint(userId, 10, 64)
+	err := c.DocumentService.DeleteDocument(middleware.UserId(ctx), id)
+	if errors.Is(err, services.ErrDocumentNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Document moved to the trash"})
+}
+
+// RestoreDocument endpoint
+// @Summary Restore the document from the trash
+// @Description Returns the document from the trash back to the library
+// @Tags Documents
+// @ID restoreDocument
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Success 200 {object} models.Document
+// @Header 200 {string} ETag "Document version"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/restore [post]
+func (c *DocumentController) RestoreDocument(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+
+	document, err := c.DocumentService.RestoreDocument(middleware.UserId(ctx), id)
+	if !respondDocumentError(ctx, err) {
 		return
 	}
 
-	documentIdUint, err := strconv.ParseUint(documentId, 10, 64)
+	ctx.Header("ETag", documentETag(document))
+	ctx.JSON(http.StatusOK, document)
+}
+
+// GetTrash endpoint
+// @Summary Gives documents in the trash
+// @Description Returns the user's documents in the trash, recently deleted first
+// @Tags Documents
+// @ID getTrash
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.GetTrashResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/trash [get]
+func (c *DocumentController) GetTrash(ctx *gin.Context) {
+	documents, err := c.DocumentService.GetTrash(middleware.UserId(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid document id"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = c.DocumentService.DeleteDocument(uint(userIdUint), uint(documentIdUint))
+	ctx.JSON(http.StatusOK, responses.GetTrashResponse{Documents: documents})
+}
+
+// EmptyTrash endpoint
+// @Summary Empty the trash
+// @Description Permanently deletes all documents in the trash together with their files and versions
+// @Tags Documents
+// @ID emptyTrash
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.EmptyTrashResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/trash [delete]
+func (c *DocumentController) EmptyTrash(ctx *gin.Context) {
+	purged, err := c.DocumentService.EmptyTrash(middleware.UserId(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Document successfully deleted"})
+	ctx.JSON(http.StatusOK, responses.EmptyTrashResponse{Deleted: purged})
 }
 
 // GetDocuments endpoint
-// @Summary Gives all user's documents' metadata
-// @Description Returns metadata of all documents in the user's library
+// @Summary Gives a page of user's documents' metadata
+// @Description Returns documents in the user's library together with metadata extracted from their files, optionally only ones in a collection, with a tag, of a format or with a reading status.
+// @Description Each document carries the user's reading state once it was opened, opened sorts by its last_opened_at with never opened documents last in descending order.
+// @Description Pass next_cursor of the response as cursor with the same sort and order to get the next page, it is omitted on the last page
 // @Tags Documents
 // @ID getDocuments
 // @Accept json
 // @Produce json
-// @Param userId path uint true "User id"
-// @Success 200 {object} responses.GetDocumentsResponse "List of user's documents"
+// @Param sort query string false "Sort field, created by default" Enums(title, created, size, opened)
+// @Param order query string false "Sort order, asc for title and desc for others by default" Enums(asc, desc)
+// @Param limit query int false "Page size, 50 by default, at most 200"
+// @Param cursor query string false "Position to continue listing from"
+// @Param format query string false "Only documents of the format" Enums(pdf, epub)
+// @Param collection_id query uint false "Only documents directly in the collection"
+// @Param tag_id query uint false "Only documents with the tag"
+// @Param status query string false "Only documents with the reading status, never opened documents are to-read" Enums(to-read, reading, finished)
+// @Success 200 {object} responses.GetDocumentsResponse "Page of user's documents"
 // @Failure 400 {object} responses.ErrorResponse
-// @Router /documents/{userId} [get]
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents [get]
 func (c *DocumentController) GetDocuments(ctx *gin.Context) {
-	userId := ctx.Param("userId")
-	userIdUint, err := strconv.ParseUint(userId, 10, 64)
+	query := new(models.DocumentListQuery)
+	if err := ctx.ShouldBindQuery(query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	documents, total, nextCursor, err := c.DocumentService.ListDocuments(middleware.UserId(ctx), query)
+	if errors.Is(err, services.ErrInvalidListQuery) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	documents, err := c.DocumentService.GetDocuments(uint(userIdUint))
+	ctx.JSON(http.StatusOK, responses.GetDocumentsResponse{Documents: documents, Total: total, NextCursor: nextCursor})
+}
+
+// GetDocument endpoint
+// @Summary Gives the document metadata
+// @Description Returns the user's or a shared document together with metadata extracted from its file. The ETag header holds the document version for If-Match of later changes
+// @Tags Documents
+// @ID getDocument
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Success 200 {object} models.Document
+// @Header 200 {string} ETag "Document version"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id} [get]
+func (c *DocumentController) GetDocument(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+
+	document, err := c.DocumentService.GetReadableDocument(middleware.UserId(ctx), id)
+	if errors.Is(err, services.ErrDocumentNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, responses.GetDocumentsResponse{Documents: documents})
+	ctx.Header("ETag", documentETag(document))
+	ctx.JSON(http.StatusOK, document)
+}
+
+// UpdateDocument endpoint
+// @Summary Edit the document
+// @Description Changes title, author, original title, language, publication date, tags or notes of the document. Omitted fields are left unchanged, tags replace all current tags. With If-Match the document is only changed if its ETag still matches
+// @Tags Documents
+// @ID updateDocument
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param If-Match header string false "ETag of the document version the changes are based on"
+// @Param document body requests.UpdateDocumentRequest true "Request body"
+// @Success 200 {object} models.Document
+// @Header 200 {string} ETag "Document version"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 412 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id} [patch]
+func (c *DocumentController) UpdateDocument(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	req := new(requests.UpdateDocumentRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	document, err := c.DocumentService.UpdateDocument(middleware.UserId(ctx), id, version, req)
+	if !respondDocumentError(ctx, err) {
+		return
+	}
+
+	ctx.Header("ETag", documentETag(document))
+	ctx.JSON(http.StatusOK, document)
+}
+
+// ReplaceFile endpoint
+// @Summary Replace the document file
+// @Description Uploads the request body as the new content file of the document keeping its id, then extracts its metadata, text and covers. The previous file is served until the new one is completely stored. With If-Match the file is only replaced if the document ETag still matches. Files of a disallowed format, encrypted, malformed, too large or infected are rejected and the previous file is kept
+// @Tags Documents
+// @ID replaceFile
+// @Accept octet-stream
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param name query string false "File name, the current one by default"
+// @Param If-Match header string false "ETag of the document version the replacement is based on"
+// @Param file body string true "File content"
+// @Success 200 {object} models.Document
+// @Header 200 {string} ETag "Document version"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 412 {object} responses.ErrorResponse
+// @Failure 413 {object} responses.ErrorResponse
+// @Failure 422 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/file [put]
+func (c *DocumentController) ReplaceFile(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	name := ctx.Query("name")
+	if name == "" {
+		current, err := c.DocumentService.GetDocument(middleware.UserId(ctx), id)
+		if !respondDocumentError(ctx, err) {
+			return
+		}
+		name = current.FileName
+	}
+	if ctx.Request.ContentLength > maxFileSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+		return
+	}
+
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxFileSize)
+	document, err := c.DocumentService.ReplaceFile(middleware.UserId(ctx), id, version, name, body, ctx.Request.ContentLength)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+		return
+	}
+	if !respondDocumentError(ctx, err) {
+		return
+	}
+
+	ctx.Header("ETag", documentETag(document))
+	ctx.JSON(http.StatusOK, document)
+}
+
+// GetVersions endpoint
+// @Summary Gives the version history of the document
+// @Description Returns all kept versions of the document file with their upload time, size, checksum and uploader, newest first. The version matching the current file is marked as current
+// @Tags Documents
+// @ID getVersions
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Success 200 {object} responses.GetVersionsResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/versions [get]
+func (c *DocumentController) GetVersions(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+
+	versions, err := c.VersionService.GetVersions(middleware.UserId(ctx), id)
+	if !respondDocumentError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetVersionsResponse{DocumentId: id, Versions: versions})
+}
+
+// DownloadVersion endpoint
+// @Summary Downloads a version of the document file
+// @Description Returns the file content of the document version with the given number
+// @Tags Documents
+// @ID downloadVersion
+// @Produce octet-stream
+// @Param id path uint true "Document id"
+// @Param number path int true "Version number"
+// @Success 200 {file} file "Version content"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/versions/{number} [get]
+func (c *DocumentController) DownloadVersion(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+	number, ok := versionNumber(ctx)
+	if !ok {
+		return
+	}
+
+	content, version, err := c.VersionService.OpenVersion(middleware.UserId(ctx), id, number)
+	if !respondDocumentError(ctx, err) {
+		return
+	}
+	defer content.Close()
+
+	ctx.Header("Content-Type", version.MimeType)
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": version.FileName}))
+	ctx.Header("ETag", fmt.Sprintf(`"%s"`, version.Sha256))
+	http.ServeContent(ctx.Writer, ctx.Request, version.FileName, version.CreatedAt, content)
+}
+
+// RestoreVersion endpoint
+// @Summary Restores a version of the document file
+// @Description Makes the content of the version with the given number the current document file and processes it again. The restored content becomes the newest version. With If-Match the document is only restored if its ETag still matches
+// @Tags Documents
+// @ID restoreVersion
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param number path int true "Version number"
+// @Param If-Match header string false "ETag of the document version the restore is based on"
+// @Success 200 {object} models.Document
+// @Header 200 {string} ETag "Document version"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 412 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/versions/{number}/restore [post]
+func (c *DocumentController) RestoreVersion(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+	number, ok := versionNumber(ctx)
+	if !ok {
+		return
+	}
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	document, err := c.DocumentService.RestoreVersion(middleware.UserId(ctx), id, version, number)
+	if !respondDocumentError(ctx, err) {
+		return
+	}
+
+	ctx.Header("ETag", documentETag(document))
+	ctx.JSON(http.StatusOK, document)
+}
+
+// GetUsage endpoint
+// @Summary Gives the user's storage usage and quota
+// @Description Returns the total size of the user's document files and the number of documents, the trash included, together with the limits of the user. Zero means no limit
+// @Tags Documents
+// @ID getUsage
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.GetUsageResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/usage [get]
+func (c *DocumentController) GetUsage(ctx *gin.Context) {
+	usage, quota, err := c.DocumentService.QuotaService.GetUsage(middleware.UserId(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetUsageResponse{
+		Bytes:        usage.Bytes,
+		MaxBytes:     quota.MaxBytes,
+		Documents:    usage.Documents,
+		MaxDocuments: quota.MaxDocuments,
+	})
+}
+
+// GetRetentionPolicy endpoint
+// @Summary Gives the user's retention policy of document versions
+// @Description Returns how many old versions of each document are kept and for how many days. Zero means no limit
+// @Tags Documents
+// @ID getRetentionPolicy
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.RetentionPolicy
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/retention [get]
+func (c *DocumentController) GetRetentionPolicy(ctx *gin.Context) {
+	policy, err := c.VersionService.GetRetentionPolicy(middleware.UserId(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, policy)
+}
+
+// UpdateRetentionPolicy endpoint
+// @Summary Changes the user's retention policy of document versions
+// @Description Keeps at most keep_versions versions of each document and only versions younger than keep_days days. Zero disables a limit, the current version is always kept. Versions exceeding the new policy are deleted immediately
+// @Tags Documents
+// @ID updateRetentionPolicy
+// @Accept json
+// @Produce json
+// @Param policy body requests.UpdateRetentionRequest true "Request body"
+// @Success 200 {object} models.RetentionPolicy
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/retention [put]
+func (c *DocumentController) UpdateRetentionPolicy(ctx *gin.Context) {
+	req := new(requests.UpdateRetentionRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := &models.RetentionPolicy{
+		UserId:       middleware.UserId(ctx),
+		KeepVersions: req.KeepVersions,
+		KeepDays:     req.KeepDays,
+	}
+	err := c.VersionService.SetRetentionPolicy(policy)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, policy)
+}
+
+// GetText endpoint
+// @Summary Gives the extracted text of document pages
+// @Description Returns normalized plain text of PDF pages or EPUB chapters from the given range together with their offsets in the whole document text. At most 100 pages are returned at once. Responds 404 until the text of the document is extracted
+// @Tags Documents
+// @ID getText
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param from query int false "First page number, 1 by default"
+// @Param to query int false "Last page number, equals to from by default"
+// @Success 200 {object} responses.GetTextResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 500 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/text [get]
+func (c *DocumentController) GetText(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+
+	from, err := strconv.Atoi(ctx.DefaultQuery("from", "1"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
+		return
+	}
+	to, err := strconv.Atoi(ctx.DefaultQuery("to", strconv.Itoa(from)))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
+		return
+	}
+	if from < 1 || to < from || to-from >= services.MaxTextPages {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid page range"})
+		return
+	}
+
+	pages, count, err := c.TextService.GetText(middleware.UserId(ctx), id, from, to)
+	if !respondDocumentError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetTextResponse{
+		DocumentId: id,
+		PageCount:  count,
+		Pages:      pages,
+	})
+}
+
+// GetCover endpoint
+// @Summary Gives the cover thumbnail of the document
+// @Description Returns a JPEG thumbnail of the embedded EPUB cover, the first PDF page or a generated title card. Responds 304 when the cover matches If-None-Match or If-Modified-Since
+// @Tags Documents
+// @ID getCover
+// @Produce jpeg
+// @Param id path uint true "Document id"
+// @Param size query string false "Thumbnail size, small by default" Enums(small, large)
+// @Success 200 {file} file "Cover thumbnail"
+// @Success 304 {string} string "Cover not modified"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 500 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/cover [get]
+func (c *DocumentController) GetCover(ctx *gin.Context) {
+	id, ok := documentId(ctx)
+	if !ok {
+		return
+	}
+
+	size, err := thumbnails.ParseSize(ctx.DefaultQuery("size", string(thumbnails.SizeSmall)))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cover, info, err := c.CoverService.GetCover(middleware.UserId(ctx), id, size)
+	if !respondDocumentError(ctx, err) {
+		return
+	}
+	defer cover.Close()
+
+	ctx.Header("Content-Type", "image/jpeg")
+	ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", coverMaxAge))
+	ctx.Header("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size))
+	http.ServeContent(ctx.Writer, ctx.Request, info.Key, info.ModTime, cover)
+}
+
+// SearchDocuments endpoint
+// @Summary Full-text search across the user's library
+// @Description Returns pages of the user's documents matching the query ordered by relevance with highlighted snippets. Supports quoted phrases, "or" and "-" exclusions, Russian and English words are stemmed
+// @Tags Documents
+// @ID searchDocuments
+// @Accept json
+// @Produce json
+// @Param q query string true "Search query"
+// @Param limit query int false "Maximum number of hits, 20 by default, at most 100"
+// @Param offset query int false "Number of hits to skip"
+// @Success 200 {object} responses.SearchResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 500 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/search [get]
+func (c *DocumentController) SearchDocuments(ctx *gin.Context) {
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+
+	query := ctx.Query("q")
+	hits, err := c.TextService.Search(middleware.UserId(ctx), query, limit, offset)
+	if errors.Is(err, services.ErrInvalidSearch) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.SearchResponse{Query: query, Hits: hits})
 }
 
 // GetCredentials endpoint
@@ -122,21 +773,13 @@ func (c *DocumentController) GetDocuments(ctx *gin.Context) {
 // @ID getCredentials
 // @Accept json
 // @Produce json
-// @Param userId query uint true "User id"
 // @Success 200 {object} responses.GetCredentialsResponse
-// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
 // @Router /documents/credentials [get]
 func (c *DocumentController) GetCredentials(ctx *gin.Context) {
-	userId := ctx.Query("userId")
-	log.Printf(userId)
-	id, err := strconv.ParseUint(userId, 10, 64)
-	if err != nil {
-		log.Printf("invalid user id %s", userId)
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
-		return
-	}
-
-	credentials, err := c.DocumentService.GetSftpCredentials(uint(id))
+	credentials, err := c.DocumentService.GetSftpCredentials(middleware.UserId(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -159,19 +802,12 @@ func (c *DocumentController) GetCredentials(ctx *gin.Context) {
 // @ID eraseLinkedByUserId
 // @Accept json
 // @Produce json
-// @Param userId query uint true "User id"
-// @Success 200 {object} responses.CredentialsResponse
-// @Failure 400 {object} responses.ErrorResponse
+// @Success 200 {string} string "User successfully erased"
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
 // @Router /documents [delete]
 func (c *DocumentController) EraseLinkedByUserId(ctx *gin.Context) {
-	userId := ctx.Query("userId")
-	userIdUint, err := strconv.ParseUint(userId, 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
-		return
-	}
-
-	err = c.DocumentService.EraseLinkedByUserId(uint(userIdUint))
+	err := c.DocumentService.EraseLinkedByUserId(middleware.UserId(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ImportController provides endpoints importing documents from URLs and OPDS catalogs
+// @Tags Import
+type ImportController struct {
+	ImportService *services.ImportService
+}
+
+// NewImportController creates a new ImportController
+func NewImportController(importService *services.ImportService) *ImportController {
+	return &ImportController{
+		ImportService: importService,
+	}
+}
+
+// respondImportError responds with the status matching the error of an import and reports whether there was none
+func respondImportError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrInvalidImport):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEntryNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrQuotaExceeded):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCatalogUnavailable):
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// ImportUrl endpoint
+// @Summary Import a document from a URL
+// @Description Creates a pending document and queues the job downloading its file from the http or https URL. The download is limited in size and time, the file is validated and processed like an upload. The document is rejected if the download fails for good
+// @Tags Import
+// @ID importUrl
+// @Accept json
+// @Produce json
+// @Param request body requests.ImportUrlRequest true "URL of the file"
+// @Success 202 {object} responses.ImportResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 413 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/import-url [post]
+func (c *ImportController) ImportUrl(ctx *gin.Context) {
+	var req requests.ImportUrlRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	document, job, err := c.ImportService.ImportUrl(middleware.UserId(ctx), &req)
+	if !respondImportError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, responses.ImportResponse{Document: document, Job: job})
+}
+
+// GetCatalog endpoint
+// @Summary Browse an OPDS catalog
+// @Description Fetches the OPDS 1.2 feed at the URL and returns its entries with absolute navigation, acquisition and image links as well as the links to the start, parent, previous and next feeds
+// @Tags Import
+// @ID getCatalog
+// @Produce json
+// @Param url query string true "URL of the OPDS feed"
+// @Success 200 {object} models.OpdsFeed
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 502 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/opds [get]
+func (c *ImportController) GetCatalog(ctx *gin.Context) {
+	feedUrl := ctx.Query("url")
+	if feedUrl == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+		return
+	}
+
+	feed, err := c.ImportService.GetCatalog(ctx.Request.Context(), feedUrl)
+	if !respondImportError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, feed)
+}
+
+// AcquireEntry endpoint
+// @Summary Import a publication of an OPDS catalog
+// @Description Looks the entry up in the OPDS feed and imports its free EPUB or PDF acquisition link like importUrl. The document gets the title, authors and language of the entry
+// @Tags Import
+// @ID acquireEntry
+// @Accept json
+// @Produce json
+// @Param request body requests.AcquireEntryRequest true "Feed and entry id"
+// @Success 202 {object} responses.ImportResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 413 {object} responses.ErrorResponse
+// @Failure 502 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/opds/acquire [post]
+func (c *ImportController) AcquireEntry(ctx *gin.Context) {
+	var req requests.AcquireEntryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	document, job, err := c.ImportService.AcquireEntry(ctx.Request.Context(), middleware.UserId(ctx), &req)
+	if !respondImportError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, responses.ImportResponse{Document: document, Job: job})
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// JobController provides endpoints for background processing jobs of the user's documents
+// @Tags Jobs
+type JobController struct {
+	JobService *services.JobService
+}
+
+// NewJobController creates a new JobController
+func NewJobController(jobService *services.JobService) *JobController {
+	return &JobController{
+		JobService: jobService,
+	}
+}
+
+// respondJobError responds with the status matching the error of a job action and reports whether there was none
+func respondJobError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrJobNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrJobFinished):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// GetJobs endpoint
+// @Summary Gives the user's processing jobs
+// @Description Returns the 100 most recent background jobs processing the user's documents, newest first
+// @Tags Jobs
+// @ID getJobs
+// @Produce json
+// @Param document_id query uint false "Only jobs of the document"
+// @Param status query string false "Only jobs with the status: queued, running, succeeded, failed or canceled"
+// @Success 200 {object} responses.GetJobsResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/jobs [get]
+func (c *JobController) GetJobs(ctx *gin.Context) {
+	query := new(models.JobListQuery)
+	if err := ctx.ShouldBindQuery(query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobs, err := c.JobService.GetJobs(middleware.UserId(ctx), query)
+	if !respondJobError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetJobsResponse{Jobs: jobs})
+}
+
+// GetJob endpoint
+// @Summary Gives the status of a processing job
+// @Description Returns the status, attempts and last error of the user's background job
+// @Tags Jobs
+// @ID getJob
+// @Produce json
+// @Param jobId path uint true "Job id"
+// @Success 200 {object} models.Job
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/jobs/{jobId} [get]
+func (c *JobController) GetJob(ctx *gin.Context) {
+	id, ok := pathId(ctx, "jobId", "job")
+	if !ok {
+		return
+	}
+
+	job, err := c.JobService.GetJob(middleware.UserId(ctx), id)
+	if !respondJobError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, job)
+}
+
+// CancelJob endpoint
+// @Summary Cancel a processing job
+// @Description Cancels the user's queued or running background job. A running job stops within a third of its lease
+// @Tags Jobs
+// @ID cancelJob
+// @Produce json
+// @Param jobId path uint true "Job id"
+// @Success 200 {object} models.Job
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 409 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/jobs/{jobId}/cancel [post]
+func (c *JobController) CancelJob(ctx *gin.Context) {
+	id, ok := pathId(ctx, "jobId", "job")
+	if !ok {
+		return
+	}
+
+	job, err := c.JobService.CancelJob(middleware.UserId(ctx), id)
+	if !respondJobError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// ReadingController provides endpoints for syncing reading progress between the user's devices
+// @Tags Reading
+type ReadingController struct {
+	ReadingService *services.ReadingService
+}
+
+// NewReadingController creates a new ReadingController
+func NewReadingController(readingService *services.ReadingService) *ReadingController {
+	return &ReadingController{
+		ReadingService: readingService,
+	}
+}
+
+// respondReadingError responds with the status matching the error of a reading state action and reports whether there was none
+func respondReadingError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrDocumentNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReadingState):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// GetReadingState endpoint
+// @Summary Gives the reading state of the document
+// @Description Returns the location, percentage, last opened time and status of the user's own or shared document. Documents the user has never opened are to-read
+// @Tags Reading
+// @ID getReadingState
+// @Produce json
+// @Param id path uint true "Document id"
+// @Success 200 {object} models.ReadingState
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/reading-state [get]
+func (c *ReadingController) GetReadingState(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+
+	state, err := c.ReadingService.GetReadingState(middleware.UserId(ctx), id)
+	if !respondReadingError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, state)
+}
+
+// UpdateReadingState endpoint
+// @Summary Save the reading state of the document
+// @Description Saves the state of the document on the device unless another device saved a state with a later updated_at, and returns the state that won. Location is a page number for PDF and an EPUB CFI for EPUB documents. The status is reading if not given, a missing last_opened_at keeps the saved one
+// @Tags Reading
+// @ID updateReadingState
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param state body requests.UpdateReadingStateRequest true "Request body"
+// @Success 200 {object} models.ReadingState
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/reading-state [put]
+func (c *ReadingController) UpdateReadingState(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	req := new(requests.UpdateReadingStateRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, err := c.ReadingService.UpdateReadingState(middleware.UserId(ctx), id, req)
+	if !respondReadingError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, state)
+}
+
+// GetReadingStates endpoint
+// @Summary Gives the reading states of the user's documents
+// @Description Returns the user's reading states in the order the server stored them. With since, only states stored after it are returned, so devices can pass the synced_at of the last state they got. The device time in updated_at only decides which change wins
+// @Tags Reading
+// @ID getReadingStates
+// @Produce json
+// @Param since query string false "RFC 3339 time"
+// @Success 200 {object} responses.GetReadingStatesResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/reading-states [get]
+func (c *ReadingController) GetReadingStates(ctx *gin.Context) {
+	var since *time.Time
+	if value := ctx.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 time"})
+			return
+		}
+		since = &parsed
+	}
+
+	states, err := c.ReadingService.GetReadingStates(middleware.UserId(ctx), since)
+	if !respondReadingError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetReadingStatesResponse{States: states})
+}
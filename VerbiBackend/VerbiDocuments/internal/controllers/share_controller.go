@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ShareController provides endpoints for sharing the user's documents with other users
+// @Tags Sharing
+type ShareController struct {
+	ShareService *services.ShareService
+}
+
+// NewShareController creates a new ShareController
+func NewShareController(shareService *services.ShareService) *ShareController {
+	return &ShareController{
+		ShareService: shareService,
+	}
+}
+
+// respondShareError responds with the status matching the error of a sharing action and reports whether there was none
+func respondShareError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrDocumentNotFound), errors.Is(err, services.ErrShareNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidShare):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTooManyLookups):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// ShareDocument endpoint
+// @Summary Share the document with another user
+// @Description Grants the user with exactly the given username or email read or read and annotate access to the document. Sharing with the same user again changes the permission. Every user can look up 20 other users per hour
+// @Tags Sharing
+// @ID shareDocument
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param share body requests.ShareDocumentRequest true "Request body"
+// @Success 201 {object} models.Share
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 429 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/shares [post]
+func (c *ShareController) ShareDocument(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	req := new(requests.ShareDocumentRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := c.ShareService.ShareDocument(
+		middleware.UserId(ctx),
+		id,
+		req.Login,
+		req.Permission,
+	)
+	if !respondShareError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, share)
+}
+
+// GetShares endpoint
+// @Summary Gives users the document is shared with
+// @Description Returns shares of the user's document with the granted permissions
+// @Tags Sharing
+// @ID getShares
+// @Produce json
+// @Param id path uint true "Document id"
+// @Success 200 {object} responses.GetSharesResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/shares [get]
+func (c *ShareController) GetShares(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+
+	shares, err := c.ShareService.GetShares(middleware.UserId(ctx), id)
+	if !respondShareError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetSharesResponse{Shares: shares})
+}
+
+// RevokeShare endpoint
+// @Summary Revoke access to the document
+// @Description Takes the access to the user's document away from the user of the share
+// @Tags Sharing
+// @ID revokeShare
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param shareId path uint true "Share id"
+// @Success 200 {string} string "Share revoked"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/shares/{shareId} [delete]
+func (c *ShareController) RevokeShare(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	shareId, ok := pathId(ctx, "shareId", "share")
+	if !ok {
+		return
+	}
+
+	err := c.ShareService.RevokeShare(middleware.UserId(ctx), id, shareId)
+	if !respondShareError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Share revoked"})
+}
+
+// GetSharedWithMe endpoint
+// @Summary Gives documents shared with the user
+// @Description Returns documents other users shared with the user together with the granted permissions, recently shared first. Their files are available over sftp at the document path
+// @Tags Sharing
+// @ID getSharedWithMe
+// @Produce json
+// @Success 200 {object} responses.GetSharesResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/shared [get]
+func (c *ShareController) GetSharedWithMe(ctx *gin.Context) {
+	shares, err := c.ShareService.GetSharedWithMe(middleware.UserId(ctx))
+	if !respondShareError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetSharesResponse{Shares: shares})
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// linkPageSeparator separates pages in the text served by text only links, like in pdftotext output
+const linkPageSeparator = "\f"
+
+// ShareLinkController provides endpoints for public links to the user's documents and serves the links
+// @Tags Share links
+type ShareLinkController struct {
+	ShareLinkService *services.ShareLinkService
+}
+
+// NewShareLinkController creates a new ShareLinkController
+func NewShareLinkController(shareLinkService *services.ShareLinkService) *ShareLinkController {
+	return &ShareLinkController{
+		ShareLinkService: shareLinkService,
+	}
+}
+
+// respondLinkError responds with the status matching the error of a share link action and reports whether there was none
+func respondLinkError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrDocumentNotFound), errors.Is(err, services.ErrLinkNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLinkExpired):
+		ctx.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLinkPassword):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidLink):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// CreateShareLink endpoint
+// @Summary Create a public link to the document
+// @Description Creates a signed link serving the document file to anyone who has it at /links/{token}. A link to a page range is text only and serves the text extracted from the pages at /links/{token}/text. The link may expire, allow a limited number of downloads and require a password
+// @Tags Share links
+// @ID createShareLink
+// @Accept json
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param link body requests.CreateShareLinkRequest true "Request body"
+// @Success 201 {object} models.ShareLink
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/links [post]
+func (c *ShareLinkController) CreateShareLink(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	req := new(requests.CreateShareLinkRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, err := c.ShareLinkService.CreateLink(middleware.UserId(ctx), id, req)
+	if !respondLinkError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, link)
+}
+
+// GetShareLinks endpoint
+// @Summary Gives public links to the document
+// @Description Returns all links to the user's document including revoked ones together with their download counts
+// @Tags Share links
+// @ID getShareLinks
+// @Produce json
+// @Param id path uint true "Document id"
+// @Success 200 {object} responses.GetShareLinksResponse
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/links [get]
+func (c *ShareLinkController) GetShareLinks(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+
+	links, err := c.ShareLinkService.GetLinks(middleware.UserId(ctx), id)
+	if !respondLinkError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetShareLinksResponse{Links: links})
+}
+
+// RevokeShareLink endpoint
+// @Summary Revoke a public link
+// @Description Disables the link to the user's document, it stays listed with its download count
+// @Tags Share links
+// @ID revokeShareLink
+// @Produce json
+// @Param id path uint true "Document id"
+// @Param linkId path uint true "Link id"
+// @Success 200 {string} string "Link revoked"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /documents/{id}/links/{linkId} [delete]
+func (c *ShareLinkController) RevokeShareLink(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "document")
+	if !ok {
+		return
+	}
+	linkId, ok := pathId(ctx, "linkId", "link")
+	if !ok {
+		return
+	}
+
+	err := c.ShareLinkService.RevokeLink(middleware.UserId(ctx), id, linkId)
+	if !respondLinkError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Link revoked"})
+}
+
+// DownloadShareLink endpoint
+// @Summary Download by a public link
+// @Description Serves the document file of a link to the whole document. Needs no access token, every successful request counts as a download. Text only links to a page range are served by /links/{token}/text
+// @Tags Share links
+// @ID downloadShareLink
+// @Produce octet-stream
+// @Param token path string true "Link token"
+// @Param Password header string false "Password of the link"
+// @Success 200 {file} file
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 410 {object} responses.ErrorResponse
+// @Router /links/{token} [get]
+func (c *ShareLinkController) DownloadShareLink(ctx *gin.Context) {
+	content, err := c.ShareLinkService.OpenLink(ctx.Param("token"), ctx.GetHeader("Password"))
+	if !respondLinkError(ctx, err) {
+		return
+	}
+	document := content.Document
+	defer content.File.Close()
+
+	size, err := content.File.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.File.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	contentType := document.Metadata.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ctx.DataFromReader(http.StatusOK, size, contentType, content.File, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": document.FileName}),
+	})
+}
+
+// DownloadShareLinkText endpoint
+// @Summary Download the text of a page range by a public link
+// @Description Serves the plain text extracted from the pages of a text only link to a page range, pages are separated by form feeds. The layout and images of the pages are not included. Needs no access token, every successful request counts as a download
+// @Tags Share links
+// @ID downloadShareLinkText
+// @Produce plain
+// @Param token path string true "Link token"
+// @Param Password header string false "Password of the link"
+// @Success 200 {string} string "Text of the pages"
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Failure 410 {object} responses.ErrorResponse
+// @Router /links/{token}/text [get]
+func (c *ShareLinkController) DownloadShareLinkText(ctx *gin.Context) {
+	content, err := c.ShareLinkService.OpenLinkText(ctx.Param("token"), ctx.GetHeader("Password"))
+	if !respondLinkError(ctx, err) {
+		return
+	}
+
+	texts := make([]string, 0, len(content.Pages))
+	for _, page := range content.Pages {
+		texts = append(texts, page.Text)
+	}
+	first, last := content.Pages[0].Number, content.Pages[len(content.Pages)-1].Number
+	name := fmt.Sprintf("%s p%d-%d.txt", content.Document.Title, first, last)
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	ctx.String(http.StatusOK, strings.Join(texts, linkPageSeparator))
+}
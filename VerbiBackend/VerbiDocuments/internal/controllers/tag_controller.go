@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/models/responses"
+	"VerbiDocuments/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// TagController provides endpoints for labeling the user's documents with tags
+// @Tags Tags
+type TagController struct {
+	TagService *services.TagService
+}
+
+// NewTagController creates a new TagController
+func NewTagController(tagService *services.TagService) *TagController {
+	return &TagController{
+		TagService: tagService,
+	}
+}
+
+// GetTags endpoint
+// @Summary Gives all user's tags
+// @Description Returns all tags of the user ordered by name
+// @Tags Tags
+// @ID getTags
+// @Produce json
+// @Success 200 {object} responses.GetTagsResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /tags [get]
+func (c *TagController) GetTags(ctx *gin.Context) {
+	tags, err := c.TagService.GetTags(middleware.UserId(ctx))
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses.GetTagsResponse{Tags: tags})
+}
+
+// CreateTag endpoint
+// @Summary Create a tag
+// @Description Creates a tag with a name unique among the user's tags
+// @Tags Tags
+// @ID createTag
+// @Accept json
+// @Produce json
+// @Param tag body requests.TagRequest true "Request body"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /tags [post]
+func (c *TagController) CreateTag(ctx *gin.Context) {
+	req := new(requests.TagRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := c.TagService.CreateTag(middleware.UserId(ctx), req.Name)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, tag)
+}
+
+// RenameTag endpoint
+// @Summary Rename a tag
+// @Description Changes the name of the tag on all its documents
+// @Tags Tags
+// @ID renameTag
+// @Accept json
+// @Produce json
+// @Param id path uint true "Tag id"
+// @Param tag body requests.TagRequest true "Request body"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /tags/{id} [patch]
+func (c *TagController) RenameTag(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "tag")
+	if !ok {
+		return
+	}
+
+	req := new(requests.TagRequest)
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := c.TagService.RenameTag(middleware.UserId(ctx), id, req.Name)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag endpoint
+// @Summary Delete a tag
+// @Description Deletes the tag and removes it from all documents
+// @Tags Tags
+// @ID deleteTag
+// @Produce json
+// @Param id path uint true "Tag id"
+// @Success 200 {string} string "Tag deleted successfully"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /tags/{id} [delete]
+func (c *TagController) DeleteTag(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "tag")
+	if !ok {
+		return
+	}
+
+	err := c.TagService.DeleteTag(middleware.UserId(ctx), id)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Tag successfully deleted"})
+}
+
+// AddDocument endpoint
+// @Summary Tag a document
+// @Description Attaches the tag to the document
+// @Tags Tags
+// @ID tagDocument
+// @Produce json
+// @Param id path uint true "Tag id"
+// @Param documentId path uint true "Document id"
+// @Success 200 {string} string "Document tagged successfully"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /tags/{id}/documents/{documentId} [put]
+func (c *TagController) AddDocument(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "tag")
+	if !ok {
+		return
+	}
+	documentId, ok := pathId(ctx, "documentId", "document")
+	if !ok {
+		return
+	}
+
+	err := c.TagService.AddDocument(middleware.UserId(ctx), id, documentId)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Document successfully tagged"})
+}
+
+// RemoveDocument endpoint
+// @Summary Untag a document
+// @Description Detaches the tag from the document
+// @Tags Tags
+// @ID untagDocument
+// @Produce json
+// @Param id path uint true "Tag id"
+// @Param documentId path uint true "Document id"
+// @Success 200 {string} string "Document untagged successfully"
+// @Failure 400 {object} responses.ErrorResponse
+// @Failure 401 {object} responses.ErrorResponse
+// @Failure 404 {object} responses.ErrorResponse
+// @Security BearerAuth
+// @Router /tags/{id}/documents/{documentId} [delete]
+func (c *TagController) RemoveDocument(ctx *gin.Context) {
+	id, ok := pathId(ctx, "id", "tag")
+	if !ok {
+		return
+	}
+	documentId, ok := pathId(ctx, "documentId", "document")
+	if !ok {
+		return
+	}
+
+	err := c.TagService.RemoveDocument(middleware.UserId(ctx), id, documentId)
+	if !respondLibraryError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Document successfully untagged"})
+}
@@ -0,0 +1,129 @@
+package extractors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoCover is returned when the document has no cover that can be extracted
+var ErrNoCover = errors.New("document has no cover")
+
+// coverRenderSize is the size of the longest side of a rendered PDF page in pixels
+const coverRenderSize = 1024
+
+// coverRenderTimeout limits rendering of a PDF page
+const coverRenderTimeout = 30 * time.Second
+
+// ExtractCover returns the embedded EPUB cover or the rendered first page of a PDF
+func ExtractCover(reader io.ReaderAt, size int64, mimeType string) (image.Image, error) {
+	switch mimeType {
+	case MimeTypePdf:
+		return renderPdfCover(reader, size)
+	case MimeTypeEpub:
+		return extractEpubCover(reader, size)
+	}
+	return nil, ErrNoCover
+}
+
+// extractEpubCover decodes the cover image declared in the package document.
+// EPUB 3 marks it with the cover-image property, EPUB 2 refers to it with the cover meta element
+func extractEpubCover(reader io.ReaderAt, size int64) (image.Image, error) {
+	book, err := openEpub(reader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	coverId := ""
+	for _, meta := range book.pkg.Metadata.Metas {
+		if meta.Name == "cover" {
+			coverId = meta.Content
+		}
+	}
+
+	href := ""
+	for _, item := range book.pkg.Items {
+		if !strings.HasPrefix(item.MediaType, "image/") {
+			continue
+		}
+		if strings.Contains(" "+item.Properties+" ", " cover-image ") {
+			href = item.Href
+			break
+		}
+		if href == "" && item.Id == coverId {
+			href = item.Href
+		}
+	}
+	if href == "" {
+		return nil, ErrNoCover
+	}
+
+	file, err := book.open(book.resolve(href))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cover, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode epub cover: %w", err)
+	}
+	return cover, nil
+}
+
+// renderPdfCover renders the first page of a PDF with pdftoppm from poppler-utils when it is installed
+func renderPdfCover(reader io.ReaderAt, size int64) (image.Image, error) {
+	renderer, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return nil, ErrNoCover
+	}
+
+	dir, err := os.MkdirTemp("", "verbi-cover-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "document.pdf")
+	file, err := os.Create(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	_, err = io.Copy(file, io.NewSectionReader(reader, 0, size))
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy pdf: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), coverRenderTimeout)
+	defer cancel()
+	target := filepath.Join(dir, "cover")
+	output, err := exec.CommandContext(
+		ctx, renderer, "-png", "-f", "1", "-l", "1", "-singlefile", "-scale-to", fmt.Sprint(coverRenderSize), source, target,
+	).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render pdf cover: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	rendered, err := os.Open(target + ".png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rendered pdf cover: %w", err)
+	}
+	defer rendered.Close()
+
+	cover, _, err := image.Decode(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rendered pdf cover: %w", err)
+	}
+	return cover, nil
+}
@@ -0,0 +1,131 @@
+package extractors
+
+import (
+	"VerbiDocuments/internal/models"
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// epubContainer is META-INF/container.xml pointing to the package document
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the OPF package document describing the book
+type epubPackage struct {
+	Metadata struct {
+		Titles    []string `xml:"title"`
+		Creators  []string `xml:"creator"`
+		Languages []string `xml:"language"`
+		Dates     []string `xml:"date"`
+		Metas     []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Items []struct {
+		Id         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	ItemRefs []struct {
+		IdRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubBook is an opened EPUB archive with its parsed package document
+type epubBook struct {
+	archive *zip.Reader
+	pkg     *epubPackage
+	baseDir string
+}
+
+// openEpub opens the archive and parses its package document
+func openEpub(reader io.ReaderAt, size int64) (*epubBook, error) {
+	archive, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open epub: %w", err)
+	}
+	book := &epubBook{archive: archive}
+
+	var container epubContainer
+	err = book.decode("META-INF/container.xml", &container)
+	if err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("malformed epub: no package document")
+	}
+
+	book.pkg = new(epubPackage)
+	err = book.decode(container.Rootfiles[0].FullPath, book.pkg)
+	if err != nil {
+		return nil, err
+	}
+	book.baseDir = path.Dir(container.Rootfiles[0].FullPath)
+	return book, nil
+}
+
+// open opens a file of the archive by its path
+func (b *epubBook) open(name string) (io.ReadCloser, error) {
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	for _, file := range b.archive.File {
+		if file.Name == name {
+			return file.Open()
+		}
+	}
+	return nil, fmt.Errorf("malformed epub: missing %s", name)
+}
+
+// decode parses an XML file of the archive
+func (b *epubBook) decode(name string, target interface{}) error {
+	file, err := b.open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = xml.NewDecoder(file).Decode(target)
+	if err != nil {
+		return fmt.Errorf("malformed epub: failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// resolve returns the archive path of a manifest item href
+func (b *epubBook) resolve(href string) string {
+	return path.Join(b.baseDir, href)
+}
+
+// first returns the first non-empty value
+func first(values []string) string {
+	for _, value := range values {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			return trimmed
+		}
+	}
+	return ""
+}
+
+// extractEpubMetadata reads Dublin Core metadata of the package document.
+// EPUB has no fixed pages, so the page count is the number of spine documents
+func extractEpubMetadata(reader io.ReaderAt, size int64, metadata *models.DocumentMetadata) error {
+	book, err := openEpub(reader, size)
+	if err != nil {
+		return err
+	}
+
+	metadata.OriginalTitle = first(book.pkg.Metadata.Titles)
+	metadata.Author = first(book.pkg.Metadata.Creators)
+	metadata.Language = first(book.pkg.Metadata.Languages)
+	metadata.PublishedAt = parseDate(first(book.pkg.Metadata.Dates))
+	metadata.PageCount = len(book.pkg.ItemRefs)
+	return nil
+}
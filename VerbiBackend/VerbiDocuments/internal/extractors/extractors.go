@@ -0,0 +1,88 @@
+package extractors
+
+import (
+	"VerbiDocuments/internal/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// MimeTypePdf is the MIME type of PDF documents
+	MimeTypePdf = "application/pdf"
+	// MimeTypeEpub is the MIME type of EPUB books
+	MimeTypeEpub = "application/epub+zip"
+)
+
+// ErrUnsupportedFormat is returned when the file is neither a PDF nor an EPUB
+var ErrUnsupportedFormat = errors.New("unsupported document format")
+
+// DetectMimeType detects the file format by its leading bytes
+func DetectMimeType(reader io.ReaderAt) string {
+	header := make([]byte, 512)
+	n, _ := reader.ReadAt(header, 0)
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("%PDF-")):
+		return MimeTypePdf
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) && len(header) >= 58 &&
+		string(header[30:38]) == "mimetype" && string(header[38:58]) == MimeTypeEpub:
+		return MimeTypeEpub
+	}
+
+	return strings.Split(http.DetectContentType(header), ";")[0]
+}
+
+// ExtractMetadata reads the document file and returns its metadata.
+// Size, MIME type and checksum are filled for any file, descriptive fields only for supported formats
+func ExtractMetadata(reader io.ReaderAt, size int64) (*models.DocumentMetadata, error) {
+	hash := sha256.New()
+	_, err := io.Copy(hash, io.NewSectionReader(reader, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+
+	metadata := &models.DocumentMetadata{
+		FileSize: size,
+		MimeType: DetectMimeType(reader),
+		Sha256:   hex.EncodeToString(hash.Sum(nil)),
+	}
+
+	switch metadata.MimeType {
+	case MimeTypePdf:
+		err = extractPdfMetadata(reader, size, metadata)
+	case MimeTypeEpub:
+		err = extractEpubMetadata(reader, size, metadata)
+	default:
+		err = ErrUnsupportedFormat
+	}
+
+	return metadata, err
+}
+
+// parseDate parses full and partial ISO 8601 dates used by EPUB metadata
+func parseDate(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return &parsed
+	}
+
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if len(value) < len(layout) {
+			continue
+		}
+		parsed, err = time.Parse(layout, value[:len(layout)])
+		if err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package extractors
+
+import (
+	"VerbiDocuments/internal/models"
+	"fmt"
+	"github.com/ledongthuc/pdf"
+	"io"
+	"strings"
+	"time"
+)
+
+// openPdf parses the PDF structure. The parser panics on some malformed files, such panics are returned as errors
+func openPdf(reader io.ReaderAt, size int64) (document *pdf.Reader, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			document, err = nil, fmt.Errorf("malformed pdf: %v", recovered)
+		}
+	}()
+
+	document, err = pdf.NewReader(reader, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pdf: %w", err)
+	}
+	return document, nil
+}
+
+// parsePdfDate parses dates in the PDF format D:YYYYMMDDHHmmSSOHH'mm
+func parsePdfDate(value string) *time.Time {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "D:")
+	layouts := []string{"20060102150405", "200601021504", "2006010215", "20060102", "200601", "2006"}
+	for _, layout := range layouts {
+		if len(value) < len(layout) {
+			continue
+		}
+		parsed, err := time.Parse(layout, value[:len(layout)])
+		if err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
+
+// extractPdfMetadata reads the document information dictionary and the page tree
+func extractPdfMetadata(reader io.ReaderAt, size int64, metadata *models.DocumentMetadata) (err error) {
+	document, err := openPdf(reader, size)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("malformed pdf: %v", recovered)
+		}
+	}()
+
+	info := document.Trailer().Key("Info")
+	metadata.OriginalTitle = strings.TrimSpace(info.Key("Title").Text())
+	metadata.Author = strings.TrimSpace(info.Key("Author").Text())
+	metadata.PublishedAt = parsePdfDate(info.Key("CreationDate").Text())
+	metadata.Language = strings.TrimSpace(document.Trailer().Key("Root").Key("Lang").Text())
+	metadata.PageCount = document.NumPage()
+	return nil
+}
@@ -0,0 +1,207 @@
+package extractors
+
+import (
+	"VerbiDocuments/internal/models"
+	"fmt"
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/net/html"
+	"golang.org/x/text/unicode/norm"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	hyphenatedBreak  = regexp.MustCompile(`(\p{L})-\n(\p{Ll})`)
+	horizontalSpaces = regexp.MustCompile(`[^\S\n]+`)
+	spacesAroundLine = regexp.MustCompile(` ?\n ?`)
+	emptyLines       = regexp.MustCompile(`\n{3,}`)
+	controlChars     = regexp.MustCompile(`[\x00-\x08\x0b\x0c\x0e-\x1f\x7f]`)
+)
+
+// pageSeparator separates pages in the whole document text that page offsets refer to
+const pageSeparator = "\n\n"
+
+// NormalizeText brings extracted text to a canonical form: NFKC, joined hyphenated words, single spaces
+// and at most one empty line in a row
+func NormalizeText(text string) string {
+	text = norm.NFKC.String(text)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = controlChars.ReplaceAllString(text, "")
+	text = horizontalSpaces.ReplaceAllString(text, " ")
+	text = spacesAroundLine.ReplaceAllString(text, "\n")
+	text = hyphenatedBreak.ReplaceAllString(text, "$1$2")
+	text = emptyLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// ExtractText extracts normalized plain text of every PDF page or EPUB spine document
+func ExtractText(reader io.ReaderAt, size int64, mimeType string) ([]*models.DocumentPage, error) {
+	var pages []*models.DocumentPage
+	var err error
+
+	switch mimeType {
+	case MimeTypePdf:
+		pages, err = extractPdfText(reader, size)
+	case MimeTypeEpub:
+		pages, err = extractEpubText(reader, size)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	offset := 0
+	for i, page := range pages {
+		page.Number = i + 1
+		page.Text = NormalizeText(page.Text)
+		page.StartOffset = offset
+		page.EndOffset = offset + utf8.RuneCountInString(page.Text)
+		offset = page.EndOffset + utf8.RuneCountInString(pageSeparator)
+	}
+	return pages, nil
+}
+
+// extractPdfText extracts the text of every page
+func extractPdfText(reader io.ReaderAt, size int64) ([]*models.DocumentPage, error) {
+	document, err := openPdf(reader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	count := document.NumPage()
+	pages := make([]*models.DocumentPage, 0, count)
+	for number := 1; number <= count; number++ {
+		text, err := pdfPageText(document.Page(number))
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text of page %d: %w", number, err)
+		}
+		pages = append(pages, &models.DocumentPage{Text: text})
+	}
+	return pages, nil
+}
+
+// pdfPageText lays out positioned glyphs of the page as lines of text
+func pdfPageText(page pdf.Page) (text string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			text, err = "", fmt.Errorf("malformed pdf: %v", recovered)
+		}
+	}()
+
+	if page.V.IsNull() {
+		return "", nil
+	}
+
+	var builder strings.Builder
+	var previous *pdf.Text
+	for _, glyph := range page.Content().Text {
+		if previous != nil {
+			lineHeight := math.Max(previous.FontSize, 1)
+			if math.Abs(glyph.Y-previous.Y) > lineHeight/2 {
+				builder.WriteString("\n")
+			} else if glyph.X-(previous.X+previous.W) > lineHeight/5 {
+				builder.WriteString(" ")
+			}
+		}
+		builder.WriteString(glyph.S)
+		current := glyph
+		previous = &current
+	}
+	return builder.String(), nil
+}
+
+// blockElements are HTML elements whose content starts on a new line
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "blockquote": true, "pre": true,
+	"section": true, "article": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// extractEpubText extracts the text of every spine document
+func extractEpubText(reader io.ReaderAt, size int64) ([]*models.DocumentPage, error) {
+	book, err := openEpub(reader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	hrefs := make(map[string]string)
+	for _, item := range book.pkg.Items {
+		hrefs[item.Id] = item.Href
+	}
+
+	pages := make([]*models.DocumentPage, 0, len(book.pkg.ItemRefs))
+	for _, itemRef := range book.pkg.ItemRefs {
+		href, ok := hrefs[itemRef.IdRef]
+		if !ok {
+			return nil, fmt.Errorf("malformed epub: spine refers to unknown item %s", itemRef.IdRef)
+		}
+
+		file, err := book.open(book.resolve(href))
+		if err != nil {
+			return nil, err
+		}
+		chapter, text, err := xhtmlText(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("malformed epub: failed to parse %s: %w", href, err)
+		}
+
+		pages = append(pages, &models.DocumentPage{Chapter: chapter, Text: text})
+	}
+	return pages, nil
+}
+
+// xhtmlText returns the chapter title and the visible text of an XHTML document
+func xhtmlText(reader io.Reader) (string, string, error) {
+	root, err := html.Parse(reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	var title, heading string
+	var builder strings.Builder
+	var walk func(node *html.Node, inHeading bool)
+	walk = func(node *html.Node, inHeading bool) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "script", "style":
+				return
+			case "title":
+				if node.FirstChild != nil {
+					title = strings.TrimSpace(node.FirstChild.Data)
+				}
+				return
+			case "h1", "h2", "h3":
+				inHeading = inHeading || heading == ""
+			}
+		}
+		if node.Type == html.TextNode {
+			builder.WriteString(node.Data)
+			if inHeading {
+				heading += node.Data
+			}
+		}
+
+		block := node.Type == html.ElementNode && blockElements[node.Data]
+		if block {
+			builder.WriteString("\n")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, inHeading)
+		}
+		if block {
+			builder.WriteString("\n")
+		}
+	}
+	walk(root, false)
+
+	chapter := strings.Join(strings.Fields(heading), " ")
+	if chapter == "" {
+		chapter = title
+	}
+	return chapter, builder.String(), nil
+}
@@ -0,0 +1,99 @@
+package extractors
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ledongthuc/pdf"
+	"io"
+	"strings"
+)
+
+// ErrEncrypted is returned for encrypted or DRM protected documents whose content cannot be read
+var ErrEncrypted = errors.New("document is encrypted or DRM protected")
+
+// ErrMalformed is returned when the structure of the document cannot be parsed
+var ErrMalformed = errors.New("malformed document")
+
+// epubFontObfuscation lists algorithms of META-INF/encryption.xml that only obfuscate embedded fonts.
+// Any other algorithm encrypts the content of the book
+var epubFontObfuscation = map[string]bool{
+	"http://www.idpf.org/2008/embedding": true,
+	"http://ns.adobe.com/pdf/enc#RC":     true,
+}
+
+// epubEncryption is META-INF/encryption.xml listing encrypted resources of the book
+type epubEncryption struct {
+	Methods []struct {
+		Algorithm string `xml:"Algorithm,attr"`
+	} `xml:"EncryptedData>EncryptionMethod"`
+}
+
+// CheckDocument parses the structure of a PDF or EPUB file and returns its number of pages, chapters for EPUB.
+// Fails with ErrEncrypted for encrypted files and ErrMalformed for files that cannot be parsed
+func CheckDocument(reader io.ReaderAt, size int64, mimeType string) (int, error) {
+	switch mimeType {
+	case MimeTypePdf:
+		return checkPdf(reader, size)
+	case MimeTypeEpub:
+		return checkEpub(reader, size)
+	}
+	return 0, ErrUnsupportedFormat
+}
+
+// checkPdf opens the PDF and counts its pages. Any encryption dictionary makes the file rejected,
+// even if it only restricts permissions. The parser reports unsupported encryption schemes only by the error message
+func checkPdf(reader io.ReaderAt, size int64) (pages int, err error) {
+	document, err := openPdf(reader, size)
+	if errors.Is(err, pdf.ErrInvalidPassword) || (err != nil && strings.Contains(err.Error(), "encryption")) {
+		return 0, ErrEncrypted
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			pages, err = 0, fmt.Errorf("%w: %v", ErrMalformed, recovered)
+		}
+	}()
+
+	if !document.Trailer().Key("Encrypt").IsNull() {
+		return 0, ErrEncrypted
+	}
+	pages = document.NumPage()
+	if pages == 0 {
+		return 0, fmt.Errorf("%w: pdf has no pages", ErrMalformed)
+	}
+	return pages, nil
+}
+
+// checkEpub opens the EPUB, looks for DRM rights and encrypted resources and counts its chapters
+func checkEpub(reader io.ReaderAt, size int64) (int, error) {
+	book, err := openEpub(reader, size)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	for _, file := range book.archive.File {
+		switch file.Name {
+		case "META-INF/rights.xml":
+			return 0, ErrEncrypted
+		case "META-INF/encryption.xml":
+			var encryption epubEncryption
+			err = book.decode(file.Name, &encryption)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %v", ErrMalformed, err)
+			}
+			for _, method := range encryption.Methods {
+				if !epubFontObfuscation[method.Algorithm] {
+					return 0, ErrEncrypted
+				}
+			}
+		}
+	}
+
+	if len(book.pkg.ItemRefs) == 0 {
+		return 0, fmt.Errorf("%w: epub has no chapters", ErrMalformed)
+	}
+	return len(book.pkg.ItemRefs), nil
+}
@@ -2,6 +2,7 @@ package factories
 
 import (
 	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/interfaces"
 	"VerbiDocuments/internal/repositories"
 	"VerbiDocuments/internal/services"
 	"gorm.io/gorm"
@@ -14,10 +15,152 @@ type ControllerFactory struct{}
 func NewControllerFactory() *ControllerFactory { return &ControllerFactory{} }
 
 // GetController function to create a new instance of DocumentsController with all necessary dependencies
-func (f *ControllerFactory) GetController(db *gorm.DB) (*controllers.DocumentController, error) {
-	documentRepository := repositories.NewDocumentRepository(db)
-	sftpRepository := repositories.NewSftpRepository(db)
-	sftpService := services.NewSftpService(sftpRepository)
-	documentService := services.NewDocumentService(documentRepository, sftpRepository, sftpService)
-	return controllers.NewDocumentController(documentService), nil
+func (f *ControllerFactory) GetController(
+	db *gorm.DB,
+	blobStore interfaces.BlobStore,
+	quotaService *services.QuotaService,
+	processingService *services.ProcessingService,
+) (*controllers.DocumentController, error) {
+	documentService := services.NewDocumentService(
+		processingService.MetadataService.DocumentRepository,
+		repositories.NewSftpRepository(db),
+		repositories.NewIdempotencyRepository(db),
+		blobStore,
+		processingService,
+		quotaService,
+	)
+	return controllers.NewDocumentController(
+		documentService,
+		processingService.TextService,
+		processingService.CoverService,
+		processingService.VersionService,
+	), nil
+}
+
+// GetCollectionController creates a new instance of CollectionController sharing the document service of the document controller
+func (f *ControllerFactory) GetCollectionController(
+	db *gorm.DB,
+	documentController *controllers.DocumentController,
+) *controllers.CollectionController {
+	documentService := documentController.DocumentService
+	collectionService := services.NewCollectionService(
+		repositories.NewCollectionRepository(db),
+		documentService.DocumentRepository,
+		documentService,
+	)
+	return controllers.NewCollectionController(collectionService)
+}
+
+// GetTagController creates a new instance of TagController sharing the document service of the document controller
+func (f *ControllerFactory) GetTagController(db *gorm.DB, documentController *controllers.DocumentController) *controllers.TagController {
+	documentService := documentController.DocumentService
+	tagService := services.NewTagService(repositories.NewTagRepository(db), documentService.DocumentRepository, documentService)
+	return controllers.NewTagController(tagService)
+}
+
+// GetShareController creates a new instance of ShareController looking users up in the user directory
+func (f *ControllerFactory) GetShareController(
+	db *gorm.DB,
+	documentController *controllers.DocumentController,
+	userDirectory interfaces.UserDirectory,
+) *controllers.ShareController {
+	shareService := services.NewShareService(
+		repositories.NewShareRepository(db),
+		documentController.DocumentService.DocumentRepository,
+		userDirectory,
+	)
+	return controllers.NewShareController(shareService)
+}
+
+// GetReadingController creates a new instance of ReadingController
+func (f *ControllerFactory) GetReadingController(
+	db *gorm.DB,
+	documentController *controllers.DocumentController,
+) *controllers.ReadingController {
+	readingService := services.NewReadingService(
+		repositories.NewReadingStateRepository(db),
+		documentController.DocumentService.DocumentRepository,
+	)
+	return controllers.NewReadingController(readingService)
+}
+
+// GetAnnotationController creates a new instance of AnnotationController
+func (f *ControllerFactory) GetAnnotationController(
+	db *gorm.DB,
+	documentController *controllers.DocumentController,
+) *controllers.AnnotationController {
+	annotationService := services.NewAnnotationService(
+		repositories.NewAnnotationRepository(db),
+		documentController.DocumentService.DocumentRepository,
+		documentController.TextService.PageRepository,
+	)
+	return controllers.NewAnnotationController(annotationService)
+}
+
+// GetBookmarkController creates a new instance of BookmarkController
+func (f *ControllerFactory) GetBookmarkController(
+	db *gorm.DB,
+	documentController *controllers.DocumentController,
+) *controllers.BookmarkController {
+	bookmarkService := services.NewBookmarkService(
+		repositories.NewBookmarkRepository(db),
+		documentController.DocumentService.DocumentRepository,
+	)
+	return controllers.NewBookmarkController(bookmarkService)
+}
+
+// GetShareLinkController creates a new instance of ShareLinkController signing link tokens with the secret
+func (f *ControllerFactory) GetShareLinkController(
+	db *gorm.DB,
+	documentController *controllers.DocumentController,
+	secret []byte,
+) *controllers.ShareLinkController {
+	documentService := documentController.DocumentService
+	shareLinkService := services.NewShareLinkService(
+		repositories.NewShareLinkRepository(db),
+		documentService.DocumentRepository,
+		documentController.TextService.PageRepository,
+		documentService.BlobStore,
+		secret,
+	)
+	return controllers.NewShareLinkController(shareLinkService)
+}
+
+// GetArchiveController creates a new instance of ArchiveController sharing the document service of the document controller
+func (f *ControllerFactory) GetArchiveController(
+	db *gorm.DB,
+	documentController *controllers.DocumentController,
+) *controllers.ArchiveController {
+	archiveService := services.NewArchiveService(
+		documentController.DocumentService,
+		repositories.NewCollectionRepository(db),
+		repositories.NewTagRepository(db),
+		repositories.NewReadingStateRepository(db),
+		repositories.NewAnnotationRepository(db),
+		repositories.NewBookmarkRepository(db),
+	)
+	return controllers.NewArchiveController(archiveService)
+}
+
+// GetImportController creates a new instance of ImportController, which registers its jobs in the job service
+func (f *ControllerFactory) GetImportController(
+	documentController *controllers.DocumentController,
+	jobService *services.JobService,
+	downloader interfaces.Downloader,
+) *controllers.ImportController {
+	importService := services.NewImportService(documentController.DocumentService, jobService, downloader)
+	return controllers.NewImportController(importService)
+}
+
+// GetJobController creates a new instance of JobController
+func (f *ControllerFactory) GetJobController(jobService *services.JobService) *controllers.JobController {
+	return controllers.NewJobController(jobService)
+}
+
+// GetAdminController creates a new instance of AdminController
+func (f *ControllerFactory) GetAdminController(
+	reconcilerService *services.ReconcilerService,
+	quotaService *services.QuotaService,
+) *controllers.AdminController {
+	return controllers.NewAdminController(reconcilerService, quotaService)
 }
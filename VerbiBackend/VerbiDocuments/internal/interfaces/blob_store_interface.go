@@ -0,0 +1,24 @@
+package interfaces
+
+import (
+	"VerbiDocuments/internal/models"
+	"io"
+)
+
+// BlobReader is a readable handle to a stored object that supports random access
+type BlobReader interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// BlobStore defines the methods to work with document storage.
+// Keys are slash separated paths relative to the storage root, e.g. "1/42/book.pdf"
+type BlobStore interface {
+	Put(key string, reader io.Reader, size int64) error
+	Get(key string) (BlobReader, error)
+	Stat(key string) (*models.BlobInfo, error)
+	Delete(key string) error
+	List(prefix string) ([]*models.BlobInfo, error)
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"VerbiDocuments/internal/models"
+	"context"
+	"io"
+)
+
+// Downloader fetches files from remote servers on behalf of users, e.g. over HTTP
+type Downloader interface {
+	// Download requests the file at the URL and returns its description and content, which the caller must close.
+	// Reading the content fails once it exceeds the size limit of the downloader
+	Download(ctx context.Context, url string) (*models.RemoteFile, io.ReadCloser, error)
+}
@@ -0,0 +1,9 @@
+package interfaces
+
+import "io"
+
+// Scanner checks uploaded files for malware, e.g. with ClamAV
+type Scanner interface {
+	// Scan returns the name of the threat found in the content, empty if the content is clean
+	Scan(reader io.Reader) (string, error)
+}
@@ -0,0 +1,8 @@
+package interfaces
+
+import "VerbiDocuments/internal/models"
+
+// UserDirectory finds Verbi users by exact username or email
+type UserDirectory interface {
+	FindUser(login string) (*models.UserInfo, error)
+}
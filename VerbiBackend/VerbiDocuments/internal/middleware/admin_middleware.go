@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"os"
+)
+
+// AdminMiddleware lets through requests carrying the ADMIN_TOKEN in the X-Admin-Token header.
+// Admin endpoints are disabled when the token is not configured
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminToken := os.Getenv("ADMIN_TOKEN")
+		if adminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled"})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(adminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+
+		c.Next()
+	}
+}
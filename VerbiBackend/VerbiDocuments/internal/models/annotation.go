@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// DefaultAnnotationColor is the colour of highlights created without one
+const DefaultAnnotationColor = "#FFEB3B"
+
+// Annotation is the user's highlight of text in a document with an optional note and the LLM response to a query
+// about the highlighted text. It is anchored by the page number and the offsets in characters of the highlighted text
+// within the extracted page text, zero offsets refer to the whole page. Annotations are private to their author
+type Annotation struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	DocumentId  uint      `gorm:"not null;index:idx_document_annotations" json:"document_id"`
+	Document    *Document `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserId      uint      `gorm:"not null;index:idx_document_annotations" json:"user_id"`
+	Page        int       `gorm:"not null" json:"page"`
+	StartOffset int       `gorm:"not null" json:"start_offset"`
+	EndOffset   int       `gorm:"not null" json:"end_offset"`
+	Text        string    `gorm:"type:text;not null;default:''" json:"text"`
+	Color       string    `gorm:"not null" json:"color"`
+	Note        string    `gorm:"type:text;not null;default:''" json:"note"`
+	LlmResponse string    `gorm:"type:text;not null;default:''" json:"llm_response"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AnnotationExport is the notes of the user on a document grouped by chapter or, without chapters, by page
+type AnnotationExport struct {
+	DocumentId uint                 `json:"document_id"`
+	Title      string               `json:"title"`
+	Author     string               `json:"author,omitempty"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Sections   []*AnnotationSection `json:"sections"`
+}
+
+// AnnotationSection is a run of annotations in one chapter, or on one page if the page has no chapter
+type AnnotationSection struct {
+	Chapter     string        `json:"chapter,omitempty"`
+	Page        int           `json:"page"`
+	Annotations []*Annotation `json:"annotations"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// BlobInfo describes an object kept in the blob store
+type BlobInfo struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Bookmark is the user's named position in a document. Location has the format of ReadingState.Location:
+// a page number for PDF documents and an EPUB CFI for EPUB documents
+type Bookmark struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	DocumentId uint      `gorm:"not null;index:idx_document_bookmarks" json:"document_id"`
+	Document   *Document `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserId     uint      `gorm:"not null;index:idx_document_bookmarks" json:"user_id"`
+	Location   string    `gorm:"not null" json:"location"`
+	Label      string    `gorm:"not null;default:''" json:"label"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// Collection is a user-defined folder of documents. Collections nest through the parent collection,
+// a document may belong to any number of them
+type Collection struct {
+	ID       uint        `gorm:"primaryKey" json:"id"`
+	UserId   uint        `gorm:"not null;index" json:"-"`
+	ParentId *uint       `gorm:"index" json:"parent_id"`
+	Parent   *Collection `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Name     string      `gorm:"not null" json:"name"`
+}
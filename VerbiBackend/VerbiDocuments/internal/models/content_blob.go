@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ContentBlob is a file content kept once in the blob store under its SHA-256 no matter how many keys hold it.
+// It is deleted together with its last reference
+type ContentBlob struct {
+	Hash     string `gorm:"primaryKey;size:64" json:"hash"`
+	Size     int64  `gorm:"not null" json:"size"`
+	RefCount int64  `gorm:"not null;default:0" json:"ref_count"`
+}
+
+// BlobReference maps a key of the blob store to the content stored under it
+type BlobReference struct {
+	Key     string    `gorm:"primaryKey" json:"key"`
+	Hash    string    `gorm:"size:64;not null;index" json:"hash"`
+	Size    int64     `gorm:"not null" json:"size"`
+	ModTime time.Time `gorm:"not null" json:"mod_time"`
+}
@@ -1,9 +1,37 @@
 package models
 
-// Document data model
+import "time"
+
+const (
+	// ProcessingStatusPending means that the document has no file yet or its uploaded file is being processed
+	ProcessingStatusPending = "pending"
+	// ProcessingStatusReady means that the uploaded file passed validation and its metadata is extracted
+	ProcessingStatusReady = "ready"
+	// ProcessingStatusRejected means that the last uploaded file failed validation, RejectionReason tells why
+	ProcessingStatusRejected = "rejected"
+)
+
+// Document data model.
+// Version is incremented on every change of the document and serves as its entity tag for optimistic concurrency.
+// Documents with TrashedAt set are in the trash: hidden from the library until restored or purged.
+// ProcessingStatus describes the last uploaded file, a rejected file is deleted and the previous one kept if there was any.
+// ReadingState is only loaded in the owner's document list
 type Document struct {
-	ID     uint   `gorm:"primaryKey" json:"id"`
-	UserId uint   `gorm:"not null" json:"user_id"`
-	Title  string `gorm:"not null" json:"title"`
-	Path   string `gorm:"unique;not null" json:"path"`
+	ID               uint             `gorm:"primaryKey" json:"id"`
+	UserId           uint             `gorm:"not null" json:"user_id"`
+	Title            string           `gorm:"not null" json:"title"`
+	Path             string           `gorm:"unique;not null" json:"path"`
+	FileName         string           `json:"file_name"`
+	Broken           bool             `gorm:"not null;default:false" json:"broken"`
+	ProcessingStatus string           `gorm:"not null;default:'ready'" json:"processing_status"`
+	RejectionReason  string           `gorm:"not null;default:''" json:"rejection_reason,omitempty"`
+	Version          uint             `gorm:"not null;default:1" json:"version"`
+	Notes            string           `gorm:"not null;default:''" json:"notes"`
+	Tags             []Tag            `gorm:"many2many:document_tags;constraint:OnDelete:CASCADE" json:"tags"`
+	Collections      []Collection     `gorm:"many2many:document_collections;constraint:OnDelete:CASCADE" json:"collections"`
+	Metadata         DocumentMetadata `gorm:"embedded" json:"metadata"`
+	ReadingState     *ReadingState    `gorm:"foreignKey:DocumentId" json:"reading_state,omitempty"`
+	CreatedAt        time.Time        `gorm:"not null;default:CURRENT_TIMESTAMP;index" json:"created_at"`
+	UpdatedAt        time.Time        `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+	TrashedAt        *time.Time       `gorm:"index" json:"trashed_at,omitempty"`
 }
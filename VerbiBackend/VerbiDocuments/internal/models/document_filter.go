@@ -0,0 +1,9 @@
+package models
+
+// DocumentFilter narrows the list of the user's documents. Zero fields are not applied
+type DocumentFilter struct {
+	CollectionId uint   `form:"collection_id"`
+	TagId        uint   `form:"tag_id"`
+	MimeType     string `form:"-"`
+	Status       string `form:"status"`
+}
@@ -0,0 +1,30 @@
+package models
+
+const (
+	// SortByTitle orders documents by title ignoring case
+	SortByTitle = "title"
+	// SortByCreated orders documents by creation time
+	SortByCreated = "created"
+	// SortBySize orders documents by file size
+	SortBySize = "size"
+	// SortByOpened orders documents by the time the user last opened them, never opened documents are the oldest
+	SortByOpened = "opened"
+)
+
+// DocumentListQuery selects a page of the user's documents
+type DocumentListQuery struct {
+	DocumentFilter
+	Format string `form:"format"`
+	Sort   string `form:"sort"`
+	Order  string `form:"order"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+// DocumentCursor is the position after the last document of a page in the sort order
+type DocumentCursor struct {
+	Sort       string      `json:"s"`
+	Descending bool        `json:"d"`
+	Value      interface{} `json:"v"`
+	Id         uint        `json:"id"`
+}
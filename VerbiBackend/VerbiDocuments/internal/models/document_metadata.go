@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// DocumentMetadata describes the content of the uploaded document file
+type DocumentMetadata struct {
+	Author        string     `json:"author"`
+	OriginalTitle string     `json:"original_title"`
+	Language      string     `json:"language"`
+	PageCount     int        `json:"page_count"`
+	PublishedAt   *time.Time `json:"published_at"`
+	FileSize      int64      `json:"file_size"`
+	MimeType      string     `json:"mime_type"`
+	Sha256        string     `gorm:"column:sha256" json:"sha256"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// DocumentPage is the extracted plain text of a PDF page or an EPUB chapter.
+// Offsets are positions in characters within the whole document text, where pages are separated by an empty line
+type DocumentPage struct {
+	ID          uint     `gorm:"primaryKey" json:"-"`
+	DocumentId  uint     `gorm:"not null;uniqueIndex:idx_document_page" json:"document_id"`
+	Document    Document `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Number      int      `gorm:"not null;uniqueIndex:idx_document_page" json:"number"`
+	Chapter     string   `json:"chapter"`
+	Text        string   `gorm:"type:text;not null" json:"text"`
+	StartOffset int      `gorm:"not null" json:"start_offset"`
+	EndOffset   int      `gorm:"not null" json:"end_offset"`
+}
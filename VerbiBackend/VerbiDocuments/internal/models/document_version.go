@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// DocumentVersion is an immutable snapshot of a content file uploaded to the document.
+// Versions with the same checksum share the stored content
+type DocumentVersion struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	DocumentId uint      `gorm:"not null;uniqueIndex:idx_document_version" json:"document_id"`
+	Document   Document  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Number     int       `gorm:"not null;uniqueIndex:idx_document_version" json:"number"`
+	FileName   string    `gorm:"not null" json:"file_name"`
+	Size       int64     `gorm:"not null" json:"size"`
+	MimeType   string    `json:"mime_type"`
+	Sha256     string    `gorm:"column:sha256;not null" json:"sha256"`
+	UploadedBy uint      `gorm:"not null" json:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	Current    bool      `gorm:"-" json:"current"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the document created by a request with the Idempotency-Key header,
+// so retries of the request return the same document
+type IdempotencyKey struct {
+	UserId      uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Key         string    `gorm:"primaryKey;size:255" json:"key"`
+	RequestHash string    `gorm:"not null" json:"request_hash"`
+	DocumentId  uint      `gorm:"not null" json:"document_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Statuses of a background job
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCanceled  = "canceled"
+)
+
+// Types of background jobs
+const (
+	// JobTypeProcessUpload validates a file uploaded over sftp and extracts its metadata
+	JobTypeProcessUpload = "process_upload"
+	// JobTypeExtractText indexes the text of the current file of a document
+	JobTypeExtractText = "extract_text"
+	// JobTypeGenerateCovers renders the cover thumbnails of the current file of a document
+	JobTypeGenerateCovers = "generate_covers"
+	// JobTypeImportUrl downloads the file of a document imported from a URL and processes it like an upload
+	JobTypeImportUrl = "import_url"
+)
+
+// Job is a unit of background work on a document of the user. Queued jobs are claimed by workers once RunAt has passed,
+// a running job is leased to its worker until LockedUntil and queued again if the worker does not finish it in time.
+// Failed attempts are retried with backoff until MaxAttempts is reached
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Type        string     `gorm:"not null;index:idx_job_claim,priority:2" json:"type"`
+	Status      string     `gorm:"not null;index:idx_job_claim,priority:1" json:"status"`
+	UserId      uint       `gorm:"not null;index" json:"-"`
+	DocumentId  uint       `gorm:"not null;index" json:"document_id"`
+	Payload     string     `gorm:"type:text" json:"-"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null" json:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	RunAt       time.Time  `gorm:"not null;index:idx_job_claim,priority:3" json:"run_at"`
+	LockedUntil *time.Time `json:"-"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// JobListQuery selects the user's jobs, all of them if the fields are empty
+type JobListQuery struct {
+	DocumentId uint   `form:"document_id"`
+	Status     string `form:"status"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// LibraryArchiveVersion is the version of the library archive format written by exports
+const LibraryArchiveVersion = 1
+
+// LibraryManifest is manifest.json of a library archive. Ids are only meaningful within the archive:
+// documents refer to collections by their archive ids and to their file by its path in the archive
+type LibraryManifest struct {
+	Version     int                   `json:"version"`
+	ExportedAt  time.Time             `json:"exported_at"`
+	Tags        []string              `json:"tags"`
+	Collections []*ArchivedCollection `json:"collections"`
+	Documents   []*ArchivedDocument   `json:"documents"`
+}
+
+// ArchivedCollection is a collection in a library archive, parents are listed before their children
+type ArchivedCollection struct {
+	Id       uint   `json:"id"`
+	ParentId *uint  `json:"parent_id,omitempty"`
+	Name     string `json:"name"`
+}
+
+// ArchivedDocument is a document in a library archive with the user's data attached to it.
+// File is empty for documents without an uploaded file
+type ArchivedDocument struct {
+	Id           uint                `json:"id"`
+	Title        string              `json:"title"`
+	Notes        string              `json:"notes"`
+	FileName     string              `json:"file_name,omitempty"`
+	File         string              `json:"file,omitempty"`
+	Metadata     DocumentMetadata    `json:"metadata"`
+	Tags         []string            `json:"tags"`
+	Collections  []uint              `json:"collections"`
+	ReadingState *ArchivedReading    `json:"reading_state,omitempty"`
+	Annotations  []*ArchivedNote     `json:"annotations"`
+	Bookmarks    []*ArchivedBookmark `json:"bookmarks"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// ArchivedReading is the reading progress of a document in a library archive
+type ArchivedReading struct {
+	Location     string     `json:"location"`
+	Percentage   float64    `json:"percentage"`
+	Status       string     `json:"status"`
+	LastOpenedAt *time.Time `json:"last_opened_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ArchivedNote is an annotation of a document in a library archive
+type ArchivedNote struct {
+	Page        int       `json:"page"`
+	StartOffset int       `json:"start_offset"`
+	EndOffset   int       `json:"end_offset"`
+	Text        string    `json:"text"`
+	Color       string    `json:"color"`
+	Note        string    `json:"note"`
+	LlmResponse string    `json:"llm_response,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ArchivedBookmark is a bookmark of a document in a library archive
+type ArchivedBookmark struct {
+	Location  string    `json:"location"`
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Reasons of import conflicts
+const (
+	// ImportConflictDuplicate means that the library already has a document with the same file checksum
+	ImportConflictDuplicate = "duplicate"
+	// ImportConflictMissingFile means that the file of the document is missing from the archive or its checksum differs
+	ImportConflictMissingFile = "missing_file"
+	// ImportConflictRejected means that the file of the document was rejected by validation or the storage quota
+	ImportConflictRejected = "rejected"
+)
+
+// ImportConflict is a document of a library archive that was not imported
+type ImportConflict struct {
+	Title      string `json:"title"`
+	Sha256     string `json:"sha256,omitempty"`
+	Reason     string `json:"reason"`
+	Detail     string `json:"detail,omitempty"`
+	DocumentId uint   `json:"document_id,omitempty"`
+}
+
+// ImportReport summarizes the import of a library archive. DocumentId of a duplicate conflict
+// is the document already in the library
+type ImportReport struct {
+	Imported    []uint            `json:"imported"`
+	Collections int               `json:"collections"`
+	Conflicts   []*ImportConflict `json:"conflicts"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// OpdsFeed is a page of an OPDS 1.2 catalog. All links are absolute URLs, the pagination and start links are empty
+// if the catalog does not provide them
+type OpdsFeed struct {
+	Id       string       `json:"id"`
+	Title    string       `json:"title"`
+	Url      string       `json:"url"`
+	Start    string       `json:"start,omitempty"`
+	Up       string       `json:"up,omitempty"`
+	Previous string       `json:"previous,omitempty"`
+	Next     string       `json:"next,omitempty"`
+	Entries  []*OpdsEntry `json:"entries"`
+}
+
+// OpdsEntry is an entry of an OPDS catalog: a publication with acquisition links or, with Navigation set,
+// a link to another feed of the catalog
+type OpdsEntry struct {
+	Id           string      `json:"id"`
+	Title        string      `json:"title"`
+	Authors      []string    `json:"authors"`
+	Summary      string      `json:"summary,omitempty"`
+	Language     string      `json:"language,omitempty"`
+	Issued       string      `json:"issued,omitempty"`
+	Cover        string      `json:"cover,omitempty"`
+	Thumbnail    string      `json:"thumbnail,omitempty"`
+	Navigation   string      `json:"navigation,omitempty"`
+	Acquisitions []*OpdsLink `json:"acquisitions"`
+}
+
+// OpdsLink is an acquisition link of a publication, Rel tells how the publication is acquired, e.g. open-access
+type OpdsLink struct {
+	Rel   string `json:"rel"`
+	Href  string `json:"href"`
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+}
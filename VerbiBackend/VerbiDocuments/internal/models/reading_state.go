@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	// ReadingStatusToRead marks a document the user has not started yet
+	ReadingStatusToRead = "to-read"
+	// ReadingStatusReading marks a document the user is reading
+	ReadingStatusReading = "reading"
+	// ReadingStatusFinished marks a document the user has read
+	ReadingStatusFinished = "finished"
+)
+
+// ReadingState is the user's position in a document synced between their devices.
+// Location is a page number for PDF documents and an EPUB CFI for EPUB documents.
+// UpdatedAt is the time of the change on the device, the latest change wins. SyncedAt is the time the server stored
+// the change at, devices sync the changes stored after the last one they got without relying on their clocks
+type ReadingState struct {
+	ID           uint       `gorm:"primaryKey" json:"-"`
+	DocumentId   uint       `gorm:"not null;uniqueIndex:idx_reading_state" json:"document_id"`
+	Document     *Document  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserId       uint       `gorm:"not null;uniqueIndex:idx_reading_state;index" json:"user_id"`
+	Location     string     `gorm:"not null;default:''" json:"location"`
+	Percentage   float64    `gorm:"not null;default:0" json:"percentage"`
+	Status       string     `gorm:"not null" json:"status"`
+	LastOpenedAt *time.Time `json:"last_opened_at"`
+	UpdatedAt    time.Time  `gorm:"not null;autoUpdateTime:false" json:"updated_at"`
+	SyncedAt     time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP;index" json:"synced_at"`
+}
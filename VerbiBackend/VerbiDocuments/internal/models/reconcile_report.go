@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// OrphanBlob is a stored file that does not belong to any document
+type OrphanBlob struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Deleted bool      `json:"deleted"`
+}
+
+// MissingFile is a document whose uploaded file is absent from the storage
+type MissingFile struct {
+	DocumentId   uint   `json:"document_id"`
+	UserId       uint   `json:"user_id"`
+	Key          string `json:"key"`
+	MarkedBroken bool   `json:"marked_broken"`
+}
+
+// ReconcileReport is the result of a consistency check of the storage and the documents table
+type ReconcileReport struct {
+	StartedAt        time.Time      `json:"started_at"`
+	FinishedAt       time.Time      `json:"finished_at"`
+	Repair           bool           `json:"repair"`
+	DocumentsChecked int            `json:"documents_checked"`
+	BlobsChecked     int            `json:"blobs_checked"`
+	Orphans          []*OrphanBlob  `json:"orphans"`
+	MissingFiles     []*MissingFile `json:"missing_files"`
+	Restored         []uint         `json:"restored"`
+	Errors           []string       `json:"errors"`
+}
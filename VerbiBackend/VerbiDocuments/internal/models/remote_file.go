@@ -0,0 +1,10 @@
+package models
+
+// RemoteFile describes a file downloaded from a remote server. Url is the location the file was finally served from
+// after redirects, FileName is suggested by the server or taken from the URL path and Size is -1 if unknown
+type RemoteFile struct {
+	Url         string
+	FileName    string
+	ContentType string
+	Size        int64
+}
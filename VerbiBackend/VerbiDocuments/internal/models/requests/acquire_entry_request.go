@@ -0,0 +1,8 @@
+package requests
+
+// AcquireEntryRequest represents the body of a request to import a publication of an OPDS catalog,
+// the entry is looked up by its id in the feed
+type AcquireEntryRequest struct {
+	FeedUrl string `json:"feed_url" binding:"required,url,max=2048"`
+	EntryId string `json:"entry_id" binding:"required,max=2048"`
+}
@@ -0,0 +1,12 @@
+package requests
+
+// CreateAnnotationRequest represents the body of a request to highlight text in a document
+type CreateAnnotationRequest struct {
+	Page        int    `json:"page" binding:"required,min=1"`
+	StartOffset int    `json:"start_offset" binding:"min=0"`
+	EndOffset   int    `json:"end_offset" binding:"min=0"`
+	Text        string `json:"text" binding:"max=10000"`
+	Color       string `json:"color" binding:"omitempty,hexcolor"`
+	Note        string `json:"note" binding:"max=10000"`
+	LlmResponse string `json:"llm_response" binding:"max=100000"`
+}
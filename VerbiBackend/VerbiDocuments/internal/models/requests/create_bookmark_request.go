@@ -0,0 +1,7 @@
+package requests
+
+// CreateBookmarkRequest represents the body of a request to bookmark a position in a document
+type CreateBookmarkRequest struct {
+	Location string `json:"location" binding:"required,max=1024"`
+	Label    string `json:"label" binding:"max=255"`
+}
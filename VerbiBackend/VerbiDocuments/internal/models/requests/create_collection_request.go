@@ -0,0 +1,7 @@
+package requests
+
+// CreateCollectionRequest represents data required to create a collection, at the top level if the parent is omitted
+type CreateCollectionRequest struct {
+	Name     string `json:"name" binding:"required,max=255"`
+	ParentId *uint  `json:"parent_id"`
+}
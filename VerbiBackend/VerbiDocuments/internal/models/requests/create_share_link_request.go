@@ -0,0 +1,13 @@
+package requests
+
+import "time"
+
+// CreateShareLinkRequest represents options of a public link to a document. Omitted options impose no restriction.
+// A page range makes a text only link serving the text extracted from the pages
+type CreateShareLinkRequest struct {
+	ExpiresAt    *time.Time `json:"expires_at"`
+	MaxDownloads int        `json:"max_downloads" binding:"min=0"`
+	Password     string     `json:"password" binding:"max=72"`
+	PageFrom     int        `json:"page_from" binding:"min=0"`
+	PageTo       int        `json:"page_to" binding:"min=0"`
+}
@@ -0,0 +1,8 @@
+package requests
+
+// ImportUrlRequest represents the body of a request to import a document from a URL.
+// The title is taken from the URL when it is omitted
+type ImportUrlRequest struct {
+	Url   string `json:"url" binding:"required,url,max=2048"`
+	Title string `json:"title" binding:"max=255"`
+}
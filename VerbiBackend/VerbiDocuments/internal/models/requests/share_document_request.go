@@ -0,0 +1,7 @@
+package requests
+
+// ShareDocumentRequest represents the body of a request to share a document with another user
+type ShareDocumentRequest struct {
+	Login      string `json:"login" binding:"required,max=255"`
+	Permission string `json:"permission" binding:"required,oneof=read annotate"`
+}
@@ -0,0 +1,6 @@
+package requests
+
+// TagRequest represents data required to create or rename a tag
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
@@ -0,0 +1,9 @@
+package requests
+
+// UpdateAnnotationRequest represents user edits of an annotation. Omitted fields are left unchanged,
+// the highlighted text can't be changed
+type UpdateAnnotationRequest struct {
+	Color       *string `json:"color" binding:"omitempty,hexcolor"`
+	Note        *string `json:"note" binding:"omitempty,max=10000"`
+	LlmResponse *string `json:"llm_response" binding:"omitempty,max=100000"`
+}
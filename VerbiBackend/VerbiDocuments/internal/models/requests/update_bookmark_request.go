@@ -0,0 +1,7 @@
+package requests
+
+// UpdateBookmarkRequest represents user edits of a bookmark. Omitted fields are left unchanged
+type UpdateBookmarkRequest struct {
+	Location *string `json:"location" binding:"omitempty,min=1,max=1024"`
+	Label    *string `json:"label" binding:"omitempty,max=255"`
+}
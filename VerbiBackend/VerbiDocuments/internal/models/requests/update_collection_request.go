@@ -0,0 +1,8 @@
+package requests
+
+// UpdateCollectionRequest represents renaming or moving a collection. Omitted fields are left unchanged,
+// parent id 0 moves the collection to the top level
+type UpdateCollectionRequest struct {
+	Name     *string `json:"name" binding:"omitempty,max=255"`
+	ParentId *uint   `json:"parent_id"`
+}
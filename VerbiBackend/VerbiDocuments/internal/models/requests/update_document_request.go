@@ -0,0 +1,15 @@
+package requests
+
+import "time"
+
+// UpdateDocumentRequest represents user edits of the document. Omitted fields are left unchanged,
+// tags replace all current tags of the document when present
+type UpdateDocumentRequest struct {
+	Title         *string    `json:"title" binding:"omitempty,max=255"`
+	Author        *string    `json:"author" binding:"omitempty,max=255"`
+	OriginalTitle *string    `json:"original_title" binding:"omitempty,max=255"`
+	Language      *string    `json:"language" binding:"omitempty,max=35"`
+	PublishedAt   *time.Time `json:"published_at"`
+	Tags          []string   `json:"tags" binding:"omitempty,max=50,dive,max=64"`
+	Notes         *string    `json:"notes" binding:"omitempty,max=10000"`
+}
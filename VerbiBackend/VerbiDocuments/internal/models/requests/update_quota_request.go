@@ -0,0 +1,7 @@
+package requests
+
+// UpdateQuotaRequest represents the body of a request to set the storage quota of a user, zero limits are not applied
+type UpdateQuotaRequest struct {
+	MaxBytes     *int64 `json:"max_bytes" binding:"required,min=0"`
+	MaxDocuments *int64 `json:"max_documents" binding:"required,min=0"`
+}
@@ -0,0 +1,12 @@
+package requests
+
+import "time"
+
+// UpdateReadingStateRequest represents the body of a request to save the reading state of a document on a device
+type UpdateReadingStateRequest struct {
+	Location     string     `json:"location" binding:"max=1024"`
+	Percentage   float64    `json:"percentage" binding:"min=0,max=100"`
+	Status       string     `json:"status" binding:"omitempty,oneof=to-read reading finished"`
+	LastOpenedAt *time.Time `json:"last_opened_at"`
+	UpdatedAt    time.Time  `json:"updated_at" binding:"required"`
+}
@@ -0,0 +1,7 @@
+package requests
+
+// UpdateRetentionRequest represents the user's policy of keeping old document versions. Zero disables a limit
+type UpdateRetentionRequest struct {
+	KeepVersions int `json:"keep_versions" binding:"min=0,max=1000"`
+	KeepDays     int `json:"keep_days" binding:"min=0,max=3650"`
+}
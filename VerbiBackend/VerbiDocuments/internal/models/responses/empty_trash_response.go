@@ -0,0 +1,6 @@
+package responses
+
+// EmptyTrashResponse represents server response on emptyTrash request
+type EmptyTrashResponse struct {
+	Deleted int `json:"deleted"`
+}
@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetAnnotationsResponse represents server response on getAnnotations request
+type GetAnnotationsResponse struct {
+	Annotations []*models.Annotation `json:"annotations"`
+}
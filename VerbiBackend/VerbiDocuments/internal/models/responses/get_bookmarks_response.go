@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetBookmarksResponse represents server response on getBookmarks request
+type GetBookmarksResponse struct {
+	Bookmarks []*models.Bookmark `json:"bookmarks"`
+}
@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetCollectionsResponse represents all collections of the user. Clients build the tree by parent ids
+type GetCollectionsResponse struct {
+	Collections []*models.Collection `json:"collections"`
+}
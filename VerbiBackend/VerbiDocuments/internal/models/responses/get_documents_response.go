@@ -4,5 +4,7 @@ import "VerbiDocuments/internal/models"
 
 // GetDocumentsResponse represents server response on getDocuments request
 type GetDocumentsResponse struct {
-	Documents []*models.Document `json:"documents"`
+	Documents  []*models.Document `json:"documents"`
+	Total      int64              `json:"total"`
+	NextCursor string             `json:"next_cursor,omitempty"`
 }
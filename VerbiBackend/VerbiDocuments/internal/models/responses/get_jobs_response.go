@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetJobsResponse represents server response on getJobs request
+type GetJobsResponse struct {
+	Jobs []*models.Job `json:"jobs"`
+}
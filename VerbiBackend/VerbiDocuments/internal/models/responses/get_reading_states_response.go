@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetReadingStatesResponse represents server response on getReadingStates request
+type GetReadingStatesResponse struct {
+	States []*models.ReadingState `json:"states"`
+}
@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetShareLinksResponse represents server response on getShareLinks request
+type GetShareLinksResponse struct {
+	Links []*models.ShareLink `json:"links"`
+}
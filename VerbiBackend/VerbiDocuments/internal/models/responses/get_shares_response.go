@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetSharesResponse represents server response on getShares and getSharedDocuments requests
+type GetSharesResponse struct {
+	Shares []*models.Share `json:"shares"`
+}
@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetTagsResponse represents all tags of the user ordered by name
+type GetTagsResponse struct {
+	Tags []*models.Tag `json:"tags"`
+}
@@ -0,0 +1,10 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetTextResponse represents server response on getText request
+type GetTextResponse struct {
+	DocumentId uint                   `json:"document_id"`
+	PageCount  int                    `json:"page_count"`
+	Pages      []*models.DocumentPage `json:"pages"`
+}
@@ -0,0 +1,8 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetTrashResponse represents server response on getTrash request
+type GetTrashResponse struct {
+	Documents []*models.Document `json:"documents"`
+}
@@ -0,0 +1,9 @@
+package responses
+
+// GetUsageResponse represents server response on getUsage and getUserQuota requests. Zero limits are not applied
+type GetUsageResponse struct {
+	Bytes        int64 `json:"bytes"`
+	MaxBytes     int64 `json:"max_bytes"`
+	Documents    int64 `json:"documents"`
+	MaxDocuments int64 `json:"max_documents"`
+}
@@ -0,0 +1,9 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// GetVersionsResponse represents the version history of a document, newest first
+type GetVersionsResponse struct {
+	DocumentId uint                      `json:"document_id"`
+	Versions   []*models.DocumentVersion `json:"versions"`
+}
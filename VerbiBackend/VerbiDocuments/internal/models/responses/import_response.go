@@ -0,0 +1,10 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// ImportResponse represents server response on importUrl and acquireEntry requests:
+// the pending document and the job downloading its file
+type ImportResponse struct {
+	Document *models.Document `json:"document"`
+	Job      *models.Job      `json:"job"`
+}
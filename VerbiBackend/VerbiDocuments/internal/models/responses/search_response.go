@@ -0,0 +1,9 @@
+package responses
+
+import "VerbiDocuments/internal/models"
+
+// SearchResponse represents server response on searchDocuments request
+type SearchResponse struct {
+	Query string              `json:"query"`
+	Hits  []*models.SearchHit `json:"hits"`
+}
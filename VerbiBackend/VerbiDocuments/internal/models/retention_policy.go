@@ -0,0 +1,9 @@
+package models
+
+// RetentionPolicy limits how many old versions of each document the user keeps. Zero limits are not applied.
+// The current version of a document is always kept
+type RetentionPolicy struct {
+	UserId       uint `gorm:"primaryKey" json:"-"`
+	KeepVersions int  `gorm:"not null" json:"keep_versions"`
+	KeepDays     int  `gorm:"not null" json:"keep_days"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// SearchHit is a page of the user's document matching a full-text search query
+type SearchHit struct {
+	DocumentId uint    `json:"document_id"`
+	Title      string  `json:"title"`
+	PageNumber int     `json:"page_number"`
+	Chapter    string  `json:"chapter"`
+	Snippet    string  `json:"snippet"`
+	Rank       float64 `json:"rank"`
+}
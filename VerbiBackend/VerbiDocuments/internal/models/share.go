@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	// PermissionRead lets the recipient of a share read the document
+	PermissionRead = "read"
+	// PermissionAnnotate lets the recipient of a share read and annotate the document
+	PermissionAnnotate = "annotate"
+)
+
+// Share grants another user access to a document. The document and its files stay with the owner
+type Share struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	DocumentId uint      `gorm:"not null;uniqueIndex:idx_document_share" json:"document_id"`
+	Document   *Document `gorm:"constraint:OnDelete:CASCADE" json:"document,omitempty"`
+	OwnerId    uint      `gorm:"not null;index" json:"owner_id"`
+	UserId     uint      `gorm:"not null;uniqueIndex:idx_document_share;index" json:"user_id"`
+	Username   string    `gorm:"not null" json:"username"`
+	Permission string    `gorm:"not null" json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
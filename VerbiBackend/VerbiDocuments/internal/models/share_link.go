@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ShareLink is a public link to a document for people without a Verbi account.
+// The link token is the random key signed by the service, a zero download limit means no limit
+// and a zero page range means the whole file. A link to a page range is text only: it serves the text
+// extracted from the pages, not the pages of the file
+type ShareLink struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	DocumentId       uint       `gorm:"not null;index" json:"document_id"`
+	Document         *Document  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	OwnerId          uint       `gorm:"not null;index" json:"-"`
+	Key              string     `gorm:"column:link_key;not null;uniqueIndex" json:"-"`
+	Token            string     `gorm:"-" json:"token"`
+	PasswordHash     string     `gorm:"not null;default:''" json:"-"`
+	HasPassword      bool       `gorm:"-" json:"has_password"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	MaxDownloads     int        `gorm:"not null;default:0" json:"max_downloads"`
+	Downloads        int        `gorm:"not null;default:0" json:"downloads"`
+	PageFrom         int        `gorm:"not null;default:0" json:"page_from,omitempty"`
+	PageTo           int        `gorm:"not null;default:0" json:"page_to,omitempty"`
+	TextOnly         bool       `gorm:"-" json:"text_only"`
+	LastDownloadedAt *time.Time `json:"last_downloaded_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// StorageUsage is the storage taken by the user's documents including the ones in the trash:
+// their number and the total size of their current files. Versions are limited by the retention policy instead
+type StorageUsage struct {
+	UserId    uint  `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	Bytes     int64 `gorm:"not null;default:0" json:"bytes"`
+	Documents int64 `gorm:"not null;default:0" json:"documents"`
+}
+
+// StorageQuota limits the storage usage of the user. Zero limits are not applied.
+// Users without a quota of their own get the default quota
+type StorageQuota struct {
+	UserId       uint  `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	MaxBytes     int64 `gorm:"not null" json:"max_bytes"`
+	MaxDocuments int64 `gorm:"not null" json:"max_documents"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// Tag is a free-form label the user attaches to documents
+type Tag struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	UserId uint   `gorm:"not null;uniqueIndex:idx_user_tag" json:"-"`
+	Name   string `gorm:"not null;uniqueIndex:idx_user_tag" json:"name"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// EventUploadCompleted is the type of the event emitted when a file upload to a document directory finished
+const EventUploadCompleted = "upload.completed"
+
+// UploadEvent describes a file the uploader finished writing to a directory of the user's document. The size and checksum
+// of the file are taken from the stored file when it is processed
+type UploadEvent struct {
+	Type        string    `json:"type"`
+	UserId      uint      `json:"user_id"`
+	DocumentId  uint      `json:"document_id"`
+	UploaderId  uint      `json:"uploader_id"`
+	FileName    string    `json:"file_name"`
+	CompletedAt time.Time `json:"completed_at"`
+}
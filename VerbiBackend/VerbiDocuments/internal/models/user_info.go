@@ -0,0 +1,7 @@
+package models
+
+// UserInfo is public data of a Verbi user managed by VerbiAuth
+type UserInfo struct {
+	UserId   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
@@ -0,0 +1,165 @@
+package opds
+
+import (
+	"VerbiDocuments/internal/models"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+const (
+	// RelAcquisition is the relation of generic acquisition links
+	RelAcquisition = "http://opds-spec.org/acquisition"
+	// RelOpenAccess is the relation of links acquiring a publication without payment or registration
+	RelOpenAccess = "http://opds-spec.org/acquisition/open-access"
+
+	relImage     = "http://opds-spec.org/image"
+	relThumbnail = "http://opds-spec.org/image/thumbnail"
+)
+
+// ErrInvalidFeed is returned when the document is not an Atom feed
+var ErrInvalidFeed = errors.New("invalid OPDS feed")
+
+// atomFeed is the part of an Atom feed used by OPDS catalogs
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomEntry is an entry of an Atom feed with the Dublin Core terms used by OPDS
+type atomEntry struct {
+	Id       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Authors  []atomAuthor `xml:"author"`
+	Summary  string       `xml:"summary"`
+	Content  string       `xml:"content"`
+	Language string       `xml:"http://purl.org/dc/terms/ language"`
+	Issued   string       `xml:"http://purl.org/dc/terms/ issued"`
+	Links    []atomLink   `xml:"link"`
+}
+
+// atomAuthor is an author of an Atom entry
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomLink is a link of an Atom feed or entry
+type atomLink struct {
+	Rel   string `xml:"rel,attr"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr"`
+	Title string `xml:"title,attr"`
+}
+
+// Parse reads an OPDS 1.2 feed served from the base URL, relative links are resolved against it
+func Parse(reader io.Reader, base *url.URL) (*models.OpdsFeed, error) {
+	var feed atomFeed
+	err := xml.NewDecoder(reader).Decode(&feed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeed, err)
+	}
+
+	result := &models.OpdsFeed{
+		Id:      strings.TrimSpace(feed.Id),
+		Title:   strings.TrimSpace(feed.Title),
+		Url:     base.String(),
+		Entries: make([]*models.OpdsEntry, 0, len(feed.Entries)),
+	}
+	for _, link := range feed.Links {
+		href := resolve(base, link.Href)
+		switch link.Rel {
+		case "start":
+			result.Start = href
+		case "up":
+			result.Up = href
+		case "previous", "prev":
+			result.Previous = href
+		case "next":
+			result.Next = href
+		}
+	}
+	for _, entry := range feed.Entries {
+		result.Entries = append(result.Entries, parseEntry(entry, base))
+	}
+	return result, nil
+}
+
+// parseEntry converts the Atom entry to an OPDS entry with absolute links
+func parseEntry(entry atomEntry, base *url.URL) *models.OpdsEntry {
+	result := &models.OpdsEntry{
+		Id:           strings.TrimSpace(entry.Id),
+		Title:        strings.TrimSpace(entry.Title),
+		Authors:      []string{},
+		Summary:      strings.TrimSpace(entry.Summary),
+		Language:     strings.TrimSpace(entry.Language),
+		Issued:       strings.TrimSpace(entry.Issued),
+		Acquisitions: []*models.OpdsLink{},
+	}
+	if result.Summary == "" {
+		result.Summary = strings.TrimSpace(entry.Content)
+	}
+	for _, author := range entry.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			result.Authors = append(result.Authors, name)
+		}
+	}
+
+	for _, link := range entry.Links {
+		href := resolve(base, link.Href)
+		switch {
+		case link.Href == "":
+		case link.Rel == relImage:
+			result.Cover = href
+		case link.Rel == relThumbnail:
+			result.Thumbnail = href
+		case link.Rel == RelAcquisition || strings.HasPrefix(link.Rel, RelAcquisition+"/"):
+			result.Acquisitions = append(result.Acquisitions, &models.OpdsLink{
+				Rel:   link.Rel,
+				Href:  href,
+				Type:  mediaType(link.Type),
+				Title: link.Title,
+			})
+		case mediaType(link.Type) == "application/atom+xml" && link.Rel != "self" && result.Navigation == "":
+			result.Navigation = href
+		}
+	}
+	return result
+}
+
+// resolve returns the absolute URL of the reference relative to the base
+func resolve(base *url.URL, reference string) string {
+	parsed, err := url.Parse(strings.TrimSpace(reference))
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// mediaType returns the media type without parameters, e.g. the profile of OPDS feeds
+func mediaType(value string) string {
+	parsed, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return strings.TrimSpace(value)
+	}
+	return parsed
+}
+
+// Acquisition returns the link downloading the publication for free in the first of the media types available,
+// nil if there is none. Links requiring payment, borrowing or a subscription are skipped
+func Acquisition(entry *models.OpdsEntry, mediaTypes []string) *models.OpdsLink {
+	for _, mediaType := range mediaTypes {
+		for _, link := range entry.Acquisitions {
+			if (link.Rel == RelAcquisition || link.Rel == RelOpenAccess) && link.Type == mediaType {
+				return link
+			}
+		}
+	}
+	return nil
+}
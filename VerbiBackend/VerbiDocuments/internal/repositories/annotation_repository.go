@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+)
+
+// AnnotationRepository works with annotations database. Every query is scoped by the author of the annotations
+type AnnotationRepository struct {
+	DB *gorm.DB
+}
+
+// NewAnnotationRepository creates an annotation repository
+func NewAnnotationRepository(db *gorm.DB) *AnnotationRepository {
+	return &AnnotationRepository{DB: db}
+}
+
+// CreateAnnotation inserts a new annotation into the database
+func (r *AnnotationRepository) CreateAnnotation(annotation *models.Annotation) error {
+	return r.DB.Create(annotation).Error
+}
+
+// GetAnnotations returns the user's annotations of the document in reading order
+func (r *AnnotationRepository) GetAnnotations(userId, documentId uint) ([]*models.Annotation, error) {
+	var annotations []*models.Annotation
+	err := r.DB.Where("user_id = ? AND document_id = ?", userId, documentId).
+		Order("page, start_offset, id").
+		Find(&annotations).Error
+	return annotations, err
+}
+
+// GetAnnotation returns the user's annotation of the document with the given id
+func (r *AnnotationRepository) GetAnnotation(userId, documentId, id uint) (*models.Annotation, error) {
+	annotation := new(models.Annotation)
+	err := r.DB.Where("id = ? AND user_id = ? AND document_id = ?", id, userId, documentId).First(annotation).Error
+	return annotation, err
+}
+
+// UpdateAnnotation saves the colour, note and LLM response of the annotation
+func (r *AnnotationRepository) UpdateAnnotation(annotation *models.Annotation) error {
+	return r.DB.Model(annotation).Select("color", "note", "llm_response", "updated_at").Updates(annotation).Error
+}
+
+// DeleteAnnotation deletes the user's annotation of the document.
+// Returns gorm.ErrRecordNotFound if the user has no such annotation
+func (r *AnnotationRepository) DeleteAnnotation(userId, documentId, id uint) error {
+	result := r.DB.Where("id = ? AND user_id = ? AND document_id = ?", id, userId, documentId).Delete(&models.Annotation{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// DeleteAnnotationsByUserId deletes the user's annotations and annotations of other users in the user's documents
+func (r *AnnotationRepository) DeleteAnnotationsByUserId(userId uint) error {
+	return r.DB.Where(
+		"user_id = ? OR document_id IN (?)",
+		userId, r.DB.Model(&models.Document{}).Select("id").Where("user_id = ?", userId),
+	).Delete(&models.Annotation{}).Error
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+)
+
+// BlobRepository works with deduplicated contents of the blob store and the keys referencing them
+type BlobRepository struct {
+	DB *gorm.DB
+}
+
+// NewBlobRepository creates a blob repository
+func NewBlobRepository(db *gorm.DB) *BlobRepository {
+	return &BlobRepository{DB: db}
+}
+
+// AcquireContent adds a reference to the stored content and reports whether it exists.
+// Content that does not exist yet has to be stored and created with CreateContent
+func (r *BlobRepository) AcquireContent(hash string) (bool, error) {
+	result := r.DB.Model(&models.ContentBlob{}).
+		Where("hash = ?", hash).
+		Update("ref_count", gorm.Expr("ref_count + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateContent records newly stored content with one reference.
+// If another upload created the same content in the meantime, a reference is added to it
+func (r *BlobRepository) CreateContent(hash string, size int64) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("content_blobs.ref_count + 1")}),
+	}).Create(&models.ContentBlob{Hash: hash, Size: size, RefCount: 1}).Error
+}
+
+// ReleaseContent removes a reference to the content. After the last one the content record is deleted
+// and deleteContent removes the stored content before the transaction commits, so the content can not be
+// acquired again while it is being deleted. Nothing is released if deleteContent fails
+func (r *BlobRepository) ReleaseContent(hash string, deleteContent func() error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.ContentBlob{}).
+			Where("hash = ?", hash).
+			Update("ref_count", gorm.Expr("ref_count - 1")).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Where("hash = ? AND ref_count <= 0", hash).Delete(&models.ContentBlob{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return deleteContent()
+	})
+}
+
+// GetReference returns the reference of the key
+func (r *BlobRepository) GetReference(key string) (*models.BlobReference, error) {
+	reference := new(models.BlobReference)
+	err := r.DB.Where("key = ?", key).First(reference).Error
+	return reference, err
+}
+
+// GetReferences returns references of all keys starting with the prefix, sorted by key
+func (r *BlobRepository) GetReferences(prefix string) ([]models.BlobReference, error) {
+	var references []models.BlobReference
+	query := r.DB.Order("key")
+	if prefix != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+		query = query.Where(`key LIKE ? ESCAPE '\'`, escaped+"%")
+	}
+	err := query.Find(&references).Error
+	return references, err
+}
+
+// SaveReference points the key to the content and returns the hash of the content it referenced before,
+// empty if the key was not referenced. The reference is locked until the transaction commits, so concurrent
+// saves and deletes of the key see each other's content and never release the same content twice
+func (r *BlobRepository) SaveReference(reference *models.BlobReference) (string, error) {
+	var previous string
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		current := new(models.BlobReference)
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", reference.Key).First(current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(reference)
+			if result.Error != nil || result.RowsAffected > 0 {
+				return result.Error
+			}
+			// another save created the reference meanwhile
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", reference.Key).First(current).Error
+		}
+		if err != nil {
+			return err
+		}
+		previous = current.Hash
+		return tx.Save(reference).Error
+	})
+	return previous, err
+}
+
+// DeleteReference deletes the reference of the key and returns it. Returns gorm.ErrRecordNotFound if there is none
+func (r *BlobRepository) DeleteReference(key string) (*models.BlobReference, error) {
+	reference := new(models.BlobReference)
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(reference).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Where("key = ?", key).Delete(&models.BlobReference{})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	return reference, err
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+)
+
+// BookmarkRepository works with bookmarks database. Every query is scoped by the author of the bookmarks
+type BookmarkRepository struct {
+	DB *gorm.DB
+}
+
+// NewBookmarkRepository creates a bookmark repository
+func NewBookmarkRepository(db *gorm.DB) *BookmarkRepository {
+	return &BookmarkRepository{DB: db}
+}
+
+// CreateBookmark inserts a new bookmark into the database
+func (r *BookmarkRepository) CreateBookmark(bookmark *models.Bookmark) error {
+	return r.DB.Create(bookmark).Error
+}
+
+// GetBookmarks returns the user's bookmarks of the document ordered by creation
+func (r *BookmarkRepository) GetBookmarks(userId, documentId uint) ([]*models.Bookmark, error) {
+	var bookmarks []*models.Bookmark
+	err := r.DB.Where("user_id = ? AND document_id = ?", userId, documentId).Order("created_at, id").Find(&bookmarks).Error
+	return bookmarks, err
+}
+
+// GetBookmark returns the user's bookmark of the document with the given id
+func (r *BookmarkRepository) GetBookmark(userId, documentId, id uint) (*models.Bookmark, error) {
+	bookmark := new(models.Bookmark)
+	err := r.DB.Where("id = ? AND user_id = ? AND document_id = ?", id, userId, documentId).First(bookmark).Error
+	return bookmark, err
+}
+
+// UpdateBookmark saves the location and label of the bookmark
+func (r *BookmarkRepository) UpdateBookmark(bookmark *models.Bookmark) error {
+	return r.DB.Model(bookmark).Select("location", "label").Updates(bookmark).Error
+}
+
+// DeleteBookmark deletes the user's bookmark of the document.
+// Returns gorm.ErrRecordNotFound if the user has no such bookmark
+func (r *BookmarkRepository) DeleteBookmark(userId, documentId, id uint) error {
+	result := r.DB.Where("id = ? AND user_id = ? AND document_id = ?", id, userId, documentId).Delete(&models.Bookmark{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// DeleteBookmarksByUserId deletes the user's bookmarks and bookmarks of other users in the user's documents
+func (r *BookmarkRepository) DeleteBookmarksByUserId(userId uint) error {
+	return r.DB.Where(
+		"user_id = ? OR document_id IN (?)",
+		userId, r.DB.Model(&models.Document{}).Select("id").Where("user_id = ?", userId),
+	).Delete(&models.Bookmark{}).Error
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+)
+
+// CollectionRepository works with collections database. Every query is scoped by the owner of the collections
+type CollectionRepository struct {
+	DB *gorm.DB
+}
+
+// NewCollectionRepository creates a collection repository
+func NewCollectionRepository(db *gorm.DB) *CollectionRepository {
+	return &CollectionRepository{DB: db}
+}
+
+// CreateCollection inserts a new collection into the database
+func (r *CollectionRepository) CreateCollection(collection *models.Collection) error {
+	return r.DB.Create(collection).Error
+}
+
+// GetCollections returns all collections of the user ordered by name
+func (r *CollectionRepository) GetCollections(userId uint) ([]*models.Collection, error) {
+	var collections []*models.Collection
+	err := r.DB.Where("user_id = ?", userId).Order("name").Find(&collections).Error
+	return collections, err
+}
+
+// GetCollection returns the user's collection with the given id
+func (r *CollectionRepository) GetCollection(userId, id uint) (*models.Collection, error) {
+	var collection models.Collection
+	err := r.DB.Where("id = ? AND user_id = ?", id, userId).First(&collection).Error
+	return &collection, err
+}
+
+// UpdateCollection saves the name and the parent of the user's collection.
+// Returns gorm.ErrRecordNotFound if the user has no such collection
+func (r *CollectionRepository) UpdateCollection(collection *models.Collection) error {
+	result := r.DB.Model(&models.Collection{}).
+		Where("id = ? AND user_id = ?", collection.ID, collection.UserId).
+		Updates(map[string]interface{}{"name": collection.Name, "parent_id": collection.ParentId})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteCollections deletes the user's collections with the given ids
+func (r *CollectionRepository) DeleteCollections(userId uint, ids []uint) error {
+	return r.DB.Where("id IN ? AND user_id = ?", ids, userId).Delete(&models.Collection{}).Error
+}
+
+// DeleteCollectionsByUserId deletes all collections of the user
+func (r *CollectionRepository) DeleteCollectionsByUserId(userId uint) error {
+	return r.DB.Where("user_id = ?", userId).Delete(&models.Collection{}).Error
+}
+
+// GetDocumentIds returns ids of the user's documents belonging to any of the given collections
+func (r *CollectionRepository) GetDocumentIds(userId uint, collectionIds []uint) ([]uint, error) {
+	var ids []uint
+	err := r.DB.Table("document_collections").
+		Joins("JOIN documents ON documents.id = document_collections.document_id").
+		Where("document_collections.collection_id IN ? AND documents.user_id = ?", collectionIds, userId).
+		Distinct().
+		Pluck("document_collections.document_id", &ids).Error
+	return ids, err
+}
@@ -2,10 +2,13 @@ package repositories
 
 import (
 	"VerbiDocuments/internal/models"
+	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
-// DocumentRepository works with documents database
+// DocumentRepository works with documents database. Every query is scoped by the owner of the documents
 type DocumentRepository struct {
 	DB *gorm.DB
 }
@@ -17,6 +20,9 @@ func NewDocumentRepository(db *gorm.DB) *DocumentRepository {
 
 // CreateDocument inserts a new document into the database
 func (r *DocumentRepository) CreateDocument(document *models.Document) (uint, error) {
+	if document.Version == 0 {
+		document.Version = 1
+	}
 	err := r.DB.Create(document).Error
 	if err != nil {
 		return 0, err
@@ -24,24 +30,321 @@ func (r *DocumentRepository) CreateDocument(document *models.Document) (uint, er
 	return document.ID, nil
 }
 
-// GetDocumentsByUserId returns a list of all documents uploaded by the user
-func (r *DocumentRepository) GetDocumentsByUserId(userId uint) ([]*models.Document, error) {
+// withRelations loads tags and collections of the documents ordered by name
+func withRelations(db *gorm.DB) *gorm.DB {
+	byName := func(db *gorm.DB) *gorm.DB {
+		return db.Order("name")
+	}
+	return db.Preload("Tags", byName).Preload("Collections", byName)
+}
+
+// documentSortColumns maps sort keys of the document list to the sorted expressions
+var documentSortColumns = map[string]string{
+	models.SortByTitle:   "LOWER(documents.title)",
+	models.SortByCreated: "documents.created_at",
+	models.SortBySize:    "documents.file_size",
+	models.SortByOpened:  "COALESCE(reading_states.last_opened_at, '" + NeverOpened + "')",
+}
+
+// NeverOpened is the last opened time of documents without one in the document list sorted by it
+const NeverOpened = "1970-01-01 00:00:00+00:00"
+
+// IsDocumentSort reports whether documents can be sorted by the key
+func IsDocumentSort(sort string) bool {
+	_, ok := documentSortColumns[sort]
+	return ok
+}
+
+// filterDocuments returns a query of the user's documents outside the trash matching the filter
+func (r *DocumentRepository) filterDocuments(userId uint, filter *models.DocumentFilter) *gorm.DB {
+	query := r.DB.Model(&models.Document{}).Where("documents.user_id = ? AND documents.trashed_at IS NULL", userId)
+	if filter == nil {
+		return query
+	}
+	if filter.CollectionId != 0 {
+		query = query.Where("documents.id IN (?)",
+			r.DB.Table("document_collections").Select("document_id").Where("collection_id = ?", filter.CollectionId))
+	}
+	if filter.TagId != 0 {
+		query = query.Where("documents.id IN (?)",
+			r.DB.Table("document_tags").Select("document_id").Where("tag_id = ?", filter.TagId))
+	}
+	if filter.MimeType != "" {
+		query = query.Where("documents.mime_type = ?", filter.MimeType)
+	}
+	// Documents without a reading state are yet to be read
+	if filter.Status == models.ReadingStatusToRead {
+		query = query.Where("documents.id NOT IN (?)", r.DB.Model(&models.ReadingState{}).Select("document_id").
+			Where("user_id = ? AND status <> ?", userId, models.ReadingStatusToRead))
+	} else if filter.Status != "" {
+		query = query.Where("documents.id IN (?)", r.DB.Model(&models.ReadingState{}).Select("document_id").
+			Where("user_id = ? AND status = ?", userId, filter.Status))
+	}
+	return query
+}
+
+// GetDocumentsByUserId returns a list of all documents uploaded by the user outside the trash matching the filter
+func (r *DocumentRepository) GetDocumentsByUserId(userId uint, filter *models.DocumentFilter) ([]*models.Document, error) {
+	var documents []*models.Document
+	err := withRelations(r.filterDocuments(userId, filter)).Find(&documents).Error
+	return documents, err
+}
+
+// ListDocuments returns at most limit documents of the user matching the filter that follow the cursor in its sort order
+// with the user's reading states. Documents with equal sort values are ordered by id
+func (r *DocumentRepository) ListDocuments(
+	userId uint,
+	filter *models.DocumentFilter,
+	cursor *models.DocumentCursor,
+	limit int,
+) ([]*models.Document, error) {
+	column := documentSortColumns[cursor.Sort]
+	direction, compare := "ASC", ">"
+	if cursor.Descending {
+		direction, compare = "DESC", "<"
+	}
+
+	query := withRelations(r.filterDocuments(userId, filter)).Preload("ReadingState", "user_id = ?", userId)
+	if cursor.Sort == models.SortByOpened {
+		query = query.Select("documents.*").
+			Joins("LEFT JOIN reading_states ON reading_states.document_id = documents.id AND reading_states.user_id = ?", userId)
+	}
+	if cursor.Value != nil {
+		// Cursors keep raw titles, the database lowers them the same way as the sorted ones
+		value := "?"
+		if cursor.Sort == models.SortByTitle {
+			value = "LOWER(?)"
+		}
+		query = query.Where(
+			fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND documents.id %[2]s ?))", column, compare, value),
+			cursor.Value, cursor.Value, cursor.Id,
+		)
+	}
+
 	var documents []*models.Document
-	err := r.DB.Where("user_id = ?", userId).Find(&documents).Error
+	err := query.
+		Order(fmt.Sprintf("%s %s, documents.id %s", column, direction, direction)).
+		Limit(limit).
+		Find(&documents).Error
 	return documents, err
 }
 
-// DeleteDocument deletes a document from the database by id
-func (r *DocumentRepository) DeleteDocument(id uint) error {
-	return r.DB.Where("id = ?", id).Delete(&models.Document{}).Error
+// CountDocuments returns the number of the user's documents matching the filter
+func (r *DocumentRepository) CountDocuments(userId uint, filter *models.DocumentFilter) (int64, error) {
+	var count int64
+	err := r.filterDocuments(userId, filter).Count(&count).Error
+	return count, err
+}
+
+// DeleteDocument deletes the user's document from the database by id.
+// Returns gorm.ErrRecordNotFound if the user has no such document
+func (r *DocumentRepository) DeleteDocument(userId, id uint) error {
+	result := r.DB.Where("id = ? AND user_id = ?", id, userId).Delete(&models.Document{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteTrashedDocument permanently deletes the user's document in the trash from the database.
+// Returns gorm.ErrRecordNotFound if the user has no such document in the trash
+func (r *DocumentRepository) DeleteTrashedDocument(userId, id uint) error {
+	result := r.DB.Where("id = ? AND user_id = ? AND trashed_at IS NOT NULL", id, userId).Delete(&models.Document{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
 }
 
 // EraseLinkedByUserId deletes all user's documents from the database
-func (r *DocumentRepository) EraseLinkedByUserId(id uint) error {
-	return r.DB.Where("user_id = ?", id).Delete(&models.Document{}).Error
+func (r *DocumentRepository) EraseLinkedByUserId(userId uint) error {
+	return r.DB.Where("user_id = ?", userId).Delete(&models.Document{}).Error
+}
+
+// UpdateDocumentPath updates a path of the user's document with the given id in the database
+func (r *DocumentRepository) UpdateDocumentPath(userId, id uint, path string) error {
+	return r.DB.Model(&models.Document{}).Where("id = ? AND user_id = ?", id, userId).Update("path", path).Error
+}
+
+// GetDocument returns the user's document with the given id unless it is in the trash
+func (r *DocumentRepository) GetDocument(userId, id uint) (*models.Document, error) {
+	var document models.Document
+	err := withRelations(r.DB).Where("id = ? AND user_id = ? AND trashed_at IS NULL", id, userId).First(&document).Error
+	return &document, err
+}
+
+// GetDocumentByChecksum returns the user's document outside the trash whose current file has the SHA-256 checksum
+func (r *DocumentRepository) GetDocumentByChecksum(userId uint, sha256 string) (*models.Document, error) {
+	var document models.Document
+	err := r.DB.Where("user_id = ? AND sha256 = ? AND trashed_at IS NULL", userId, sha256).Order("id").First(&document).Error
+	return &document, err
+}
+
+// GetReadableDocument returns the document with the given id outside the trash if the user owns it
+// or it is shared with them
+func (r *DocumentRepository) GetReadableDocument(userId, id uint) (*models.Document, error) {
+	var document models.Document
+	err := withRelations(r.DB).
+		Where("id = ? AND trashed_at IS NULL", id).
+		Where("user_id = ? OR id IN (?)", userId, r.DB.Table("shares").Select("document_id").Where("user_id = ?", userId)).
+		First(&document).Error
+	return &document, err
+}
+
+// GetAnnotatableDocument returns the document with the given id outside the trash if the user owns it
+// or it is shared with them with the annotate permission
+func (r *DocumentRepository) GetAnnotatableDocument(userId, id uint) (*models.Document, error) {
+	var document models.Document
+	err := withRelations(r.DB).
+		Where("id = ? AND trashed_at IS NULL", id).
+		Where("user_id = ? OR id IN (?)", userId, r.DB.Table("shares").Select("document_id").
+			Where("user_id = ? AND permission = ?", userId, models.PermissionAnnotate)).
+		First(&document).Error
+	return &document, err
+}
+
+// TrashDocument moves the user's document to the trash.
+// Returns gorm.ErrRecordNotFound if the user has no such document outside the trash
+func (r *DocumentRepository) TrashDocument(userId, id uint, trashedAt time.Time) error {
+	result := r.DB.Model(&models.Document{}).
+		Where("id = ? AND user_id = ? AND trashed_at IS NULL", id, userId).
+		Updates(map[string]interface{}{"trashed_at": trashedAt, "version": gorm.Expr("version + 1")})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// RestoreDocument takes the user's document out of the trash.
+// Returns gorm.ErrRecordNotFound if the user has no such document in the trash
+func (r *DocumentRepository) RestoreDocument(userId, id uint) error {
+	result := r.DB.Model(&models.Document{}).
+		Where("id = ? AND user_id = ? AND trashed_at IS NOT NULL", id, userId).
+		Updates(map[string]interface{}{"trashed_at": nil, "version": gorm.Expr("version + 1")})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// GetTrashedDocuments returns the user's documents in the trash, recently trashed first
+func (r *DocumentRepository) GetTrashedDocuments(userId uint) ([]*models.Document, error) {
+	var documents []*models.Document
+	err := withRelations(r.DB).
+		Where("user_id = ? AND trashed_at IS NOT NULL", userId).
+		Order("trashed_at DESC, id").
+		Find(&documents).Error
+	return documents, err
+}
+
+// GetTrashedIds returns ids of the user's documents in the trash
+func (r *DocumentRepository) GetTrashedIds(userId uint) ([]uint, error) {
+	var ids []uint
+	err := r.DB.Model(&models.Document{}).
+		Where("user_id = ? AND trashed_at IS NOT NULL", userId).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetTrashedBefore returns documents of all users moved to the trash before the given time
+func (r *DocumentRepository) GetTrashedBefore(before time.Time) ([]*models.Document, error) {
+	var documents []*models.Document
+	err := r.DB.Where("trashed_at < ?", before).Order("id").Find(&documents).Error
+	return documents, err
+}
+
+// UpdateDocument saves all fields of the document except tags and increments its version if it still belongs
+// to its owner and nobody has changed it since it was read. Returns gorm.ErrRecordNotFound otherwise
+func (r *DocumentRepository) UpdateDocument(document *models.Document) error {
+	version := document.Version
+	document.Version++
+	result := r.DB.Model(&models.Document{}).
+		Where("id = ? AND user_id = ? AND version = ?", document.ID, document.UserId, version).
+		Select("*").
+		Omit("id", clause.Associations).
+		Updates(document)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = gorm.ErrRecordNotFound
+	}
+	if result.Error != nil {
+		document.Version = version
+		return result.Error
+	}
+	return nil
+}
+
+// ReplaceTags replaces all tags of the document with the given ones
+func (r *DocumentRepository) ReplaceTags(document *models.Document, tags []models.Tag) error {
+	err := r.DB.Model(document).Omit("Tags.*").Association("Tags").Replace(tags)
+	if err != nil {
+		return err
+	}
+	document.Tags = tags
+	return nil
+}
+
+// AddTag attaches the tag to the document
+func (r *DocumentRepository) AddTag(document *models.Document, tag *models.Tag) error {
+	return r.DB.Model(document).Omit("Tags.*").Association("Tags").Append(tag)
+}
+
+// RemoveTag detaches the tag from the document
+func (r *DocumentRepository) RemoveTag(document *models.Document, tag *models.Tag) error {
+	return r.DB.Model(document).Association("Tags").Delete(tag)
+}
+
+// AddToCollection puts the document into the collection
+func (r *DocumentRepository) AddToCollection(document *models.Document, collection *models.Collection) error {
+	return r.DB.Model(document).Omit("Collections.*").Association("Collections").Append(collection)
+}
+
+// RemoveFromCollection takes the document out of the collection
+func (r *DocumentRepository) RemoveFromCollection(document *models.Document, collection *models.Collection) error {
+	return r.DB.Model(document).Association("Collections").Delete(collection)
+}
+
+// GetAllDocuments returns documents of all users. It is only meant for maintenance tasks such as reconciliation
+func (r *DocumentRepository) GetAllDocuments() ([]*models.Document, error) {
+	var documents []*models.Document
+	err := r.DB.Order("id").Find(&documents).Error
+	return documents, err
+}
+
+// SetProcessingStatus sets the processing status of the user's document and the reason of a rejection
+func (r *DocumentRepository) SetProcessingStatus(userId, id uint, status, reason string) error {
+	return r.DB.Model(&models.Document{}).
+		Where("id = ? AND user_id = ?", id, userId).
+		Updates(map[string]interface{}{
+			"processing_status": status,
+			"rejection_reason":  reason,
+			"version":           gorm.Expr("version + 1"),
+		}).Error
+}
+
+// StartProcessing marks the user's document pending when its version equals the given one or the given version is zero.
+// Returns gorm.ErrRecordNotFound if the document does not exist or its version differs
+func (r *DocumentRepository) StartProcessing(userId, id, version uint) error {
+	query := r.DB.Model(&models.Document{}).Where("id = ? AND user_id = ? AND trashed_at IS NULL", id, userId)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+	result := query.Updates(map[string]interface{}{
+		"processing_status": models.ProcessingStatusPending,
+		"rejection_reason":  "",
+		"version":           gorm.Expr("version + 1"),
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
 }
 
-// UpdateDocumentPath updates a path of the document with the given id in the database
-func (r *DocumentRepository) UpdateDocumentPath(id uint, path string) error {
-	return r.DB.Model(&models.Document{}).Where("id = ?", id).Update("path", path).Error
+// SetBroken marks the user's document as having or not having its file missing from the storage
+func (r *DocumentRepository) SetBroken(userId, id uint, broken bool) error {
+	return r.DB.Model(&models.Document{}).
+		Where("id = ? AND user_id = ?", id, userId).
+		Updates(map[string]interface{}{"broken": broken, "version": gorm.Expr("version + 1")}).Error
 }
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+// IdempotencyRepository works with idempotency keys of document creation requests
+type IdempotencyRepository struct {
+	DB *gorm.DB
+}
+
+// NewIdempotencyRepository creates an idempotency repository
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+// GetKey returns the user's idempotency key created after the given time
+func (r *IdempotencyRepository) GetKey(userId uint, key string, after time.Time) (*models.IdempotencyKey, error) {
+	var idempotencyKey models.IdempotencyKey
+	err := r.DB.Where("user_id = ? AND key = ? AND created_at > ?", userId, key, after).First(&idempotencyKey).Error
+	return &idempotencyKey, err
+}
+
+// SaveKey inserts the idempotency key. Fails if the user already has the same key
+func (r *IdempotencyRepository) SaveKey(idempotencyKey *models.IdempotencyKey) error {
+	return r.DB.Create(idempotencyKey).Error
+}
+
+// DeleteExpiredKeys deletes the user's idempotency keys created before the given time
+func (r *IdempotencyRepository) DeleteExpiredKeys(userId uint, before time.Time) error {
+	return r.DB.Where("user_id = ? AND created_at <= ?", userId, before).Delete(&models.IdempotencyKey{}).Error
+}
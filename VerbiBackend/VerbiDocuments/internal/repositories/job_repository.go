@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// JobRepository works with background jobs database
+type JobRepository struct {
+	DB *gorm.DB
+}
+
+// NewJobRepository creates a job repository
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{DB: db}
+}
+
+// CreateJob saves a new job
+func (r *JobRepository) CreateJob(job *models.Job) error {
+	return r.DB.Create(job).Error
+}
+
+// GetJob returns the user's job with the given id
+func (r *JobRepository) GetJob(userId, id uint) (*models.Job, error) {
+	job := new(models.Job)
+	err := r.DB.Where("id = ? AND user_id = ?", id, userId).First(job).Error
+	return job, err
+}
+
+// GetJobs returns the user's jobs, newest first, optionally only the ones of the document or with the status
+func (r *JobRepository) GetJobs(userId, documentId uint, status string, limit int) ([]*models.Job, error) {
+	query := r.DB.Where("user_id = ?", userId)
+	if documentId != 0 {
+		query = query.Where("document_id = ?", documentId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var jobs []*models.Job
+	err := query.Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// ClaimJob takes the oldest due queued job of the type, marks it running for a new attempt leased until the given time
+// and returns it. Concurrent workers skip rows locked by each other. Returns gorm.ErrRecordNotFound if no job is due
+func (r *JobRepository) ClaimJob(jobType string, now, lockedUntil time.Time) (*models.Job, error) {
+	job := new(models.Job)
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND type = ? AND run_at <= ?", models.JobStatusQueued, jobType, now).
+			Order("run_at, id").
+			First(job).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(job).
+			Where("status = ?", models.JobStatusQueued).
+			Updates(map[string]interface{}{
+				"status":       models.JobStatusRunning,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"locked_until": lockedUntil,
+				"started_at":   now,
+			})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	job.Status = models.JobStatusRunning
+	job.Attempts++
+	job.LockedUntil = &lockedUntil
+	job.StartedAt = &now
+	return job, nil
+}
+
+// runningAttempt returns a query of the job if it is still running the attempt. Claiming a job again counts a new attempt,
+// so a worker whose lease expired does not change the job once another worker claimed it
+func (r *JobRepository) runningAttempt(id uint, attempt int) *gorm.DB {
+	return r.DB.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND attempts = ?", id, models.JobStatusRunning, attempt)
+}
+
+// IsJobRunning reports whether the job is still running the attempt, it is not once it was canceled
+func (r *JobRepository) IsJobRunning(id uint, attempt int) (bool, error) {
+	var count int64
+	err := r.runningAttempt(id, attempt).Count(&count).Error
+	return count > 0, err
+}
+
+// ExtendLease keeps the job running the attempt leased until the given time.
+// Returns gorm.ErrRecordNotFound if the job is not running the attempt anymore, e.g. because it was canceled
+func (r *JobRepository) ExtendLease(id uint, attempt int, lockedUntil time.Time) error {
+	result := r.runningAttempt(id, attempt).Update("locked_until", lockedUntil)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// FinishJob gives the job running the attempt its final status. A job canceled or claimed again meanwhile is left as is
+func (r *JobRepository) FinishJob(id uint, attempt int, status, lastError string, now time.Time) error {
+	return r.runningAttempt(id, attempt).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"last_error":   lastError,
+			"locked_until": nil,
+			"finished_at":  now,
+		}).Error
+}
+
+// RetryJob queues the job running the attempt again to run at the given time.
+// A job canceled or claimed again meanwhile is left as is
+func (r *JobRepository) RetryJob(id uint, attempt int, lastError string, runAt time.Time) error {
+	return r.runningAttempt(id, attempt).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusQueued,
+			"last_error":   lastError,
+			"locked_until": nil,
+			"run_at":       runAt,
+		}).Error
+}
+
+// CancelJob cancels the user's job unless it is finished. Returns gorm.ErrRecordNotFound if no such job can be canceled
+func (r *JobRepository) CancelJob(userId, id uint, now time.Time) error {
+	result := r.DB.Model(&models.Job{}).
+		Where("id = ? AND user_id = ? AND status IN ?", id, userId, []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusCanceled,
+			"locked_until": nil,
+			"finished_at":  now,
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// ReleaseExpiredJobs queues running jobs whose lease expired before now again, or fails them if they used all attempts.
+// Returns the number of released jobs
+func (r *JobRepository) ReleaseExpiredJobs(now time.Time) (int64, error) {
+	var released int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&models.Job{}).
+			Where("status = ? AND locked_until < ?", models.JobStatusRunning, now)
+
+		result := expired.Session(&gorm.Session{}).
+			Where("attempts >= max_attempts").
+			Updates(map[string]interface{}{
+				"status":       models.JobStatusFailed,
+				"last_error":   "lease expired",
+				"locked_until": nil,
+				"finished_at":  now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		released = result.RowsAffected
+
+		result = expired.Session(&gorm.Session{}).
+			Updates(map[string]interface{}{
+				"status":       models.JobStatusQueued,
+				"last_error":   "lease expired",
+				"locked_until": nil,
+				"run_at":       now,
+			})
+		released += result.RowsAffected
+		return result.Error
+	})
+	return released, err
+}
+
+// DeleteFinishedJobs deletes jobs that finished before the given time and returns how many were deleted
+func (r *JobRepository) DeleteFinishedJobs(before time.Time) (int64, error) {
+	result := r.DB.
+		Where("status IN ? AND finished_at < ?", []string{models.JobStatusSucceeded, models.JobStatusFailed, models.JobStatusCanceled}, before).
+		Delete(&models.Job{})
+	return result.RowsAffected, result.Error
+}
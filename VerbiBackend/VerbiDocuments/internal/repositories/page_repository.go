@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// searchConfig is the Postgres text search configuration of the page index.
+// The russian configuration stems Cyrillic words with the Russian stemmer and Latin words with the English one
+const searchConfig = "russian"
+
+// searchHeadline configures highlighting of matched words in search snippets
+const searchHeadline = "StartSel=<mark>, StopSel=</mark>, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// PageRepository works with the extracted text of document pages
+type PageRepository struct {
+	DB *gorm.DB
+}
+
+// NewPageRepository creates a page repository
+func NewPageRepository(db *gorm.DB) *PageRepository {
+	return &PageRepository{DB: db}
+}
+
+// ReplacePages replaces all pages of the user's document with the given ones in a single transaction.
+// Returns gorm.ErrRecordNotFound if the user has no such document
+func (r *PageRepository) ReplacePages(userId, documentId uint, pages []*models.DocumentPage) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("id = ? AND user_id = ?", documentId, userId).First(&models.Document{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("document_id = ?", documentId).Delete(&models.DocumentPage{}).Error
+		if err != nil {
+			return err
+		}
+		if len(pages) == 0 {
+			return nil
+		}
+
+		for _, page := range pages {
+			page.DocumentId = documentId
+		}
+		return tx.CreateInBatches(pages, 100).Error
+	})
+}
+
+// ownedPages returns a query of pages of the user's document
+func (r *PageRepository) ownedPages(userId, documentId uint) *gorm.DB {
+	return r.DB.Model(&models.DocumentPage{}).
+		Joins("JOIN documents ON documents.id = document_pages.document_id").
+		Where("document_pages.document_id = ? AND documents.user_id = ?", documentId, userId)
+}
+
+// GetPages returns pages of the user's document with numbers from the given range ordered by number
+func (r *PageRepository) GetPages(userId, documentId uint, from, to int) ([]*models.DocumentPage, error) {
+	var pages []*models.DocumentPage
+	err := r.ownedPages(userId, documentId).
+		Where("document_pages.number BETWEEN ? AND ?", from, to).
+		Order("document_pages.number").
+		Find(&pages).Error
+	return pages, err
+}
+
+// GetChapters returns numbers and chapters of all pages of the user's document without their text ordered by number
+func (r *PageRepository) GetChapters(userId, documentId uint) ([]*models.DocumentPage, error) {
+	var pages []*models.DocumentPage
+	err := r.ownedPages(userId, documentId).
+		Select("document_pages.number", "document_pages.chapter").
+		Order("document_pages.number").
+		Find(&pages).Error
+	return pages, err
+}
+
+// CountPages returns the number of pages with extracted text of the user's document
+func (r *PageRepository) CountPages(userId, documentId uint) (int, error) {
+	var count int64
+	err := r.ownedPages(userId, documentId).Count(&count).Error
+	return int(count), err
+}
+
+// CreateSearchIndex creates the full-text index of page texts if it does not exist
+func (r *PageRepository) CreateSearchIndex() error {
+	return r.DB.Exec(fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS idx_document_pages_search ON document_pages USING GIN (to_tsvector('%s', text))",
+		searchConfig,
+	)).Error
+}
+
+// SearchPages finds pages of the user's documents outside the trash matching the web search style query ordered by relevance.
+// Snippets are only built for the returned page of results
+func (r *PageRepository) SearchPages(userId uint, query string, limit, offset int) ([]*models.SearchHit, error) {
+	var hits []*models.SearchHit
+	err := r.DB.Raw(fmt.Sprintf(`
+SELECT hits.document_id, hits.title, hits.page_number, hits.chapter, hits.rank,
+	ts_headline('%[1]s', hits.text, hits.query, ?) AS snippet
+FROM (
+	SELECT p.document_id, d.title, p.number AS page_number, p.chapter, p.text, q.query,
+		ts_rank(to_tsvector('%[1]s', p.text), q.query) AS rank
+	FROM document_pages p
+	JOIN documents d ON d.id = p.document_id
+	CROSS JOIN websearch_to_tsquery('%[1]s', ?) AS q(query)
+	WHERE d.user_id = ? AND d.trashed_at IS NULL AND to_tsvector('%[1]s', p.text) @@ q.query
+	ORDER BY rank DESC, p.document_id, p.number
+	LIMIT ? OFFSET ?
+) AS hits
+ORDER BY hits.rank DESC, hits.document_id, hits.page_number`, searchConfig),
+		searchHeadline, query, userId, limit, offset,
+	).Scan(&hits).Error
+	return hits, err
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuotaRepository works with storage usage and quotas database
+type QuotaRepository struct {
+	DB *gorm.DB
+}
+
+// NewQuotaRepository creates a quota repository
+func NewQuotaRepository(db *gorm.DB) *QuotaRepository {
+	return &QuotaRepository{DB: db}
+}
+
+// BackfillUsage computes the usage of users who have documents but no usage record yet from their documents
+func (r *QuotaRepository) BackfillUsage() error {
+	return r.DB.Exec(`INSERT INTO storage_usages (user_id, bytes, documents)
+		SELECT user_id, COALESCE(SUM(file_size), 0), COUNT(*) FROM documents GROUP BY user_id
+		ON CONFLICT (user_id) DO NOTHING`).Error
+}
+
+// GetUsage returns the storage usage of the user, which is zero if nothing was recorded yet
+func (r *QuotaRepository) GetUsage(userId uint) (*models.StorageUsage, error) {
+	usage := &models.StorageUsage{UserId: userId}
+	err := r.DB.Where("user_id = ?", userId).First(usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return usage, nil
+	}
+	return usage, err
+}
+
+// AddUsage changes the storage usage of the user by the given amounts. With a quota, positive amounts are only added
+// if the usage stays within it, otherwise gorm.ErrRecordNotFound is returned
+func (r *QuotaRepository) AddUsage(userId uint, bytes, documents int64, quota *models.StorageQuota) error {
+	err := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.StorageUsage{UserId: userId}).Error
+	if err != nil {
+		return err
+	}
+
+	query := r.DB.Model(&models.StorageUsage{}).Where("user_id = ?", userId)
+	if quota != nil && quota.MaxBytes > 0 && bytes > 0 {
+		query = query.Where("bytes + ? <= ?", bytes, quota.MaxBytes)
+	}
+	if quota != nil && quota.MaxDocuments > 0 && documents > 0 {
+		query = query.Where("documents + ? <= ?", documents, quota.MaxDocuments)
+	}
+	result := query.Updates(map[string]interface{}{
+		"bytes":     gorm.Expr("bytes + ?", bytes),
+		"documents": gorm.Expr("documents + ?", documents),
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// GetQuota returns the quota set for the user
+func (r *QuotaRepository) GetQuota(userId uint) (*models.StorageQuota, error) {
+	quota := new(models.StorageQuota)
+	err := r.DB.Where("user_id = ?", userId).First(quota).Error
+	return quota, err
+}
+
+// SaveQuota creates or replaces the quota of the user
+func (r *QuotaRepository) SaveQuota(quota *models.StorageQuota) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_bytes", "max_documents"}),
+	}).Create(quota).Error
+}
+
+// DeleteQuota deletes the quota set for the user. Returns gorm.ErrRecordNotFound if there is none
+func (r *QuotaRepository) DeleteQuota(userId uint) error {
+	result := r.DB.Where("user_id = ?", userId).Delete(&models.StorageQuota{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// DeleteByUserId deletes the storage usage and the quota of the user
+func (r *QuotaRepository) DeleteByUserId(userId uint) error {
+	err := r.DB.Where("user_id = ?", userId).Delete(&models.StorageUsage{}).Error
+	if err != nil {
+		return err
+	}
+	return r.DB.Where("user_id = ?", userId).Delete(&models.StorageQuota{}).Error
+}
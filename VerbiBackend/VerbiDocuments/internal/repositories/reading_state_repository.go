@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ReadingStateRepository works with reading states database
+type ReadingStateRepository struct {
+	DB *gorm.DB
+}
+
+// NewReadingStateRepository creates a reading state repository
+func NewReadingStateRepository(db *gorm.DB) *ReadingStateRepository {
+	return &ReadingStateRepository{DB: db}
+}
+
+// SaveReadingState stores the state unless the stored state of the same user and document was changed later.
+// A missing last opened time keeps the stored one. Returns the state stored afterwards
+func (r *ReadingStateRepository) SaveReadingState(state *models.ReadingState) (*models.ReadingState, error) {
+	updates := clause.AssignmentColumns([]string{"location", "percentage", "status", "updated_at", "synced_at"})
+	updates = append(updates, clause.Assignment{
+		Column: clause.Column{Name: "last_opened_at"},
+		Value:  gorm.Expr("COALESCE(excluded.last_opened_at, reading_states.last_opened_at)"),
+	})
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "document_id"}, {Name: "user_id"}},
+		Where:     clause.Where{Exprs: []clause.Expression{gorm.Expr("reading_states.updated_at < excluded.updated_at")}},
+		DoUpdates: updates,
+	}).Create(state).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetReadingState(state.UserId, state.DocumentId)
+}
+
+// GetReadingState returns the user's state of the document
+func (r *ReadingStateRepository) GetReadingState(userId, documentId uint) (*models.ReadingState, error) {
+	state := new(models.ReadingState)
+	err := r.DB.Where("user_id = ? AND document_id = ?", userId, documentId).First(state).Error
+	return state, err
+}
+
+// GetReadingStates returns the user's states of documents outside the trash stored after since, if it is set,
+// in the order they were stored
+func (r *ReadingStateRepository) GetReadingStates(userId uint, since *time.Time) ([]*models.ReadingState, error) {
+	query := r.DB.Joins("Document").Where("reading_states.user_id = ? AND \"Document\".trashed_at IS NULL", userId)
+	if since != nil {
+		query = query.Where("reading_states.synced_at > ?", *since)
+	}
+	var states []*models.ReadingState
+	err := query.Order("reading_states.synced_at, reading_states.id").Find(&states).Error
+	return states, err
+}
+
+// DeleteReadingStatesByUserId deletes the user's reading states and states of other users in the user's documents
+func (r *ReadingStateRepository) DeleteReadingStatesByUserId(userId uint) error {
+	return r.DB.Where(
+		"user_id = ? OR document_id IN (?)",
+		userId, r.DB.Model(&models.Document{}).Select("id").Where("user_id = ?", userId),
+	).Delete(&models.ReadingState{}).Error
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"VerbiDocuments/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"os"
 )
 
@@ -16,7 +17,7 @@ func NewSftpRepository(db *gorm.DB) *SftpRepository {
 	return &SftpRepository{DB: db}
 }
 
-// SaveSftpCredentials saves temporary SFTP credentials for a user
+// SaveSftpCredentials saves temporary SFTP credentials for a user replacing the previous ones
 func (r *SftpRepository) SaveSftpCredentials(userId uint, username, password string) error {
 	credentials := models.SftpCredentials{
 		UserId:   userId,
@@ -26,7 +27,7 @@ func (r *SftpRepository) SaveSftpCredentials(userId uint, username, password str
 		Port:     os.Getenv("SFTP_PORT"),
 	}
 
-	return r.DB.Create(&credentials).Error
+	return r.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&credentials).Error
 }
 
 // GetSftpCredentials retrieves temporary sftp credentials for a user
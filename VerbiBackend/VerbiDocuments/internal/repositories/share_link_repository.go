@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ShareLinkRepository works with public share links database
+type ShareLinkRepository struct {
+	DB *gorm.DB
+}
+
+// NewShareLinkRepository creates a share link repository
+func NewShareLinkRepository(db *gorm.DB) *ShareLinkRepository {
+	return &ShareLinkRepository{DB: db}
+}
+
+// CreateLink inserts a new share link into the database
+func (r *ShareLinkRepository) CreateLink(link *models.ShareLink) error {
+	return r.DB.Create(link).Error
+}
+
+// GetLinks returns all links to the owner's document including revoked ones, newest first
+func (r *ShareLinkRepository) GetLinks(ownerId, documentId uint) ([]*models.ShareLink, error) {
+	var links []*models.ShareLink
+	err := r.DB.Where("owner_id = ? AND document_id = ?", ownerId, documentId).Order("id DESC").Find(&links).Error
+	return links, err
+}
+
+// GetLinkByKey returns the link with the given key
+func (r *ShareLinkRepository) GetLinkByKey(key string) (*models.ShareLink, error) {
+	var link models.ShareLink
+	err := r.DB.Where("link_key = ?", key).First(&link).Error
+	return &link, err
+}
+
+// RevokeLink marks the link to the owner's document revoked.
+// Returns gorm.ErrRecordNotFound if there is no such link that is not revoked yet
+func (r *ShareLinkRepository) RevokeLink(ownerId, documentId, id uint, revokedAt time.Time) error {
+	result := r.DB.Model(&models.ShareLink{}).
+		Where("id = ? AND owner_id = ? AND document_id = ? AND revoked_at IS NULL", id, ownerId, documentId).
+		Update("revoked_at", revokedAt)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// CountDownload increments the downloads of the link unless it has reached its download limit.
+// Returns gorm.ErrRecordNotFound if the limit is reached
+func (r *ShareLinkRepository) CountDownload(id uint, downloadedAt time.Time) error {
+	result := r.DB.Model(&models.ShareLink{}).
+		Where("id = ? AND (max_downloads = 0 OR downloads < max_downloads)", id).
+		Updates(map[string]interface{}{"downloads": gorm.Expr("downloads + 1"), "last_downloaded_at": downloadedAt})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// DeleteLinksByOwnerId deletes all links created by the user
+func (r *ShareLinkRepository) DeleteLinksByOwnerId(ownerId uint) error {
+	return r.DB.Where("owner_id = ?", ownerId).Delete(&models.ShareLink{}).Error
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShareRepository works with shares database
+type ShareRepository struct {
+	DB *gorm.DB
+}
+
+// NewShareRepository creates a share repository
+func NewShareRepository(db *gorm.DB) *ShareRepository {
+	return &ShareRepository{DB: db}
+}
+
+// SaveShare creates the share or updates the permission of an existing share of the document with the same user
+func (r *ShareRepository) SaveShare(share *models.Share) error {
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "document_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"username", "permission"}),
+	}).Create(share).Error
+	if err != nil {
+		return err
+	}
+	return r.DB.Where("document_id = ? AND user_id = ?", share.DocumentId, share.UserId).First(share).Error
+}
+
+// GetShares returns shares of the owner's document ordered by creation
+func (r *ShareRepository) GetShares(ownerId, documentId uint) ([]*models.Share, error) {
+	var shares []*models.Share
+	err := r.DB.Where("owner_id = ? AND document_id = ?", ownerId, documentId).Order("id").Find(&shares).Error
+	return shares, err
+}
+
+// DeleteShare deletes the share of the owner's document. Returns gorm.ErrRecordNotFound if there is no such share
+func (r *ShareRepository) DeleteShare(ownerId, documentId, id uint) error {
+	result := r.DB.Where("id = ? AND owner_id = ? AND document_id = ?", id, ownerId, documentId).Delete(&models.Share{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// GetSharedWithUser returns shares of documents outside the trash shared with the user, recently shared first
+func (r *ShareRepository) GetSharedWithUser(userId uint) ([]*models.Share, error) {
+	var shares []*models.Share
+	err := r.DB.Joins("Document").
+		Where("shares.user_id = ? AND \"Document\".trashed_at IS NULL", userId).
+		Order("shares.created_at DESC, shares.id").
+		Find(&shares).Error
+	return shares, err
+}
+
+// DeleteSharesByUserId deletes shares of the user's documents and shares of other documents with the user
+func (r *ShareRepository) DeleteSharesByUserId(userId uint) error {
+	return r.DB.Where("owner_id = ? OR user_id = ?", userId, userId).Delete(&models.Share{}).Error
+}
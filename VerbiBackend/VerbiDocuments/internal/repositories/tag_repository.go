@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagRepository works with tags database. Every query is scoped by the owner of the tags
+type TagRepository struct {
+	DB *gorm.DB
+}
+
+// NewTagRepository creates a tag repository
+func NewTagRepository(db *gorm.DB) *TagRepository {
+	return &TagRepository{DB: db}
+}
+
+// GetOrCreateTags returns the user's tags with the given names, creating the missing ones
+func (r *TagRepository) GetOrCreateTags(userId uint, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	if len(names) == 0 {
+		return tags, nil
+	}
+
+	for _, name := range names {
+		tags = append(tags, models.Tag{UserId: userId, Name: name})
+	}
+	err := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tags = tags[:0]
+	err = r.DB.Where("user_id = ? AND name IN ?", userId, names).Order("name").Find(&tags).Error
+	return tags, err
+}
+
+// GetTags returns all tags of the user ordered by name
+func (r *TagRepository) GetTags(userId uint) ([]*models.Tag, error) {
+	var tags []*models.Tag
+	err := r.DB.Where("user_id = ?", userId).Order("name").Find(&tags).Error
+	return tags, err
+}
+
+// GetTag returns the user's tag with the given id
+func (r *TagRepository) GetTag(userId, id uint) (*models.Tag, error) {
+	var tag models.Tag
+	err := r.DB.Where("id = ? AND user_id = ?", id, userId).First(&tag).Error
+	return &tag, err
+}
+
+// GetTagByName returns the user's tag with the given name
+func (r *TagRepository) GetTagByName(userId uint, name string) (*models.Tag, error) {
+	var tag models.Tag
+	err := r.DB.Where("user_id = ? AND name = ?", userId, name).First(&tag).Error
+	return &tag, err
+}
+
+// CreateTag inserts a new tag into the database
+func (r *TagRepository) CreateTag(tag *models.Tag) error {
+	return r.DB.Create(tag).Error
+}
+
+// RenameTag changes the name of the user's tag. Returns gorm.ErrRecordNotFound if the user has no such tag
+func (r *TagRepository) RenameTag(userId, id uint, name string) error {
+	result := r.DB.Model(&models.Tag{}).Where("id = ? AND user_id = ?", id, userId).Update("name", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteTag deletes the user's tag. Returns gorm.ErrRecordNotFound if the user has no such tag
+func (r *TagRepository) DeleteTag(userId, id uint) error {
+	result := r.DB.Where("id = ? AND user_id = ?", id, userId).Delete(&models.Tag{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteTagsByUserId deletes all tags of the user
+func (r *TagRepository) DeleteTagsByUserId(userId uint) error {
+	return r.DB.Where("user_id = ?", userId).Delete(&models.Tag{}).Error
+}
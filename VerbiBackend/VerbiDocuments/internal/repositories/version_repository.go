@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"VerbiDocuments/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VersionRepository works with document versions and the users' retention policies
+type VersionRepository struct {
+	DB *gorm.DB
+}
+
+// NewVersionRepository creates a version repository
+func NewVersionRepository(db *gorm.DB) *VersionRepository {
+	return &VersionRepository{DB: db}
+}
+
+// CreateVersion inserts a new version of the document numbered after its latest version. The document row stays locked
+// until the version is inserted, so concurrent versions of the document get consecutive numbers
+func (r *VersionRepository) CreateVersion(version *models.DocumentVersion) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", version.DocumentId).
+			Take(&models.Document{}).Error
+		if err != nil {
+			return err
+		}
+
+		var latest int
+		err = tx.Model(&models.DocumentVersion{}).
+			Where("document_id = ?", version.DocumentId).
+			Select("COALESCE(MAX(number), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+
+		version.Number = latest + 1
+		return tx.Create(version).Error
+	})
+}
+
+// GetLatestVersion returns the newest version of the document
+func (r *VersionRepository) GetLatestVersion(documentId uint) (*models.DocumentVersion, error) {
+	var version models.DocumentVersion
+	err := r.DB.Where("document_id = ?", documentId).Order("number DESC").First(&version).Error
+	return &version, err
+}
+
+// ownedVersions returns a query of versions of the user's document
+func (r *VersionRepository) ownedVersions(userId, documentId uint) *gorm.DB {
+	return r.DB.Model(&models.DocumentVersion{}).
+		Joins("JOIN documents ON documents.id = document_versions.document_id").
+		Where("document_versions.document_id = ? AND documents.user_id = ?", documentId, userId)
+}
+
+// GetVersions returns all versions of the user's document, newest first
+func (r *VersionRepository) GetVersions(userId, documentId uint) ([]*models.DocumentVersion, error) {
+	var versions []*models.DocumentVersion
+	err := r.ownedVersions(userId, documentId).Order("document_versions.number DESC").Find(&versions).Error
+	return versions, err
+}
+
+// GetVersion returns the version of the user's document with the given number
+func (r *VersionRepository) GetVersion(userId, documentId uint, number int) (*models.DocumentVersion, error) {
+	var version models.DocumentVersion
+	err := r.ownedVersions(userId, documentId).Where("document_versions.number = ?", number).First(&version).Error
+	return &version, err
+}
+
+// DeleteVersions deletes versions by their ids
+func (r *VersionRepository) DeleteVersions(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.DB.Delete(&models.DocumentVersion{}, ids).Error
+}
+
+// CountVersionsWithChecksum returns the number of versions of the document sharing the content with the given checksum
+func (r *VersionRepository) CountVersionsWithChecksum(documentId uint, sha256 string) (int, error) {
+	var count int64
+	err := r.DB.Model(&models.DocumentVersion{}).Where("document_id = ? AND sha256 = ?", documentId, sha256).Count(&count).Error
+	return int(count), err
+}
+
+// GetRetentionPolicy returns the retention policy of the user or gorm.ErrRecordNotFound if the user has not set one
+func (r *VersionRepository) GetRetentionPolicy(userId uint) (*models.RetentionPolicy, error) {
+	var policy models.RetentionPolicy
+	err := r.DB.Where("user_id = ?", userId).First(&policy).Error
+	return &policy, err
+}
+
+// SaveRetentionPolicy creates or replaces the retention policy of the user
+func (r *VersionRepository) SaveRetentionPolicy(policy *models.RetentionPolicy) error {
+	return r.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(policy).Error
+}
+
+// DeleteRetentionPolicy deletes the retention policy of the user
+func (r *VersionRepository) DeleteRetentionPolicy(userId uint) error {
+	return r.DB.Where("user_id = ?", userId).Delete(&models.RetentionPolicy{}).Error
+}
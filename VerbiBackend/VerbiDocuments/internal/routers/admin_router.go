@@ -0,0 +1,23 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// SetupAdminRoutes sets up the routes for maintenance actions and the metrics endpoint, all requiring the admin token
+func SetupAdminRoutes(r *gin.Engine, adminController *controllers.AdminController) {
+	r.GET("/metrics", middleware.AdminMiddleware(), gin.WrapH(promhttp.Handler()))
+
+	adminGroup := r.Group("/api/v1/admin")
+	adminGroup.Use(middleware.AdminMiddleware())
+	{
+		adminGroup.GET("/reconcile", adminController.GetReconcileReport)
+		adminGroup.POST("/reconcile", adminController.Reconcile)
+		adminGroup.GET("/quotas/:userId", adminController.GetUserQuota)
+		adminGroup.PUT("/quotas/:userId", adminController.UpdateUserQuota)
+		adminGroup.DELETE("/quotas/:userId", adminController.ResetUserQuota)
+	}
+}
@@ -0,0 +1,32 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAnnotationRoutes sets up the routes for highlights, notes and bookmarks in documents.
+// All of them act on behalf of the user authenticated by the access token
+func SetupAnnotationRoutes(
+	r *gin.Engine,
+	annotationController *controllers.AnnotationController,
+	bookmarkController *controllers.BookmarkController,
+) {
+	api := r.Group("/api/v1")
+
+	annotationGroup := api.Group("/documents")
+	annotationGroup.Use(middleware.AuthMiddleware())
+	{
+		annotationGroup.GET("/:id/annotations", annotationController.GetAnnotations)
+		annotationGroup.POST("/:id/annotations", annotationController.CreateAnnotation)
+		annotationGroup.GET("/:id/annotations/export", annotationController.ExportAnnotations)
+		annotationGroup.GET("/:id/annotations/:annotationId", annotationController.GetAnnotation)
+		annotationGroup.PATCH("/:id/annotations/:annotationId", annotationController.UpdateAnnotation)
+		annotationGroup.DELETE("/:id/annotations/:annotationId", annotationController.DeleteAnnotation)
+		annotationGroup.GET("/:id/bookmarks", bookmarkController.GetBookmarks)
+		annotationGroup.POST("/:id/bookmarks", bookmarkController.CreateBookmark)
+		annotationGroup.PATCH("/:id/bookmarks/:bookmarkId", bookmarkController.UpdateBookmark)
+		annotationGroup.DELETE("/:id/bookmarks/:bookmarkId", bookmarkController.DeleteBookmark)
+	}
+}
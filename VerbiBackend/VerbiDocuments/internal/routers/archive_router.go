@@ -0,0 +1,20 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupArchiveRoutes sets up the routes for exporting and importing the library as a portable archive.
+// All of them act on behalf of the user authenticated by the access token
+func SetupArchiveRoutes(r *gin.Engine, archiveController *controllers.ArchiveController) {
+	api := r.Group("/api/v1")
+
+	archiveGroup := api.Group("/documents")
+	archiveGroup.Use(middleware.AuthMiddleware())
+	{
+		archiveGroup.GET("/export", archiveController.ExportLibrary)
+		archiveGroup.POST("/import", archiveController.ImportLibrary)
+	}
+}
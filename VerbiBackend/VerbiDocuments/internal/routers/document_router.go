@@ -2,19 +2,37 @@ package routers
 
 import (
 	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes sets up the routes for document management actions
+// SetupRoutes sets up the routes for document management actions.
+// All of them act on behalf of the user authenticated by the access token
 func SetupRoutes(r *gin.Engine, documentController *controllers.DocumentController) {
 	api := r.Group("/api/v1")
 
 	documentGroup := api.Group("/documents")
+	documentGroup.Use(middleware.AuthMiddleware())
 	{
 		documentGroup.POST("/", documentController.CreateDocument)
-		documentGroup.GET("/:userId", documentController.GetDocuments)
-		documentGroup.DELETE("/:userId", documentController.DeleteDocument)
-		documentGroup.GET("/credentials", documentController.GetCredentials)
+		documentGroup.GET("/", documentController.GetDocuments)
 		documentGroup.DELETE("/", documentController.EraseLinkedByUserId)
+		documentGroup.GET("/credentials", documentController.GetCredentials)
+		documentGroup.GET("/search", documentController.SearchDocuments)
+		documentGroup.GET("/usage", documentController.GetUsage)
+		documentGroup.GET("/retention", documentController.GetRetentionPolicy)
+		documentGroup.PUT("/retention", documentController.UpdateRetentionPolicy)
+		documentGroup.GET("/trash", documentController.GetTrash)
+		documentGroup.DELETE("/trash", documentController.EmptyTrash)
+		documentGroup.GET("/:id", documentController.GetDocument)
+		documentGroup.PATCH("/:id", documentController.UpdateDocument)
+		documentGroup.DELETE("/:id", documentController.DeleteDocument)
+		documentGroup.POST("/:id/restore", documentController.RestoreDocument)
+		documentGroup.PUT("/:id/file", documentController.ReplaceFile)
+		documentGroup.GET("/:id/versions", documentController.GetVersions)
+		documentGroup.GET("/:id/versions/:number", documentController.DownloadVersion)
+		documentGroup.POST("/:id/versions/:number/restore", documentController.RestoreVersion)
+		documentGroup.GET("/:id/text", documentController.GetText)
+		documentGroup.GET("/:id/cover", documentController.GetCover)
 	}
 }
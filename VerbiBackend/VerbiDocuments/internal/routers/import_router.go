@@ -0,0 +1,21 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupImportRoutes sets up the routes for importing documents from URLs and OPDS catalogs.
+// All of them act on behalf of the user authenticated by the access token
+func SetupImportRoutes(r *gin.Engine, importController *controllers.ImportController) {
+	api := r.Group("/api/v1")
+
+	importGroup := api.Group("/documents")
+	importGroup.Use(middleware.AuthMiddleware())
+	{
+		importGroup.POST("/import-url", importController.ImportUrl)
+		importGroup.GET("/opds", importController.GetCatalog)
+		importGroup.POST("/opds/acquire", importController.AcquireEntry)
+	}
+}
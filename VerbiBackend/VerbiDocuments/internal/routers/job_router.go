@@ -0,0 +1,21 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupJobRoutes sets up the routes for background processing jobs of documents.
+// All of them act on behalf of the user authenticated by the access token
+func SetupJobRoutes(r *gin.Engine, jobController *controllers.JobController) {
+	api := r.Group("/api/v1")
+
+	jobGroup := api.Group("/documents/jobs")
+	jobGroup.Use(middleware.AuthMiddleware())
+	{
+		jobGroup.GET("/", jobController.GetJobs)
+		jobGroup.GET("/:jobId", jobController.GetJob)
+		jobGroup.POST("/:jobId/cancel", jobController.CancelJob)
+	}
+}
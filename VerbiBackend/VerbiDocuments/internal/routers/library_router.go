@@ -0,0 +1,38 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupLibraryRoutes sets up the routes for organizing documents into collections and tagging them.
+// All of them act on behalf of the user authenticated by the access token
+func SetupLibraryRoutes(
+	r *gin.Engine,
+	collectionController *controllers.CollectionController,
+	tagController *controllers.TagController,
+) {
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthMiddleware())
+
+	collectionGroup := api.Group("/collections")
+	{
+		collectionGroup.GET("/", collectionController.GetCollections)
+		collectionGroup.POST("/", collectionController.CreateCollection)
+		collectionGroup.PATCH("/:id", collectionController.UpdateCollection)
+		collectionGroup.DELETE("/:id", collectionController.DeleteCollection)
+		collectionGroup.PUT("/:id/documents/:documentId", collectionController.AddDocument)
+		collectionGroup.DELETE("/:id/documents/:documentId", collectionController.RemoveDocument)
+	}
+
+	tagGroup := api.Group("/tags")
+	{
+		tagGroup.GET("/", tagController.GetTags)
+		tagGroup.POST("/", tagController.CreateTag)
+		tagGroup.PATCH("/:id", tagController.RenameTag)
+		tagGroup.DELETE("/:id", tagController.DeleteTag)
+		tagGroup.PUT("/:id/documents/:documentId", tagController.AddDocument)
+		tagGroup.DELETE("/:id/documents/:documentId", tagController.RemoveDocument)
+	}
+}
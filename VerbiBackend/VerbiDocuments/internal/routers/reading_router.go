@@ -0,0 +1,21 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupReadingRoutes sets up the routes for syncing reading progress.
+// All of them act on behalf of the user authenticated by the access token
+func SetupReadingRoutes(r *gin.Engine, readingController *controllers.ReadingController) {
+	api := r.Group("/api/v1")
+
+	readingGroup := api.Group("/documents")
+	readingGroup.Use(middleware.AuthMiddleware())
+	{
+		readingGroup.GET("/reading-states", readingController.GetReadingStates)
+		readingGroup.GET("/:id/reading-state", readingController.GetReadingState)
+		readingGroup.PUT("/:id/reading-state", readingController.UpdateReadingState)
+	}
+}
@@ -0,0 +1,24 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupShareLinkRoutes sets up the routes for managing public links to documents, which act on behalf of the user
+// authenticated by the access token, and the public routes serving files and text of the links without authentication
+func SetupShareLinkRoutes(r *gin.Engine, shareLinkController *controllers.ShareLinkController) {
+	api := r.Group("/api/v1")
+
+	linkGroup := api.Group("/documents")
+	linkGroup.Use(middleware.AuthMiddleware())
+	{
+		linkGroup.GET("/:id/links", shareLinkController.GetShareLinks)
+		linkGroup.POST("/:id/links", shareLinkController.CreateShareLink)
+		linkGroup.DELETE("/:id/links/:linkId", shareLinkController.RevokeShareLink)
+	}
+
+	api.GET("/links/:token", shareLinkController.DownloadShareLink)
+	api.GET("/links/:token/text", shareLinkController.DownloadShareLinkText)
+}
@@ -0,0 +1,22 @@
+package routers
+
+import (
+	"VerbiDocuments/internal/controllers"
+	"VerbiDocuments/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupShareRoutes sets up the routes for sharing documents with other users.
+// All of them act on behalf of the user authenticated by the access token
+func SetupShareRoutes(r *gin.Engine, shareController *controllers.ShareController) {
+	api := r.Group("/api/v1")
+
+	shareGroup := api.Group("/documents")
+	shareGroup.Use(middleware.AuthMiddleware())
+	{
+		shareGroup.GET("/shared", shareController.GetSharedWithMe)
+		shareGroup.GET("/:id/shares", shareController.GetShares)
+		shareGroup.POST("/:id/shares", shareController.ShareDocument)
+		shareGroup.DELETE("/:id/shares/:shareId", shareController.RevokeShare)
+	}
+}
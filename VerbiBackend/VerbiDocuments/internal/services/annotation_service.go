@@ -0,0 +1,292 @@
+package services
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"bytes"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// ErrAnnotationNotFound is returned when the user has no annotation with the requested id in the document
+var ErrAnnotationNotFound = errors.New("annotation not found")
+
+// ErrInvalidAnnotation is returned when the anchor of a new annotation does not match the document
+var ErrInvalidAnnotation = errors.New("invalid annotation")
+
+// ErrAnnotationForbidden is returned when a document is shared with the user without the annotate permission
+var ErrAnnotationForbidden = errors.New("document is shared without the annotate permission")
+
+// AnnotationService handles the user's highlights and notes in their own documents and documents shared with them
+type AnnotationService struct {
+	AnnotationRepository *repositories.AnnotationRepository
+	DocumentRepository   *repositories.DocumentRepository
+	PageRepository       *repositories.PageRepository
+}
+
+// NewAnnotationService creates a new AnnotationService
+func NewAnnotationService(
+	annotationRepository *repositories.AnnotationRepository,
+	documentRepository *repositories.DocumentRepository,
+	pageRepository *repositories.PageRepository,
+) *AnnotationService {
+	return &AnnotationService{
+		AnnotationRepository: annotationRepository,
+		DocumentRepository:   documentRepository,
+		PageRepository:       pageRepository,
+	}
+}
+
+// getReadableDocument returns the document the user can read mapping a missing one to ErrDocumentNotFound
+func (s *AnnotationService) getReadableDocument(userId, documentId uint) (*models.Document, error) {
+	document, err := s.DocumentRepository.GetReadableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	return document, nil
+}
+
+// getAnnotatableDocument returns the document the user can annotate. A document the user can only read
+// gives ErrAnnotationForbidden
+func (s *AnnotationService) getAnnotatableDocument(userId, documentId uint) (*models.Document, error) {
+	document, err := s.DocumentRepository.GetAnnotatableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_, err = s.getReadableDocument(userId, documentId)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrAnnotationForbidden
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	return document, nil
+}
+
+// CreateAnnotation highlights text in the document. When the text of the page is extracted, the offsets must be
+// within it and the highlighted text is taken from it if not given
+func (s *AnnotationService) CreateAnnotation(
+	userId, documentId uint,
+	req *requests.CreateAnnotationRequest,
+) (*models.Annotation, error) {
+	document, err := s.getAnnotatableDocument(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Page < 1 || document.Metadata.PageCount > 0 && req.Page > document.Metadata.PageCount {
+		return nil, fmt.Errorf("%w: document has no page %d", ErrInvalidAnnotation, req.Page)
+	}
+	if req.StartOffset < 0 || req.EndOffset < req.StartOffset {
+		return nil, fmt.Errorf("%w: start_offset must not be after end_offset", ErrInvalidAnnotation)
+	}
+	text := req.Text
+	if req.EndOffset > 0 {
+		pages, err := s.PageRepository.GetPages(document.UserId, documentId, req.Page, req.Page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve page: %w", err)
+		}
+		if len(pages) > 0 {
+			pageText := []rune(pages[0].Text)
+			if req.EndOffset > len(pageText) {
+				return nil, fmt.Errorf("%w: page %d has %d characters", ErrInvalidAnnotation, req.Page, len(pageText))
+			}
+			if text == "" {
+				text = string(pageText[req.StartOffset:req.EndOffset])
+			}
+		}
+	}
+
+	annotation := &models.Annotation{
+		DocumentId:  documentId,
+		UserId:      userId,
+		Page:        req.Page,
+		StartOffset: req.StartOffset,
+		EndOffset:   req.EndOffset,
+		Text:        text,
+		Color:       req.Color,
+		Note:        req.Note,
+		LlmResponse: req.LlmResponse,
+	}
+	if annotation.Color == "" {
+		annotation.Color = models.DefaultAnnotationColor
+	}
+	err = s.AnnotationRepository.CreateAnnotation(annotation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save annotation: %w", err)
+	}
+	return annotation, nil
+}
+
+// GetAnnotations returns the user's annotations of the document in reading order
+func (s *AnnotationService) GetAnnotations(userId, documentId uint) ([]*models.Annotation, error) {
+	_, err := s.getReadableDocument(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations, err := s.AnnotationRepository.GetAnnotations(userId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+// getAnnotation returns the user's annotation of the document mapping a missing one to ErrAnnotationNotFound
+func (s *AnnotationService) getAnnotation(userId, documentId, id uint) (*models.Annotation, error) {
+	annotation, err := s.AnnotationRepository.GetAnnotation(userId, documentId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAnnotationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotation: %w", err)
+	}
+	return annotation, nil
+}
+
+// GetAnnotation returns the user's annotation of the document
+func (s *AnnotationService) GetAnnotation(userId, documentId, id uint) (*models.Annotation, error) {
+	_, err := s.getReadableDocument(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+	return s.getAnnotation(userId, documentId, id)
+}
+
+// UpdateAnnotation applies the user's edits to the colour, note and LLM response of the annotation
+func (s *AnnotationService) UpdateAnnotation(
+	userId, documentId, id uint,
+	req *requests.UpdateAnnotationRequest,
+) (*models.Annotation, error) {
+	_, err := s.getAnnotatableDocument(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+	annotation, err := s.getAnnotation(userId, documentId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Color != nil {
+		annotation.Color = *req.Color
+	}
+	if req.Note != nil {
+		annotation.Note = *req.Note
+	}
+	if req.LlmResponse != nil {
+		annotation.LlmResponse = *req.LlmResponse
+	}
+	err = s.AnnotationRepository.UpdateAnnotation(annotation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update annotation: %w", err)
+	}
+	return annotation, nil
+}
+
+// DeleteAnnotation deletes the user's annotation of the document
+func (s *AnnotationService) DeleteAnnotation(userId, documentId, id uint) error {
+	_, err := s.getAnnotatableDocument(userId, documentId)
+	if err != nil {
+		return err
+	}
+
+	err = s.AnnotationRepository.DeleteAnnotation(userId, documentId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAnnotationNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete annotation: %w", err)
+	}
+	return nil
+}
+
+// ExportAnnotations returns the user's annotations of the document grouped by the chapters of their pages.
+// Annotations on pages without a chapter, like pages of most PDF documents, are grouped by page
+func (s *AnnotationService) ExportAnnotations(userId, documentId uint) (*models.AnnotationExport, error) {
+	document, err := s.getReadableDocument(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := s.AnnotationRepository.GetAnnotations(userId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotations: %w", err)
+	}
+	pages, err := s.PageRepository.GetChapters(document.UserId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve chapters: %w", err)
+	}
+	chapters := make(map[int]string, len(pages))
+	for _, page := range pages {
+		chapters[page.Number] = page.Chapter
+	}
+
+	export := &models.AnnotationExport{
+		DocumentId: documentId,
+		Title:      document.Title,
+		Author:     document.Metadata.Author,
+		ExportedAt: time.Now().UTC(),
+		Sections:   []*models.AnnotationSection{},
+	}
+	var section *models.AnnotationSection
+	for _, annotation := range annotations {
+		chapter := chapters[annotation.Page]
+		sameSection := section != nil && section.Chapter == chapter && (chapter != "" || section.Page == annotation.Page)
+		if !sameSection {
+			section = &models.AnnotationSection{Chapter: chapter, Page: annotation.Page}
+			export.Sections = append(export.Sections, section)
+		}
+		section.Annotations = append(section.Annotations, annotation)
+	}
+	return export, nil
+}
+
+// AnnotationsMarkdown renders exported annotations as a Markdown document with a heading per section,
+// highlighted text as quotes followed by notes and LLM responses
+func AnnotationsMarkdown(export *models.AnnotationExport) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n", export.Title)
+	if export.Author != "" {
+		fmt.Fprintf(&b, "\n*%s*\n", export.Author)
+	}
+
+	for _, section := range export.Sections {
+		if section.Chapter != "" {
+			fmt.Fprintf(&b, "\n## %s\n", section.Chapter)
+		} else {
+			fmt.Fprintf(&b, "\n## Page %d\n", section.Page)
+		}
+		for _, annotation := range section.Annotations {
+			b.WriteString("\n")
+			if annotation.Text != "" {
+				b.WriteString(markdownQuote(annotation.Text))
+				b.WriteString("\n")
+			}
+			if section.Chapter != "" {
+				fmt.Fprintf(&b, "\n*Page %d*\n", annotation.Page)
+			}
+			if annotation.Note != "" {
+				fmt.Fprintf(&b, "\n%s\n", annotation.Note)
+			}
+			if annotation.LlmResponse != "" {
+				fmt.Fprintf(&b, "\n**LLM response:**\n\n%s\n", annotation.LlmResponse)
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+// markdownQuote formats the text as a Markdown block quote
+func markdownQuote(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
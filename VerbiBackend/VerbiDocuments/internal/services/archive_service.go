@@ -0,0 +1,596 @@
+package services
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"time"
+)
+
+// ErrInvalidArchive is returned when an imported library archive is not a ZIP file with a valid manifest
+var ErrInvalidArchive = errors.New("invalid library archive")
+
+// manifestName is the name of the manifest in a library archive
+const manifestName = "manifest.json"
+
+// maxManifestSize is the largest accepted manifest of a library archive in bytes.
+// It also bounds the ZIP structure an archive may add to the files it holds
+const maxManifestSize = 8 << 20
+
+// ArchiveService exports the user's library as a ZIP archive of the document files and a JSON manifest
+// of everything the user attached to them, and imports such archives into a library
+type ArchiveService struct {
+	DocumentService        *DocumentService
+	CollectionRepository   *repositories.CollectionRepository
+	TagRepository          *repositories.TagRepository
+	ReadingStateRepository *repositories.ReadingStateRepository
+	AnnotationRepository   *repositories.AnnotationRepository
+	BookmarkRepository     *repositories.BookmarkRepository
+}
+
+// NewArchiveService creates a new ArchiveService
+func NewArchiveService(
+	documentService *DocumentService,
+	collectionRepository *repositories.CollectionRepository,
+	tagRepository *repositories.TagRepository,
+	readingStateRepository *repositories.ReadingStateRepository,
+	annotationRepository *repositories.AnnotationRepository,
+	bookmarkRepository *repositories.BookmarkRepository,
+) *ArchiveService {
+	return &ArchiveService{
+		DocumentService:        documentService,
+		CollectionRepository:   collectionRepository,
+		TagRepository:          tagRepository,
+		ReadingStateRepository: readingStateRepository,
+		AnnotationRepository:   annotationRepository,
+		BookmarkRepository:     bookmarkRepository,
+	}
+}
+
+// PrepareExport collects the manifest of the user's library: all documents outside the trash with their metadata,
+// tags, collections, reading progress, annotations and bookmarks, as well as all tags and collections of the user
+func (s *ArchiveService) PrepareExport(userId uint) (*models.LibraryManifest, error) {
+	documents, err := s.DocumentService.DocumentRepository.GetDocumentsByUserId(userId, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve documents: %w", err)
+	}
+	tags, err := s.TagRepository.GetTags(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tags: %w", err)
+	}
+	collections, err := s.CollectionRepository.GetCollections(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve collections: %w", err)
+	}
+	states, err := s.ReadingStateRepository.GetReadingStates(userId, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve reading states: %w", err)
+	}
+	readings := make(map[uint]*models.ReadingState, len(states))
+	for _, state := range states {
+		readings[state.DocumentId] = state
+	}
+
+	manifest := &models.LibraryManifest{
+		Version:     models.LibraryArchiveVersion,
+		ExportedAt:  time.Now(),
+		Tags:        make([]string, 0, len(tags)),
+		Collections: archiveCollections(collections),
+		Documents:   make([]*models.ArchivedDocument, 0, len(documents)),
+	}
+	for _, tag := range tags {
+		manifest.Tags = append(manifest.Tags, tag.Name)
+	}
+	for _, document := range documents {
+		archived, err := s.archiveDocument(document, readings[document.ID])
+		if err != nil {
+			return nil, err
+		}
+		manifest.Documents = append(manifest.Documents, archived)
+	}
+	return manifest, nil
+}
+
+// archiveCollections lists the collections with every parent before its children
+func archiveCollections(collections []*models.Collection) []*models.ArchivedCollection {
+	children := make(map[uint][]*models.Collection)
+	for _, collection := range collections {
+		var parentId uint
+		if collection.ParentId != nil {
+			parentId = *collection.ParentId
+		}
+		children[parentId] = append(children[parentId], collection)
+	}
+
+	archived := make([]*models.ArchivedCollection, 0, len(collections))
+	var visit func(parentId uint)
+	visit = func(parentId uint) {
+		for _, collection := range children[parentId] {
+			archived = append(archived, &models.ArchivedCollection{
+				Id:       collection.ID,
+				ParentId: collection.ParentId,
+				Name:     collection.Name,
+			})
+			visit(collection.ID)
+		}
+	}
+	visit(0)
+	return archived
+}
+
+// archiveDocument describes the user's document with its reading state, if there is one, in a library archive
+func (s *ArchiveService) archiveDocument(document *models.Document, reading *models.ReadingState) (*models.ArchivedDocument, error) {
+	archived := &models.ArchivedDocument{
+		Id:          document.ID,
+		Title:       document.Title,
+		Notes:       document.Notes,
+		FileName:    document.FileName,
+		Metadata:    document.Metadata,
+		Tags:        make([]string, 0, len(document.Tags)),
+		Collections: make([]uint, 0, len(document.Collections)),
+		Annotations: []*models.ArchivedNote{},
+		Bookmarks:   []*models.ArchivedBookmark{},
+		CreatedAt:   document.CreatedAt,
+	}
+	if document.FileName != "" {
+		archived.File = fmt.Sprintf("files/%d/%s", document.ID, document.FileName)
+	}
+	for _, tag := range document.Tags {
+		archived.Tags = append(archived.Tags, tag.Name)
+	}
+	for _, collection := range document.Collections {
+		archived.Collections = append(archived.Collections, collection.ID)
+	}
+	if reading != nil {
+		archived.ReadingState = &models.ArchivedReading{
+			Location:     reading.Location,
+			Percentage:   reading.Percentage,
+			Status:       reading.Status,
+			LastOpenedAt: reading.LastOpenedAt,
+			UpdatedAt:    reading.UpdatedAt,
+		}
+	}
+
+	annotations, err := s.AnnotationRepository.GetAnnotations(document.UserId, document.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotations of document %d: %w", document.ID, err)
+	}
+	for _, annotation := range annotations {
+		archived.Annotations = append(archived.Annotations, &models.ArchivedNote{
+			Page:        annotation.Page,
+			StartOffset: annotation.StartOffset,
+			EndOffset:   annotation.EndOffset,
+			Text:        annotation.Text,
+			Color:       annotation.Color,
+			Note:        annotation.Note,
+			LlmResponse: annotation.LlmResponse,
+			CreatedAt:   annotation.CreatedAt,
+		})
+	}
+
+	bookmarks, err := s.BookmarkRepository.GetBookmarks(document.UserId, document.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve bookmarks of document %d: %w", document.ID, err)
+	}
+	for _, bookmark := range bookmarks {
+		archived.Bookmarks = append(archived.Bookmarks, &models.ArchivedBookmark{
+			Location:  bookmark.Location,
+			Label:     bookmark.Label,
+			CreatedAt: bookmark.CreatedAt,
+		})
+	}
+	return archived, nil
+}
+
+// WriteArchive streams the files of the manifest's documents followed by the manifest itself as a ZIP archive.
+// The manifest comes last, so documents whose file has disappeared from the storage meanwhile are listed without one
+func (s *ArchiveService) WriteArchive(userId uint, manifest *models.LibraryManifest, writer io.Writer) error {
+	archive := zip.NewWriter(writer)
+	for _, document := range manifest.Documents {
+		if document.File == "" {
+			continue
+		}
+		written, err := s.writeFile(archive, userId, document)
+		if err != nil {
+			return err
+		}
+		if !written {
+			log.Printf("file of document %d of user %d is missing from the storage", document.Id, userId)
+			document.File = ""
+		}
+	}
+
+	entry, err := archive.Create(manifestName)
+	if err != nil {
+		return fmt.Errorf("failed to add manifest to archive: %w", err)
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return archive.Close()
+}
+
+// writeFile copies the stored file of the document into the archive and reports whether the file exists.
+// Document files are compressed already, so they are stored as is
+func (s *ArchiveService) writeFile(archive *zip.Writer, userId uint, document *models.ArchivedDocument) (bool, error) {
+	reader, err := s.DocumentService.BlobStore.Get(storage.DocumentKey(userId, document.Id, document.FileName))
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open file of document %d: %w", document.Id, err)
+	}
+	defer reader.Close()
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: document.File, Method: zip.Store, Modified: document.CreatedAt})
+	if err != nil {
+		return false, fmt.Errorf("failed to add file of document %d to archive: %w", document.Id, err)
+	}
+	_, err = io.Copy(entry, reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to write file of document %d: %w", document.Id, err)
+	}
+	return true, nil
+}
+
+// AllowedArchiveSize returns the largest library archive the user can import: the files in it must fit into the storage
+// quota of the user and the rest of the archive into maxManifestSize. Returns -1 if the size is not limited
+func (s *ArchiveService) AllowedArchiveSize(userId uint) (int64, error) {
+	allowed, err := s.DocumentService.QuotaService.AllowedFileSize(userId, 0)
+	if err != nil || allowed < 0 {
+		return allowed, err
+	}
+	return allowed + maxManifestSize, nil
+}
+
+// ImportLibrary recreates the documents of a library archive under the user's account with their metadata, tags,
+// collections, reading progress, annotations and bookmarks. Collections with the same name and parent as existing ones
+// are merged into them. Documents whose file is already in the library, is missing from the archive or is rejected
+// are skipped and reported as conflicts. Fails with ErrInvalidArchive before importing anything
+// if the archive or its manifest is malformed
+func (s *ArchiveService) ImportLibrary(userId uint, reader io.ReaderAt, size int64) (*models.ImportReport, error) {
+	archive, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	manifest, err := readManifest(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.TagRepository.GetOrCreateTags(userId, normalizeTags(manifest.Tags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save tags: %w", err)
+	}
+	report := &models.ImportReport{Imported: []uint{}, Conflicts: []*models.ImportConflict{}}
+	collections, err := s.importCollections(userId, manifest.Collections, report)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	for _, archived := range manifest.Documents {
+		conflict, err := s.importDocument(userId, archived, files[archived.File], collections, report)
+		if err != nil {
+			return nil, err
+		}
+		if conflict != nil {
+			report.Conflicts = append(report.Conflicts, conflict)
+		}
+	}
+	return report, nil
+}
+
+// readManifest decodes the manifest of the archive and checks that its references are consistent.
+// Manifests larger than maxManifestSize are refused
+func readManifest(archive *zip.Reader) (*models.LibraryManifest, error) {
+	file, err := archive.Open(manifestName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is missing", ErrInvalidArchive, manifestName)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if info.Size() > maxManifestSize {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrInvalidArchive, manifestName, maxManifestSize)
+	}
+
+	manifest := new(models.LibraryManifest)
+	err = json.NewDecoder(io.LimitReader(file, maxManifestSize)).Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if manifest.Version < 1 || manifest.Version > models.LibraryArchiveVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, manifest.Version)
+	}
+
+	collections := make(map[uint]bool, len(manifest.Collections))
+	for _, collection := range manifest.Collections {
+		if collection.ParentId != nil && !collections[*collection.ParentId] {
+			return nil, fmt.Errorf("%w: parent of collection %d is not listed before it", ErrInvalidArchive, collection.Id)
+		}
+		if collection.Name == "" || collections[collection.Id] {
+			return nil, fmt.Errorf("%w: invalid collection %d", ErrInvalidArchive, collection.Id)
+		}
+		collections[collection.Id] = true
+	}
+	for _, document := range manifest.Documents {
+		if document.Title == "" {
+			return nil, fmt.Errorf("%w: document %d has no title", ErrInvalidArchive, document.Id)
+		}
+		for _, id := range document.Collections {
+			if !collections[id] {
+				return nil, fmt.Errorf("%w: document %d is in unknown collection %d", ErrInvalidArchive, document.Id, id)
+			}
+		}
+		reading := document.ReadingState
+		if reading != nil && !readingStatuses[reading.Status] {
+			return nil, fmt.Errorf("%w: document %d has invalid reading status %q", ErrInvalidArchive, document.Id, reading.Status)
+		}
+	}
+	return manifest, nil
+}
+
+// readingStatuses are the reading statuses accepted in a library archive
+var readingStatuses = map[string]bool{
+	models.ReadingStatusToRead:   true,
+	models.ReadingStatusReading:  true,
+	models.ReadingStatusFinished: true,
+}
+
+// importCollections recreates the archived collections in the user's library, reusing existing collections
+// with the same name and parent, and returns them by their archive ids
+func (s *ArchiveService) importCollections(
+	userId uint,
+	archived []*models.ArchivedCollection,
+	report *models.ImportReport,
+) (map[uint]*models.Collection, error) {
+	type collectionKey struct {
+		parentId uint
+		name     string
+	}
+	existing, err := s.CollectionRepository.GetCollections(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve collections: %w", err)
+	}
+	byKey := make(map[collectionKey]*models.Collection, len(existing))
+	for _, collection := range existing {
+		var parentId uint
+		if collection.ParentId != nil {
+			parentId = *collection.ParentId
+		}
+		byKey[collectionKey{parentId, collection.Name}] = collection
+	}
+
+	collections := make(map[uint]*models.Collection, len(archived))
+	for _, archivedCollection := range archived {
+		var parentId *uint
+		key := collectionKey{name: archivedCollection.Name}
+		if archivedCollection.ParentId != nil {
+			parentId = &collections[*archivedCollection.ParentId].ID
+			key.parentId = *parentId
+		}
+		collection, ok := byKey[key]
+		if !ok {
+			collection = &models.Collection{UserId: userId, ParentId: parentId, Name: archivedCollection.Name}
+			err = s.CollectionRepository.CreateCollection(collection)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create collection %s: %w", collection.Name, err)
+			}
+			byKey[key] = collection
+			report.Collections++
+		}
+		collections[archivedCollection.Id] = collection
+	}
+	return collections, nil
+}
+
+// importDocument recreates the archived document from its file in the archive, if it has one, and returns the conflict
+// preventing the import, if there is one. Files that do not fit into the remaining storage quota are rejected
+// before they are inflated. Imported documents are added to the report
+func (s *ArchiveService) importDocument(
+	userId uint,
+	archived *models.ArchivedDocument,
+	file *zip.File,
+	collections map[uint]*models.Collection,
+	report *models.ImportReport,
+) (*models.ImportConflict, error) {
+	conflict := &models.ImportConflict{Title: archived.Title, Sha256: archived.Metadata.Sha256}
+	if archived.File != "" {
+		if file == nil {
+			conflict.Reason, conflict.Detail = models.ImportConflictMissingFile, archived.File+" is not in the archive"
+			return conflict, nil
+		}
+		allowed, err := s.DocumentService.QuotaService.AllowedFileSize(userId, 0)
+		if err != nil {
+			return nil, err
+		}
+		if allowed >= 0 && file.UncompressedSize64 > uint64(allowed) {
+			err = fmt.Errorf("%w: %s of %d bytes exceeds the remaining %d bytes", ErrQuotaExceeded, archived.File, file.UncompressedSize64, allowed)
+			conflict.Reason, conflict.Detail = models.ImportConflictRejected, err.Error()
+			return conflict, nil
+		}
+		checksum, err := fileChecksum(file, allowed)
+		if errors.Is(err, ErrQuotaExceeded) {
+			conflict.Reason, conflict.Detail = models.ImportConflictRejected, err.Error()
+			return conflict, nil
+		}
+		if err != nil {
+			conflict.Reason, conflict.Detail = models.ImportConflictMissingFile, err.Error()
+			return conflict, nil
+		}
+		if archived.Metadata.Sha256 != "" && checksum != archived.Metadata.Sha256 {
+			conflict.Reason, conflict.Detail = models.ImportConflictMissingFile, "checksum of "+archived.File+" does not match"
+			return conflict, nil
+		}
+		conflict.Sha256 = checksum
+
+		duplicate, err := s.DocumentService.DocumentRepository.GetDocumentByChecksum(userId, checksum)
+		if err == nil {
+			conflict.Reason, conflict.DocumentId = models.ImportConflictDuplicate, duplicate.ID
+			return conflict, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to look for duplicates of %s: %w", archived.Title, err)
+		}
+	}
+
+	document, err := s.DocumentService.AddDocument(userId, archived.Title)
+	if errors.Is(err, ErrQuotaExceeded) {
+		conflict.Reason, conflict.Detail = models.ImportConflictRejected, err.Error()
+		return conflict, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create document %s: %w", archived.Title, err)
+	}
+
+	err = s.restoreDocument(userId, document, archived, file, collections)
+	if err == nil {
+		report.Imported = append(report.Imported, document.ID)
+		return nil, nil
+	}
+	if discardErr := s.DocumentService.discardDocument(document); discardErr != nil {
+		log.Printf("failed to discard partially imported document %d: %v", document.ID, discardErr)
+	}
+	if errors.Is(err, ErrFileRejected) || errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrInvalidUpdate) {
+		conflict.Reason, conflict.Detail = models.ImportConflictRejected, err.Error()
+		return conflict, nil
+	}
+	return nil, fmt.Errorf("failed to import document %s: %w", archived.Title, err)
+}
+
+// fileChecksum returns the hex encoded SHA-256 checksum of the archived file. Fails with ErrQuotaExceeded
+// once more than limit bytes are inflated, the size is not limited if limit is -1
+func fileChecksum(file *zip.File, limit int64) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer reader.Close()
+
+	var inflated io.Reader = reader
+	if limit >= 0 {
+		inflated = io.LimitReader(reader, limit+1)
+	}
+	hash := sha256.New()
+	read, err := io.Copy(hash, inflated)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	if limit >= 0 && read > limit {
+		return "", fmt.Errorf("%w: %s exceeds the remaining %d bytes", ErrQuotaExceeded, file.Name, limit)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// restoreDocument uploads the archived file, if there is one, into the new document and restores everything
+// the user attached to the archived document
+func (s *ArchiveService) restoreDocument(
+	userId uint,
+	document *models.Document,
+	archived *models.ArchivedDocument,
+	file *zip.File,
+	collections map[uint]*models.Collection,
+) error {
+	if file != nil {
+		reader, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", file.Name, err)
+		}
+		_, err = s.DocumentService.ReplaceFile(userId, document.ID, 0, archived.FileName, reader, int64(file.UncompressedSize64))
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// the user's edits of the metadata take precedence over the metadata extracted from the file
+	metadata := archived.Metadata
+	update := &requests.UpdateDocumentRequest{
+		Title:         &archived.Title,
+		Author:        &metadata.Author,
+		OriginalTitle: &metadata.OriginalTitle,
+		Language:      &metadata.Language,
+		PublishedAt:   metadata.PublishedAt,
+		Tags:          append([]string{}, archived.Tags...),
+		Notes:         &archived.Notes,
+	}
+	document, err := s.DocumentService.UpdateDocument(userId, document.ID, 0, update)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range archived.Collections {
+		err = s.DocumentService.DocumentRepository.AddToCollection(document, collections[id])
+		if err != nil {
+			return fmt.Errorf("failed to add document to collection: %w", err)
+		}
+	}
+	if reading := archived.ReadingState; reading != nil {
+		_, err = s.ReadingStateRepository.SaveReadingState(&models.ReadingState{
+			DocumentId:   document.ID,
+			UserId:       userId,
+			Location:     reading.Location,
+			Percentage:   reading.Percentage,
+			Status:       reading.Status,
+			LastOpenedAt: reading.LastOpenedAt,
+			UpdatedAt:    reading.UpdatedAt,
+			SyncedAt:     time.Now().UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save reading state: %w", err)
+		}
+	}
+	for _, note := range archived.Annotations {
+		color := note.Color
+		if color == "" {
+			color = models.DefaultAnnotationColor
+		}
+		err = s.AnnotationRepository.CreateAnnotation(&models.Annotation{
+			DocumentId:  document.ID,
+			UserId:      userId,
+			Page:        note.Page,
+			StartOffset: note.StartOffset,
+			EndOffset:   note.EndOffset,
+			Text:        note.Text,
+			Color:       color,
+			Note:        note.Note,
+			LlmResponse: note.LlmResponse,
+			CreatedAt:   note.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save annotation: %w", err)
+		}
+	}
+	for _, archivedBookmark := range archived.Bookmarks {
+		err = s.BookmarkRepository.CreateBookmark(&models.Bookmark{
+			DocumentId: document.ID,
+			UserId:     userId,
+			Location:   archivedBookmark.Location,
+			Label:      archivedBookmark.Label,
+			CreatedAt:  archivedBookmark.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save bookmark: %w", err)
+		}
+	}
+	return nil
+}
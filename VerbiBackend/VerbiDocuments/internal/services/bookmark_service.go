@@ -0,0 +1,135 @@
+package services
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// ErrBookmarkNotFound is returned when the user has no bookmark with the requested id in the document
+var ErrBookmarkNotFound = errors.New("bookmark not found")
+
+// ErrInvalidBookmark is returned when a bookmark can't be saved as requested
+var ErrInvalidBookmark = errors.New("invalid bookmark")
+
+// BookmarkService handles the user's bookmarks in their own documents and documents shared with them
+type BookmarkService struct {
+	BookmarkRepository *repositories.BookmarkRepository
+	DocumentRepository *repositories.DocumentRepository
+}
+
+// NewBookmarkService creates a new BookmarkService
+func NewBookmarkService(
+	bookmarkRepository *repositories.BookmarkRepository,
+	documentRepository *repositories.DocumentRepository,
+) *BookmarkService {
+	return &BookmarkService{
+		BookmarkRepository: bookmarkRepository,
+		DocumentRepository: documentRepository,
+	}
+}
+
+// checkReadable returns ErrDocumentNotFound if the user can't read the document
+func (s *BookmarkService) checkReadable(userId, documentId uint) error {
+	_, err := s.DocumentRepository.GetReadableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrDocumentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	return nil
+}
+
+// CreateBookmark bookmarks the location in the document
+func (s *BookmarkService) CreateBookmark(userId, documentId uint, req *requests.CreateBookmarkRequest) (*models.Bookmark, error) {
+	location := strings.TrimSpace(req.Location)
+	if location == "" {
+		return nil, fmt.Errorf("%w: location is required", ErrInvalidBookmark)
+	}
+	err := s.checkReadable(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmark := &models.Bookmark{
+		DocumentId: documentId,
+		UserId:     userId,
+		Location:   location,
+		Label:      strings.TrimSpace(req.Label),
+	}
+	err = s.BookmarkRepository.CreateBookmark(bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save bookmark: %w", err)
+	}
+	return bookmark, nil
+}
+
+// GetBookmarks returns the user's bookmarks of the document ordered by creation
+func (s *BookmarkService) GetBookmarks(userId, documentId uint) ([]*models.Bookmark, error) {
+	err := s.checkReadable(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks, err := s.BookmarkRepository.GetBookmarks(userId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve bookmarks: %w", err)
+	}
+	return bookmarks, nil
+}
+
+// UpdateBookmark changes the location or label of the user's bookmark
+func (s *BookmarkService) UpdateBookmark(
+	userId, documentId, id uint,
+	req *requests.UpdateBookmarkRequest,
+) (*models.Bookmark, error) {
+	err := s.checkReadable(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+	bookmark, err := s.BookmarkRepository.GetBookmark(userId, documentId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookmarkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve bookmark: %w", err)
+	}
+
+	if req.Location != nil {
+		location := strings.TrimSpace(*req.Location)
+		if location == "" {
+			return nil, fmt.Errorf("%w: location is required", ErrInvalidBookmark)
+		}
+		bookmark.Location = location
+	}
+	if req.Label != nil {
+		bookmark.Label = strings.TrimSpace(*req.Label)
+	}
+	err = s.BookmarkRepository.UpdateBookmark(bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update bookmark: %w", err)
+	}
+	return bookmark, nil
+}
+
+// DeleteBookmark deletes the user's bookmark of the document
+func (s *BookmarkService) DeleteBookmark(userId, documentId, id uint) error {
+	err := s.checkReadable(userId, documentId)
+	if err != nil {
+		return err
+	}
+
+	err = s.BookmarkRepository.DeleteBookmark(userId, documentId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBookmarkNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete bookmark: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// ErrCollectionNotFound is returned when the user has no collection with the requested id
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// ErrInvalidCollection is returned when a collection would get an empty or duplicate name or a parent inside itself
+var ErrInvalidCollection = errors.New("invalid collection")
+
+const (
+	// KeepDocuments leaves documents of a deleted collection in the library
+	KeepDocuments = "keep"
+	// TrashDocuments moves documents of a deleted collection to the trash
+	TrashDocuments = "trash"
+)
+
+// CollectionService handles actions related to organizing documents into nested collections
+type CollectionService struct {
+	CollectionRepository *repositories.CollectionRepository
+	DocumentRepository   *repositories.DocumentRepository
+	DocumentService      *DocumentService
+}
+
+// NewCollectionService creates a new CollectionService
+func NewCollectionService(
+	collectionRepository *repositories.CollectionRepository,
+	documentRepository *repositories.DocumentRepository,
+	documentService *DocumentService,
+) *CollectionService {
+	return &CollectionService{
+		CollectionRepository: collectionRepository,
+		DocumentRepository:   documentRepository,
+		DocumentService:      documentService,
+	}
+}
+
+// GetCollections returns all collections of the user
+func (s *CollectionService) GetCollections(userId uint) ([]*models.Collection, error) {
+	collections, err := s.CollectionRepository.GetCollections(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve collections: %w", err)
+	}
+	return collections, nil
+}
+
+// getCollection returns the user's collection mapping a missing one to ErrCollectionNotFound
+func (s *CollectionService) getCollection(userId, id uint) (*models.Collection, error) {
+	collection, err := s.CollectionRepository.GetCollection(userId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve collection: %w", err)
+	}
+	return collection, nil
+}
+
+// validate checks the name and the parent of a new or changed collection against the other collections of the user
+func (s *CollectionService) validate(collection *models.Collection) error {
+	if collection.Name == "" {
+		return fmt.Errorf("%w: name must not be empty", ErrInvalidCollection)
+	}
+
+	collections, err := s.CollectionRepository.GetCollections(collection.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve collections: %w", err)
+	}
+	parents := make(map[uint]*uint, len(collections))
+	for _, other := range collections {
+		parents[other.ID] = other.ParentId
+		if other.ID != collection.ID && other.Name == collection.Name && sameParent(other.ParentId, collection.ParentId) {
+			return fmt.Errorf("%w: collection %q already exists", ErrInvalidCollection, collection.Name)
+		}
+	}
+
+	for parent := collection.ParentId; parent != nil; parent = parents[*parent] {
+		if _, ok := parents[*parent]; !ok {
+			return fmt.Errorf("%w: parent collection not found", ErrInvalidCollection)
+		}
+		if *parent == collection.ID {
+			return fmt.Errorf("%w: collection can not be moved inside itself", ErrInvalidCollection)
+		}
+	}
+	return nil
+}
+
+// sameParent reports whether both collections are top level or have the same parent
+func sameParent(a, b *uint) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// CreateCollection creates a collection of the user inside the parent collection or at the top level if it is nil
+func (s *CollectionService) CreateCollection(userId uint, name string, parentId *uint) (*models.Collection, error) {
+	collection := &models.Collection{UserId: userId, Name: strings.TrimSpace(name), ParentId: parentId}
+	err := s.validate(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.CollectionRepository.CreateCollection(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save collection: %w", err)
+	}
+	return collection, nil
+}
+
+// UpdateCollection renames the user's collection or moves it to another parent, parent id 0 moves it to the top level
+func (s *CollectionService) UpdateCollection(userId, id uint, name *string, parentId *uint) (*models.Collection, error) {
+	collection, err := s.getCollection(userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != nil {
+		collection.Name = strings.TrimSpace(*name)
+	}
+	if parentId != nil && *parentId == 0 {
+		collection.ParentId = nil
+	} else if parentId != nil {
+		collection.ParentId = parentId
+	}
+	err = s.validate(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.CollectionRepository.UpdateCollection(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update collection: %w", err)
+	}
+	return collection, nil
+}
+
+// DeleteCollection deletes the user's collection with all nested collections.
+// Their documents stay in the library with KeepDocuments and are moved to the trash with TrashDocuments
+func (s *CollectionService) DeleteCollection(userId, id uint, documents string) error {
+	if documents != KeepDocuments && documents != TrashDocuments {
+		return fmt.Errorf("%w: documents must be %s or %s", ErrInvalidCollection, KeepDocuments, TrashDocuments)
+	}
+	_, err := s.getCollection(userId, id)
+	if err != nil {
+		return err
+	}
+
+	collections, err := s.CollectionRepository.GetCollections(userId)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve collections: %w", err)
+	}
+	ids := nestedCollections(id, collections)
+
+	// the collections are not deleted unless their documents are moved to the trash as well
+	return s.CollectionRepository.DB.Transaction(func(tx *gorm.DB) error {
+		collectionRepository := repositories.NewCollectionRepository(tx)
+		var documentIds []uint
+		if documents == TrashDocuments {
+			documentIds, err = collectionRepository.GetDocumentIds(userId, ids)
+			if err != nil {
+				return fmt.Errorf("failed to retrieve documents of collection: %w", err)
+			}
+		}
+
+		err = collectionRepository.DeleteCollections(userId, ids)
+		if err != nil {
+			return fmt.Errorf("failed to delete collection: %w", err)
+		}
+
+		documentRepository := repositories.NewDocumentRepository(tx)
+		trashedAt := time.Now()
+		for _, documentId := range documentIds {
+			err = documentRepository.TrashDocument(userId, documentId, trashedAt)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("failed to move document to the trash: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// nestedCollections returns the id of the collection followed by ids of all collections nested in it
+func nestedCollections(id uint, collections []*models.Collection) []uint {
+	children := make(map[uint][]uint)
+	for _, collection := range collections {
+		if collection.ParentId != nil {
+			children[*collection.ParentId] = append(children[*collection.ParentId], collection.ID)
+		}
+	}
+
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+// getDocumentAndCollection returns the user's document and collection mapping missing ones to not found errors
+func (s *CollectionService) getDocumentAndCollection(userId, id, documentId uint) (*models.Document, *models.Collection, error) {
+	collection, err := s.getCollection(userId, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	document, err := s.DocumentService.GetDocument(userId, documentId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return document, collection, nil
+}
+
+// AddDocument puts the user's document into the collection, adding it twice has no effect
+func (s *CollectionService) AddDocument(userId, id, documentId uint) error {
+	document, collection, err := s.getDocumentAndCollection(userId, id, documentId)
+	if err != nil {
+		return err
+	}
+
+	err = s.DocumentRepository.AddToCollection(document, collection)
+	if err != nil {
+		return fmt.Errorf("failed to add document to collection: %w", err)
+	}
+	return nil
+}
+
+// RemoveDocument takes the user's document out of the collection
+func (s *CollectionService) RemoveDocument(userId, id, documentId uint) error {
+	document, collection, err := s.getDocumentAndCollection(userId, id, documentId)
+	if err != nil {
+		return err
+	}
+
+	err = s.DocumentRepository.RemoveFromCollection(document, collection)
+	if err != nil {
+		return fmt.Errorf("failed to remove document from collection: %w", err)
+	}
+	return nil
+}
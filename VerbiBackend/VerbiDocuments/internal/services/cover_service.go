@@ -0,0 +1,114 @@
+package services
+
+import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/internal/thumbnails"
+	"bytes"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"image"
+	"log"
+)
+
+// CoverService generates and serves cover thumbnails of documents
+type CoverService struct {
+	DocumentRepository *repositories.DocumentRepository
+	BlobStore          interfaces.BlobStore
+}
+
+// NewCoverService creates a new CoverService
+func NewCoverService(documentRepository *repositories.DocumentRepository, blobStore interfaces.BlobStore) *CoverService {
+	return &CoverService{
+		DocumentRepository: documentRepository,
+		BlobStore:          blobStore,
+	}
+}
+
+// GenerateCovers stores thumbnails of the embedded EPUB cover or the first PDF page of the document file.
+// Documents without a cover get a generated title card
+func (s *CoverService) GenerateCovers(document *models.Document) error {
+	cover, err := s.extractCover(document)
+	if err != nil {
+		if !errors.Is(err, extractors.ErrNoCover) {
+			log.Printf("failed to extract cover of document %d: %v", document.ID, err)
+		}
+		cover, err = thumbnails.TitleCard(document.Title, document.Metadata.Author)
+		if err != nil {
+			return fmt.Errorf("failed to draw title card: %w", err)
+		}
+	}
+	return s.saveCovers(document, cover)
+}
+
+// extractCover reads the cover from the document file
+func (s *CoverService) extractCover(document *models.Document) (image.Image, error) {
+	if document.FileName == "" {
+		return nil, extractors.ErrNoCover
+	}
+
+	key := storage.DocumentKey(document.UserId, document.ID, document.FileName)
+	info, err := s.BlobStore.Stat(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	reader, err := s.BlobStore.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	defer reader.Close()
+
+	return extractors.ExtractCover(reader, info.Size, document.Metadata.MimeType)
+}
+
+// saveCovers stores the cover scaled to every thumbnail size
+func (s *CoverService) saveCovers(document *models.Document, cover image.Image) error {
+	for _, size := range thumbnails.Sizes {
+		data, err := thumbnails.Encode(cover, size)
+		if err != nil {
+			return err
+		}
+
+		key := storage.DocumentKey(document.UserId, document.ID, thumbnails.FileName(size))
+		err = s.BlobStore.Put(key, bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// GetCover opens the cover thumbnail of the given size of the user's or a shared document.
+// Covers of documents whose file was not uploaded yet are generated on first request
+func (s *CoverService) GetCover(userId, documentId uint, size thumbnails.Size) (interfaces.BlobReader, *models.BlobInfo, error) {
+	document, err := s.DocumentRepository.GetReadableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+
+	key := storage.DocumentKey(document.UserId, documentId, thumbnails.FileName(size))
+	info, err := s.BlobStore.Stat(key)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		err = s.GenerateCovers(document)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate cover: %w", err)
+		}
+		info, err = s.BlobStore.Stat(key)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat cover: %w", err)
+	}
+
+	reader, err := s.BlobStore.Get(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open cover: %w", err)
+	}
+	return reader, info, nil
+}
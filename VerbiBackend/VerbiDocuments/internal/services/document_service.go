@@ -1,31 +1,88 @@
 package services
 
 import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/interfaces"
 	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
 	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"log"
 	"os"
+	"strings"
+	"time"
 )
 
+// ErrDocumentNotFound is returned when the user has no document with the requested id
+var ErrDocumentNotFound = errors.New("document not found")
+
+// idempotencyKeyTTL is the time during which retries with the same Idempotency-Key return the same document
+const idempotencyKeyTTL = 24 * time.Hour
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent with a different request
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// ErrVersionMismatch is returned when the document was changed after the version the client expects
+var ErrVersionMismatch = errors.New("document was changed by another request")
+
+// ErrInvalidUpdate is returned when the requested changes of a document are invalid
+var ErrInvalidUpdate = errors.New("invalid document update")
+
+// ErrInvalidListQuery is returned when sorting, filtering or pagination parameters of the document list are invalid
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+const (
+	// DefaultPageSize is the number of documents in a page of the list when the limit is omitted
+	DefaultPageSize = 50
+	// MaxPageSize is the largest number of documents in a page of the list
+	MaxPageSize = 200
+)
+
+// documentFormats maps format names accepted by the document list filter to MIME types
+var documentFormats = map[string]string{
+	"pdf":  extractors.MimeTypePdf,
+	"epub": extractors.MimeTypeEpub,
+}
+
+// maxFileNameLength is the longest accepted name of a document file
+const maxFileNameLength = 255
+
 // DocumentService handles actions related to documents management
 type DocumentService struct {
-	DocumentRepository *repositories.DocumentRepository
-	SftpRepository     *repositories.SftpRepository
-	SftpService        *SftpService
+	DocumentRepository    *repositories.DocumentRepository
+	SftpRepository        *repositories.SftpRepository
+	IdempotencyRepository *repositories.IdempotencyRepository
+	BlobStore             interfaces.BlobStore
+	ProcessingService     *ProcessingService
+	QuotaService          *QuotaService
 }
 
 // NewDocumentService creates a new document service
 func NewDocumentService(
 	documentRepository *repositories.DocumentRepository,
 	sftpRepository *repositories.SftpRepository,
-	sftpService *SftpService,
+	idempotencyRepository *repositories.IdempotencyRepository,
+	blobStore interfaces.BlobStore,
+	processingService *ProcessingService,
+	quotaService *QuotaService,
 ) *DocumentService {
 	return &DocumentService{
-		DocumentRepository: documentRepository,
-		SftpRepository:     sftpRepository,
-		SftpService:        sftpService,
+		DocumentRepository:    documentRepository,
+		SftpRepository:        sftpRepository,
+		IdempotencyRepository: idempotencyRepository,
+		BlobStore:             blobStore,
+		ProcessingService:     processingService,
+		QuotaService:          quotaService,
 	}
 }
 
@@ -39,8 +96,41 @@ func generateRandomString(length int) (string, error) {
 	return base64.URLEncoding.EncodeToString(b)[:length], nil
 }
 
-// CreateDocument saves a new document metadata in the database and creates SFTP directory
-func (s *DocumentService) CreateDocument(userId uint, title string) (map[string]interface{}, error) {
+// hashCreateRequest identifies the content of a creation request to detect reuse of idempotency keys
+func hashCreateRequest(title string) string {
+	sum := sha256.Sum256([]byte(title))
+	return hex.EncodeToString(sum[:])
+}
+
+// creationResponse describes the created document and the credentials for uploading its file
+func creationResponse(document *models.Document, credentials *models.SftpCredentials) map[string]interface{} {
+	return map[string]interface{}{
+		"documentId": document.ID,
+		"title":      document.Title,
+		"path":       document.Path,
+		"sftp": map[string]string{
+			"username": credentials.Username,
+			"password": credentials.Password,
+			"host":     credentials.Host,
+			"port":     credentials.Port,
+		},
+	}
+}
+
+// CreateDocument saves a new document metadata in the database and issues credentials for uploading its file.
+// The document, its path, the credentials and the idempotency key are saved in a single transaction, so a failure
+// leaves nothing behind. Document directories are virtual and need no storage changes until the file is uploaded.
+// Fails with ErrQuotaExceeded when the user has as many documents as the quota allows.
+// A repeated request with the same non-empty idempotency key returns the document created by the first one
+func (s *DocumentService) CreateDocument(userId uint, title, idempotencyKey string) (map[string]interface{}, error) {
+	requestHash := hashCreateRequest(title)
+	if idempotencyKey != "" {
+		response, err := s.replayCreation(userId, idempotencyKey, requestHash)
+		if response != nil || err != nil {
+			return response, err
+		}
+	}
+
 	tempUsername, err := generateRandomString(10)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate temporary username: %w", err)
@@ -51,83 +141,681 @@ func (s *DocumentService) CreateDocument(userId uint, title string) (map[string]
 		return nil, fmt.Errorf("failed to generate temporary password: %w", err)
 	}
 
+	quota, err := s.QuotaService.GetQuota(userId)
+	if err != nil {
+		return nil, err
+	}
+
 	document := &models.Document{
-		UserId: userId,
-		Title:  title,
+		UserId:           userId,
+		Title:            title,
+		ProcessingStatus: models.ProcessingStatusPending,
+	}
+	credentials := &models.SftpCredentials{
+		UserId:   userId,
+		Username: tempUsername,
+		Password: tempPassword,
+		Host:     os.Getenv("SFTP_HOST"),
+		Port:     os.Getenv("SFTP_PORT"),
 	}
 
-	id, err := s.DocumentRepository.CreateDocument(document)
+	err = s.DocumentRepository.DB.Transaction(func(tx *gorm.DB) error {
+		err := saveNewDocument(tx, document, quota)
+		if err != nil {
+			return err
+		}
+		id := document.ID
+
+		err = repositories.NewSftpRepository(tx).SaveSftpCredentials(userId, tempUsername, tempPassword)
+		if err != nil {
+			return fmt.Errorf("failed to save sftp credentials: %w", err)
+		}
+
+		if idempotencyKey == "" {
+			return nil
+		}
+		idempotencyRepository := repositories.NewIdempotencyRepository(tx)
+		err = idempotencyRepository.DeleteExpiredKeys(userId, time.Now().Add(-idempotencyKeyTTL))
+		if err != nil {
+			return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+		}
+		err = idempotencyRepository.SaveKey(&models.IdempotencyKey{
+			UserId:      userId,
+			Key:         idempotencyKey,
+			RequestHash: requestHash,
+			DocumentId:  id,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save idempotency key: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to save document metadata: %w", err)
+		if idempotencyKey != "" {
+			// a concurrent retry with the same key may have saved its document first
+			response, replayErr := s.replayCreation(userId, idempotencyKey, requestHash)
+			if response != nil || replayErr != nil {
+				return response, replayErr
+			}
+		}
+		return nil, err
+	}
+
+	return creationResponse(document, credentials), nil
+}
+
+// saveNewDocument saves the document counting it into the storage usage of its owner and sets its path
+func saveNewDocument(tx *gorm.DB, document *models.Document, quota *models.StorageQuota) error {
+	err := repositories.NewQuotaRepository(tx).AddUsage(document.UserId, 0, 1, quota)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: at most %d documents are allowed", ErrQuotaExceeded, quota.MaxDocuments)
 	}
-	document.Path = fmt.Sprintf("/%d/%d", userId, id)
-	err = s.DocumentRepository.UpdateDocumentPath(id, document.Path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update document path: %w", err)
+		return fmt.Errorf("failed to update storage usage: %w", err)
 	}
 
-	err = s.SftpRepository.SaveSftpCredentials(userId, tempUsername, tempPassword)
+	documentRepository := repositories.NewDocumentRepository(tx)
+	id, err := documentRepository.CreateDocument(document)
+	if err != nil {
+		return fmt.Errorf("failed to save document metadata: %w", err)
+	}
+	document.Path = fmt.Sprintf("/%d/%d", document.UserId, id)
+	err = documentRepository.UpdateDocumentPath(document.UserId, id, document.Path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to save sftp credentials: %w", err)
+		return fmt.Errorf("failed to update document path: %w", err)
 	}
+	return nil
+}
 
-	_, err = s.SftpService.CreateUserDirectory(userId)
+// AddDocument saves a new pending document whose file is uploaded by the service itself, so no sftp credentials
+// are issued. Fails with ErrQuotaExceeded when the user has as many documents as the quota allows
+func (s *DocumentService) AddDocument(userId uint, title string) (*models.Document, error) {
+	quota, err := s.QuotaService.GetQuota(userId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create sftp directory: %w", err)
+		return nil, err
 	}
 
-	err = s.SftpService.CreateDocumentDirectory(userId, id)
+	document := &models.Document{
+		UserId:           userId,
+		Title:            title,
+		ProcessingStatus: models.ProcessingStatusPending,
+	}
+	err = s.DocumentRepository.DB.Transaction(func(tx *gorm.DB) error {
+		return saveNewDocument(tx, document, quota)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create document directory: %w", err)
+		return nil, err
 	}
+	return document, nil
+}
 
-	return map[string]interface{}{
-		"documentId": id,
-		"title":      document.Title,
-		"path":       document.Path,
-		"sftp": map[string]string{
-			"username": tempUsername,
-			"password": tempPassword,
-			"host":     os.Getenv("SFTP_HOST"),
-			"port":     os.Getenv("SFTP_PORT"),
-		},
-	}, nil
+// replayCreation returns the response for the document created with the idempotency key or nil if there is none
+func (s *DocumentService) replayCreation(userId uint, key, requestHash string) (map[string]interface{}, error) {
+	idempotencyKey, err := s.IdempotencyRepository.GetKey(userId, key, time.Now().Add(-idempotencyKeyTTL))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	if idempotencyKey.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	document, err := s.DocumentRepository.GetDocument(userId, idempotencyKey.DocumentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	credentials, err := s.SftpRepository.GetSftpCredentials(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve sftp credentials: %w", err)
+	}
+	return creationResponse(document, credentials), nil
+}
+
+// ListDocuments returns a page of the user's documents matching the query, the total number of matching documents and
+// the cursor of the next page, which is empty on the last page. Titles are sorted in ascending order by default,
+// creation time and size in descending one
+func (s *DocumentService) ListDocuments(userId uint, query *models.DocumentListQuery) ([]*models.Document, int64, string, error) {
+	cursor, err := parseListQuery(query)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	documents, err := s.DocumentRepository.ListDocuments(userId, &query.DocumentFilter, cursor, query.Limit+1)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to retrieve documents: %w", err)
+	}
+	total, err := s.DocumentRepository.CountDocuments(userId, &query.DocumentFilter)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	if len(documents) <= query.Limit {
+		return documents, total, "", nil
+	}
+	documents = documents[:query.Limit]
+	last := documents[len(documents)-1]
+	next := &models.DocumentCursor{Sort: cursor.Sort, Descending: cursor.Descending, Id: last.ID}
+	switch cursor.Sort {
+	case models.SortByTitle:
+		next.Value = last.Title
+	case models.SortByCreated:
+		next.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	case models.SortBySize:
+		next.Value = last.Metadata.FileSize
+	case models.SortByOpened:
+		next.Value = repositories.NeverOpened
+		if last.ReadingState != nil && last.ReadingState.LastOpenedAt != nil {
+			next.Value = last.ReadingState.LastOpenedAt.Format(time.RFC3339Nano)
+		}
+	}
+	encoded, err := json.Marshal(next)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return documents, total, base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// parseListQuery validates the list query filling in defaults and returns the position to list documents from
+func parseListQuery(query *models.DocumentListQuery) (*models.DocumentCursor, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if query.Limit < 1 || query.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be from 1 to %d", ErrInvalidListQuery, MaxPageSize)
+	}
+	if query.Format != "" {
+		mimeType, ok := documentFormats[query.Format]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidListQuery, query.Format)
+		}
+		query.MimeType = mimeType
+	}
+	switch query.Status {
+	case "", models.ReadingStatusToRead, models.ReadingStatusReading, models.ReadingStatusFinished:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidListQuery, query.Status)
+	}
+
+	if query.Sort == "" {
+		query.Sort = models.SortByCreated
+	}
+	if !repositories.IsDocumentSort(query.Sort) {
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidListQuery, query.Sort)
+	}
+	position := &models.DocumentCursor{Sort: query.Sort, Descending: query.Sort != models.SortByTitle}
+	switch query.Order {
+	case "":
+	case "asc", "desc":
+		position.Descending = query.Order == "desc"
+	default:
+		return nil, fmt.Errorf("%w: order must be asc or desc", ErrInvalidListQuery)
+	}
+	if query.Cursor == "" {
+		return position, nil
+	}
+
+	cursor, err := decodeCursor(query.Cursor)
+	if err != nil || cursor.Sort != position.Sort || cursor.Descending != position.Descending {
+		return nil, fmt.Errorf("%w: cursor does not match the query", ErrInvalidListQuery)
+	}
+	return cursor, nil
+}
+
+// decodeCursor parses a cursor of the document list converting its value to the type of the sorted column
+func decodeCursor(encoded string) (*models.DocumentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	cursor := new(models.DocumentCursor)
+	err = decoder.Decode(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := cursor.Value.(type) {
+	case string:
+		if cursor.Sort == models.SortByOpened && value == repositories.NeverOpened {
+			return cursor, nil
+		}
+		if cursor.Sort == models.SortByCreated || cursor.Sort == models.SortByOpened {
+			cursor.Value, err = time.Parse(time.RFC3339Nano, value)
+			return cursor, err
+		}
+		if cursor.Sort == models.SortByTitle {
+			return cursor, nil
+		}
+	case json.Number:
+		if cursor.Sort == models.SortBySize {
+			cursor.Value, err = value.Int64()
+			return cursor, err
+		}
+	}
+	return nil, errors.New("invalid cursor value")
 }
 
-// GetDocuments returns all documents' uploaded by user with the given userId
-func (s *DocumentService) GetDocuments(userId uint) ([]*models.Document, error) {
-	documents, err := s.DocumentRepository.GetDocumentsByUserId(userId)
+// GetDocuments returns documents uploaded by user with the given userId matching the filter
+func (s *DocumentService) GetDocuments(userId uint, filter *models.DocumentFilter) ([]*models.Document, error) {
+	documents, err := s.DocumentRepository.GetDocumentsByUserId(userId, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve documents: %w", err)
 	}
 	return documents, nil
 }
 
-// DeleteDocument deletes the document with the given documentId from the directory of the user with the given userId
+// normalizeTags trims tag names and drops empty and duplicate ones
+func normalizeTags(names []string) []string {
+	tags := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+// checkVersion returns ErrVersionMismatch if the expected version is set and differs from the version of the document
+func checkVersion(document *models.Document, version uint) error {
+	if version != 0 && document.Version != version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// GetDocument returns the user's document with the given documentId
+func (s *DocumentService) GetDocument(userId, documentId uint) (*models.Document, error) {
+	document, err := s.DocumentRepository.GetDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	return document, nil
+}
+
+// GetReadableDocument returns the user's document or a document shared with the user with the given documentId.
+// Personal data of the owner is left out of shared documents
+func (s *DocumentService) GetReadableDocument(userId, documentId uint) (*models.Document, error) {
+	document, err := s.DocumentRepository.GetReadableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	if document.UserId != userId {
+		hidePersonalData(document)
+	}
+	return document, nil
+}
+
+// UpdateDocument applies the user's edits to the title, metadata, tags and notes of the document.
+// If version is not zero, the document is only updated when its current version equals it
+func (s *DocumentService) UpdateDocument(userId, documentId, version uint, req *requests.UpdateDocumentRequest) (*models.Document, error) {
+	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
+		return nil, fmt.Errorf("%w: title must not be empty", ErrInvalidUpdate)
+	}
+
+	var document *models.Document
+	err := s.DocumentRepository.DB.Transaction(func(tx *gorm.DB) error {
+		documentRepository := repositories.NewDocumentRepository(tx)
+		var err error
+		document, err = documentRepository.GetDocument(userId, documentId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDocumentNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to retrieve document: %w", err)
+		}
+		if err = checkVersion(document, version); err != nil {
+			return err
+		}
+
+		if req.Title != nil {
+			document.Title = strings.TrimSpace(*req.Title)
+		}
+		if req.Author != nil {
+			document.Metadata.Author = *req.Author
+		}
+		if req.OriginalTitle != nil {
+			document.Metadata.OriginalTitle = *req.OriginalTitle
+		}
+		if req.Language != nil {
+			document.Metadata.Language = *req.Language
+		}
+		if req.PublishedAt != nil {
+			document.Metadata.PublishedAt = req.PublishedAt
+		}
+		if req.Notes != nil {
+			document.Notes = *req.Notes
+		}
+
+		// the version check of the update makes concurrent edits fail instead of overwriting each other
+		err = documentRepository.UpdateDocument(document)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVersionMismatch
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update document: %w", err)
+		}
+
+		if req.Tags == nil {
+			return nil
+		}
+		tags, err := repositories.NewTagRepository(tx).GetOrCreateTags(userId, normalizeTags(req.Tags))
+		if err != nil {
+			return fmt.Errorf("failed to save tags: %w", err)
+		}
+		err = documentRepository.ReplaceTags(document, tags)
+		if err != nil {
+			return fmt.Errorf("failed to update document tags: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// ValidateFileName reports whether the name can be used for the content file of a document
+func ValidateFileName(name string) error {
+	if name == "" || len(name) > maxFileNameLength || strings.ContainsAny(name, "/\\") || !IsDocumentFile(name) {
+		return fmt.Errorf("%w: invalid file name %q", ErrInvalidUpdate, name)
+	}
+	return nil
+}
+
+// ReplaceFile uploads a new content file of the document, validates it and extracts its metadata, its text and covers
+// are produced by background jobs. Readers keep getting the previous file until the new one is stored completely
+// and its metadata is saved, then the previous file is deleted if its name differs.
+// If version is not zero, the file is only replaced when the current version of the document equals it. The version is
+// checked when the document becomes pending before the file is stored, so of concurrent replacements expecting the same
+// version only one stores its file. Fails with ErrQuotaExceeded when the new file does not fit into the user's storage quota
+func (s *DocumentService) ReplaceFile(userId, documentId, version uint, name string, reader io.Reader, size int64) (*models.Document, error) {
+	if err := ValidateFileName(name); err != nil {
+		return nil, err
+	}
+
+	document, err := s.GetDocument(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkVersion(document, version); err != nil {
+		return nil, err
+	}
+	previous := document.FileName
+	previousStatus, previousReason := document.ProcessingStatus, document.RejectionReason
+
+	allowed, err := s.QuotaService.AllowedFileSize(userId, document.Metadata.FileSize)
+	if err != nil {
+		return nil, err
+	}
+	reader, err = limitUpload(reader, size, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DocumentRepository.StartProcessing(userId, documentId, version)
+	if errors.Is(err, gorm.ErrRecordNotFound) && version != 0 {
+		return nil, ErrVersionMismatch
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark document pending: %w", err)
+	}
+
+	key := storage.DocumentKey(userId, documentId, name)
+	err = s.BlobStore.Put(key, reader, size)
+	if err != nil {
+		if statusErr := s.DocumentRepository.SetProcessingStatus(userId, documentId, previousStatus, previousReason); statusErr != nil {
+			log.Printf("failed to reset the status of document %d: %v", documentId, statusErr)
+		}
+		return nil, fmt.Errorf("failed to store file: %w", err)
+	}
+
+	document, err = s.ProcessingService.ProcessUpload(key, userId)
+	if document == nil {
+		if err != nil && !errors.Is(err, ErrFileRejected) && !errors.Is(err, ErrQuotaExceeded) {
+			// nothing retries the upload, so an unvalidated file must not stay in place
+			s.ProcessingService.AbandonUpload(key, previousStatus, previousReason)
+		} else if name != previous {
+			if deleteErr := s.BlobStore.Delete(key); deleteErr != nil {
+				log.Printf("failed to delete %s: %v", key, deleteErr)
+			}
+		}
+		return nil, fmt.Errorf("failed to process file: %w", err)
+	}
+	if err != nil {
+		log.Printf("failed to process replacement of document %d: %v", documentId, err)
+	}
+	return document, nil
+}
+
+// RestoreVersion makes the content of the version with the given number the current file of the user's document.
+// The restored content is recorded as the newest version, so the history is never rewritten.
+// If version is not zero, the document is only restored when its current version equals it
+func (s *DocumentService) RestoreVersion(userId, documentId, version uint, number int) (*models.Document, error) {
+	reader, documentVersion, err := s.ProcessingService.VersionService.OpenVersion(userId, documentId, number)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return s.ReplaceFile(userId, documentId, version, documentVersion.FileName, reader, documentVersion.Size)
+}
+
+// DeleteDocument moves the user's document with the given documentId to the trash.
+// Its files are kept until the document is restored or purged
 func (s *DocumentService) DeleteDocument(userId, documentId uint) error {
-	err := s.DocumentRepository.DeleteDocument(documentId)
+	err := s.DocumentRepository.TrashDocument(userId, documentId, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrDocumentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move document to the trash: %w", err)
+	}
+	return nil
+}
+
+// RestoreDocument takes the user's document with the given documentId out of the trash back to the library
+func (s *DocumentService) RestoreDocument(userId, documentId uint) (*models.Document, error) {
+	err := s.DocumentRepository.RestoreDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore document: %w", err)
+	}
+	return s.GetDocument(userId, documentId)
+}
+
+// GetTrash returns the user's documents in the trash
+func (s *DocumentService) GetTrash(userId uint) ([]*models.Document, error) {
+	documents, err := s.DocumentRepository.GetTrashedDocuments(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve trash: %w", err)
+	}
+	return documents, nil
+}
+
+// purgeDocument permanently deletes the user's document in the trash from the database and the storage
+// and releases the storage usage taken by its content and side files
+func (s *DocumentService) purgeDocument(document *models.Document) error {
+	userId, documentId := document.UserId, document.ID
+	sideSize, err := s.sideFilesSize(userId, documentId)
+	if err != nil {
+		return err
+	}
+	err = s.DocumentRepository.DB.Transaction(func(tx *gorm.DB) error {
+		err := repositories.NewDocumentRepository(tx).DeleteTrashedDocument(userId, documentId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDocumentNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete document from the database: %w", err)
+		}
+		err = repositories.NewQuotaRepository(tx).AddUsage(userId, -document.Metadata.FileSize-sideSize, -1, nil)
+		if err != nil {
+			return fmt.Errorf("failed to update storage usage: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete document from the database: %w", err)
+		return err
 	}
 
-	err = s.SftpService.DeleteDocumentDirectory(userId, documentId)
+	err = storage.DeletePrefix(s.BlobStore, storage.DocumentPrefix(userId, documentId))
 	if err != nil {
-		return fmt.Errorf("failed to delete document from the sftp server: %w", err)
+		return fmt.Errorf("failed to delete document from the storage: %w", err)
 	}
 
 	return nil
 }
 
+// sideFilesSize returns the total size of the side files stored in the directory of the user's document
+func (s *DocumentService) sideFilesSize(userId, documentId uint) (int64, error) {
+	prefix := storage.DocumentPrefix(userId, documentId)
+	blobs, err := s.BlobStore.List(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	var size int64
+	for _, blob := range blobs {
+		_, _, name, ok := storage.ParseDocumentKey(blob.Key)
+		if ok && IsSideFile(name) {
+			size += blob.Size
+		}
+	}
+	return size, nil
+}
+
+// discardDocument permanently deletes a new document that could not be completed, e.g. by importing its file
+func (s *DocumentService) discardDocument(document *models.Document) error {
+	// the file stored meanwhile counts into the storage usage released by the purge
+	current, err := s.GetDocument(document.UserId, document.ID)
+	if err != nil {
+		return err
+	}
+	err = s.DeleteDocument(current.UserId, current.ID)
+	if err != nil {
+		return err
+	}
+	return s.purgeDocument(current)
+}
+
+// EmptyTrash permanently deletes all the user's documents in the trash and returns their number
+func (s *DocumentService) EmptyTrash(userId uint) (int, error) {
+	documents, err := s.DocumentRepository.GetTrashedDocuments(userId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve trash: %w", err)
+	}
+
+	purged := 0
+	for _, document := range documents {
+		err = s.purgeDocument(document)
+		if errors.Is(err, ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// PurgeTrash permanently deletes documents of all users that were moved to the trash before the given time
+// and returns their number. Documents restored in the meantime are kept
+func (s *DocumentService) PurgeTrash(before time.Time) (int, error) {
+	documents, err := s.DocumentRepository.GetTrashedBefore(before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve trashed documents: %w", err)
+	}
+
+	purged := 0
+	for _, document := range documents {
+		err = s.purgeDocument(document)
+		if errors.Is(err, ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
 // EraseLinkedByUserId deletes all the documents uploaded by the user with the given userId
 func (s *DocumentService) EraseLinkedByUserId(userId uint) error {
-	err := s.DocumentRepository.EraseLinkedByUserId(userId)
+	err := s.DocumentRepository.DB.Transaction(func(tx *gorm.DB) error {
+		err := repositories.NewReadingStateRepository(tx).DeleteReadingStatesByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete reading states: %w", err)
+		}
+		err = repositories.NewAnnotationRepository(tx).DeleteAnnotationsByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete annotations: %w", err)
+		}
+		err = repositories.NewBookmarkRepository(tx).DeleteBookmarksByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete bookmarks: %w", err)
+		}
+		err = repositories.NewDocumentRepository(tx).EraseLinkedByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to erase linked documents from the database: %w", err)
+		}
+		err = repositories.NewTagRepository(tx).DeleteTagsByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete tags: %w", err)
+		}
+		err = repositories.NewCollectionRepository(tx).DeleteCollectionsByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete collections: %w", err)
+		}
+		err = repositories.NewVersionRepository(tx).DeleteRetentionPolicy(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete retention policy: %w", err)
+		}
+		err = repositories.NewShareRepository(tx).DeleteSharesByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete shares: %w", err)
+		}
+		err = repositories.NewShareLinkRepository(tx).DeleteLinksByOwnerId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete share links: %w", err)
+		}
+		err = repositories.NewQuotaRepository(tx).DeleteByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete storage usage: %w", err)
+		}
+		err = repositories.NewSftpRepository(tx).DeleteSftpCredentials(userId)
+		if err != nil {
+			return fmt.Errorf("failed to delete sftp credentials: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to erase linked documents from the database: %w", err)
+		return err
 	}
 
-	err = s.SftpService.DeleteUserDirectory(userId)
+	err = storage.DeletePrefix(s.BlobStore, storage.UserPrefix(userId))
 	if err != nil {
-		return fmt.Errorf("failed to erase linked documents from the sftp server: %w", err)
+		return fmt.Errorf("failed to erase linked documents from the storage: %w", err)
 	}
 
 	return nil
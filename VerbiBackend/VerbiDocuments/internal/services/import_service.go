@@ -0,0 +1,248 @@
+package services
+
+import (
+	"VerbiDocuments/internal/clients"
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/opds"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrInvalidImport is returned when a URL or catalog entry cannot be imported
+var ErrInvalidImport = errors.New("invalid import")
+
+// ErrCatalogUnavailable is returned when an OPDS catalog cannot be fetched or is not a valid feed
+var ErrCatalogUnavailable = errors.New("catalog unavailable")
+
+// ErrEntryNotFound is returned when the OPDS feed has no entry with the requested id
+var ErrEntryNotFound = errors.New("catalog entry not found")
+
+// maxFeedSize limits the size of OPDS feeds in bytes
+const maxFeedSize = 10 << 20
+
+// acquisitionTypes are the media types of catalog publications that can be imported, in the order of preference
+var acquisitionTypes = []string{extractors.MimeTypeEpub, extractors.MimeTypePdf}
+
+// ImportService imports documents from URLs and OPDS catalogs. Files are downloaded by import_url jobs into new
+// pending documents and go through the same processing as uploads
+type ImportService struct {
+	DocumentService *DocumentService
+	JobService      *JobService
+	Downloader      interfaces.Downloader
+}
+
+// importPayload is the payload of import_url jobs
+type importPayload struct {
+	Url string `json:"url"`
+}
+
+// NewImportService creates a new ImportService and registers the handler of import_url jobs in the job service
+func NewImportService(documentService *DocumentService, jobService *JobService, downloader interfaces.Downloader) *ImportService {
+	s := &ImportService{
+		DocumentService: documentService,
+		JobService:      jobService,
+		Downloader:      downloader,
+	}
+	jobService.Register(models.JobTypeImportUrl, s.runImportUrl)
+	return s
+}
+
+// parseImportUrl checks that the URL is an absolute http or https URL
+func parseImportUrl(rawURL string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: %q is not an http or https URL", ErrInvalidImport, rawURL)
+	}
+	return parsed, nil
+}
+
+// ImportUrl creates a pending document of the user and queues the job downloading its file from the URL.
+// Without a title the document is named after the file in the URL
+func (s *ImportService) ImportUrl(userId uint, req *requests.ImportUrlRequest) (*models.Document, *models.Job, error) {
+	parsed, err := parseImportUrl(req.Url)
+	if err != nil {
+		return nil, nil, err
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(parsed.Path), path.Ext(parsed.Path))
+	}
+	if title == "" || title == "/" || title == "." {
+		title = parsed.Hostname()
+	}
+	return s.enqueueImport(userId, title, parsed.String(), nil)
+}
+
+// GetCatalog fetches and parses the OPDS feed at the URL
+func (s *ImportService) GetCatalog(ctx context.Context, feedUrl string) (*models.OpdsFeed, error) {
+	parsed, err := parseImportUrl(feedUrl)
+	if err != nil {
+		return nil, err
+	}
+	file, body, err := s.Downloader.Download(ctx, parsed.String())
+	if errors.Is(err, clients.ErrForbiddenAddress) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCatalogUnavailable, err)
+	}
+	defer body.Close()
+
+	base, err := url.Parse(file.Url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCatalogUnavailable, err)
+	}
+	feed, err := opds.Parse(io.LimitReader(body, maxFeedSize), base)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCatalogUnavailable, err)
+	}
+	return feed, nil
+}
+
+// AcquireEntry imports the publication of the OPDS feed entry with the given id, preferring EPUB over PDF.
+// The document gets the title, authors and language of the entry, which are kept over the metadata of the file
+func (s *ImportService) AcquireEntry(ctx context.Context, userId uint, req *requests.AcquireEntryRequest) (*models.Document, *models.Job, error) {
+	feed, err := s.GetCatalog(ctx, req.FeedUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	var entry *models.OpdsEntry
+	for _, candidate := range feed.Entries {
+		if candidate.Id == req.EntryId {
+			entry = candidate
+			break
+		}
+	}
+	if entry == nil {
+		return nil, nil, ErrEntryNotFound
+	}
+	link := opds.Acquisition(entry, acquisitionTypes)
+	if link == nil {
+		return nil, nil, fmt.Errorf("%w: entry has no free EPUB or PDF acquisition link", ErrInvalidImport)
+	}
+	if _, err = parseImportUrl(link.Href); err != nil {
+		return nil, nil, err
+	}
+
+	title := entry.Title
+	if title == "" {
+		title = feed.Title
+	}
+	author := strings.Join(entry.Authors, ", ")
+	update := &requests.UpdateDocumentRequest{Author: &author, Language: &entry.Language}
+	return s.enqueueImport(userId, title, link.Href, update)
+}
+
+// enqueueImport creates a pending document with the title, applies the update to it if there is one
+// and queues the job downloading its file from the URL. The document is discarded if the job cannot be queued
+func (s *ImportService) enqueueImport(
+	userId uint,
+	title, fileUrl string,
+	update *requests.UpdateDocumentRequest,
+) (*models.Document, *models.Job, error) {
+	document, err := s.DocumentService.AddDocument(userId, title)
+	if err != nil {
+		return nil, nil, err
+	}
+	if update != nil {
+		document, err = s.DocumentService.UpdateDocument(userId, document.ID, 0, update)
+	}
+	var job *models.Job
+	if err == nil {
+		job, err = s.JobService.Enqueue(models.JobTypeImportUrl, userId, document.ID, importPayload{Url: fileUrl})
+	}
+	if err != nil {
+		if discardErr := s.DocumentService.discardDocument(document); discardErr != nil {
+			log.Printf("failed to discard document %d: %v", document.ID, discardErr)
+		}
+		return nil, nil, err
+	}
+	return document, job, nil
+}
+
+// runImportUrl runs an import_url job downloading the file of the document and processing it like an upload.
+// Downloads that cannot succeed, e.g. refused by the server, too large or rejected by validation, are not retried,
+// the document gets the rejected status like after its last failed attempt. Deleted documents are not imported
+func (s *ImportService) runImportUrl(ctx context.Context, job *models.Job) error {
+	var payload importPayload
+	if err := DecodePayload(job, &payload); err != nil {
+		return err
+	}
+	_, err := s.DocumentService.GetDocument(job.UserId, job.DocumentId)
+	if errors.Is(err, ErrDocumentNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	file, body, err := s.Downloader.Download(ctx, payload.Url)
+	if err != nil {
+		return s.failImport(ctx, job, err)
+	}
+	defer body.Close()
+
+	_, err = s.DocumentService.ReplaceFile(job.UserId, job.DocumentId, 0, importFileName(file), body, file.Size)
+	if err != nil {
+		return s.failImport(ctx, job, err)
+	}
+	return nil
+}
+
+// failImport rejects the document of the failed import_url job unless the failure is temporary and the job
+// has attempts left. Returns the error to retry the job with, nil if it is not retried
+func (s *ImportService) failImport(ctx context.Context, job *models.Job, err error) error {
+	if ctx.Err() != nil || errors.Is(err, ErrDocumentNotFound) {
+		return err
+	}
+	permanent := errors.Is(err, clients.ErrForbiddenAddress) ||
+		errors.Is(err, clients.ErrDownloadRefused) ||
+		errors.Is(err, clients.ErrDownloadTooLarge) ||
+		errors.Is(err, ErrQuotaExceeded) ||
+		errors.Is(err, ErrInvalidUpdate)
+	if !permanent && !errors.Is(err, ErrFileRejected) && job.Attempts < job.MaxAttempts {
+		return err
+	}
+
+	log.Printf("import of document %d failed: %v", job.DocumentId, err)
+	// rejected files already rejected their document
+	if !errors.Is(err, ErrFileRejected) {
+		rejectErr := s.DocumentService.DocumentRepository.SetProcessingStatus(
+			job.UserId, job.DocumentId, models.ProcessingStatusRejected, err.Error(),
+		)
+		if rejectErr != nil {
+			return fmt.Errorf("failed to reject document %d: %w", job.DocumentId, rejectErr)
+		}
+	}
+	if permanent || errors.Is(err, ErrFileRejected) {
+		return nil
+	}
+	return err
+}
+
+// importFileName returns the name of the downloaded file, using the extension of its content type
+// if the name has no known one and "document" if the server suggests no valid name
+func importFileName(file *models.RemoteFile) string {
+	name := strings.TrimSpace(file.FileName)
+	extension := strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+	if _, known := documentFormats[extension]; !known {
+		for format, mimeType := range documentFormats {
+			if mimeType == file.ContentType {
+				name = strings.TrimSuffix(name, path.Ext(name)) + "." + format
+			}
+		}
+	}
+	if ValidateFileName(name) != nil || strings.HasPrefix(name, ".") {
+		name = "document" + path.Ext(name)
+	}
+	return name
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound is returned when the job does not exist or belongs to another user
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobFinished is returned when canceling a job that already finished
+var ErrJobFinished = errors.New("job already finished")
+
+// maxJobs limits the number of jobs returned by one listing
+const maxJobs = 100
+
+// JobHandler runs a claimed job. The context is canceled when the job is canceled or the workers stop.
+// A returned error fails the attempt, which is retried while the job has attempts left
+type JobHandler func(ctx context.Context, job *models.Job) error
+
+// JobService runs background jobs stored in the database. Every registered job type is worked on by its own workers,
+// as many as its concurrency allows. The concurrency limits one instance of the service, every running instance adds
+// its own workers, so the jobs of a type running at once across all instances are at most its concurrency times the
+// number of instances. Running jobs are checked for cancels every PollInterval. Failed attempts are retried after a
+// delay doubling with every attempt, from RetryDelay up to MaxRetryDelay
+type JobService struct {
+	JobRepository      *repositories.JobRepository
+	MaxAttempts        int
+	RetryDelay         time.Duration
+	MaxRetryDelay      time.Duration
+	Lease              time.Duration
+	PollInterval       time.Duration
+	Retention          time.Duration
+	Concurrency        map[string]int
+	DefaultConcurrency int
+
+	mutex    sync.Mutex
+	handlers map[string]JobHandler
+	wake     map[string]chan struct{}
+}
+
+// NewJobService creates a new JobService with default settings: 5 attempts retried after 10s up to 1h,
+// leases of 10m, polling every 5s, finished jobs kept for a week and one worker per job type
+func NewJobService(jobRepository *repositories.JobRepository) *JobService {
+	return &JobService{
+		JobRepository:      jobRepository,
+		MaxAttempts:        5,
+		RetryDelay:         10 * time.Second,
+		MaxRetryDelay:      time.Hour,
+		Lease:              10 * time.Minute,
+		PollInterval:       5 * time.Second,
+		Retention:          7 * 24 * time.Hour,
+		Concurrency:        map[string]int{},
+		DefaultConcurrency: 1,
+		handlers:           map[string]JobHandler{},
+		wake:               map[string]chan struct{}{},
+	}
+}
+
+// Register sets the handler running jobs of the type
+func (s *JobService) Register(jobType string, handler JobHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[jobType] = handler
+	s.wake[jobType] = make(chan struct{}, 1)
+}
+
+// handler returns the handler of the job type and the channel waking its workers
+func (s *JobService) handler(jobType string) (JobHandler, chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.handlers[jobType], s.wake[jobType]
+}
+
+// Enqueue creates a job of the type for the user's document, the payload is stored as JSON
+func (s *JobService) Enqueue(jobType string, userId, documentId uint, payload interface{}) (*models.Job, error) {
+	handler, wake := s.handler(jobType)
+	if handler == nil {
+		return nil, fmt.Errorf("unknown job type %s", jobType)
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Status:      models.JobStatusQueued,
+		UserId:      userId,
+		DocumentId:  documentId,
+		Payload:     string(encoded),
+		MaxAttempts: s.MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	err = s.JobRepository.CreateJob(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue %s job: %w", jobType, err)
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// GetJob returns the user's job with the given id
+func (s *JobService) GetJob(userId, id uint) (*models.Job, error) {
+	job, err := s.JobRepository.GetJob(userId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve job: %w", err)
+	}
+	return job, nil
+}
+
+// GetJobs returns the user's most recent jobs selected by the query
+func (s *JobService) GetJobs(userId uint, query *models.JobListQuery) ([]*models.Job, error) {
+	jobs, err := s.JobRepository.GetJobs(userId, query.DocumentId, query.Status, maxJobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// CancelJob cancels the user's queued or running job. A running job is interrupted by its worker
+// the next time the worker checks it, within PollInterval
+func (s *JobService) CancelJob(userId, id uint) (*models.Job, error) {
+	err := s.JobRepository.CancelJob(userId, id, time.Now())
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	job, getErr := s.GetJob(userId, id)
+	if getErr != nil {
+		return nil, getErr
+	}
+	if err != nil {
+		return nil, ErrJobFinished
+	}
+	return job, nil
+}
+
+// Start runs the workers of all registered job types and the maintenance of expired leases and finished jobs
+// until the context is canceled
+func (s *JobService) Start(ctx context.Context) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for jobType := range s.handlers {
+		concurrency, ok := s.Concurrency[jobType]
+		if !ok {
+			concurrency = s.DefaultConcurrency
+		}
+		for i := 0; i < concurrency; i++ {
+			go s.work(ctx, jobType)
+		}
+	}
+	go s.maintain(ctx)
+}
+
+// work runs due jobs of the type one after another, waiting for new jobs when none is due
+func (s *JobService) work(ctx context.Context, jobType string) {
+	_, wake := s.handler(jobType)
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		ran, err := s.RunNext(ctx, jobType)
+		if err != nil {
+			log.Printf("failed to run %s job: %v", jobType, err)
+		}
+		if ran {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// maintain queues jobs of workers that stopped extending their leases again and deletes old finished jobs
+func (s *JobService) maintain(ctx context.Context) {
+	ticker := time.NewTicker(s.Lease / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		released, err := s.JobRepository.ReleaseExpiredJobs(time.Now())
+		if err != nil {
+			log.Printf("failed to release expired jobs: %v", err)
+		}
+		if released > 0 {
+			log.Printf("released %d jobs with expired leases", released)
+		}
+		if s.Retention > 0 {
+			_, err = s.JobRepository.DeleteFinishedJobs(time.Now().Add(-s.Retention))
+			if err != nil {
+				log.Printf("failed to delete finished jobs: %v", err)
+			}
+		}
+	}
+}
+
+// RunPending runs due jobs of all registered types in the calling goroutine until none is left
+// and returns how many ran. Jobs waiting for a retry are left for later
+func (s *JobService) RunPending(ctx context.Context) (int, error) {
+	s.mutex.Lock()
+	jobTypes := make([]string, 0, len(s.handlers))
+	for jobType := range s.handlers {
+		jobTypes = append(jobTypes, jobType)
+	}
+	s.mutex.Unlock()
+	sort.Strings(jobTypes)
+
+	count := 0
+	for {
+		ranAny := false
+		for _, jobType := range jobTypes {
+			ran, err := s.RunNext(ctx, jobType)
+			if err != nil {
+				return count, err
+			}
+			if ran {
+				count++
+				ranAny = true
+			}
+		}
+		if !ranAny {
+			return count, nil
+		}
+	}
+}
+
+// RunNext claims the next due job of the type and runs it. Reports whether there was a job to run
+func (s *JobService) RunNext(ctx context.Context, jobType string) (bool, error) {
+	handler, _ := s.handler(jobType)
+	if handler == nil {
+		return false, fmt.Errorf("unknown job type %s", jobType)
+	}
+	if ctx.Err() != nil {
+		return false, nil
+	}
+
+	job, err := s.JobRepository.ClaimJob(jobType, time.Now(), time.Now().Add(s.Lease))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	err = s.run(ctx, handler, job)
+	if err != nil {
+		return true, fmt.Errorf("failed to finish job %d: %w", job.ID, err)
+	}
+	return true, nil
+}
+
+// run runs the handler on the job while extending its lease, then records the outcome of the attempt.
+// A job interrupted by stopping the workers is queued again right away
+func (s *JobService) run(ctx context.Context, handler JobHandler, job *models.Job) error {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.keepLease(jobCtx, cancel, done, job.ID, job.Attempts)
+
+	err := runHandler(jobCtx, handler, job)
+	if err == nil {
+		return s.JobRepository.FinishJob(job.ID, job.Attempts, models.JobStatusSucceeded, "", time.Now())
+	}
+
+	if ctx.Err() != nil {
+		return s.JobRepository.RetryJob(job.ID, job.Attempts, "interrupted by shutdown", time.Now())
+	}
+	log.Printf("attempt %d of %s job %d failed: %v", job.Attempts, job.Type, job.ID, err)
+	if job.Attempts >= job.MaxAttempts {
+		return s.JobRepository.FinishJob(job.ID, job.Attempts, models.JobStatusFailed, err.Error(), time.Now())
+	}
+	return s.JobRepository.RetryJob(job.ID, job.Attempts, err.Error(), time.Now().Add(s.retryDelay(job.Attempts)))
+}
+
+// keepLease extends the lease of the job running the attempt until it is done, checking every PollInterval whether
+// it is still running, and cancels it once it is not
+func (s *JobService) keepLease(ctx context.Context, cancel context.CancelFunc, done chan struct{}, id uint, attempt int) {
+	poll := time.NewTicker(min(s.PollInterval, s.Lease/3))
+	defer poll.Stop()
+	extended := time.Now()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		}
+
+		var err error
+		if time.Since(extended) >= s.Lease/3 {
+			err = s.JobRepository.ExtendLease(id, attempt, time.Now().Add(s.Lease))
+			if err == nil {
+				extended = time.Now()
+			}
+		} else {
+			var running bool
+			running, err = s.JobRepository.IsJobRunning(id, attempt)
+			if err == nil && !running {
+				err = gorm.ErrRecordNotFound
+			}
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			cancel()
+			return
+		}
+		if err != nil {
+			log.Printf("failed to check job %d: %v", id, err)
+		}
+	}
+}
+
+// runHandler calls the handler and turns its panic into an error
+func runHandler(ctx context.Context, handler JobHandler, job *models.Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// retryDelay returns the delay before the attempt after the given one
+func (s *JobService) retryDelay(attempts int) time.Duration {
+	delay := s.RetryDelay
+	for i := 1; i < attempts && delay < s.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.MaxRetryDelay)
+}
+
+// DecodePayload decodes the JSON payload of the job into the value
+func DecodePayload(job *models.Job, value interface{}) error {
+	err := json.Unmarshal([]byte(job.Payload), value)
+	if err != nil {
+		return fmt.Errorf("invalid payload of job %d: %w", job.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/internal/thumbnails"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"strings"
+)
+
+// PreviewFileName is the name of the first page preview the iOS client uploads next to the document
+const PreviewFileName = "preview.pdf"
+
+// MetadataService extracts metadata from uploaded document files and accounts their size in the storage usage
+type MetadataService struct {
+	DocumentRepository *repositories.DocumentRepository
+	QuotaService       *QuotaService
+	BlobStore          interfaces.BlobStore
+}
+
+// NewMetadataService creates a new MetadataService
+func NewMetadataService(
+	documentRepository *repositories.DocumentRepository,
+	quotaService *QuotaService,
+	blobStore interfaces.BlobStore,
+) *MetadataService {
+	return &MetadataService{
+		DocumentRepository: documentRepository,
+		QuotaService:       quotaService,
+		BlobStore:          blobStore,
+	}
+}
+
+// IsDocumentFile reports whether the stored file is the content of a document and not a preview or a service file
+func IsDocumentFile(name string) bool {
+	return name != PreviewFileName && !strings.HasPrefix(name, ".")
+}
+
+// IsSideFile reports whether the stored file is a preview or a temporary file a client writes next to the content
+// of a document. Side files are charged against the storage quota when they are stored, unlike the covers
+// and versions kept by the service
+func IsSideFile(name string) bool {
+	if name == PreviewFileName {
+		return true
+	}
+	if !strings.HasPrefix(name, ".") || name == storage.VersionsDirectory {
+		return false
+	}
+	for _, size := range thumbnails.Sizes {
+		if name == thumbnails.FileName(size) {
+			return false
+		}
+	}
+	return true
+}
+
+// metadataSaveAttempts is how many times saving extracted metadata is retried when the document changes concurrently
+const metadataSaveAttempts = 3
+
+// ProcessUpload extracts metadata of a file uploaded to a document directory and saves it to the document.
+// Descriptive fields are only filled when they are empty, so user edits survive re-uploads.
+// The storage usage of the owner changes by the difference between the sizes of the new and the previous file
+// together with the metadata, the previous file is deleted if its name differs. Fails with ErrQuotaExceeded
+// when the new file does not fit into the quota of the owner.
+// Returns the updated document or nil when the file is not the content of a document
+func (s *MetadataService) ProcessUpload(key string) (*models.Document, error) {
+	userId, documentId, name, ok := storage.ParseDocumentKey(key)
+	if !ok || !IsDocumentFile(name) {
+		return nil, nil
+	}
+
+	_, err := s.DocumentRepository.GetDocument(userId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find document %d of user %d: %w", documentId, userId, err)
+	}
+	quota, err := s.QuotaService.GetQuota(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.BlobStore.Stat(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	reader, err := s.BlobStore.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	defer reader.Close()
+
+	metadata, err := extractors.ExtractMetadata(reader, info.Size)
+	if errors.Is(err, extractors.ErrUnsupportedFormat) {
+		log.Printf("document %d has unsupported format %s", documentId, metadata.MimeType)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to extract metadata of %s: %w", key, err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		document, err := s.DocumentRepository.GetDocument(userId, documentId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find document %d of user %d: %w", documentId, userId, err)
+		}
+
+		current := &document.Metadata
+		previousName, previousSize := document.FileName, current.FileSize
+		if current.Author == "" {
+			current.Author = metadata.Author
+		}
+		if current.OriginalTitle == "" {
+			current.OriginalTitle = metadata.OriginalTitle
+		}
+		if current.Language == "" {
+			current.Language = metadata.Language
+		}
+		if current.PublishedAt == nil {
+			current.PublishedAt = metadata.PublishedAt
+		}
+		current.PageCount = metadata.PageCount
+		current.FileSize = metadata.FileSize
+		current.MimeType = metadata.MimeType
+		current.Sha256 = metadata.Sha256
+		document.FileName = name
+		document.Broken = false
+		document.ProcessingStatus = models.ProcessingStatusReady
+		document.RejectionReason = ""
+
+		// the document is re-read when the user edits it between reading and saving
+		err = s.saveMetadata(document, current.FileSize-previousSize, quota)
+		if errors.Is(err, gorm.ErrRecordNotFound) && attempt < metadataSaveAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save metadata of document %d: %w", documentId, err)
+		}
+
+		if previousName != "" && previousName != name {
+			err = s.BlobStore.Delete(storage.DocumentKey(userId, documentId, previousName))
+			if err != nil {
+				log.Printf("failed to delete previous file of document %d: %v", documentId, err)
+			}
+		}
+		return document, nil
+	}
+}
+
+// saveMetadata saves the document and changes the storage usage of its owner by delta in one transaction.
+// Fails with ErrQuotaExceeded when the usage would exceed the quota
+func (s *MetadataService) saveMetadata(document *models.Document, delta int64, quota *models.StorageQuota) error {
+	return s.DocumentRepository.DB.Transaction(func(tx *gorm.DB) error {
+		err := repositories.NewDocumentRepository(tx).UpdateDocument(document)
+		if err != nil || delta == 0 {
+			return err
+		}
+		err = repositories.NewQuotaRepository(tx).AddUsage(document.UserId, delta, 0, quota)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: at most %d bytes are allowed", ErrQuotaExceeded, quota.MaxBytes)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update storage usage: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"strings"
+)
+
+// ProcessingService runs the processing pipeline of uploaded document files.
+// Text extraction and cover generation run as background jobs
+type ProcessingService struct {
+	ValidationService *ValidationService
+	MetadataService   *MetadataService
+	TextService       *TextService
+	CoverService      *CoverService
+	VersionService    *VersionService
+	JobService        *JobService
+}
+
+// uploadPayload is the payload of process_upload jobs
+type uploadPayload struct {
+	Key        string `json:"key"`
+	UploaderId uint   `json:"uploader_id"`
+}
+
+// NewProcessingService creates a new ProcessingService and registers the handlers of its jobs in the job service
+func NewProcessingService(
+	validationService *ValidationService,
+	metadataService *MetadataService,
+	textService *TextService,
+	coverService *CoverService,
+	versionService *VersionService,
+	jobService *JobService,
+) *ProcessingService {
+	s := &ProcessingService{
+		ValidationService: validationService,
+		MetadataService:   metadataService,
+		TextService:       textService,
+		CoverService:      coverService,
+		VersionService:    versionService,
+		JobService:        jobService,
+	}
+	jobService.Register(models.JobTypeProcessUpload, s.runProcessUpload)
+	jobService.Register(models.JobTypeExtractText, s.runExtractText)
+	jobService.Register(models.JobTypeGenerateCovers, s.runGenerateCovers)
+	return s
+}
+
+// UploadCompleted handles an upload.completed event: the document becomes pending and a job processing the uploaded
+// file is queued. Events of files that are not the content of a document or of missing documents are ignored
+func (s *ProcessingService) UploadCompleted(event *models.UploadEvent) (*models.Job, error) {
+	if !IsDocumentFile(event.FileName) {
+		return nil, nil
+	}
+	documentRepository := s.MetadataService.DocumentRepository
+	_, err := documentRepository.GetDocument(event.UserId, event.DocumentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find document %d of user %d: %w", event.DocumentId, event.UserId, err)
+	}
+
+	err = documentRepository.SetProcessingStatus(event.UserId, event.DocumentId, models.ProcessingStatusPending, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark document %d pending: %w", event.DocumentId, err)
+	}
+	key := storage.DocumentKey(event.UserId, event.DocumentId, event.FileName)
+	return s.JobService.Enqueue(
+		models.JobTypeProcessUpload,
+		event.UserId,
+		event.DocumentId,
+		uploadPayload{Key: key, UploaderId: event.UploaderId},
+	)
+}
+
+// ProcessUpload validates a file uploaded to a document directory by the uploader and extracts its metadata, then records
+// it as a new version and queues the jobs indexing its text and regenerating covers. A failure of one of the last
+// steps does not prevent the others. The document is pending while its file is validated. A rejected file is deleted,
+// the document gets the rejected status and the error wrapping ErrFileRejected is returned. A file that does not fit
+// into the storage quota is handled the same way and the error wrapping ErrQuotaExceeded is returned.
+// Returns the updated document, which is nil if the metadata was not saved or the file is not the content of a document
+func (s *ProcessingService) ProcessUpload(key string, uploaderId uint) (*models.Document, error) {
+	userId, documentId, name, ok := storage.ParseDocumentKey(key)
+	if !ok || !IsDocumentFile(name) {
+		return nil, nil
+	}
+
+	documentRepository := s.MetadataService.DocumentRepository
+	_, err := documentRepository.GetDocument(userId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find document %d of user %d: %w", documentId, userId, err)
+	}
+	err = documentRepository.SetProcessingStatus(userId, documentId, models.ProcessingStatusPending, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark document %d pending: %w", documentId, err)
+	}
+	err = s.ValidationService.Validate(key)
+	if errors.Is(err, ErrFileRejected) {
+		s.reject(key, err)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := s.MetadataService.ProcessUpload(key)
+	if errors.Is(err, ErrQuotaExceeded) {
+		s.reject(key, err)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, nil
+	}
+
+	var errs []error
+	err = s.VersionService.RecordVersion(document, uploaderId)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to record version of document %d: %w", document.ID, err))
+	}
+	for _, jobType := range []string{models.JobTypeExtractText, models.JobTypeGenerateCovers} {
+		_, err = s.JobService.Enqueue(jobType, document.UserId, document.ID, struct{}{})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return document, errors.Join(errs...)
+}
+
+// runProcessUpload runs a process_upload job. Rejected files, files exceeding the quota, files deleted or renamed meanwhile
+// and files of deleted documents are not retried. The document of a file that is gone is ready again.
+// A file that still fails on the last attempt, for example because the scanner is down, is rejected
+func (s *ProcessingService) runProcessUpload(ctx context.Context, job *models.Job) error {
+	var payload uploadPayload
+	if err := DecodePayload(job, &payload); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := s.ProcessUpload(payload.Key, payload.UploaderId)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		log.Printf("uploaded file %s is gone", payload.Key)
+		err = s.MetadataService.DocumentRepository.SetProcessingStatus(job.UserId, job.DocumentId, models.ProcessingStatusReady, "")
+		if err != nil {
+			return fmt.Errorf("failed to mark document %d ready: %w", job.DocumentId, err)
+		}
+		return nil
+	}
+	if errors.Is(err, ErrFileRejected) || errors.Is(err, ErrQuotaExceeded) || errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("upload %s was not processed: %v", payload.Key, err)
+		return nil
+	}
+	if err != nil && ctx.Err() == nil && job.Attempts >= job.MaxAttempts {
+		s.reject(payload.Key, fmt.Errorf("file could not be validated: %w", err))
+	}
+	return err
+}
+
+// currentDocument returns the document of the job, nil if it was deleted meanwhile
+func (s *ProcessingService) currentDocument(ctx context.Context, job *models.Job) (*models.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	document, err := s.MetadataService.DocumentRepository.GetDocument(job.UserId, job.DocumentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find document %d: %w", job.DocumentId, err)
+	}
+	return document, nil
+}
+
+// runExtractText runs an extract_text job indexing the current file of the document
+func (s *ProcessingService) runExtractText(ctx context.Context, job *models.Job) error {
+	document, err := s.currentDocument(ctx, job)
+	if err != nil || document == nil {
+		return err
+	}
+	err = s.TextService.ExtractText(document)
+	if err != nil {
+		return fmt.Errorf("failed to index document %d: %w", document.ID, err)
+	}
+	return nil
+}
+
+// runGenerateCovers runs a generate_covers job rendering covers of the current file of the document
+func (s *ProcessingService) runGenerateCovers(ctx context.Context, job *models.Job) error {
+	document, err := s.currentDocument(ctx, job)
+	if err != nil || document == nil {
+		return err
+	}
+	err = s.CoverService.GenerateCovers(document)
+	if err != nil {
+		return fmt.Errorf("failed to generate covers of document %d: %w", document.ID, err)
+	}
+	return nil
+}
+
+// AbandonUpload discards an uploaded file whose processing failed for a reason other than its content and sets
+// the processing status of its document back to status with the reason
+func (s *ProcessingService) AbandonUpload(key, status, reason string) {
+	userId, documentId, _, _ := storage.ParseDocumentKey(key)
+	s.discardUpload(key)
+	err := s.MetadataService.DocumentRepository.SetProcessingStatus(userId, documentId, status, reason)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("failed to reset the status of document %d: %v", documentId, err)
+	}
+}
+
+// reject discards the rejected file and marks its document rejected
+func (s *ProcessingService) reject(key string, rejection error) {
+	userId, documentId, _, _ := storage.ParseDocumentKey(key)
+	s.discardUpload(key)
+
+	reason := strings.TrimPrefix(rejection.Error(), ErrFileRejected.Error()+": ")
+	err := s.MetadataService.DocumentRepository.SetProcessingStatus(userId, documentId, models.ProcessingStatusRejected, reason)
+	if err != nil {
+		log.Printf("failed to mark document %d rejected: %v", documentId, err)
+	}
+}
+
+// discardUpload deletes an uploaded file that is not accepted. If the file replaced the current file
+// of the document under the same name, the current file is restored from its version
+func (s *ProcessingService) discardUpload(key string) {
+	userId, documentId, name, _ := storage.ParseDocumentKey(key)
+	blobStore := s.MetadataService.BlobStore
+
+	document, err := s.MetadataService.DocumentRepository.GetDocument(userId, documentId)
+	if err == nil && document.FileName == name && document.Metadata.Sha256 != "" {
+		var reader interfaces.BlobReader
+		reader, err = blobStore.Get(storage.VersionKey(userId, documentId, document.Metadata.Sha256))
+		if err == nil {
+			err = blobStore.Put(key, reader, document.Metadata.FileSize)
+			reader.Close()
+		}
+		if err == nil {
+			return
+		}
+		log.Printf("failed to restore the file of document %d: %v", documentId, err)
+	}
+	if err := blobStore.Delete(key); err != nil {
+		log.Printf("failed to delete discarded file %s: %v", key, err)
+	}
+}
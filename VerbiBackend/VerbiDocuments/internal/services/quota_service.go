@@ -0,0 +1,140 @@
+package services
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+)
+
+// ErrQuotaExceeded is returned when an upload or a new document does not fit into the user's storage quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// ErrQuotaNotFound is returned when the user has no quota of their own
+var ErrQuotaNotFound = errors.New("quota not found")
+
+// QuotaService enforces per-user limits of the number of documents and the total size of their files
+type QuotaService struct {
+	QuotaRepository *repositories.QuotaRepository
+	DefaultQuota    models.StorageQuota
+}
+
+// NewQuotaService creates a new QuotaService applying the default limits to users without a quota of their own
+func NewQuotaService(quotaRepository *repositories.QuotaRepository, maxBytes, maxDocuments int64) *QuotaService {
+	return &QuotaService{
+		QuotaRepository: quotaRepository,
+		DefaultQuota:    models.StorageQuota{MaxBytes: maxBytes, MaxDocuments: maxDocuments},
+	}
+}
+
+// GetQuota returns the quota of the user or the default quota if the user has none
+func (s *QuotaService) GetQuota(userId uint) (*models.StorageQuota, error) {
+	quota, err := s.QuotaRepository.GetQuota(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		quota := s.DefaultQuota
+		quota.UserId = userId
+		return &quota, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve quota: %w", err)
+	}
+	return quota, nil
+}
+
+// GetUsage returns the storage usage of the user together with the quota applied to it
+func (s *QuotaService) GetUsage(userId uint) (*models.StorageUsage, *models.StorageQuota, error) {
+	usage, err := s.QuotaRepository.GetUsage(userId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve storage usage: %w", err)
+	}
+	quota, err := s.GetQuota(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return usage, quota, nil
+}
+
+// AllowedFileSize returns the largest file the user can upload in place of a file of the replaced size,
+// or -1 if the size is not limited
+func (s *QuotaService) AllowedFileSize(userId uint, replaced int64) (int64, error) {
+	usage, quota, err := s.GetUsage(userId)
+	if err != nil {
+		return 0, err
+	}
+	if quota.MaxBytes == 0 {
+		return -1, nil
+	}
+	return max(quota.MaxBytes-usage.Bytes+replaced, 0), nil
+}
+
+// AddBytes changes the storage usage of the user by the given number of bytes.
+// Fails with ErrQuotaExceeded when a positive amount does not fit into the quota
+func (s *QuotaService) AddBytes(userId uint, bytes int64) error {
+	quota, err := s.GetQuota(userId)
+	if err != nil {
+		return err
+	}
+	err = s.QuotaRepository.AddUsage(userId, bytes, 0, quota)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: at most %d bytes are allowed", ErrQuotaExceeded, quota.MaxBytes)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update storage usage: %w", err)
+	}
+	return nil
+}
+
+// SetQuota sets the quota of the user overriding the default one
+func (s *QuotaService) SetQuota(userId uint, maxBytes, maxDocuments int64) (*models.StorageQuota, error) {
+	if maxBytes < 0 || maxDocuments < 0 {
+		return nil, errors.New("quota limits must not be negative")
+	}
+	quota := &models.StorageQuota{UserId: userId, MaxBytes: maxBytes, MaxDocuments: maxDocuments}
+	err := s.QuotaRepository.SaveQuota(quota)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save quota: %w", err)
+	}
+	return quota, nil
+}
+
+// ResetQuota deletes the quota of the user, so the default quota applies again
+func (s *QuotaService) ResetQuota(userId uint) error {
+	err := s.QuotaRepository.DeleteQuota(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrQuotaNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete quota: %w", err)
+	}
+	return nil
+}
+
+// quotaReader fails with ErrQuotaExceeded once more than the allowed number of bytes is read
+type quotaReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+// Read reads from the underlying reader counting the allowed bytes down
+func (r *quotaReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, ErrQuotaExceeded
+	}
+	return n, err
+}
+
+// limitUpload checks the declared size of an upload against the allowed size and makes the reader fail
+// when the content turns out to be larger. A negative allowed size does not limit the upload
+func limitUpload(reader io.Reader, size, allowed int64) (io.Reader, error) {
+	if allowed < 0 {
+		return reader, nil
+	}
+	if size > allowed {
+		return nil, fmt.Errorf("%w: file of %d bytes does not fit into %d free bytes", ErrQuotaExceeded, size, allowed)
+	}
+	return &quotaReader{reader: reader, remaining: allowed}, nil
+}
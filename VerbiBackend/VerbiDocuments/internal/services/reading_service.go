@@ -0,0 +1,126 @@
+package services
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ErrInvalidReadingState is returned when a reading state can't be saved as requested
+var ErrInvalidReadingState = errors.New("invalid reading state")
+
+// ReadingService syncs the position of the user in their own and shared documents between the user's devices
+type ReadingService struct {
+	ReadingStateRepository *repositories.ReadingStateRepository
+	DocumentRepository     *repositories.DocumentRepository
+}
+
+// NewReadingService creates a new ReadingService
+func NewReadingService(
+	readingStateRepository *repositories.ReadingStateRepository,
+	documentRepository *repositories.DocumentRepository,
+) *ReadingService {
+	return &ReadingService{
+		ReadingStateRepository: readingStateRepository,
+		DocumentRepository:     documentRepository,
+	}
+}
+
+// checkReadable returns ErrDocumentNotFound if the user can't read the document
+func (s *ReadingService) checkReadable(userId, documentId uint) error {
+	_, err := s.DocumentRepository.GetReadableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrDocumentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	return nil
+}
+
+// GetReadingState returns the user's state of the document. Documents the user has never opened are to be read
+func (s *ReadingService) GetReadingState(userId, documentId uint) (*models.ReadingState, error) {
+	err := s.checkReadable(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.ReadingStateRepository.GetReadingState(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.ReadingState{DocumentId: documentId, UserId: userId, Status: models.ReadingStatusToRead}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve reading state: %w", err)
+	}
+	return state, nil
+}
+
+// UpdateReadingState saves the state of the document sent by a device of the user unless another device saved
+// a later one, and returns the state that won. Times in the future are taken as now, so a device with a clock
+// running ahead can't block changes from the others. The status is reading if not given
+func (s *ReadingService) UpdateReadingState(
+	userId, documentId uint,
+	req *requests.UpdateReadingStateRequest,
+) (*models.ReadingState, error) {
+	if req.Percentage < 0 || req.Percentage > 100 {
+		return nil, fmt.Errorf("%w: percentage must be from 0 to 100", ErrInvalidReadingState)
+	}
+	status := req.Status
+	switch status {
+	case "":
+		status = models.ReadingStatusReading
+	case models.ReadingStatusToRead, models.ReadingStatusReading, models.ReadingStatusFinished:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidReadingState, status)
+	}
+	if req.UpdatedAt.IsZero() {
+		return nil, fmt.Errorf("%w: updated_at is required", ErrInvalidReadingState)
+	}
+	err := s.checkReadable(userId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	state := &models.ReadingState{
+		DocumentId: documentId,
+		UserId:     userId,
+		Location:   req.Location,
+		Percentage: req.Percentage,
+		Status:     status,
+		UpdatedAt:  notAfter(req.UpdatedAt.UTC(), now),
+		SyncedAt:   now,
+	}
+	if req.LastOpenedAt != nil {
+		lastOpenedAt := notAfter(req.LastOpenedAt.UTC(), now)
+		state.LastOpenedAt = &lastOpenedAt
+	}
+
+	saved, err := s.ReadingStateRepository.SaveReadingState(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save reading state: %w", err)
+	}
+	return saved, nil
+}
+
+// notAfter returns t or limit if t is after it
+func notAfter(t, limit time.Time) time.Time {
+	if t.After(limit) {
+		return limit
+	}
+	return t
+}
+
+// GetReadingStates returns the user's reading states stored after since, all if it is nil, in the order they were stored.
+// Devices pass the synced_at of the last state they got to sync only the changes
+func (s *ReadingService) GetReadingStates(userId uint, since *time.Time) ([]*models.ReadingState, error) {
+	states, err := s.ReadingStateRepository.GetReadingStates(userId, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve reading states: %w", err)
+	}
+	return states, nil
+}
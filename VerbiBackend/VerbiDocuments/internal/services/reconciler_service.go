@@ -0,0 +1,185 @@
+package services
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	reconcileRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "verbi_documents_reconcile_runs_total",
+		Help: "Number of storage and database reconciliations by result",
+	}, []string{"result"})
+	reconcileOrphans = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "verbi_documents_reconcile_orphan_blobs",
+		Help: "Number of stored files without a document found by the last reconciliation",
+	})
+	reconcileMissing = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "verbi_documents_reconcile_missing_files",
+		Help: "Number of documents without their file found by the last reconciliation",
+	})
+	reconcileDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "verbi_documents_reconcile_deleted_blobs_total",
+		Help: "Number of orphan files deleted by reconciliations",
+	})
+	reconcileLastRun = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "verbi_documents_reconcile_last_run_timestamp_seconds",
+		Help: "Time when the last reconciliation finished",
+	})
+)
+
+// ReconcilerService finds and repairs inconsistencies between the blob store and the documents table
+type ReconcilerService struct {
+	DocumentRepository *repositories.DocumentRepository
+	BlobStore          interfaces.BlobStore
+	GracePeriod        time.Duration
+
+	running    sync.Mutex
+	mutex      sync.Mutex
+	lastReport *models.ReconcileReport
+}
+
+// NewReconcilerService creates a new ReconcilerService. Orphan files younger than the grace period are never deleted
+func NewReconcilerService(
+	documentRepository *repositories.DocumentRepository,
+	blobStore interfaces.BlobStore,
+	gracePeriod time.Duration,
+) *ReconcilerService {
+	return &ReconcilerService{
+		DocumentRepository: documentRepository,
+		BlobStore:          blobStore,
+		GracePeriod:        gracePeriod,
+	}
+}
+
+// documentOfKey returns the user and the document directory the key is stored in
+func documentOfKey(key string) (userId, documentId uint, ok bool) {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 {
+		return 0, 0, false
+	}
+	user, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	document, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(user), uint(document), true
+}
+
+// Reconcile walks the blob store and the documents table and reports files without documents and documents without files.
+// With repair enabled, orphan files older than the grace period are deleted and documents without files are marked broken.
+// Documents whose file reappeared are marked healthy again
+func (s *ReconcilerService) Reconcile(repair bool) (*models.ReconcileReport, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	report := &models.ReconcileReport{
+		StartedAt:    time.Now(),
+		Repair:       repair,
+		Orphans:      []*models.OrphanBlob{},
+		MissingFiles: []*models.MissingFile{},
+		Restored:     []uint{},
+		Errors:       []string{},
+	}
+
+	documents, err := s.DocumentRepository.GetAllDocuments()
+	if err != nil {
+		reconcileRuns.WithLabelValues("error").Inc()
+		return nil, fmt.Errorf("failed to retrieve documents: %w", err)
+	}
+	blobs, err := s.BlobStore.List("")
+	if err != nil {
+		reconcileRuns.WithLabelValues("error").Inc()
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	report.DocumentsChecked = len(documents)
+	report.BlobsChecked = len(blobs)
+
+	owners := make(map[uint]uint, len(documents))
+	for _, document := range documents {
+		owners[document.ID] = document.UserId
+	}
+	stored := make(map[string]bool, len(blobs))
+	for _, blob := range blobs {
+		stored[blob.Key] = true
+	}
+
+	for _, blob := range blobs {
+		userId, documentId, ok := documentOfKey(blob.Key)
+		if ok && owners[documentId] == userId {
+			continue
+		}
+
+		orphan := &models.OrphanBlob{Key: blob.Key, Size: blob.Size, ModTime: blob.ModTime}
+		if repair && time.Since(blob.ModTime) > s.GracePeriod {
+			if err := s.BlobStore.Delete(blob.Key); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to delete %s: %v", blob.Key, err))
+			} else {
+				orphan.Deleted = true
+				reconcileDeleted.Inc()
+			}
+		}
+		report.Orphans = append(report.Orphans, orphan)
+	}
+
+	for _, document := range documents {
+		if document.FileName == "" {
+			continue
+		}
+		key := storage.DocumentKey(document.UserId, document.ID, document.FileName)
+		if stored[key] {
+			if document.Broken && repair {
+				if err := s.DocumentRepository.SetBroken(document.UserId, document.ID, false); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("failed to restore document %d: %v", document.ID, err))
+				} else {
+					report.Restored = append(report.Restored, document.ID)
+				}
+			}
+			continue
+		}
+
+		missing := &models.MissingFile{DocumentId: document.ID, UserId: document.UserId, Key: key, MarkedBroken: document.Broken}
+		if repair && !document.Broken {
+			if err := s.DocumentRepository.SetBroken(document.UserId, document.ID, true); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to mark document %d broken: %v", document.ID, err))
+			} else {
+				missing.MarkedBroken = true
+			}
+		}
+		report.MissingFiles = append(report.MissingFiles, missing)
+	}
+
+	report.FinishedAt = time.Now()
+	s.mutex.Lock()
+	s.lastReport = report
+	s.mutex.Unlock()
+
+	reconcileOrphans.Set(float64(len(report.Orphans)))
+	reconcileMissing.Set(float64(len(report.MissingFiles)))
+	reconcileLastRun.Set(float64(report.FinishedAt.Unix()))
+	if len(report.Errors) > 0 {
+		reconcileRuns.WithLabelValues("partial").Inc()
+	} else {
+		reconcileRuns.WithLabelValues("success").Inc()
+	}
+	return report, nil
+}
+
+// LastReport returns the report of the last finished reconciliation or nil if there was none
+func (s *ReconcilerService) LastReport() *models.ReconcileReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastReport
+}
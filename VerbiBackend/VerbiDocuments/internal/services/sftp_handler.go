@@ -0,0 +1,551 @@
+package services
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/storage"
+	"errors"
+	"fmt"
+	"github.com/pkg/sftp"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sftpHandler implements sftp request handlers on top of the blob store.
+// Directories are virtual: "/<userId>" and "/<userId>/<documentId>" always exist,
+// deeper directories exist as long as they contain files. Directories of documents in the trash are hidden.
+// Documents other users shared with the user are readable at their own paths "/<ownerId>/<documentId>".
+// Uploads and renamed files are limited to the size returned by quota for their key, -1 if the size is not limited,
+// keys quota returns an error for can not be written. Stored and deleted files are reported to charge with the change
+// of the stored size, a file is not stored if charge fails. Files readable returns an error for can not be read.
+// Files closed after writing and renamed files are reported to onUpload
+type sftpHandler struct {
+	store    interfaces.BlobStore
+	root     string
+	onUpload func(key string)
+	trashed  func() (map[string]bool, error)
+	shared   func() (map[string]bool, error)
+	quota    func(key string) (int64, error)
+	charge   func(key string, delta int64) error
+	readable func(key string) error
+}
+
+// blobFileInfo describes a blob or a virtual directory for sftp clients
+type blobFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *blobFileInfo) Name() string       { return i.name }
+func (i *blobFileInfo) Size() int64        { return i.size }
+func (i *blobFileInfo) ModTime() time.Time { return i.modTime }
+func (i *blobFileInfo) IsDir() bool        { return i.dir }
+func (i *blobFileInfo) Sys() interface{}   { return nil }
+func (i *blobFileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// listerAt serves a fixed list of entries to the sftp server
+type listerAt []os.FileInfo
+
+// ListAt copies entries starting from the given offset
+func (l listerAt) ListAt(entries []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(entries, l[offset:])
+	if n < len(entries) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// sftpUpload buffers a file written over sftp in a temporary file and stores it on close
+type sftpUpload struct {
+	*os.File
+	store    interfaces.BlobStore
+	key      string
+	limit    int64
+	exceeded atomic.Bool
+	written  atomic.Bool
+	onUpload func(key string)
+	charge   func(key string, delta int64) error
+}
+
+// WriteAt writes to the temporary file unless the file would grow past the limit of the upload.
+// An upload that exceeded the limit once is not stored
+func (u *sftpUpload) WriteAt(p []byte, off int64) (int, error) {
+	if u.limit >= 0 && off+int64(len(p)) > u.limit {
+		u.exceeded.Store(true)
+		return 0, ErrQuotaExceeded
+	}
+	u.written.Store(true)
+	return u.File.WriteAt(p, off)
+}
+
+// Close moves the uploaded content to the blob store and reports the upload if anything was written
+func (u *sftpUpload) Close() error {
+	defer os.Remove(u.Name())
+	defer u.File.Close()
+
+	if u.exceeded.Load() {
+		return ErrQuotaExceeded
+	}
+	info, err := u.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = u.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	delta, err := chargeStored(u.store, u.charge, u.key, info.Size())
+	if err != nil {
+		return err
+	}
+	err = u.store.Put(u.key, u.File, info.Size())
+	if err != nil {
+		refund(u.charge, u.key, delta)
+		return err
+	}
+
+	if u.written.Load() && u.onUpload != nil {
+		u.onUpload(u.key)
+	}
+	return nil
+}
+
+// chargeStored charges the change of the stored size when the object under the key is replaced by one of the given size
+// and returns the charged change
+func chargeStored(store interfaces.BlobStore, charge func(key string, delta int64) error, key string, size int64) (int64, error) {
+	if charge == nil {
+		return 0, nil
+	}
+	var previous int64
+	info, err := store.Stat(key)
+	if err == nil {
+		previous = info.Size
+	} else if !errors.Is(err, storage.ErrBlobNotFound) {
+		return 0, err
+	}
+	delta := size - previous
+	return delta, charge(key, delta)
+}
+
+// refund returns the charged change of the stored size of the key
+func refund(charge func(key string, delta int64) error, key string, delta int64) {
+	if charge == nil || delta == 0 {
+		return
+	}
+	if err := charge(key, -delta); err != nil {
+		log.Printf("failed to refund %d bytes of %s: %v", delta, key, err)
+	}
+}
+
+// resolve converts an sftp path to a storage key and checks that it is inside the user's directory
+// or a directory of a document shared with the user
+func (h *sftpHandler) resolve(filepath string) (string, error) {
+	key := strings.TrimPrefix(path.Clean("/"+filepath), "/")
+	if !h.isOwn(key) {
+		return h.resolveShared(key)
+	}
+
+	if key != h.root && h.trashed != nil {
+		trashed, err := h.trashed()
+		if err != nil {
+			return "", err
+		}
+		document, _, _ := strings.Cut(key[len(h.root)+1:], "/")
+		if trashed[document] {
+			return "", os.ErrNotExist
+		}
+	}
+	return key, nil
+}
+
+// resolveShared checks that the key of another user's directory belongs to a document shared with the user
+// or is the directory of its owner
+func (h *sftpHandler) resolveShared(key string) (string, error) {
+	if h.shared == nil {
+		return "", os.ErrPermission
+	}
+	shared, err := h.shared()
+	if err != nil {
+		return "", err
+	}
+
+	owner, rest, _ := strings.Cut(key, "/")
+	document, _, _ := strings.Cut(rest, "/")
+	if shared[owner+"/"+document] {
+		return key, nil
+	}
+	if rest == "" {
+		for directory := range shared {
+			if strings.HasPrefix(directory, owner+"/") {
+				return key, nil
+			}
+		}
+	}
+	return "", os.ErrPermission
+}
+
+// isOwn reports whether the key is inside the user's directory
+func (h *sftpHandler) isOwn(key string) bool {
+	return key == h.root || strings.HasPrefix(key, h.root+"/")
+}
+
+// isReadOnly reports whether the key refers to stored versions, which are immutable and only change by retention,
+// or to a document shared with the user
+func (h *sftpHandler) isReadOnly(key string) bool {
+	return !h.isOwn(key) || storage.IsVersionKey(key)
+}
+
+// isVirtualDirectory reports whether the resolved key is a user or a document directory
+func (h *sftpHandler) isVirtualDirectory(key string) bool {
+	return strings.Count(key, "/") < 2
+}
+
+// Fileread opens a file for downloading
+func (h *sftpHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	key, err := h.resolve(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if h.readable != nil {
+		if err = h.readable(key); err != nil {
+			return nil, err
+		}
+	}
+
+	reader, err := h.store.Get(key)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, os.ErrNotExist
+	}
+	return reader, err
+}
+
+// Filewrite opens a file for uploading
+func (h *sftpHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	key, err := h.resolve(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if h.isVirtualDirectory(key) || h.isReadOnly(key) {
+		return nil, os.ErrPermission
+	}
+
+	limit := int64(-1)
+	if h.quota != nil {
+		limit, err = h.quota(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	temp, err := os.CreateTemp("", "verbi-sftp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	if r.Pflags().Append {
+		existing, err := h.store.Get(key)
+		if err == nil {
+			_, err = io.Copy(temp, existing)
+			existing.Close()
+		}
+		if err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+			temp.Close()
+			os.Remove(temp.Name())
+			return nil, err
+		}
+	}
+
+	return &sftpUpload{File: temp, store: h.store, key: key, limit: limit, onUpload: h.onUpload, charge: h.charge}, nil
+}
+
+// Filecmd handles commands changing the file tree
+func (h *sftpHandler) Filecmd(r *sftp.Request) error {
+	key, err := h.resolve(r.Filepath)
+	if err != nil {
+		return err
+	}
+
+	if h.isReadOnly(key) && r.Method != "Setstat" && r.Method != "Mkdir" {
+		return os.ErrPermission
+	}
+
+	switch r.Method {
+	case "Setstat", "Mkdir":
+		return nil
+	case "Remove":
+		info, err := h.store.Stat(key)
+		if err != nil {
+			return os.ErrNotExist
+		}
+		return h.delete(key, info.Size)
+	case "Rmdir":
+		info, err := h.stat(key)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return sftp.ErrSSHFxFailure
+		}
+		return h.removeDirectory(key)
+	case "Rename":
+		target, err := h.resolve(r.Target)
+		if err != nil {
+			return err
+		}
+		return h.rename(key, target)
+	}
+
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// removeDirectory deletes the files in the directory and its subdirectories. Stored versions are kept
+func (h *sftpHandler) removeDirectory(key string) error {
+	blobs, err := h.store.List(key + "/")
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		if storage.IsVersionKey(blob.Key) {
+			continue
+		}
+		if err := h.delete(blob.Key, blob.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete deletes the file of the given size and refunds its size
+func (h *sftpHandler) delete(key string, size int64) error {
+	if err := h.store.Delete(key); err != nil {
+		return err
+	}
+	refund(h.charge, key, size)
+	return nil
+}
+
+// rename copies the file to the new key and removes the old one. The old file is refunded first,
+// so its size is available to the new one
+func (h *sftpHandler) rename(source, target string) error {
+	if h.isVirtualDirectory(target) || h.isReadOnly(target) {
+		return os.ErrPermission
+	}
+
+	info, err := h.store.Stat(source)
+	if err != nil {
+		return os.ErrNotExist
+	}
+	refund(h.charge, source, info.Size)
+	err = h.copy(source, target, info.Size)
+	if err != nil {
+		if h.charge != nil {
+			if chargeErr := h.charge(source, info.Size); chargeErr != nil {
+				log.Printf("failed to charge %s again: %v", source, chargeErr)
+			}
+		}
+		return err
+	}
+	return h.store.Delete(source)
+}
+
+// copy stores the file of the given size under the target key if it fits into the quota and reports the upload
+func (h *sftpHandler) copy(source, target string, size int64) error {
+	if h.quota != nil {
+		limit, err := h.quota(target)
+		if err != nil {
+			return err
+		}
+		if limit >= 0 && size > limit {
+			return ErrQuotaExceeded
+		}
+	}
+
+	reader, err := h.store.Get(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	delta, err := chargeStored(h.store, h.charge, target, size)
+	if err != nil {
+		return err
+	}
+	err = h.store.Put(target, reader, size)
+	if err != nil {
+		refund(h.charge, target, delta)
+		return err
+	}
+
+	if h.onUpload != nil {
+		h.onUpload(target)
+	}
+	return nil
+}
+
+// Filelist lists directories and stats files
+func (h *sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	if path.Clean("/"+r.Filepath) == "/" {
+		if r.Method == "List" {
+			return h.listUsers()
+		}
+		return listerAt{&blobFileInfo{name: "/", dir: true, modTime: time.Now()}}, nil
+	}
+
+	key, err := h.resolve(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Method {
+	case "List":
+		return h.list(key)
+	case "Stat":
+		info, err := h.stat(key)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	}
+
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// stat describes a file or a directory
+func (h *sftpHandler) stat(key string) (os.FileInfo, error) {
+	blob, err := h.store.Stat(key)
+	if err == nil {
+		return &blobFileInfo{name: path.Base(key), size: blob.Size, modTime: blob.ModTime}, nil
+	}
+	if !errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, err
+	}
+
+	if !h.isVirtualDirectory(key) {
+		children, err := h.store.List(key + "/")
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 {
+			return nil, os.ErrNotExist
+		}
+	}
+	return &blobFileInfo{name: path.Base(key), dir: true, modTime: time.Now()}, nil
+}
+
+// listUsers returns the directory of the user followed by directories of owners of documents shared with the user
+func (h *sftpHandler) listUsers() (sftp.ListerAt, error) {
+	result := listerAt{&blobFileInfo{name: h.root, dir: true, modTime: time.Now()}}
+	if h.shared == nil {
+		return result, nil
+	}
+	shared, err := h.shared()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]bool)
+	for directory := range shared {
+		owner, _, _ := strings.Cut(directory, "/")
+		owners[owner] = true
+	}
+	names := make([]string, 0, len(owners))
+	for owner := range owners {
+		names = append(names, owner)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result, &blobFileInfo{name: name, dir: true, modTime: time.Now()})
+	}
+	return result, nil
+}
+
+// listShared returns directories of documents the owner shared with the user
+func (h *sftpHandler) listShared(owner string) (sftp.ListerAt, error) {
+	shared, err := h.shared()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for directory := range shared {
+		if document, ok := strings.CutPrefix(directory, owner+"/"); ok {
+			names = append(names, document)
+		}
+	}
+	sort.Strings(names)
+
+	result := make(listerAt, 0, len(names))
+	for _, name := range names {
+		result = append(result, &blobFileInfo{name: name, dir: true, modTime: time.Now()})
+	}
+	return result, nil
+}
+
+// list returns immediate children of a directory
+func (h *sftpHandler) list(key string) (sftp.ListerAt, error) {
+	if key != h.root && !strings.Contains(key, "/") {
+		return h.listShared(key)
+	}
+
+	blobs, err := h.store.List(key + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*blobFileInfo)
+	for _, blob := range blobs {
+		name, _, nested := strings.Cut(strings.TrimPrefix(blob.Key, key+"/"), "/")
+		if !nested {
+			entries[name] = &blobFileInfo{name: name, size: blob.Size, modTime: blob.ModTime}
+			continue
+		}
+		if entry, ok := entries[name]; !ok || entry.modTime.Before(blob.ModTime) {
+			entries[name] = &blobFileInfo{name: name, dir: true, modTime: blob.ModTime}
+		}
+	}
+
+	if key == h.root && h.trashed != nil {
+		trashed, err := h.trashed()
+		if err != nil {
+			return nil, err
+		}
+		for name := range trashed {
+			delete(entries, name)
+		}
+	}
+
+	if len(entries) == 0 && !h.isVirtualDirectory(key) {
+		if _, err := h.store.Stat(key); err == nil {
+			return nil, sftp.ErrSSHFxFailure
+		}
+		return nil, os.ErrNotExist
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make(listerAt, 0, len(names))
+	for _, name := range names {
+		result = append(result, entries[name])
+	}
+	return result, nil
+}
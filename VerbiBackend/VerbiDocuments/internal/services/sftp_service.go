@@ -1,134 +1,212 @@
 package services
 
 import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
 	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
 	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
-// SftpService works with sftp server
+// SftpService serves the documents storage over sftp
 type SftpService struct {
-	SftpRepository *repositories.SftpRepository
+	SftpRepository     *repositories.SftpRepository
+	DocumentRepository *repositories.DocumentRepository
+	ShareRepository    *repositories.ShareRepository
+	BlobStore          interfaces.BlobStore
+	ProcessingService  *ProcessingService
+	QuotaService       *QuotaService
 }
 
 // NewSftpService creates an instance of SftpService
-func NewSftpService(sftpRepository *repositories.SftpRepository) *SftpService {
+func NewSftpService(
+	sftpRepository *repositories.SftpRepository,
+	documentRepository *repositories.DocumentRepository,
+	shareRepository *repositories.ShareRepository,
+	blobStore interfaces.BlobStore,
+	processingService *ProcessingService,
+	quotaService *QuotaService,
+) *SftpService {
 	return &SftpService{
-		SftpRepository: sftpRepository,
+		SftpRepository:     sftpRepository,
+		DocumentRepository: documentRepository,
+		ShareRepository:    shareRepository,
+		BlobStore:          blobStore,
+		ProcessingService:  processingService,
+		QuotaService:       quotaService,
 	}
 }
 
-// createClient creates and returns a new sftp connection
-func (s *SftpService) createClient(userId uint) (*sftp.Client, error) {
-	credentials, err := s.SftpRepository.GetSftpCredentials(userId)
+// Authenticate checks temporary sftp credentials and returns id of the user they belong to
+func (s *SftpService) Authenticate(username, password string) (uint, bool) {
+	credentials, err := s.SftpRepository.GetSftpCredentialsByUsername(username)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve sftp credentials: %w", err)
+		log.Printf("Auth error for user %s: %v", username, err)
+		return 0, false
 	}
 
-	config := &ssh.ClientConfig{
-		User: credentials.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(credentials.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	if subtle.ConstantTimeCompare([]byte(credentials.Password), []byte(password)) != 1 {
+		return 0, false
 	}
+	return credentials.UserId, true
+}
 
-	connection, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", credentials.Host, credentials.Port), config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to sftp server: %w", err)
-	}
+// SftpSession is an sftp connection of the user. It counts the uploads completed during the session
+type SftpSession struct {
+	Handlers sftp.Handlers
+	service  *SftpService
+	userId   uint
+	uploads  atomic.Int64
+}
 
-	client, err := sftp.NewClient(connection)
-	if err != nil {
-		connection.Close()
-		return nil, fmt.Errorf("failed to create sftp client: %w", err)
+// OpenSession returns a session with sftp request handlers that give the user access to their own directory
+// in the blob store except directories of documents in the trash, and read access to documents shared with the user.
+// Only content and side files of the user's documents can be written and they must fit into the storage quota of the user.
+// Content files of pending documents can not be read until they are validated
+func (s *SftpService) OpenSession(userId uint) *SftpSession {
+	session := &SftpSession{service: s, userId: userId}
+	handler := &sftpHandler{
+		store:    s.BlobStore,
+		root:     strconv.FormatUint(uint64(userId), 10),
+		onUpload: session.completeUpload,
+		trashed:  func() (map[string]bool, error) { return s.trashedDirectories(userId) },
+		shared:   func() (map[string]bool, error) { return s.sharedDirectories(userId) },
+		quota:    func(key string) (int64, error) { return s.allowedUploadSize(userId, key) },
+		charge:   func(key string, delta int64) error { return s.chargeSideFile(userId, key, delta) },
+		readable: s.checkReadable,
 	}
 
-	return client, nil
+	session.Handlers = sftp.Handlers{
+		FileGet:  handler,
+		FilePut:  handler,
+		FileCmd:  handler,
+		FileList: handler,
+	}
+	return session
 }
 
-// CreateUserDirectory creates a new directory on the SFTP server for the given user
-func (s *SftpService) CreateUserDirectory(userId uint) (string, error) {
-	directoryPath := fmt.Sprintf("/%d", userId)
+// Uploads returns the number of upload.completed events of the session
+func (s *SftpSession) Uploads() int64 {
+	return s.uploads.Load()
+}
 
-	client, err := s.createClient(userId)
-	if err != nil {
-		return "", fmt.Errorf("failed to create sftp client: %w", err)
+// completeUpload emits the upload.completed event of a file written to a document directory. The event is counted
+// in the session and handled by the processing service, which marks the document pending and queues the processing.
+// Files outside of document directories do not emit events
+func (s *SftpSession) completeUpload(key string) {
+	userId, documentId, name, ok := storage.ParseDocumentKey(key)
+	if !ok {
+		return
 	}
-	defer func(client *sftp.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Printf("failed to close sftp client: %v", err)
-		}
-	}(client)
+	event := &models.UploadEvent{
+		Type:        models.EventUploadCompleted,
+		UserId:      userId,
+		DocumentId:  documentId,
+		UploaderId:  s.userId,
+		FileName:    name,
+		CompletedAt: time.Now(),
+	}
+	s.uploads.Add(1)
 
-	err = client.Mkdir(directoryPath)
+	log.Printf("%s: document %d file %s", event.Type, documentId, name)
+	_, err := s.service.ProcessingService.UploadCompleted(event)
 	if err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
+		log.Printf("failed to handle %s of %s: %v", event.Type, key, err)
 	}
-
-	return directoryPath, nil
 }
 
-// DeleteUserDirectory deletes all files uploaded by the user with the given userId
-func (s *SftpService) DeleteUserDirectory(userId uint) error {
-	directoryPath := fmt.Sprintf("/%d", userId)
-
-	client, err := s.createClient(userId)
+// trashedDirectories returns names of the user's document directories that belong to documents in the trash
+func (s *SftpService) trashedDirectories(userId uint) (map[string]bool, error) {
+	ids, err := s.DocumentRepository.GetTrashedIds(userId)
 	if err != nil {
-		return fmt.Errorf("failed to create sftp client: %w", err)
+		return nil, fmt.Errorf("failed to retrieve trashed documents: %w", err)
 	}
-	defer func(client *sftp.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Printf("failed to close sftp client: %v", err)
-		}
-	}(client)
 
-	err = client.RemoveDirectory(directoryPath)
-	if err != nil {
-		return fmt.Errorf("failed to delete directory: %w", err)
+	directories := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		directories[strconv.FormatUint(uint64(id), 10)] = true
 	}
-
-	return s.SftpRepository.DeleteSftpCredentials(userId)
+	return directories, nil
 }
 
-// CreateDocumentDirectory creates a directory for a new file
-func (s *SftpService) CreateDocumentDirectory(userId, documentId uint) error {
-	directoryPath := fmt.Sprintf("/%d/%d", userId, documentId)
-
-	client, err := s.createClient(userId)
+// checkReadable refuses reading content files of pending documents, which may not have been validated yet
+func (s *SftpService) checkReadable(key string) error {
+	ownerId, documentId, name, ok := storage.ParseDocumentKey(key)
+	if !ok || !IsDocumentFile(name) {
+		return nil
+	}
+	document, err := s.DocumentRepository.GetDocument(ownerId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create sftp client: %w", err)
+		return fmt.Errorf("failed to find document %d: %w", documentId, err)
 	}
+	if document.ProcessingStatus == models.ProcessingStatusPending {
+		return os.ErrPermission
+	}
+	return nil
+}
 
-	err = client.Mkdir(directoryPath)
+// sharedDirectories returns "<ownerId>/<documentId>" keys of directories of documents shared with the user
+func (s *SftpService) sharedDirectories(userId uint) (map[string]bool, error) {
+	shares, err := s.ShareRepository.GetSharedWithUser(userId)
 	if err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		return nil, fmt.Errorf("failed to retrieve shared documents: %w", err)
 	}
 
-	return nil
+	directories := make(map[string]bool, len(shares))
+	for _, share := range shares {
+		directories[fmt.Sprintf("%d/%d", share.OwnerId, share.DocumentId)] = true
+	}
+	return directories, nil
 }
 
-// DeleteDocumentDirectory deletes a directory created for a file
-func (s *SftpService) DeleteDocumentDirectory(userId, documentId uint) error {
-	directoryPath := fmt.Sprintf("/%d/%d", userId, documentId)
-
-	client, err := s.createClient(userId)
+// allowedUploadSize returns how large the file uploaded to the key may be, -1 if the size is not limited.
+// Only content and side files of the user's existing documents can be uploaded, content files are charged when
+// they are processed and side files when they are stored. An uploaded file replaces the current one with its key
+// or the current content file, so their size is available again
+func (s *SftpService) allowedUploadSize(userId uint, key string) (int64, error) {
+	_, documentId, name, ok := storage.ParseDocumentKey(key)
+	if !ok || (!IsDocumentFile(name) && !IsSideFile(name)) {
+		return 0, os.ErrPermission
+	}
+	document, err := s.DocumentRepository.GetDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, os.ErrPermission
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create sftp client: %w", err)
+		return 0, fmt.Errorf("failed to find document %d: %w", documentId, err)
 	}
 
-	err = client.RemoveDirectory(directoryPath)
-	if err != nil {
-		return fmt.Errorf("failed to delete directory: %w", err)
+	replaced := document.Metadata.FileSize
+	if IsSideFile(name) {
+		replaced = 0
+		info, err := s.BlobStore.Stat(key)
+		if err == nil {
+			replaced = info.Size
+		} else if !errors.Is(err, storage.ErrBlobNotFound) {
+			return 0, fmt.Errorf("failed to stat %s: %w", key, err)
+		}
 	}
+	return s.QuotaService.AllowedFileSize(userId, replaced)
+}
 
-	err = client.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close sftp client: %w", err)
+// chargeSideFile changes the storage usage of the user by the change of the size of a side file written
+// or deleted over sftp. Other files are not charged here
+func (s *SftpService) chargeSideFile(userId uint, key string, delta int64) error {
+	_, _, name, ok := storage.ParseDocumentKey(key)
+	if !ok || !IsSideFile(name) || delta == 0 {
+		return nil
 	}
-	return nil
+	return s.QuotaService.AddBytes(userId, delta)
 }
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// ErrLinkNotFound is returned when a share link does not exist, its signature is invalid or it was revoked
+var ErrLinkNotFound = errors.New("link not found")
+
+// ErrLinkExpired is returned when a share link has expired or reached its download limit
+var ErrLinkExpired = errors.New("link has expired")
+
+// ErrLinkPassword is returned when a share link is protected by a password and a different one is given
+var ErrLinkPassword = errors.New("invalid link password")
+
+// ErrInvalidLink is returned when the options of a new share link are invalid
+var ErrInvalidLink = errors.New("invalid link")
+
+// linkKeyLength is the number of random bytes in the key of a share link
+const linkKeyLength = 16
+
+// LinkContent is what a share link serves: the document file or, for text only links to a page range,
+// the text extracted from the pages
+type LinkContent struct {
+	Document *models.Document
+	File     interfaces.BlobReader
+	Pages    []*models.DocumentPage
+}
+
+// ShareLinkService handles public links to documents for people without a Verbi account.
+// Link tokens are random keys signed with the secret, so forged tokens are rejected without a database lookup
+type ShareLinkService struct {
+	ShareLinkRepository *repositories.ShareLinkRepository
+	DocumentRepository  *repositories.DocumentRepository
+	PageRepository      *repositories.PageRepository
+	BlobStore           interfaces.BlobStore
+	Secret              []byte
+}
+
+// NewShareLinkService creates a new ShareLinkService
+func NewShareLinkService(
+	shareLinkRepository *repositories.ShareLinkRepository,
+	documentRepository *repositories.DocumentRepository,
+	pageRepository *repositories.PageRepository,
+	blobStore interfaces.BlobStore,
+	secret []byte,
+) *ShareLinkService {
+	return &ShareLinkService{
+		ShareLinkRepository: shareLinkRepository,
+		DocumentRepository:  documentRepository,
+		PageRepository:      pageRepository,
+		BlobStore:           blobStore,
+		Secret:              secret,
+	}
+}
+
+// sign returns the signature of the link key
+func (s *ShareLinkService) sign(key string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// withToken fills in the token, password and text only flags of the link for its owner
+func (s *ShareLinkService) withToken(link *models.ShareLink) *models.ShareLink {
+	link.Token = link.Key + "." + s.sign(link.Key)
+	link.HasPassword = link.PasswordHash != ""
+	link.TextOnly = link.PageFrom > 0
+	return link
+}
+
+// CreateLink creates a public link to the owner's document with the given restrictions
+func (s *ShareLinkService) CreateLink(ownerId, documentId uint, req *requests.CreateShareLinkRequest) (*models.ShareLink, error) {
+	document, err := s.DocumentRepository.GetDocument(ownerId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidLink)
+	}
+	if (req.PageFrom == 0) != (req.PageTo == 0) || req.PageTo < req.PageFrom {
+		return nil, fmt.Errorf("%w: page range must have both ends with page_from not after page_to", ErrInvalidLink)
+	}
+	if document.Metadata.PageCount > 0 && req.PageTo > document.Metadata.PageCount {
+		return nil, fmt.Errorf("%w: document has %d pages", ErrInvalidLink, document.Metadata.PageCount)
+	}
+
+	random := make([]byte, linkKeyLength)
+	_, err = rand.Read(random)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate link key: %w", err)
+	}
+	link := &models.ShareLink{
+		DocumentId:   documentId,
+		OwnerId:      ownerId,
+		Key:          base64.RawURLEncoding.EncodeToString(random),
+		ExpiresAt:    req.ExpiresAt,
+		MaxDownloads: req.MaxDownloads,
+		PageFrom:     req.PageFrom,
+		PageTo:       req.PageTo,
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash link password: %w", err)
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	err = s.ShareLinkRepository.CreateLink(link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save link: %w", err)
+	}
+	return s.withToken(link), nil
+}
+
+// GetLinks returns all links to the owner's document with their access counts
+func (s *ShareLinkService) GetLinks(ownerId, documentId uint) ([]*models.ShareLink, error) {
+	_, err := s.DocumentRepository.GetDocument(ownerId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+
+	links, err := s.ShareLinkRepository.GetLinks(ownerId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve links: %w", err)
+	}
+	for _, link := range links {
+		s.withToken(link)
+	}
+	return links, nil
+}
+
+// RevokeLink disables the link to the owner's document. Revoked links stay listed with their access counts
+func (s *ShareLinkService) RevokeLink(ownerId, documentId, id uint) error {
+	err := s.ShareLinkRepository.RevokeLink(ownerId, documentId, id, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrLinkNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke link: %w", err)
+	}
+	return nil
+}
+
+// getLink returns the link with the given token checking its signature, revocation, expiry and password
+func (s *ShareLinkService) getLink(token, password string) (*models.ShareLink, error) {
+	key, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(key))) {
+		return nil, ErrLinkNotFound
+	}
+
+	link, err := s.ShareLinkRepository.GetLinkByKey(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLinkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve link: %w", err)
+	}
+
+	if link.RevokedAt != nil {
+		return nil, ErrLinkNotFound
+	}
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		return nil, ErrLinkExpired
+	}
+	if link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads {
+		return nil, fmt.Errorf("%w: download limit reached", ErrLinkExpired)
+	}
+	if link.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+		return nil, ErrLinkPassword
+	}
+	return link, nil
+}
+
+// OpenLink opens the document file served by the link with the given token and counts the download.
+// The file of a pending document is not served until it is validated. Text only links are opened with OpenLinkText.
+// The caller must close the file of the content
+func (s *ShareLinkService) OpenLink(token, password string) (*LinkContent, error) {
+	return s.openLink(token, password, false)
+}
+
+// OpenLinkText returns the text of the pages served by the text only link with the given token and counts the download
+func (s *ShareLinkService) OpenLinkText(token, password string) (*LinkContent, error) {
+	return s.openLink(token, password, true)
+}
+
+// openLink opens the content served by the link with the given token and counts the download.
+// Fails with ErrLinkNotFound unless the link is text only exactly when text is requested
+func (s *ShareLinkService) openLink(token, password string, text bool) (*LinkContent, error) {
+	link, err := s.getLink(token, password)
+	if err != nil {
+		return nil, err
+	}
+	if text && link.PageFrom == 0 {
+		return nil, fmt.Errorf("%w: link serves the file of the document", ErrLinkNotFound)
+	}
+	if !text && link.PageFrom > 0 {
+		return nil, fmt.Errorf("%w: link serves the text of pages %d-%d only", ErrLinkNotFound, link.PageFrom, link.PageTo)
+	}
+
+	document, err := s.DocumentRepository.GetDocument(link.OwnerId, link.DocumentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLinkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+
+	content := &LinkContent{Document: document}
+	if link.PageFrom > 0 {
+		content.Pages, err = s.PageRepository.GetPages(link.OwnerId, link.DocumentId, link.PageFrom, link.PageTo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve pages: %w", err)
+		}
+		if len(content.Pages) == 0 {
+			return nil, fmt.Errorf("%w: text of the pages is not available yet", ErrLinkNotFound)
+		}
+	} else {
+		if document.FileName == "" {
+			return nil, fmt.Errorf("%w: file is not uploaded yet", ErrLinkNotFound)
+		}
+		if document.ProcessingStatus == models.ProcessingStatusPending {
+			return nil, fmt.Errorf("%w: file is being processed", ErrLinkNotFound)
+		}
+		content.File, err = s.BlobStore.Get(storage.DocumentKey(link.OwnerId, link.DocumentId, document.FileName))
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return nil, fmt.Errorf("%w: file is missing", ErrLinkNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+	}
+
+	err = s.ShareLinkRepository.CountDownload(link.ID, time.Now())
+	if err != nil {
+		if content.File != nil {
+			content.File.Close()
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: download limit reached", ErrLinkExpired)
+		}
+		return nil, fmt.Errorf("failed to count download: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"VerbiDocuments/internal/clients"
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrShareNotFound is returned when the document has no share with the requested id
+var ErrShareNotFound = errors.New("share not found")
+
+// ErrInvalidShare is returned when a document can't be shared as requested
+var ErrInvalidShare = errors.New("invalid share")
+
+// ErrUserNotFound is returned when there is no Verbi user with the requested username or email
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrTooManyLookups is returned when the user looked up too many other users recently
+var ErrTooManyLookups = errors.New("too many users looked up, try again later")
+
+// ShareService handles sharing documents with other users. Shared documents stay in the owner's storage.
+// Every user can look up at most LookupLimit other users within LookupWindow, so sharing can not be used to probe
+// which accounts exist. The lookups are counted by each instance of the service
+type ShareService struct {
+	ShareRepository    *repositories.ShareRepository
+	DocumentRepository *repositories.DocumentRepository
+	UserDirectory      interfaces.UserDirectory
+	LookupLimit        int
+	LookupWindow       time.Duration
+	lookupsMutex       sync.Mutex
+	lookups            map[uint][]time.Time
+}
+
+// NewShareService creates a new ShareService
+func NewShareService(
+	shareRepository *repositories.ShareRepository,
+	documentRepository *repositories.DocumentRepository,
+	userDirectory interfaces.UserDirectory,
+) *ShareService {
+	return &ShareService{
+		ShareRepository:    shareRepository,
+		DocumentRepository: documentRepository,
+		UserDirectory:      userDirectory,
+		LookupLimit:        20,
+		LookupWindow:       time.Hour,
+		lookups:            map[uint][]time.Time{},
+	}
+}
+
+// allowLookup counts a lookup of another user by the user and reports whether it is within the limit
+func (s *ShareService) allowLookup(userId uint, now time.Time) bool {
+	s.lookupsMutex.Lock()
+	defer s.lookupsMutex.Unlock()
+
+	var recent []time.Time
+	for _, at := range s.lookups[userId] {
+		if now.Sub(at) < s.LookupWindow {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= s.LookupLimit {
+		s.lookups[userId] = recent
+		return false
+	}
+	s.lookups[userId] = append(recent, now)
+	return true
+}
+
+// getDocument returns the owner's document mapping a missing one to ErrDocumentNotFound
+func (s *ShareService) getDocument(ownerId, documentId uint) (*models.Document, error) {
+	document, err := s.DocumentRepository.GetDocument(ownerId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+	return document, nil
+}
+
+// ShareDocument grants the user with exactly the given username or email the permission to the owner's document.
+// Sharing the document with the same user again changes the permission. Fails with ErrTooManyLookups when the owner
+// looked up too many users recently
+func (s *ShareService) ShareDocument(ownerId, documentId uint, login, permission string) (*models.Share, error) {
+	if permission != models.PermissionRead && permission != models.PermissionAnnotate {
+		return nil, fmt.Errorf("%w: permission must be %s or %s", ErrInvalidShare, models.PermissionRead, models.PermissionAnnotate)
+	}
+	document, err := s.getDocument(ownerId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.allowLookup(ownerId, time.Now()) {
+		return nil, ErrTooManyLookups
+	}
+	user, err := s.UserDirectory.FindUser(strings.TrimSpace(login))
+	if errors.Is(err, clients.ErrUserNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if user.UserId == ownerId {
+		return nil, fmt.Errorf("%w: documents can't be shared with their owner", ErrInvalidShare)
+	}
+
+	share := &models.Share{
+		DocumentId: document.ID,
+		OwnerId:    ownerId,
+		UserId:     user.UserId,
+		Username:   user.Username,
+		Permission: permission,
+	}
+	err = s.ShareRepository.SaveShare(share)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save share: %w", err)
+	}
+	return share, nil
+}
+
+// GetShares returns the users the owner's document is shared with
+func (s *ShareService) GetShares(ownerId, documentId uint) ([]*models.Share, error) {
+	_, err := s.getDocument(ownerId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := s.ShareRepository.GetShares(ownerId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve shares: %w", err)
+	}
+	return shares, nil
+}
+
+// RevokeShare takes the access to the owner's document away from the user of the share
+func (s *ShareService) RevokeShare(ownerId, documentId, id uint) error {
+	err := s.ShareRepository.DeleteShare(ownerId, documentId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrShareNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+	return nil
+}
+
+// GetSharedWithMe returns documents other users shared with the user together with the granted permissions.
+// Personal data of the owners such as notes, tags and collections is left out
+func (s *ShareService) GetSharedWithMe(userId uint) ([]*models.Share, error) {
+	shares, err := s.ShareRepository.GetSharedWithUser(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve shared documents: %w", err)
+	}
+	for _, share := range shares {
+		hidePersonalData(share.Document)
+	}
+	return shares, nil
+}
+
+// hidePersonalData clears fields of the document that only its owner may see
+func hidePersonalData(document *models.Document) {
+	document.Notes = ""
+	document.Tags = []models.Tag{}
+	document.Collections = []models.Collection{}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// ErrTagNotFound is returned when the user has no tag with the requested id
+var ErrTagNotFound = errors.New("tag not found")
+
+// ErrInvalidTag is returned when a tag would get an empty name or the name of another tag
+var ErrInvalidTag = errors.New("invalid tag")
+
+// TagService handles actions related to labeling documents with free-form tags
+type TagService struct {
+	TagRepository      *repositories.TagRepository
+	DocumentRepository *repositories.DocumentRepository
+	DocumentService    *DocumentService
+}
+
+// NewTagService creates a new TagService
+func NewTagService(
+	tagRepository *repositories.TagRepository,
+	documentRepository *repositories.DocumentRepository,
+	documentService *DocumentService,
+) *TagService {
+	return &TagService{
+		TagRepository:      tagRepository,
+		DocumentRepository: documentRepository,
+		DocumentService:    documentService,
+	}
+}
+
+// GetTags returns all tags of the user
+func (s *TagService) GetTags(userId uint) ([]*models.Tag, error) {
+	tags, err := s.TagRepository.GetTags(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tags: %w", err)
+	}
+	return tags, nil
+}
+
+// getTag returns the user's tag mapping a missing one to ErrTagNotFound
+func (s *TagService) getTag(userId, id uint) (*models.Tag, error) {
+	tag, err := s.TagRepository.GetTag(userId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTagNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tag: %w", err)
+	}
+	return tag, nil
+}
+
+// validateName trims the tag name and checks that it is not empty and no other tag of the user has it
+func (s *TagService) validateName(userId, id uint, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name must not be empty", ErrInvalidTag)
+	}
+
+	existing, err := s.TagRepository.GetTagByName(userId, name)
+	if err == nil && existing.ID != id {
+		return "", fmt.Errorf("%w: tag %q already exists", ErrInvalidTag, name)
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("failed to check tag name: %w", err)
+	}
+	return name, nil
+}
+
+// CreateTag creates a tag of the user
+func (s *TagService) CreateTag(userId uint, name string) (*models.Tag, error) {
+	name, err := s.validateName(userId, 0, name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &models.Tag{UserId: userId, Name: name}
+	err = s.TagRepository.CreateTag(tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save tag: %w", err)
+	}
+	return tag, nil
+}
+
+// RenameTag changes the name of the user's tag on all its documents
+func (s *TagService) RenameTag(userId, id uint, name string) (*models.Tag, error) {
+	tag, err := s.getTag(userId, id)
+	if err != nil {
+		return nil, err
+	}
+	tag.Name, err = s.validateName(userId, id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.TagRepository.RenameTag(userId, id, tag.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename tag: %w", err)
+	}
+	return tag, nil
+}
+
+// DeleteTag deletes the user's tag and detaches it from all documents
+func (s *TagService) DeleteTag(userId, id uint) error {
+	err := s.TagRepository.DeleteTag(userId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTagNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return nil
+}
+
+// AddDocument attaches the user's tag to the document, adding it twice has no effect
+func (s *TagService) AddDocument(userId, id, documentId uint) error {
+	tag, err := s.getTag(userId, id)
+	if err != nil {
+		return err
+	}
+	document, err := s.DocumentService.GetDocument(userId, documentId)
+	if err != nil {
+		return err
+	}
+
+	err = s.DocumentRepository.AddTag(document, tag)
+	if err != nil {
+		return fmt.Errorf("failed to tag document: %w", err)
+	}
+	return nil
+}
+
+// RemoveDocument detaches the user's tag from the document
+func (s *TagService) RemoveDocument(userId, id, documentId uint) error {
+	tag, err := s.getTag(userId, id)
+	if err != nil {
+		return err
+	}
+	document, err := s.DocumentService.GetDocument(userId, documentId)
+	if err != nil {
+		return err
+	}
+
+	err = s.DocumentRepository.RemoveTag(document, tag)
+	if err != nil {
+		return fmt.Errorf("failed to untag document: %w", err)
+	}
+	return nil
+}
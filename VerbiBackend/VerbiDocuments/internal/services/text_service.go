@@ -0,0 +1,129 @@
+package services
+
+import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTextPages is the largest number of pages returned by a single text request
+const MaxTextPages = 100
+
+// MaxSearchHits is the largest number of search hits returned at once
+const MaxSearchHits = 100
+
+// maxSearchQueryLength limits the length of search queries in characters
+const maxSearchQueryLength = 256
+
+// ErrInvalidSearch is returned when search parameters are invalid
+var ErrInvalidSearch = errors.New("invalid search")
+
+// ErrTextNotExtracted is returned when the document has no extracted text yet
+var ErrTextNotExtracted = errors.New("document text is not extracted")
+
+// TextService extracts plain text from document files and serves it by pages
+type TextService struct {
+	DocumentRepository *repositories.DocumentRepository
+	PageRepository     *repositories.PageRepository
+	BlobStore          interfaces.BlobStore
+}
+
+// NewTextService creates a new TextService
+func NewTextService(
+	documentRepository *repositories.DocumentRepository,
+	pageRepository *repositories.PageRepository,
+	blobStore interfaces.BlobStore,
+) *TextService {
+	return &TextService{
+		DocumentRepository: documentRepository,
+		PageRepository:     pageRepository,
+		BlobStore:          blobStore,
+	}
+}
+
+// ExtractText extracts text of the document file and replaces the stored pages of the document.
+// Documents in unsupported formats are left without pages
+func (s *TextService) ExtractText(document *models.Document) error {
+	key := storage.DocumentKey(document.UserId, document.ID, document.FileName)
+	info, err := s.BlobStore.Stat(key)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	reader, err := s.BlobStore.Get(key)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	defer reader.Close()
+
+	pages, err := extractors.ExtractText(reader, info.Size, document.Metadata.MimeType)
+	if errors.Is(err, extractors.ErrUnsupportedFormat) {
+		pages = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to extract text of %s: %w", key, err)
+	}
+
+	err = s.PageRepository.ReplacePages(document.UserId, document.ID, pages)
+	if err != nil {
+		return fmt.Errorf("failed to save text of document %d: %w", document.ID, err)
+	}
+	return nil
+}
+
+// GetText returns pages of the user's or a shared document with numbers from the given range together with the total number of pages.
+// Returns ErrTextNotExtracted if the document has no pages, e.g. while its file is processed
+func (s *TextService) GetText(userId, documentId uint, from, to int) ([]*models.DocumentPage, int, error) {
+	if from < 1 || to < from {
+		return nil, 0, errors.New("invalid page range")
+	}
+	if to-from >= MaxTextPages {
+		return nil, 0, fmt.Errorf("at most %d pages can be requested at once", MaxTextPages)
+	}
+
+	document, err := s.DocumentRepository.GetReadableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, 0, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+
+	count, err := s.PageRepository.CountPages(document.UserId, documentId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count pages: %w", err)
+	}
+	if count == 0 {
+		return nil, 0, ErrTextNotExtracted
+	}
+	pages, err := s.PageRepository.GetPages(document.UserId, documentId, from, to)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve pages: %w", err)
+	}
+	return pages, count, nil
+}
+
+// Search finds pages of the user's documents matching the query. Quoted phrases, "or" and "-" exclusions are supported
+func (s *TextService) Search(userId uint, query string, limit, offset int) ([]*models.SearchHit, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: empty query", ErrInvalidSearch)
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return nil, fmt.Errorf("%w: query is longer than %d characters", ErrInvalidSearch, maxSearchQueryLength)
+	}
+	if limit < 1 || limit > MaxSearchHits || offset < 0 {
+		return nil, fmt.Errorf("%w: limit must be from 1 to %d and offset must not be negative", ErrInvalidSearch, MaxSearchHits)
+	}
+
+	hits, err := s.PageRepository.SearchPages(userId, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+	return hits, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/interfaces"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// ErrFileRejected is returned when an uploaded file does not pass validation, the error tells the reason
+var ErrFileRejected = errors.New("file rejected")
+
+// ValidationService checks uploaded document files before they are processed: the format detected by magic bytes
+// must be allowed, the file must be readable, neither encrypted nor DRM protected, within the size and page limits
+// and clean according to the malware scanner
+type ValidationService struct {
+	BlobStore        interfaces.BlobStore
+	Scanner          interfaces.Scanner
+	AllowedMimeTypes []string
+	MaxFileSize      int64
+	MaxPageCount     int
+}
+
+// NewValidationService creates a new ValidationService. Zero limits are not applied, a nil scanner skips malware scanning
+func NewValidationService(
+	blobStore interfaces.BlobStore,
+	scanner interfaces.Scanner,
+	allowedMimeTypes []string,
+	maxFileSize int64,
+	maxPageCount int,
+) *ValidationService {
+	return &ValidationService{
+		BlobStore:        blobStore,
+		Scanner:          scanner,
+		AllowedMimeTypes: allowedMimeTypes,
+		MaxFileSize:      maxFileSize,
+		MaxPageCount:     maxPageCount,
+	}
+}
+
+// rejection returns the error rejecting a file for the reason
+func rejection(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrFileRejected, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the file stored under the key. Returns an error wrapping ErrFileRejected if the file is not accepted.
+// A failure of the scanner is returned as a plain error, so the file stays pending and its processing can be retried
+func (s *ValidationService) Validate(key string) error {
+	info, err := s.BlobStore.Stat(key)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	if info.Size == 0 {
+		return rejection("file is empty")
+	}
+	if s.MaxFileSize > 0 && info.Size > s.MaxFileSize {
+		return rejection("file of %d bytes exceeds the limit of %d bytes", info.Size, s.MaxFileSize)
+	}
+
+	reader, err := s.BlobStore.Get(key)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	defer reader.Close()
+
+	mimeType := extractors.DetectMimeType(reader)
+	if !slices.Contains(s.AllowedMimeTypes, mimeType) {
+		return rejection("files of type %s are not allowed", mimeType)
+	}
+
+	pages, err := extractors.CheckDocument(reader, info.Size, mimeType)
+	if errors.Is(err, extractors.ErrEncrypted) || errors.Is(err, extractors.ErrMalformed) ||
+		errors.Is(err, extractors.ErrUnsupportedFormat) {
+		return rejection("%v", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", key, err)
+	}
+	if s.MaxPageCount > 0 && pages > s.MaxPageCount {
+		return rejection("document of %d pages exceeds the limit of %d pages", pages, s.MaxPageCount)
+	}
+
+	if s.Scanner == nil {
+		return nil
+	}
+	threat, err := s.Scanner.Scan(io.NewSectionReader(reader, 0, info.Size))
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", key, err)
+	}
+	if threat != "" {
+		return rejection("malware detected: %s", threat)
+	}
+	return nil
+}
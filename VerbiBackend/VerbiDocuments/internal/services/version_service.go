@@ -0,0 +1,256 @@
+package services
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"time"
+)
+
+// DefaultKeepVersions is the number of versions kept of each document of users who have not set a retention policy
+const DefaultKeepVersions = 10
+
+// ErrVersionNotFound is returned when the document has no version with the requested number
+var ErrVersionNotFound = errors.New("version not found")
+
+// VersionService keeps immutable versions of uploaded document files and prunes them by the users' retention policies
+type VersionService struct {
+	DocumentRepository *repositories.DocumentRepository
+	VersionRepository  *repositories.VersionRepository
+	BlobStore          interfaces.BlobStore
+}
+
+// NewVersionService creates a new VersionService
+func NewVersionService(
+	documentRepository *repositories.DocumentRepository,
+	versionRepository *repositories.VersionRepository,
+	blobStore interfaces.BlobStore,
+) *VersionService {
+	return &VersionService{
+		DocumentRepository: documentRepository,
+		VersionRepository:  versionRepository,
+		BlobStore:          blobStore,
+	}
+}
+
+// RecordVersion saves the current file of the document as its new version unless it is already the latest one.
+// The content is copied next to the document, so later uploads and restores never change it
+func (s *VersionService) RecordVersion(document *models.Document, uploaderId uint) error {
+	checksum := document.Metadata.Sha256
+	latest, err := s.VersionRepository.GetLatestVersion(document.ID)
+	if err == nil && latest.Sha256 == checksum && latest.FileName == document.FileName {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to retrieve latest version: %w", err)
+	}
+
+	key := storage.VersionKey(document.UserId, document.ID, checksum)
+	_, err = s.BlobStore.Stat(key)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		err = s.copyContent(storage.DocumentKey(document.UserId, document.ID, document.FileName), key, checksum)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.VersionRepository.CreateVersion(&models.DocumentVersion{
+		DocumentId: document.ID,
+		FileName:   document.FileName,
+		Size:       document.Metadata.FileSize,
+		MimeType:   document.Metadata.MimeType,
+		Sha256:     checksum,
+		UploadedBy: uploaderId,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save version: %w", err)
+	}
+
+	policy, err := s.GetRetentionPolicy(document.UserId)
+	if err != nil {
+		return err
+	}
+	return s.PruneVersions(document, policy)
+}
+
+// copyContent copies the file to the version key and checks that its content still has the expected checksum
+func (s *VersionService) copyContent(source, target, checksum string) error {
+	reader, err := s.BlobStore.Get(source)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", source, err)
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	err = s.BlobStore.Put(target, io.TeeReader(reader, hash), -1)
+	if err != nil {
+		return fmt.Errorf("failed to store version: %w", err)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != checksum {
+		_ = s.BlobStore.Delete(target)
+		return fmt.Errorf("file %s changed while its version was saved", source)
+	}
+	return nil
+}
+
+// GetVersions returns all versions of the user's or a shared document, newest first.
+// The version matching the current file is marked
+func (s *VersionService) GetVersions(userId, documentId uint) ([]*models.DocumentVersion, error) {
+	document, err := s.DocumentRepository.GetReadableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+
+	versions, err := s.VersionRepository.GetVersions(document.UserId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve versions: %w", err)
+	}
+	if current := currentVersion(document, versions); current >= 0 {
+		versions[current].Current = true
+	}
+	return versions, nil
+}
+
+// currentVersion returns the index of the newest version matching the current file of the document or -1
+func currentVersion(document *models.Document, versions []*models.DocumentVersion) int {
+	for i, version := range versions {
+		if version.Sha256 == document.Metadata.Sha256 && version.FileName == document.FileName {
+			return i
+		}
+	}
+	return -1
+}
+
+// OpenVersion opens the stored content of the version of the user's or a shared document with the given number
+func (s *VersionService) OpenVersion(userId, documentId uint, number int) (interfaces.BlobReader, *models.DocumentVersion, error) {
+	document, err := s.DocumentRepository.GetReadableDocument(userId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve document: %w", err)
+	}
+
+	version, err := s.VersionRepository.GetVersion(document.UserId, documentId, number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve version: %w", err)
+	}
+
+	reader, err := s.BlobStore.Get(storage.VersionKey(document.UserId, documentId, version.Sha256))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open version %d: %w", number, err)
+	}
+	return reader, version, nil
+}
+
+// GetRetentionPolicy returns the retention policy of the user or the default one if the user has not set it
+func (s *VersionService) GetRetentionPolicy(userId uint) (*models.RetentionPolicy, error) {
+	policy, err := s.VersionRepository.GetRetentionPolicy(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.RetentionPolicy{UserId: userId, KeepVersions: DefaultKeepVersions}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve retention policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetRetentionPolicy saves the retention policy of the user and immediately prunes versions of all the user's documents
+func (s *VersionService) SetRetentionPolicy(policy *models.RetentionPolicy) error {
+	err := s.VersionRepository.SaveRetentionPolicy(policy)
+	if err != nil {
+		return fmt.Errorf("failed to save retention policy: %w", err)
+	}
+
+	documents, err := s.DocumentRepository.GetDocumentsByUserId(policy.UserId, nil)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve documents: %w", err)
+	}
+	for _, document := range documents {
+		if err := s.PruneVersions(document, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneVersions deletes versions of the document exceeding the retention policy. The latest and the current versions
+// are always kept. Stored contents are deleted once no version refers to them
+func (s *VersionService) PruneVersions(document *models.Document, policy *models.RetentionPolicy) error {
+	versions, err := s.VersionRepository.GetVersions(document.UserId, document.ID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve versions of document %d: %w", document.ID, err)
+	}
+
+	current := currentVersion(document, versions)
+	cutoff := time.Now().AddDate(0, 0, -policy.KeepDays)
+	var ids []uint
+	checksums := make(map[string]bool)
+	for i, version := range versions {
+		if i == 0 || i == current {
+			continue
+		}
+		if (policy.KeepVersions > 0 && i >= policy.KeepVersions) || (policy.KeepDays > 0 && version.CreatedAt.Before(cutoff)) {
+			ids = append(ids, version.ID)
+			checksums[version.Sha256] = true
+		}
+	}
+
+	err = s.VersionRepository.DeleteVersions(ids)
+	if err != nil {
+		return fmt.Errorf("failed to delete versions of document %d: %w", document.ID, err)
+	}
+
+	for checksum := range checksums {
+		count, err := s.VersionRepository.CountVersionsWithChecksum(document.ID, checksum)
+		if err != nil {
+			return fmt.Errorf("failed to count versions of document %d: %w", document.ID, err)
+		}
+		if count > 0 {
+			continue
+		}
+		err = s.BlobStore.Delete(storage.VersionKey(document.UserId, document.ID, checksum))
+		if err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+			return fmt.Errorf("failed to delete version content of document %d: %w", document.ID, err)
+		}
+	}
+	return nil
+}
+
+// PruneAllVersions applies retention policies to the documents of all users, so age limits take effect without new uploads
+func (s *VersionService) PruneAllVersions() error {
+	documents, err := s.DocumentRepository.GetAllDocuments()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve documents: %w", err)
+	}
+
+	policies := make(map[uint]*models.RetentionPolicy)
+	var errs []error
+	for _, document := range documents {
+		policy, ok := policies[document.UserId]
+		if !ok {
+			policy, err = s.GetRetentionPolicy(document.UserId)
+			if err != nil {
+				return err
+			}
+			policies[document.UserId] = policy
+		}
+		if err := s.PruneVersions(document, policy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
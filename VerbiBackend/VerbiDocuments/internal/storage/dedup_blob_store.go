@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContentDirectory is the directory of the underlying store keeping deduplicated contents by their SHA-256
+const ContentDirectory = ".content"
+
+// DedupBlobStore keeps every distinct content once in the underlying store and maps keys to it,
+// so identical files of the same or different users share one physical copy.
+// Keys written before deduplication stay in the underlying store until they are written again or Deduplicate runs
+type DedupBlobStore struct {
+	Store          interfaces.BlobStore
+	BlobRepository *repositories.BlobRepository
+}
+
+// NewDedupBlobStore creates a DedupBlobStore on top of the underlying store
+func NewDedupBlobStore(store interfaces.BlobStore, blobRepository *repositories.BlobRepository) *DedupBlobStore {
+	return &DedupBlobStore{
+		Store:          store,
+		BlobRepository: blobRepository,
+	}
+}
+
+// contentKey returns the key of the content with the given hash in the underlying store
+func contentKey(hash string) string {
+	return ContentDirectory + "/" + hash[:2] + "/" + hash
+}
+
+// isContentKey reports whether the key refers to the content directory, which is not accessible through the store
+func isContentKey(key string) bool {
+	return key == ContentDirectory || strings.HasPrefix(key, ContentDirectory+"/")
+}
+
+// cleanKey normalizes the key and rejects keys inside the content directory
+func (s *DedupBlobStore) cleanKey(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if isContentKey(cleaned) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return cleaned, nil
+}
+
+// Put hashes the object into a temporary file, stores its content unless it is already stored and points the key to it.
+// The content previously referenced by the key is released
+func (s *DedupBlobStore) Put(key string, reader io.Reader, size int64) error {
+	cleaned, err := s.cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp("", "verbi-blob-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(temp, hash), reader)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("failed to write %s: expected %d bytes, got %d", key, size, written)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	exists, err := s.BlobRepository.AcquireContent(sum)
+	if err != nil {
+		return fmt.Errorf("failed to reference content of %s: %w", key, err)
+	}
+	if !exists {
+		_, err = temp.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		err = s.Store.Put(contentKey(sum), temp, written)
+		if err != nil {
+			return err
+		}
+		err = s.BlobRepository.CreateContent(sum, written)
+		if err != nil {
+			return fmt.Errorf("failed to record content of %s: %w", key, err)
+		}
+	}
+
+	previous, err := s.BlobRepository.SaveReference(&models.BlobReference{
+		Key:     cleaned,
+		Hash:    sum,
+		Size:    written,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		s.release(sum)
+		return fmt.Errorf("failed to reference content of %s: %w", key, err)
+	}
+
+	if previous != "" {
+		s.release(previous)
+		return nil
+	}
+	return s.Store.Delete(cleaned)
+}
+
+// release removes a reference to the content and deletes the content after its last reference
+func (s *DedupBlobStore) release(hash string) {
+	err := s.BlobRepository.ReleaseContent(hash, func() error {
+		return s.Store.Delete(contentKey(hash))
+	})
+	if err != nil {
+		log.Printf("failed to release content %s: %v", hash, err)
+	}
+}
+
+// Get opens the content referenced by the key for reading
+func (s *DedupBlobStore) Get(key string) (interfaces.BlobReader, error) {
+	cleaned, err := s.cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	reference, err := s.BlobRepository.GetReference(cleaned)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.Store.Get(cleaned)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s: %w", key, err)
+	}
+	return s.Store.Get(contentKey(reference.Hash))
+}
+
+// Stat returns size and modification time of the object, which is the time the key was written
+func (s *DedupBlobStore) Stat(key string) (*models.BlobInfo, error) {
+	cleaned, err := s.cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	reference, err := s.BlobRepository.GetReference(cleaned)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.Store.Stat(cleaned)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s: %w", key, err)
+	}
+	return &models.BlobInfo{Key: reference.Key, Size: reference.Size, ModTime: reference.ModTime}, nil
+}
+
+// Delete removes the key and deletes its content if no other key references it. Deleting a missing object is not an error
+func (s *DedupBlobStore) Delete(key string) error {
+	cleaned, err := s.cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	reference, err := s.BlobRepository.DeleteReference(cleaned)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.Store.Delete(cleaned)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	s.release(reference.Hash)
+	return nil
+}
+
+// List returns all objects whose keys start with the given prefix, sorted by key
+func (s *DedupBlobStore) List(prefix string) ([]*models.BlobInfo, error) {
+	references, err := s.BlobRepository.GetReferences(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	stored, err := s.Store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := make([]*models.BlobInfo, 0, len(references)+len(stored))
+	referenced := make(map[string]bool, len(references))
+	for _, reference := range references {
+		referenced[reference.Key] = true
+		blobs = append(blobs, &models.BlobInfo{Key: reference.Key, Size: reference.Size, ModTime: reference.ModTime})
+	}
+	for _, blob := range stored {
+		if !referenced[blob.Key] && !isContentKey(blob.Key) {
+			blobs = append(blobs, blob)
+		}
+	}
+
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Key < blobs[j].Key })
+	return blobs, nil
+}
+
+// Deduplicate moves objects written before deduplication into the content directory and returns how many were moved
+func (s *DedupBlobStore) Deduplicate() (int, error) {
+	stored, err := s.Store.List("")
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, blob := range stored {
+		if isContentKey(blob.Key) {
+			continue
+		}
+		reader, err := s.Store.Get(blob.Key)
+		if err != nil {
+			return moved, fmt.Errorf("failed to open %s: %w", blob.Key, err)
+		}
+		err = s.Put(blob.Key, reader, blob.Size)
+		reader.Close()
+		if err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
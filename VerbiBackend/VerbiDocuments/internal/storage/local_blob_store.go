@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalBlobStore keeps objects as regular files under the root directory
+type LocalBlobStore struct {
+	Root string
+}
+
+// NewLocalBlobStore creates a LocalBlobStore and makes sure that its root directory exists
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	absoluteRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage root: %w", err)
+	}
+
+	err = os.MkdirAll(absoluteRoot, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage root: %w", err)
+	}
+
+	return &LocalBlobStore{Root: absoluteRoot}, nil
+}
+
+// filePath converts a storage key to a path on the local filesystem
+func (s *LocalBlobStore) filePath(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(cleaned)), nil
+}
+
+// Put writes the object to a temporary file and atomically moves it to its place
+func (s *LocalBlobStore) Put(key string, reader io.Reader, size int64) error {
+	target, err := s.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	written, err := io.Copy(temp, reader)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("failed to write %s: expected %d bytes, got %d", key, size, written)
+	}
+
+	err = os.Rename(temp.Name(), target)
+	if err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", key, err)
+	}
+
+	return nil
+}
+
+// Get opens the object for reading
+func (s *LocalBlobStore) Get(key string) (interfaces.BlobReader, error) {
+	target, err := s.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err == nil && info.IsDir() {
+		file.Close()
+		return nil, ErrBlobNotFound
+	}
+
+	return file, err
+}
+
+// Stat returns size and modification time of the object
+func (s *LocalBlobStore) Stat(key string) (*models.BlobInfo, error) {
+	target, err := s.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cleaned, _ := CleanKey(key)
+	return &models.BlobInfo{Key: cleaned, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete removes the object and the directories left empty after it. Deleting a missing object is not an error
+func (s *LocalBlobStore) Delete(key string) error {
+	target, err := s.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(target)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	for dir := filepath.Dir(target); dir != s.Root && strings.HasPrefix(dir, s.Root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// List returns all objects whose keys start with the given prefix, sorted by key
+func (s *LocalBlobStore) List(prefix string) ([]*models.BlobInfo, error) {
+	start := s.Root
+	if index := strings.LastIndex(prefix, "/"); index > 0 {
+		directory, err := s.filePath(prefix[:index])
+		if err != nil {
+			return nil, err
+		}
+		start = directory
+	}
+
+	blobs := make([]*models.BlobInfo, 0)
+	err := filepath.WalkDir(start, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		relative, err := filepath.Rel(s.Root, current)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, &models.BlobInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Key < blobs[j].Key })
+	return blobs, nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"VerbiDocuments/internal/models"
+	"context"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"net/http"
+	"os"
+)
+
+// S3BlobStore keeps objects in a bucket of an S3-compatible object storage (AWS S3, MinIO, etc.)
+type S3BlobStore struct {
+	Client *minio.Client
+	Bucket string
+}
+
+// NewS3BlobStore connects to the object storage and creates the bucket if it does not exist yet
+func NewS3BlobStore(endpoint, accessKey, secretKey, bucket string, useSSL bool) (*S3BlobStore, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", bucket, err)
+	}
+	if !exists {
+		err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+		}
+	}
+
+	return &S3BlobStore{Client: client, Bucket: bucket}, nil
+}
+
+// isNotFound checks whether the storage responded that the object does not exist
+func isNotFound(err error) bool {
+	response := minio.ToErrorResponse(err)
+	return response.StatusCode == http.StatusNotFound || response.Code == "NoSuchKey"
+}
+
+// Put uploads the object. If the size is unknown (negative) the content is buffered in a temporary file first
+func (s *S3BlobStore) Put(key string, reader io.Reader, size int64) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	if size < 0 {
+		buffer, err := os.CreateTemp("", "verbi-s3-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		defer os.Remove(buffer.Name())
+		defer buffer.Close()
+
+		size, err = io.Copy(buffer, reader)
+		if err != nil {
+			return fmt.Errorf("failed to buffer %s: %w", key, err)
+		}
+		_, err = buffer.Seek(0, io.SeekStart)
+		if err != nil {
+			return fmt.Errorf("failed to buffer %s: %w", key, err)
+		}
+		reader = buffer
+	}
+
+	_, err = s.Client.PutObject(context.Background(), s.Bucket, cleaned, reader, size, minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get opens the object for streaming reads. The returned handle fetches data lazily with range requests
+func (s *S3BlobStore) Get(key string) (interfaces.BlobReader, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := s.Client.GetObject(context.Background(), s.Bucket, cleaned, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+
+	_, err = object.Stat()
+	if err != nil {
+		object.Close()
+		if isNotFound(err) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+
+	return object, nil
+}
+
+// Stat returns size and modification time of the object
+func (s *S3BlobStore) Stat(key string) (*models.BlobInfo, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.Client.StatObject(context.Background(), s.Bucket, cleaned, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+
+	return &models.BlobInfo{Key: info.Key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+// Delete removes the object. Deleting a missing object is not an error
+func (s *S3BlobStore) Delete(key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = s.Client.RemoveObject(context.Background(), s.Bucket, cleaned, minio.RemoveObjectOptions{})
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// List returns all objects whose keys start with the given prefix, sorted by key
+func (s *S3BlobStore) List(prefix string) ([]*models.BlobInfo, error) {
+	blobs := make([]*models.BlobInfo, 0)
+	objects := s.Client.ListObjects(context.Background(), s.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+
+	for object := range objects {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, object.Err)
+		}
+		blobs = append(blobs, &models.BlobInfo{Key: object.Key, Size: object.Size, ModTime: object.LastModified})
+	}
+
+	return blobs, nil
+}
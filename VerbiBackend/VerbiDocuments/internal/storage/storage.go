@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"VerbiDocuments/internal/interfaces"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrBlobNotFound is returned when the requested object does not exist in the blob store
+var ErrBlobNotFound = errors.New("blob not found")
+
+// CleanKey normalizes a storage key and rejects empty keys and keys referring to parent directories
+func CleanKey(key string) (string, error) {
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return cleaned, nil
+}
+
+// UserPrefix returns the key prefix under which all files of the user are stored
+func UserPrefix(userId uint) string {
+	return fmt.Sprintf("%d/", userId)
+}
+
+// DocumentPrefix returns the key prefix under which all files of the document are stored
+func DocumentPrefix(userId, documentId uint) string {
+	return fmt.Sprintf("%d/%d/", userId, documentId)
+}
+
+// DeletePrefix deletes every object whose key starts with the given prefix
+func DeletePrefix(store interfaces.BlobStore, prefix string) error {
+	blobs, err := store.List(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	for _, blob := range blobs {
+		if err := store.Delete(blob.Key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", blob.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// ParseDocumentKey splits a key of a file stored in a document directory into its parts
+func ParseDocumentKey(key string) (userId, documentId uint, name string, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(key, "/"), "/", 3)
+	if len(parts) != 3 || parts[2] == "" || strings.Contains(parts[2], "/") {
+		return 0, 0, "", false
+	}
+
+	user, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+	document, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+
+	return uint(user), uint(document), parts[2], true
+}
+
+// DocumentKey returns the key of the file with the given name stored in the document directory
+func DocumentKey(userId, documentId uint, name string) string {
+	return DocumentPrefix(userId, documentId) + name
+}
+
+// VersionsDirectory is the hidden directory of a document holding the contents of its versions
+const VersionsDirectory = ".versions"
+
+// VersionKey returns the key of the stored content of a document version with the given checksum
+func VersionKey(userId, documentId uint, sha256 string) string {
+	return DocumentPrefix(userId, documentId) + VersionsDirectory + "/" + sha256
+}
+
+// IsVersionKey reports whether the key refers to the versions directory of a document or to a file inside it
+func IsVersionKey(key string) bool {
+	parts := strings.SplitN(strings.TrimPrefix(key, "/"), "/", 4)
+	return len(parts) >= 3 && parts[2] == VersionsDirectory
+}
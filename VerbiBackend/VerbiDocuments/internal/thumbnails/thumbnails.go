@@ -0,0 +1,160 @@
+package thumbnails
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+)
+
+// Size is a named thumbnail width
+type Size string
+
+const (
+	// SizeSmall fits library list cells
+	SizeSmall Size = "small"
+	// SizeLarge fits the book details screen
+	SizeLarge Size = "large"
+)
+
+// Sizes are all generated thumbnail sizes
+var Sizes = []Size{SizeSmall, SizeLarge}
+
+// widths are thumbnail widths in pixels, heights keep the aspect ratio of the cover
+var widths = map[Size]int{
+	SizeSmall: 160,
+	SizeLarge: 480,
+}
+
+// cardWidth and cardHeight are dimensions of generated title cards, the usual 2:3 book proportion
+const (
+	cardWidth  = 960
+	cardHeight = 1440
+)
+
+// jpegQuality is the quality of encoded thumbnails
+const jpegQuality = 85
+
+// ParseSize checks that the size name is known
+func ParseSize(name string) (Size, error) {
+	size := Size(name)
+	if _, ok := widths[size]; !ok {
+		return "", fmt.Errorf("unknown thumbnail size %q", name)
+	}
+	return size, nil
+}
+
+// FileName returns the name of the thumbnail file kept next to the document.
+// The name starts with a dot, so thumbnails are not taken for document content
+func FileName(size Size) string {
+	return fmt.Sprintf(".cover-%s.jpg", size)
+}
+
+// Encode scales the cover to the width of the size and encodes it as JPEG
+func Encode(cover image.Image, size Size) ([]byte, error) {
+	bounds := cover.Bounds()
+	width := widths[size]
+	height := bounds.Dy() * width / max(bounds.Dx(), 1)
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, max(height, 1)))
+	draw.Draw(thumbnail, thumbnail.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), cover, bounds, draw.Over, nil)
+
+	var buffer bytes.Buffer
+	err := jpeg.Encode(&buffer, thumbnail, &jpeg.Options{Quality: jpegQuality})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// TitleCard draws a cover with the title and the author on a background whose color depends on the title
+func TitleCard(title, author string) (image.Image, error) {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(title))
+	sum := hash.Sum32()
+	background := color.RGBA{R: uint8(40 + sum%120), G: uint8(40 + (sum>>8)%120), B: uint8(40 + (sum>>16)%120), A: 255}
+
+	card := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	draw.Draw(card, card.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	titleFace, err := newFace(gobold.TTF, 80)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	authorFace, err := newFace(goregular.TTF, 48)
+	if err != nil {
+		return nil, err
+	}
+	defer authorFace.Close()
+
+	margin := cardWidth / 10
+	y := cardHeight / 4
+	for _, line := range wrap(titleFace, title, cardWidth-2*margin, 6) {
+		drawLine(card, titleFace, line, margin, y)
+		y += 100
+	}
+	y = cardHeight - cardHeight/6
+	for _, line := range wrap(authorFace, author, cardWidth-2*margin, 2) {
+		drawLine(card, authorFace, line, margin, y)
+		y += 60
+	}
+	return card, nil
+}
+
+// newFace loads an embedded Go font, they cover Latin and Cyrillic scripts
+func newFace(ttf []byte, size float64) (font.Face, error) {
+	parsed, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	return face, nil
+}
+
+// drawLine draws a line of white text with its baseline at y
+func drawLine(target draw.Image, face font.Face, text string, x, y int) {
+	drawer := &font.Drawer{Dst: target, Src: image.White, Face: face, Dot: fixed.P(x, y)}
+	drawer.DrawString(text)
+}
+
+// wrap splits the text into at most limit lines fitting the width, the last line is ellipsized
+func wrap(face font.Face, text string, width, limit int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := strings.TrimSpace(line + " " + word)
+		if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > limit {
+		lines = lines[:limit]
+		last := []rune(lines[limit-1])
+		for len(last) > 0 && font.MeasureString(face, string(last)+"…").Ceil() > width {
+			last = last[:len(last)-1]
+		}
+		lines[limit-1] = string(last) + "…"
+	}
+	return lines
+}
@@ -5,7 +5,12 @@ import (
 	"VerbiDocuments/internal/config"
 	"VerbiDocuments/internal/factories"
 	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
 	"VerbiDocuments/internal/routers"
+	"VerbiDocuments/internal/services"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -22,6 +27,11 @@ import (
 
 // @host localhost:8081
 // @BasePath /api/v1
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description `Bearer <your_access_token>`
 func main() {
 	err := config.LoadEnv()
 	if err != nil {
@@ -48,26 +58,140 @@ func main() {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
 
-	err = db.AutoMigrate(&models.Document{}, &models.SftpCredentials{})
+	err = db.AutoMigrate(
+		&models.Document{},
+		&models.Tag{},
+		&models.Collection{},
+		&models.Share{},
+		&models.ShareLink{},
+		&models.ReadingState{},
+		&models.Annotation{},
+		&models.Bookmark{},
+		&models.DocumentPage{},
+		&models.DocumentVersion{},
+		&models.RetentionPolicy{},
+		&models.SftpCredentials{},
+		&models.IdempotencyKey{},
+		&models.StorageUsage{},
+		&models.StorageQuota{},
+		&models.ContentBlob{},
+		&models.BlobReference{},
+		&models.Job{},
+	)
 	if err != nil {
 		log.Fatalf("failed to migrate: %v", err)
 	}
 
+	err = repositories.NewPageRepository(db).CreateSearchIndex()
+	if err != nil {
+		log.Fatalf("failed to create search index: %v", err)
+	}
+
+	blobStore, err := config.SetupBlobStore(db)
+	if err != nil {
+		log.Fatalf("failed to setup storage: %v", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "deduplicate" {
+		moved, err := blobStore.Deduplicate()
+		if err != nil {
+			log.Fatalf("deduplication failed after %d files: %v", moved, err)
+		}
+		log.Printf("deduplicated %d files", moved)
+		return
+	}
+
+	quotaService, err := config.SetupQuotaService(db)
+	if err != nil {
+		log.Fatalf("failed to setup quotas: %v", err)
+	}
+
+	validationService, err := config.SetupValidationService(blobStore)
+	if err != nil {
+		log.Fatalf("failed to setup upload validation: %v", err)
+	}
+
+	jobService, err := config.SetupJobService(db)
+	if err != nil {
+		log.Fatalf("failed to setup job queue: %v", err)
+	}
+	processingService := config.SetupProcessingService(db, blobStore, quotaService, validationService, jobService)
+
+	reconciler, err := config.SetupReconciler(db, blobStore)
+	if err != nil {
+		log.Fatalf("failed to setup reconciler: %v", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		reconcileCommand(reconciler, os.Args[2:])
+		return
+	}
+
+	err = config.StartReconciler(reconciler)
+	if err != nil {
+		log.Fatalf("failed to start reconciler: %v", err)
+	}
+
+	err = config.StartVersionPruner(db, blobStore)
+	if err != nil {
+		log.Fatalf("failed to start version pruner: %v", err)
+	}
+
 	go func() {
-		err = config.SetupSftpServer(db)
+		err = config.SetupSftpServer(db, blobStore, quotaService, processingService)
 		if err != nil {
 			log.Fatalf("failed to setup sftp server: %v", err)
 		}
 	}()
 
 	controllerFactory := factories.NewControllerFactory()
-	documentsController, err := controllerFactory.GetController(db)
+	documentsController, err := controllerFactory.GetController(db, blobStore, quotaService, processingService)
 	if err != nil {
 		log.Fatalf("failed to create documents controller: %v", err)
 	}
 
+	downloadClient, err := config.SetupDownloadClient()
+	if err != nil {
+		log.Fatalf("failed to setup imports: %v", err)
+	}
+	// all job types are registered once the import controller exists
+	importController := controllerFactory.GetImportController(documentsController, jobService, downloadClient)
+	jobService.Start(context.Background())
+
+	err = config.StartTrashPurger(documentsController.DocumentService)
+	if err != nil {
+		log.Fatalf("failed to start trash purger: %v", err)
+	}
+
+	userDirectory, err := config.SetupUserDirectory()
+	if err != nil {
+		log.Fatalf("failed to setup user directory: %v", err)
+	}
+
+	shareLinkSecret, err := config.ShareLinkSecret()
+	if err != nil {
+		log.Fatalf("failed to setup share links: %v", err)
+	}
+
 	r := gin.Default()
 	routers.SetupRoutes(r, documentsController)
+	routers.SetupLibraryRoutes(
+		r,
+		controllerFactory.GetCollectionController(db, documentsController),
+		controllerFactory.GetTagController(db, documentsController),
+	)
+	routers.SetupShareRoutes(r, controllerFactory.GetShareController(db, documentsController, userDirectory))
+	routers.SetupReadingRoutes(r, controllerFactory.GetReadingController(db, documentsController))
+	routers.SetupAnnotationRoutes(
+		r,
+		controllerFactory.GetAnnotationController(db, documentsController),
+		controllerFactory.GetBookmarkController(db, documentsController),
+	)
+	routers.SetupArchiveRoutes(r, controllerFactory.GetArchiveController(db, documentsController))
+	routers.SetupImportRoutes(r, importController)
+	routers.SetupJobRoutes(r, controllerFactory.GetJobController(jobService))
+	routers.SetupShareLinkRoutes(r, controllerFactory.GetShareLinkController(db, documentsController, shareLinkSecret))
+	routers.SetupAdminRoutes(r, controllerFactory.GetAdminController(reconciler, quotaService))
 
 	url := ginSwagger.URL("http://localhost:8081/swagger/doc.json")
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
@@ -78,3 +202,21 @@ func main() {
 		log.Fatalf("HTTP server failed: %v", err)
 	}
 }
+
+// reconcileCommand runs reconciliation once and prints the report as JSON: service reconcile [-repair]
+func reconcileCommand(reconciler *services.ReconcilerService, args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "delete orphan files older than the grace period and mark documents without files broken")
+	_ = flags.Parse(args)
+
+	report, err := reconciler.Reconcile(*repair)
+	if err != nil {
+		log.Fatalf("reconciliation failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("failed to print report: %v", err)
+	}
+}
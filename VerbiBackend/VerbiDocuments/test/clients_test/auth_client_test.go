@@ -0,0 +1,40 @@
+package clients_test
+
+import (
+	"VerbiDocuments/internal/clients"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAuthClientFindUser tests that users are looked up with the service token and missing ones are reported
+func TestAuthClientFindUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/internal/users" || r.Header.Get("Service-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("login") {
+		case "reader@example.com":
+			_, _ = w.Write([]byte(`{"user_id": 2, "username": "reader"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := clients.NewAuthClient(server.URL+"/api/v1/", "token")
+
+	user, err := client.FindUser("reader@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), user.UserId)
+	assert.Equal(t, "reader", user.Username)
+
+	_, err = client.FindUser("nobody")
+	assert.ErrorIs(t, err, clients.ErrUserNotFound)
+	client.ServiceToken = "wrong"
+	_, err = client.FindUser("reader@example.com")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, clients.ErrUserNotFound)
+}
@@ -0,0 +1,86 @@
+package clients_test
+
+import (
+	"VerbiDocuments/internal/clients"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startClamdStub serves the clamd INSTREAM command, reporting content containing EICAR as infected
+func startClamdStub(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				command, err := reader.ReadString(0)
+				if err != nil || command != "zINSTREAM\x00" {
+					_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var content bytes.Buffer
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(reader, size); err != nil {
+						return
+					}
+					length := binary.BigEndian.Uint32(size)
+					if length == 0 {
+						break
+					}
+					if _, err := io.CopyN(&content, reader, int64(length)); err != nil {
+						return
+					}
+				}
+
+				if strings.Contains(content.String(), "EICAR") {
+					_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+					return
+				}
+				_, _ = conn.Write([]byte("stream: OK\x00"))
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// TestClamdClientScan tests that clean content passes and threats are reported by name
+func TestClamdClientScan(t *testing.T) {
+	client := clients.NewClamdClient(startClamdStub(t), time.Second)
+
+	threat, err := client.Scan(bytes.NewReader(bytes.Repeat([]byte("clean "), 50000)))
+	assert.NoError(t, err)
+	assert.Empty(t, threat)
+
+	threat, err = client.Scan(strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Eicar-Test-Signature", threat)
+}
+
+// TestClamdClientUnavailable tests that an unreachable daemon is an error rather than a clean result
+func TestClamdClientUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	assert.NoError(t, listener.Close())
+
+	_, err = clients.NewClamdClient(address, time.Second).Scan(strings.NewReader("content"))
+	assert.Error(t, err)
+}
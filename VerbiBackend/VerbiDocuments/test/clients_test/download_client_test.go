@@ -0,0 +1,88 @@
+package clients_test
+
+import (
+	"VerbiDocuments/internal/clients"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startFileServer serves /book with a suggested file name, redirects /latest to it and streams /stream
+// without a content length
+func startFileServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/book", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf; charset=binary")
+		w.Header().Set("Content-Disposition", `attachment; filename="../My Book.pdf"`)
+		_, _ = w.Write([]byte("%PDF-1.4 book"))
+	})
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/book", http.StatusFound)
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 4; i++ {
+			_, _ = w.Write([]byte(strings.Repeat("a", 10)))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestDownload tests that redirects are followed and the file is described by the final response
+func TestDownload(t *testing.T) {
+	server := startFileServer(t)
+	client := clients.NewDownloadClient(time.Second, 1024, true)
+
+	file, body, err := client.Download(context.Background(), server.URL+"/latest")
+	assert.NoError(t, err)
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4 book", string(content))
+	assert.Equal(t, server.URL+"/book", file.Url)
+	assert.Equal(t, "My Book.pdf", file.FileName)
+	assert.Equal(t, "application/pdf", file.ContentType)
+	assert.Equal(t, int64(len(content)), file.Size)
+}
+
+// TestDownloadLimits tests that downloads fail when they exceed the size or time limit, are refused by the server
+// or lead to private addresses
+func TestDownloadLimits(t *testing.T) {
+	server := startFileServer(t)
+	ctx := context.Background()
+	client := clients.NewDownloadClient(100*time.Millisecond, 30, true)
+
+	file, body, err := client.Download(ctx, server.URL+"/stream")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), file.Size)
+	_, err = io.ReadAll(body)
+	assert.ErrorIs(t, err, clients.ErrDownloadTooLarge)
+	body.Close()
+
+	_, _, err = client.Download(ctx, server.URL+"/missing")
+	assert.ErrorIs(t, err, clients.ErrDownloadRefused)
+	_, _, err = client.Download(ctx, server.URL+"/slow")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, clients.ErrDownloadRefused)
+	_, _, err = client.Download(ctx, "file:///etc/passwd")
+	assert.ErrorIs(t, err, clients.ErrForbiddenAddress)
+
+	client = clients.NewDownloadClient(time.Second, 0, false)
+	_, _, err = client.Download(ctx, server.URL+"/book")
+	assert.ErrorIs(t, err, clients.ErrForbiddenAddress)
+	for _, address := range []string{"100.64.0.1", "100.127.255.254", "[64:ff9b::a00:1]", "[64:ff9b::808:808]"} {
+		_, _, err = client.Download(ctx, "http://"+address+"/book")
+		assert.ErrorIs(t, err, clients.ErrForbiddenAddress, address)
+	}
+}
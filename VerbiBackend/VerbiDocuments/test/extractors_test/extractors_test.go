@@ -0,0 +1,204 @@
+package extractors_test
+
+import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/test/fixtures"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExtractPdfMetadata tests reading of the PDF document information
+func TestExtractPdfMetadata(t *testing.T) {
+	data := fixtures.PDF(map[string]string{
+		"Title":        "War and Peace",
+		"Author":       "Leo Tolstoy",
+		"CreationDate": "D:18690101000000Z",
+	}, "ru", []string{"first page", "second page", "third page"})
+
+	metadata, err := extractors.ExtractMetadata(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, extractors.MimeTypePdf, metadata.MimeType)
+	assert.Equal(t, "War and Peace", metadata.OriginalTitle)
+	assert.Equal(t, "Leo Tolstoy", metadata.Author)
+	assert.Equal(t, "ru", metadata.Language)
+	assert.Equal(t, 3, metadata.PageCount)
+	assert.Equal(t, 1869, metadata.PublishedAt.Year())
+	assert.Equal(t, int64(len(data)), metadata.FileSize)
+	assert.Len(t, metadata.Sha256, 64)
+}
+
+// TestExtractEpubMetadata tests reading of the EPUB package metadata
+func TestExtractEpubMetadata(t *testing.T) {
+	data := fixtures.EPUB("Anna Karenina", "Leo Tolstoy", "en", "1878-05-12", []fixtures.EpubChapter{
+		{Title: "Chapter 1", Text: "Happy families are all alike"},
+		{Title: "Chapter 2", Text: "Stepan Arkadyevitch"},
+	}, nil)
+
+	metadata, err := extractors.ExtractMetadata(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, extractors.MimeTypeEpub, metadata.MimeType)
+	assert.Equal(t, "Anna Karenina", metadata.OriginalTitle)
+	assert.Equal(t, "Leo Tolstoy", metadata.Author)
+	assert.Equal(t, "en", metadata.Language)
+	assert.Equal(t, 2, metadata.PageCount)
+	assert.Equal(t, 1878, metadata.PublishedAt.Year())
+	assert.Equal(t, 5, int(metadata.PublishedAt.Month()))
+}
+
+// TestExtractUnsupportedFormat tests that unknown files still get technical metadata
+func TestExtractUnsupportedFormat(t *testing.T) {
+	data := []byte("just some plain text")
+
+	metadata, err := extractors.ExtractMetadata(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorIs(t, err, extractors.ErrUnsupportedFormat)
+	assert.Equal(t, "text/plain", metadata.MimeType)
+	assert.Equal(t, int64(len(data)), metadata.FileSize)
+	assert.Len(t, metadata.Sha256, 64)
+}
+
+// TestExtractMalformedPdf tests that broken files are reported as errors instead of crashing
+func TestExtractMalformedPdf(t *testing.T) {
+	data := []byte("%PDF-1.4\ngarbage\n%%EOF\n")
+
+	_, err := extractors.ExtractMetadata(bytes.NewReader(data), int64(len(data)))
+	assert.Error(t, err)
+}
+
+// TestExtractPdfText tests extraction of the text of every PDF page with offsets
+func TestExtractPdfText(t *testing.T) {
+	data := fixtures.PDF(nil, "en", []string{"Happy families are\nall alike", "Every unhappy family"})
+
+	pages, err := extractors.ExtractText(bytes.NewReader(data), int64(len(data)), extractors.MimeTypePdf)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, 1, pages[0].Number)
+	assert.Equal(t, "Happy families are\nall alike", pages[0].Text)
+	assert.Equal(t, 0, pages[0].StartOffset)
+	assert.Equal(t, 28, pages[0].EndOffset)
+	assert.Equal(t, 2, pages[1].Number)
+	assert.Equal(t, "Every unhappy family", pages[1].Text)
+	assert.Equal(t, 30, pages[1].StartOffset)
+	assert.Equal(t, 50, pages[1].EndOffset)
+}
+
+// TestExtractEpubText tests extraction of the text of every EPUB chapter
+func TestExtractEpubText(t *testing.T) {
+	data := fixtures.EPUB("Anna Karenina", "Leo Tolstoy", "ru", "1878", []fixtures.EpubChapter{
+		{Title: "Часть первая", Text: "Все счастливые семьи\nпохожи друг на друга"},
+		{Title: "Chapter 2", Text: "Stepan Arkadyevitch"},
+	}, nil)
+
+	pages, err := extractors.ExtractText(bytes.NewReader(data), int64(len(data)), extractors.MimeTypeEpub)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, "Часть первая", pages[0].Chapter)
+	assert.Equal(t, "Часть первая\n\nВсе счастливые семьи\n\nпохожи друг на друга", pages[0].Text)
+	assert.Equal(t, 56, pages[0].EndOffset)
+	assert.Equal(t, "Chapter 2", pages[1].Chapter)
+	assert.Equal(t, "Chapter 2\n\nStepan Arkadyevitch", pages[1].Text)
+	assert.Equal(t, 58, pages[1].StartOffset)
+}
+
+// TestNormalizeText tests normalization of extracted text
+func TestNormalizeText(t *testing.T) {
+	assert.Equal(t, "fine text", extractors.NormalizeText("  ﬁne\t  text \r\n"))
+	assert.Equal(t, "extraction works", extractors.NormalizeText("extrac-\ntion works"))
+	assert.Equal(t, "one\n\ntwo", extractors.NormalizeText("one \n\n\n\n two"))
+}
+
+// TestExtractEpubCover tests decoding of the cover image declared in the EPUB package
+func TestExtractEpubCover(t *testing.T) {
+	source := image.NewRGBA(image.Rect(0, 0, 20, 30))
+	source.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var cover bytes.Buffer
+	assert.NoError(t, png.Encode(&cover, source))
+	data := fixtures.EPUB("Title", "Author", "en", "2000", []fixtures.EpubChapter{{Title: "1", Text: "text"}}, cover.Bytes())
+
+	extracted, err := extractors.ExtractCover(bytes.NewReader(data), int64(len(data)), extractors.MimeTypeEpub)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 30), extracted.Bounds())
+	red, _, _, _ := extracted.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), red)
+}
+
+// TestExtractMissingCover tests that books without a cover and unsupported files report ErrNoCover
+func TestExtractMissingCover(t *testing.T) {
+	data := fixtures.EPUB("Title", "Author", "en", "2000", []fixtures.EpubChapter{{Title: "1", Text: "text"}}, nil)
+	_, err := extractors.ExtractCover(bytes.NewReader(data), int64(len(data)), extractors.MimeTypeEpub)
+	assert.ErrorIs(t, err, extractors.ErrNoCover)
+
+	_, err = extractors.ExtractCover(bytes.NewReader([]byte("text")), 4, "text/plain")
+	assert.ErrorIs(t, err, extractors.ErrNoCover)
+}
+
+// TestExtractPdfCover tests rendering of the first PDF page, it requires pdftoppm
+func TestExtractPdfCover(t *testing.T) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		t.Skip("pdftoppm is not installed")
+	}
+	data := fixtures.PDF(nil, "en", []string{"first page"})
+
+	cover, err := extractors.ExtractCover(bytes.NewReader(data), int64(len(data)), extractors.MimeTypePdf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, cover.Bounds().Dy())
+}
+
+// TestCheckDocument tests that valid documents pass the check with their number of pages
+func TestCheckDocument(t *testing.T) {
+	pdf := fixtures.PDF(nil, "en", []string{"first", "second", "third"})
+	pages, err := extractors.CheckDocument(bytes.NewReader(pdf), int64(len(pdf)), extractors.MimeTypePdf)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pages)
+
+	epub := fixtures.EPUB("Title", "Author", "en", "2000", []fixtures.EpubChapter{{Title: "1", Text: "a"}, {Title: "2", Text: "b"}}, nil)
+	epub = fixtures.WithEpubFile(epub, "META-INF/encryption.xml", `<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<EncryptedData xmlns="http://www.w3.org/2001/04/xmlenc#"><EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/></EncryptedData>
+</encryption>`)
+	pages, err = extractors.CheckDocument(bytes.NewReader(epub), int64(len(epub)), extractors.MimeTypeEpub)
+	assert.NoError(t, err, "obfuscated fonts are not DRM")
+	assert.Equal(t, 2, pages)
+
+	_, err = extractors.CheckDocument(bytes.NewReader([]byte("text")), 4, "text/plain")
+	assert.ErrorIs(t, err, extractors.ErrUnsupportedFormat)
+}
+
+// TestCheckEncryptedDocument tests that encrypted PDFs and DRM protected EPUBs are detected
+func TestCheckEncryptedDocument(t *testing.T) {
+	pdf := fixtures.EncryptedPDF([]string{"secret"})
+	_, err := extractors.CheckDocument(bytes.NewReader(pdf), int64(len(pdf)), extractors.MimeTypePdf)
+	assert.ErrorIs(t, err, extractors.ErrEncrypted)
+
+	epub := fixtures.EPUB("Title", "Author", "en", "2000", []fixtures.EpubChapter{{Title: "1", Text: "a"}}, nil)
+	for name, content := range map[string]string{
+		"META-INF/rights.xml": `<rights/>`,
+		"META-INF/encryption.xml": `<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<EncryptedData xmlns="http://www.w3.org/2001/04/xmlenc#"><EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/></EncryptedData>
+</encryption>`,
+	} {
+		protected := fixtures.WithEpubFile(epub, name, content)
+		_, err = extractors.CheckDocument(bytes.NewReader(protected), int64(len(protected)), extractors.MimeTypeEpub)
+		assert.ErrorIs(t, err, extractors.ErrEncrypted, name)
+	}
+}
+
+// TestCheckMalformedDocument tests that files of an allowed format with a broken structure are detected
+func TestCheckMalformedDocument(t *testing.T) {
+	pdf := []byte("%PDF-1.4\ngarbage\n%%EOF\n")
+	_, err := extractors.CheckDocument(bytes.NewReader(pdf), int64(len(pdf)), extractors.MimeTypePdf)
+	assert.ErrorIs(t, err, extractors.ErrMalformed)
+
+	epub := fixtures.EPUB("Title", "Author", "en", "2000", []fixtures.EpubChapter{{Title: "1", Text: "a"}}, nil)
+	epub = epub[:len(epub)/2]
+	_, err = extractors.CheckDocument(bytes.NewReader(epub), int64(len(epub)), extractors.MimeTypeEpub)
+	assert.ErrorIs(t, err, extractors.ErrMalformed)
+
+	empty := fixtures.EPUB("Title", "Author", "en", "2000", nil, nil)
+	_, err = extractors.CheckDocument(bytes.NewReader(empty), int64(len(empty)), extractors.MimeTypeEpub)
+	assert.ErrorIs(t, err, extractors.ErrMalformed)
+}
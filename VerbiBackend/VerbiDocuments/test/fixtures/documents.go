@@ -0,0 +1,153 @@
+package fixtures
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// escapePdfString escapes characters with special meaning in PDF literal strings
+func escapePdfString(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(value)
+}
+
+// PDF builds a minimal valid PDF document with the given document information and one text page per element of pages
+func PDF(info map[string]string, language string, pages []string) []byte {
+	objects := []string{
+		fmt.Sprintf("<< /Type /Catalog /Pages 2 0 R /Lang (%s) >>", escapePdfString(language)),
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	entries := make([]string, 0, len(info))
+	for key, value := range info {
+		entries = append(entries, fmt.Sprintf("/%s (%s)", key, escapePdfString(value)))
+	}
+	objects = append(objects, "<< "+strings.Join(entries, " ")+" >>")
+
+	kids := make([]string, 0, len(pages))
+	for _, text := range pages {
+		pageId := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageId))
+
+		var content strings.Builder
+		content.WriteString("BT /F1 12 Tf 72 720 Td 14 TL\n")
+		for _, line := range strings.Split(text, "\n") {
+			content.WriteString(fmt.Sprintf("(%s) Tj T*\n", escapePdfString(line)))
+		}
+		content.WriteString("ET")
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageId+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var buffer bytes.Buffer
+	buffer.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buffer.Len()
+		buffer.WriteString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, object))
+	}
+
+	xref := buffer.Len()
+	buffer.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1))
+	for _, offset := range offsets {
+		buffer.WriteString(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	buffer.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref))
+	return buffer.Bytes()
+}
+
+// EpubChapter is a chapter of a generated EPUB book
+type EpubChapter struct {
+	Title string
+	Text  string
+}
+
+// EPUB builds a minimal valid EPUB 3 book. The cover is added to the manifest if it is not nil
+func EPUB(title, author, language, date string, chapters []EpubChapter, cover []byte) []byte {
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+
+	mimetype, _ := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	_, _ = mimetype.Write([]byte("application/epub+zip"))
+
+	write := func(name, content string) {
+		file, _ := archive.Create(name)
+		_, _ = file.Write([]byte(content))
+	}
+
+	write("META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`)
+
+	var manifest, spine strings.Builder
+	for i, chapter := range chapters {
+		name := fmt.Sprintf("chapter%d.xhtml", i+1)
+		manifest.WriteString(fmt.Sprintf(`<item id="c%d" href="text/%s" media-type="application/xhtml+xml"/>`, i+1, name))
+		spine.WriteString(fmt.Sprintf(`<itemref idref="c%d"/>`, i+1))
+
+		var body strings.Builder
+		for _, paragraph := range strings.Split(chapter.Text, "\n") {
+			body.WriteString("<p>" + paragraph + "</p>")
+		}
+		write("OEBPS/text/"+name, fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>%s</title><style>p { margin: 0 }</style></head>
+<body><h1>%s</h1>%s</body></html>`, chapter.Title, chapter.Title, body.String()))
+	}
+
+	coverMeta := ""
+	if cover != nil {
+		manifest.WriteString(`<item id="cover-image" href="images/cover.png" media-type="image/png" properties="cover-image"/>`)
+		coverMeta = `<meta name="cover" content="cover-image"/>`
+		file, _ := archive.Create("OEBPS/images/cover.png")
+		_, _ = file.Write(cover)
+	}
+
+	write("OEBPS/content.opf", fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="id">urn:uuid:0</dc:identifier>
+    <dc:title>%s</dc:title>
+    <dc:creator>%s</dc:creator>
+    <dc:language>%s</dc:language>
+    <dc:date>%s</dc:date>
+    %s
+  </metadata>
+  <manifest>%s</manifest>
+  <spine>%s</spine>
+</package>`, title, author, language, date, coverMeta, manifest.String(), spine.String()))
+
+	_ = archive.Close()
+	return buffer.Bytes()
+}
+
+// EncryptedPDF builds a PDF document with the given pages protected by a user password
+func EncryptedPDF(pages []string) []byte {
+	encrypt := fmt.Sprintf("/Encrypt << /Filter /Standard /V 1 /R 2 /O (%s) /U (%s) /P -4 >> /ID [(0123456789abcdef) (0123456789abcdef)] >>",
+		strings.Repeat("o", 32), strings.Repeat("u", 32))
+	return bytes.Replace(PDF(nil, "en", pages), []byte("/Info 4 0 R >>"), []byte("/Info 4 0 R "+encrypt), 1)
+}
+
+// WithEpubFile returns a copy of the EPUB book with a file added to the archive
+func WithEpubFile(book []byte, name, content string) []byte {
+	source, err := zip.NewReader(bytes.NewReader(book), int64(len(book)))
+	if err != nil {
+		panic(err)
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for _, file := range source.File {
+		_ = archive.Copy(file)
+	}
+	added, _ := archive.Create(name)
+	_, _ = added.Write([]byte(content))
+	_ = archive.Close()
+	return buffer.Bytes()
+}
@@ -0,0 +1,45 @@
+package fixtures
+
+// OpdsNavigationFeed is an OPDS 1.2 navigation feed with one subsection leading to OpdsAcquisitionFeed at fiction.xml
+const OpdsNavigationFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>urn:catalog:root</id>
+  <title>Test Catalog</title>
+  <link rel="self" href="root.xml" type="application/atom+xml;profile=opds-catalog;kind=navigation"/>
+  <link rel="start" href="root.xml" type="application/atom+xml;profile=opds-catalog;kind=navigation"/>
+  <entry>
+    <id>urn:catalog:fiction</id>
+    <title>Fiction</title>
+    <content type="text">Novels and stories</content>
+    <link rel="subsection" href="fiction.xml" type="application/atom+xml;profile=opds-catalog;kind=acquisition"/>
+  </entry>
+</feed>`
+
+// OpdsAcquisitionFeed is an OPDS 1.2 acquisition feed of a free book, available as EPUB at /files/alice.epub
+// and as PDF at /files/alice.pdf, and of a book that can only be bought
+const OpdsAcquisitionFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:dcterms="http://purl.org/dc/terms/">
+  <id>urn:catalog:fiction</id>
+  <title>Fiction</title>
+  <link rel="start" href="root.xml" type="application/atom+xml;profile=opds-catalog;kind=navigation"/>
+  <link rel="up" href="root.xml" type="application/atom+xml;profile=opds-catalog;kind=navigation"/>
+  <link rel="next" href="fiction.xml?page=2" type="application/atom+xml;profile=opds-catalog;kind=acquisition"/>
+  <entry>
+    <id>urn:book:alice</id>
+    <title>Alice's Adventures in Wonderland</title>
+    <author><name>Lewis Carroll</name></author>
+    <dcterms:language>en</dcterms:language>
+    <dcterms:issued>1865</dcterms:issued>
+    <summary>A girl falls down a rabbit hole.</summary>
+    <link rel="http://opds-spec.org/image" href="/covers/alice.jpg" type="image/jpeg"/>
+    <link rel="http://opds-spec.org/image/thumbnail" href="/covers/alice-small.jpg" type="image/jpeg"/>
+    <link rel="http://opds-spec.org/acquisition/open-access" href="/files/alice.pdf" type="application/pdf"/>
+    <link rel="http://opds-spec.org/acquisition" href="/files/alice.epub" type="application/epub+zip"/>
+  </entry>
+  <entry>
+    <id>urn:book:paid</id>
+    <title>Paid Book</title>
+    <author><name>Someone</name></author>
+    <link rel="http://opds-spec.org/acquisition/buy" href="/shop/paid" type="text/html"/>
+  </entry>
+</feed>`
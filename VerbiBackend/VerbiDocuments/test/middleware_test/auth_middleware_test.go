@@ -0,0 +1,71 @@
+package middleware_test
+
+import (
+	"VerbiDocuments/internal/middleware"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupRouter creates a router responding with the id of the authenticated user
+func setupRouter(t *testing.T) *gin.Engine {
+	t.Setenv("JWT_SECRET", "test_secret")
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/", middleware.AuthMiddleware(), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, fmt.Sprint(middleware.UserId(ctx)))
+	})
+	return router
+}
+
+// sign creates an access token like VerbiAuth does
+func sign(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+// request performs a request with the given Authorization header
+func request(router *gin.Engine, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// TestAuthMiddleware tests that the user id is taken from a valid access token
+func TestAuthMiddleware(t *testing.T) {
+	router := setupRouter(t)
+	token := sign(t, "test_secret", jwt.MapClaims{"user_id": 42, "exp": time.Now().Add(time.Minute).Unix()})
+
+	response := request(router, "Bearer "+token)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "42", response.Body.String())
+}
+
+// TestAuthMiddlewareRejectsInvalidTokens tests that requests without a valid access token are rejected
+func TestAuthMiddlewareRejectsInvalidTokens(t *testing.T) {
+	router := setupRouter(t)
+	valid := jwt.MapClaims{"user_id": 42, "exp": time.Now().Add(time.Minute).Unix()}
+
+	assert.Equal(t, http.StatusUnauthorized, request(router, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(router, sign(t, "test_secret", valid)).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(router, "Bearer "+sign(t, "other_secret", valid)).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(router, "Bearer "+sign(t, "test_secret", jwt.MapClaims{
+		"user_id": 42,
+		"exp":     time.Now().Add(-time.Minute).Unix(),
+	})).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(router, "Bearer "+sign(t, "test_secret", jwt.MapClaims{
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})).Code)
+}
@@ -0,0 +1,186 @@
+package mocks
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockS3Object is an object kept in memory by MockS3Server
+type mockS3Object struct {
+	data    []byte
+	etag    string
+	modTime time.Time
+}
+
+// MockS3Server is an in-memory stand-in for MinIO supporting the path-style subset of the S3 API used by S3BlobStore
+type MockS3Server struct {
+	Server  *httptest.Server
+	mutex   sync.Mutex
+	buckets map[string]map[string]*mockS3Object
+}
+
+// NewMockS3Server starts a new MockS3Server
+func NewMockS3Server() *MockS3Server {
+	s := &MockS3Server{buckets: make(map[string]map[string]*mockS3Object)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Endpoint returns host and port of the server without the scheme
+func (s *MockS3Server) Endpoint() string {
+	return strings.TrimPrefix(s.Server.URL, "http://")
+}
+
+// Close stops the server
+func (s *MockS3Server) Close() {
+	s.Server.Close()
+}
+
+// writeError responds with an S3 error document
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// readBody reads the request body decoding aws-chunked payloads
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	reader := bufio.NewReader(r.Body)
+	var body bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+		if _, err := io.CopyN(&body, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// handle routes S3 requests
+func (s *MockS3Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	bucket, exists := s.buckets[bucketName]
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodPut:
+			if !exists {
+				s.buckets[bucketName] = make(map[string]*mockS3Object)
+			}
+			w.WriteHeader(http.StatusOK)
+		case !exists:
+			writeError(w, http.StatusNotFound, "NoSuchBucket")
+		case r.URL.Query().Has("location"):
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></LocationConstraint>`)
+		case r.Method == http.MethodGet:
+			s.list(w, bucketName, bucket, r.URL.Query().Get("prefix"))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+		return
+	}
+
+	if !exists {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		sum := md5.Sum(data)
+		object := &mockS3Object{data: data, etag: hex.EncodeToString(sum[:]), modTime: time.Now().UTC()}
+		bucket[key] = object
+		w.Header().Set("ETag", `"`+object.etag+`"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		object, ok := bucket[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"`+object.etag+`"`)
+		http.ServeContent(w, r, key, object.modTime, bytes.NewReader(object.data))
+	case http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// list responds with a ListObjectsV2 result containing all keys with the prefix
+func (s *MockS3Server) list(w http.ResponseWriter, bucketName string, bucket map[string]*mockS3Object, prefix string) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int64
+		StorageClass string
+	}
+	type listResult struct {
+		XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}
+
+	result := listResult{Name: bucketName, Prefix: prefix, MaxKeys: 1000}
+	for key, object := range bucket {
+		if strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, content{
+				Key:          key,
+				LastModified: object.modTime.Format("2006-01-02T15:04:05.000Z"),
+				ETag:         `"` + object.etag + `"`,
+				Size:         int64(len(object.data)),
+				StorageClass: "STANDARD",
+			})
+		}
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(result)
+}
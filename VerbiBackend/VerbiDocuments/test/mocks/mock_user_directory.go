@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"VerbiDocuments/internal/clients"
+	"VerbiDocuments/internal/models"
+)
+
+// MockUserDirectory is an in-memory stand-in for VerbiAuth finding users by username or email
+type MockUserDirectory struct {
+	Users map[string]*models.UserInfo
+}
+
+// FindUser returns the user registered under the login or clients.ErrUserNotFound
+func (d *MockUserDirectory) FindUser(login string) (*models.UserInfo, error) {
+	user, ok := d.Users[login]
+	if !ok {
+		return nil, clients.ErrUserNotFound
+	}
+	return user, nil
+}
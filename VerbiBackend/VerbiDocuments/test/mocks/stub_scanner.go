@@ -0,0 +1,30 @@
+package mocks
+
+import (
+	"bytes"
+	"io"
+)
+
+// StubScanner is a stand-in for a malware scanner reporting content containing the signature as the threat
+type StubScanner struct {
+	Signature string
+	Threat    string
+	Err       error
+	Scanned   int
+}
+
+// Scan reads the whole content and returns the threat if the content contains the signature
+func (s *StubScanner) Scan(reader io.Reader) (string, error) {
+	s.Scanned++
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	if s.Err != nil {
+		return "", s.Err
+	}
+	if s.Signature != "" && bytes.Contains(content, []byte(s.Signature)) {
+		return s.Threat, nil
+	}
+	return "", nil
+}
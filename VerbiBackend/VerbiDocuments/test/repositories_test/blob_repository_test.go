@@ -0,0 +1,58 @@
+package repositories_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// setupMockedBlobRepository creates a BlobRepository on top of a mocked Postgres connection
+func setupMockedBlobRepository(t *testing.T) (*repositories.BlobRepository, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	assert.NoError(t, err)
+	return repositories.NewBlobRepository(db), mock
+}
+
+// TestSaveReferenceLocksKey tests that the previous content of a key is read under a row lock,
+// so concurrent saves of the key can not both release it
+func TestSaveReferenceLocksKey(t *testing.T) {
+	repository, mock := setupMockedBlobRepository(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "blob_references" WHERE key = \$1 .*FOR UPDATE`).
+		WithArgs("1/1/book.pdf", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "hash", "size", "mod_time"}).AddRow("1/1/book.pdf", "old", 3, time.Now()))
+	mock.ExpectExec(`UPDATE "blob_references"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	previous, err := repository.SaveReference(&models.BlobReference{Key: "1/1/book.pdf", Hash: "new", Size: 3, ModTime: time.Now()})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", previous)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSaveReferenceCreatedConcurrently tests that a key created by another save meanwhile is locked and updated
+func TestSaveReferenceCreatedConcurrently(t *testing.T) {
+	repository, mock := setupMockedBlobRepository(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE`).WillReturnRows(sqlmock.NewRows([]string{"key", "hash", "size", "mod_time"}))
+	mock.ExpectExec(`INSERT INTO "blob_references" .* ON CONFLICT DO NOTHING`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "hash", "size", "mod_time"}).AddRow("1/1/book.pdf", "other", 5, time.Now()))
+	mock.ExpectExec(`UPDATE "blob_references"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	previous, err := repository.SaveReference(&models.BlobReference{Key: "1/1/book.pdf", Hash: "new", Size: 3, ModTime: time.Now()})
+	assert.NoError(t, err)
+	assert.Equal(t, "other", previous)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,108 @@
+package repositories_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupDocumentRepository creates a DocumentRepository with a document of user 1 and a document of user 2
+func setupDocumentRepository(t *testing.T) (*repositories.DocumentRepository, *models.Document, *models.Document) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.Document{}, &models.DocumentPage{}))
+
+	repository := repositories.NewDocumentRepository(db)
+	first := &models.Document{UserId: 1, Title: "First", Path: "/1/1"}
+	_, err = repository.CreateDocument(first)
+	assert.NoError(t, err)
+	second := &models.Document{UserId: 2, Title: "Second", Path: "/2/2"}
+	_, err = repository.CreateDocument(second)
+	assert.NoError(t, err)
+
+	return repository, first, second
+}
+
+// TestGetDocumentChecksOwner tests that documents are only found for their owners
+func TestGetDocumentChecksOwner(t *testing.T) {
+	repository, first, _ := setupDocumentRepository(t)
+
+	document, err := repository.GetDocument(1, first.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "First", document.Title)
+
+	_, err = repository.GetDocument(2, first.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+// TestDeleteDocumentChecksOwner tests that users cannot delete documents of other users
+func TestDeleteDocumentChecksOwner(t *testing.T) {
+	repository, first, second := setupDocumentRepository(t)
+
+	assert.ErrorIs(t, repository.DeleteDocument(1, second.ID), gorm.ErrRecordNotFound)
+	_, err := repository.GetDocument(2, second.ID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, repository.DeleteDocument(1, first.ID))
+	_, err = repository.GetDocument(1, first.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+// TestUpdateDocumentChecksOwner tests that a document cannot be moved to or overwritten by another user
+func TestUpdateDocumentChecksOwner(t *testing.T) {
+	repository, first, second := setupDocumentRepository(t)
+
+	first.Metadata.Author = "Author"
+	assert.NoError(t, repository.UpdateDocument(first))
+	saved, err := repository.GetDocument(1, first.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Author", saved.Metadata.Author)
+
+	forged := *second
+	forged.UserId = 1
+	forged.Title = "Forged"
+	assert.ErrorIs(t, repository.UpdateDocument(&forged), gorm.ErrRecordNotFound)
+	saved, err = repository.GetDocument(2, second.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Second", saved.Title)
+}
+
+// TestGetPagesChecksOwner tests that pages of other users' documents are not returned
+func TestGetPagesChecksOwner(t *testing.T) {
+	repository, first, _ := setupDocumentRepository(t)
+	pageRepository := repositories.NewPageRepository(repository.DB)
+
+	assert.ErrorIs(t, pageRepository.ReplacePages(2, first.ID, []*models.DocumentPage{{Number: 1, Text: "x"}}), gorm.ErrRecordNotFound)
+	assert.NoError(t, pageRepository.ReplacePages(1, first.ID, []*models.DocumentPage{{Number: 1, Text: "text"}}))
+
+	pages, err := pageRepository.GetPages(1, first.ID, 1, 1)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+
+	pages, err = pageRepository.GetPages(2, first.ID, 1, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, pages)
+	count, err := pageRepository.CountPages(2, first.ID)
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+// TestStartProcessingChecksVersion tests that documents only become pending at the expected version
+func TestStartProcessingChecksVersion(t *testing.T) {
+	repository, first, _ := setupDocumentRepository(t)
+
+	assert.ErrorIs(t, repository.StartProcessing(1, first.ID, first.Version+1), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, repository.StartProcessing(2, first.ID, 0), gorm.ErrRecordNotFound)
+	assert.NoError(t, repository.StartProcessing(1, first.ID, first.Version))
+	assert.ErrorIs(t, repository.StartProcessing(1, first.ID, first.Version), gorm.ErrRecordNotFound)
+
+	document, err := repository.GetDocument(1, first.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusPending, document.ProcessingStatus)
+	assert.Equal(t, first.Version+1, document.Version)
+	assert.NoError(t, repository.StartProcessing(1, first.ID, 0))
+}
@@ -0,0 +1,39 @@
+package repositories_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestCreateVersionLocksDocument tests that the latest version number is read while the document row is locked,
+// so concurrent versions of the document can not get the same number
+func TestCreateVersionLocksDocument(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer conn.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	assert.NoError(t, err)
+	repository := repositories.NewVersionRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id" FROM "documents" WHERE id = \$1 .*FOR UPDATE`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(number\), 0\) FROM "document_versions"`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO "document_versions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	version := &models.DocumentVersion{DocumentId: 7, FileName: "book.pdf", Sha256: "hash", UploadedBy: 1}
+	assert.NoError(t, repository.CreateVersion(version))
+	assert.Equal(t, 3, version.Number)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,138 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupAnnotationService creates an AnnotationService sharing an in-memory database with its DocumentService
+// and a document of user 1 with extracted text of three pages, the first two in one chapter
+func setupAnnotationService(t *testing.T) (*services.AnnotationService, *services.DocumentService, uint) {
+	documentService := setupDocumentService(t)
+	id := createDocument(t, documentService, "Book")
+	db := documentService.DocumentRepository.DB
+	pageRepository := repositories.NewPageRepository(db)
+	assert.NoError(t, pageRepository.ReplacePages(1, id, []*models.DocumentPage{
+		{Number: 1, Chapter: "Beginning", Text: "It was a bright cold day in April"},
+		{Number: 2, Chapter: "Beginning", Text: "and the clocks were striking thirteen"},
+		{Number: 3, Text: "The end"},
+	}))
+
+	annotationService := services.NewAnnotationService(
+		repositories.NewAnnotationRepository(db),
+		documentService.DocumentRepository,
+		pageRepository,
+	)
+	return annotationService, documentService, id
+}
+
+// TestAnnotations tests highlighting text, editing and deleting annotations
+func TestAnnotations(t *testing.T) {
+	annotationService, _, id := setupAnnotationService(t)
+
+	highlight, err := annotationService.CreateAnnotation(1, id, &requests.CreateAnnotationRequest{
+		Page:        1,
+		StartOffset: 9,
+		EndOffset:   22,
+		Note:        "weather",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "bright cold d", highlight.Text)
+	assert.Equal(t, models.DefaultAnnotationColor, highlight.Color)
+	_, err = annotationService.CreateAnnotation(1, id, &requests.CreateAnnotationRequest{Page: 1, EndOffset: 100})
+	assert.ErrorIs(t, err, services.ErrInvalidAnnotation)
+	_, err = annotationService.CreateAnnotation(1, id, &requests.CreateAnnotationRequest{Page: 1, StartOffset: 5, EndOffset: 2})
+	assert.ErrorIs(t, err, services.ErrInvalidAnnotation)
+	_, err = annotationService.CreateAnnotation(2, id, &requests.CreateAnnotationRequest{Page: 1})
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+
+	page, err := annotationService.CreateAnnotation(1, id, &requests.CreateAnnotationRequest{Page: 1, Color: "#00FF00"})
+	assert.NoError(t, err)
+	annotations, err := annotationService.GetAnnotations(1, id)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{page.ID, highlight.ID}, []uint{annotations[0].ID, annotations[1].ID})
+
+	note := "cold"
+	response := "April in England"
+	updated, err := annotationService.UpdateAnnotation(1, id, highlight.ID, &requests.UpdateAnnotationRequest{
+		Note:        &note,
+		LlmResponse: &response,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "cold", updated.Note)
+	saved, err := annotationService.GetAnnotation(1, id, highlight.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "April in England", saved.LlmResponse)
+	assert.Equal(t, "bright cold d", saved.Text)
+
+	assert.ErrorIs(t, annotationService.DeleteAnnotation(2, id, page.ID), services.ErrDocumentNotFound)
+	assert.NoError(t, annotationService.DeleteAnnotation(1, id, page.ID))
+	assert.ErrorIs(t, annotationService.DeleteAnnotation(1, id, page.ID), services.ErrAnnotationNotFound)
+}
+
+// TestSharedAnnotations tests that only users with the annotate permission annotate shared documents
+// and that annotations are private to their authors
+func TestSharedAnnotations(t *testing.T) {
+	annotationService, documentService, id := setupAnnotationService(t)
+	shareRepository := repositories.NewShareRepository(documentService.DocumentRepository.DB)
+	assert.NoError(t, shareRepository.SaveShare(&models.Share{
+		DocumentId: id, OwnerId: 1, UserId: 2, Username: "reader", Permission: models.PermissionRead,
+	}))
+	own, err := annotationService.CreateAnnotation(1, id, &requests.CreateAnnotationRequest{Page: 2})
+	assert.NoError(t, err)
+
+	_, err = annotationService.CreateAnnotation(2, id, &requests.CreateAnnotationRequest{Page: 2})
+	assert.ErrorIs(t, err, services.ErrAnnotationForbidden)
+	annotations, err := annotationService.GetAnnotations(2, id)
+	assert.NoError(t, err)
+	assert.Empty(t, annotations)
+
+	assert.NoError(t, shareRepository.SaveShare(&models.Share{
+		DocumentId: id, OwnerId: 1, UserId: 2, Username: "reader", Permission: models.PermissionAnnotate,
+	}))
+	annotation, err := annotationService.CreateAnnotation(2, id, &requests.CreateAnnotationRequest{Page: 2, EndOffset: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, "and", annotation.Text)
+	annotations, err = annotationService.GetAnnotations(2, id)
+	assert.NoError(t, err)
+	assert.Len(t, annotations, 1)
+	assert.ErrorIs(t, annotationService.DeleteAnnotation(2, id, own.ID), services.ErrAnnotationNotFound)
+}
+
+// TestExportAnnotations tests grouping exported annotations by chapter and page and rendering them as Markdown
+func TestExportAnnotations(t *testing.T) {
+	annotationService, _, id := setupAnnotationService(t)
+	for _, req := range []*requests.CreateAnnotationRequest{
+		{Page: 3, Text: "The end"},
+		{Page: 2, EndOffset: 10, Note: "time"},
+		{Page: 1, StartOffset: 9, EndOffset: 15, LlmResponse: "Sunny"},
+	} {
+		_, err := annotationService.CreateAnnotation(1, id, req)
+		assert.NoError(t, err)
+	}
+
+	export, err := annotationService.ExportAnnotations(1, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Book", export.Title)
+	assert.Len(t, export.Sections, 2)
+	assert.Equal(t, "Beginning", export.Sections[0].Chapter)
+	assert.Len(t, export.Sections[0].Annotations, 2)
+	assert.Empty(t, export.Sections[1].Chapter)
+	assert.Equal(t, 3, export.Sections[1].Page)
+
+	assert.Equal(t, "# Book\n"+
+		"\n## Beginning\n"+
+		"\n> bright\n\n*Page 1*\n\n**LLM response:**\n\nSunny\n"+
+		"\n> and the cl\n\n*Page 2*\n\ntime\n"+
+		"\n## Page 3\n"+
+		"\n> The end\n",
+		string(services.AnnotationsMarkdown(export)))
+
+	_, err = annotationService.ExportAnnotations(2, id)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+}
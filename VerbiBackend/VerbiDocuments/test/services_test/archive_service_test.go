@@ -0,0 +1,232 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/test/fixtures"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupArchiveService creates an ArchiveService sharing an in-memory database with its DocumentService
+func setupArchiveService(t *testing.T) *services.ArchiveService {
+	documentService := setupDocumentService(t)
+	db := documentService.DocumentRepository.DB
+	return services.NewArchiveService(
+		documentService,
+		repositories.NewCollectionRepository(db),
+		repositories.NewTagRepository(db),
+		repositories.NewReadingStateRepository(db),
+		repositories.NewAnnotationRepository(db),
+		repositories.NewBookmarkRepository(db),
+	)
+}
+
+// exportLibrary exports the user's library and returns the archive
+func exportLibrary(t *testing.T, archiveService *services.ArchiveService, userId uint) []byte {
+	manifest, err := archiveService.PrepareExport(userId)
+	assert.NoError(t, err)
+	var archive bytes.Buffer
+	assert.NoError(t, archiveService.WriteArchive(userId, manifest, &archive))
+	return archive.Bytes()
+}
+
+// importLibrary imports the archive into the user's library
+func importLibrary(t *testing.T, archiveService *services.ArchiveService, userId uint, archive []byte) *models.ImportReport {
+	report, err := archiveService.ImportLibrary(userId, bytes.NewReader(archive), int64(len(archive)))
+	assert.NoError(t, err)
+	return report
+}
+
+// TestLibraryArchiveRoundTrip tests that importing an exported library recreates its documents with everything attached
+// to them and that importing it again only reports the documents as duplicates
+func TestLibraryArchiveRoundTrip(t *testing.T) {
+	archiveService := setupArchiveService(t)
+	documentService := archiveService.DocumentService
+	book := fixtures.PDF(map[string]string{"Author": "Extracted"}, "en", []string{"first", "second"})
+	id := createDocument(t, documentService, "Book")
+	_, err := documentService.ReplaceFile(1, id, 0, "book.pdf", bytes.NewReader(book), int64(len(book)))
+	assert.NoError(t, err)
+	author, notes := "Edited", "Borrowed from a friend"
+	_, err = documentService.UpdateDocument(1, id, 0, &requests.UpdateDocumentRequest{
+		Author: &author,
+		Tags:   []string{"fiction"},
+		Notes:  &notes,
+	})
+	assert.NoError(t, err)
+	draftId := createDocument(t, documentService, "Draft")
+
+	parent := &models.Collection{UserId: 1, Name: "Shelf"}
+	assert.NoError(t, archiveService.CollectionRepository.CreateCollection(parent))
+	child := &models.Collection{UserId: 1, ParentId: &parent.ID, Name: "Novels"}
+	assert.NoError(t, archiveService.CollectionRepository.CreateCollection(child))
+	document, err := documentService.GetDocument(1, id)
+	assert.NoError(t, err)
+	assert.NoError(t, documentService.DocumentRepository.AddToCollection(document, child))
+	_, err = archiveService.TagRepository.GetOrCreateTags(1, []string{"unused"})
+	assert.NoError(t, err)
+
+	readAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	_, err = archiveService.ReadingStateRepository.SaveReadingState(&models.ReadingState{
+		DocumentId: id, UserId: 1, Location: "2", Percentage: 50, Status: models.ReadingStatusReading, UpdatedAt: readAt,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, archiveService.AnnotationRepository.CreateAnnotation(&models.Annotation{
+		DocumentId: id, UserId: 1, Page: 1, Text: "first", Color: "#FF0000", Note: "Opening",
+	}))
+	assert.NoError(t, archiveService.BookmarkRepository.CreateBookmark(&models.Bookmark{
+		DocumentId: id, UserId: 1, Location: "2", Label: "Halfway",
+	}))
+
+	archive := exportLibrary(t, archiveService, 1)
+	report := importLibrary(t, archiveService, 2, archive)
+	assert.Len(t, report.Imported, 2)
+	assert.Equal(t, 2, report.Collections)
+	assert.Empty(t, report.Conflicts)
+
+	documents, err := documentService.GetDocuments(2, nil)
+	assert.NoError(t, err)
+	assert.Len(t, documents, 2)
+	var imported *models.Document
+	for _, document := range documents {
+		if document.Title == "Book" {
+			imported = document
+		}
+	}
+	assert.NotNil(t, imported)
+	assert.Equal(t, "book.pdf", imported.FileName)
+	assert.Equal(t, models.ProcessingStatusReady, imported.ProcessingStatus)
+	assert.Equal(t, "Edited", imported.Metadata.Author)
+	assert.Equal(t, 2, imported.Metadata.PageCount)
+	assert.Equal(t, notes, imported.Notes)
+	assert.Equal(t, "fiction", imported.Tags[0].Name)
+	assert.Equal(t, "Novels", imported.Collections[0].Name)
+	assert.NotNil(t, imported.Collections[0].ParentId)
+
+	tags, err := archiveService.TagRepository.GetTags(2)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+	state, err := archiveService.ReadingStateRepository.GetReadingState(2, imported.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", state.Location)
+	assert.True(t, readAt.Equal(state.UpdatedAt))
+	annotations, err := archiveService.AnnotationRepository.GetAnnotations(2, imported.ID)
+	assert.NoError(t, err)
+	assert.Len(t, annotations, 1)
+	assert.Equal(t, "Opening", annotations[0].Note)
+	bookmarks, err := archiveService.BookmarkRepository.GetBookmarks(2, imported.ID)
+	assert.NoError(t, err)
+	assert.Len(t, bookmarks, 1)
+	assert.Equal(t, "Halfway", bookmarks[0].Label)
+
+	report = importLibrary(t, archiveService, 2, archive)
+	assert.Len(t, report.Imported, 1, "the document without a file has nothing to deduplicate by")
+	assert.Zero(t, report.Collections)
+	assert.Len(t, report.Conflicts, 1)
+	assert.Equal(t, models.ImportConflictDuplicate, report.Conflicts[0].Reason)
+	assert.Equal(t, imported.ID, report.Conflicts[0].DocumentId)
+	assert.Equal(t, imported.Metadata.Sha256, report.Conflicts[0].Sha256)
+
+	report = importLibrary(t, archiveService, 1, archive)
+	assert.Len(t, report.Conflicts, 1)
+	assert.Equal(t, id, report.Conflicts[0].DocumentId)
+	assert.Len(t, report.Imported, 1)
+	assert.NotEqual(t, draftId, report.Imported[0])
+}
+
+// TestImportLibraryConflicts tests that documents with missing or rejected files are reported without being imported
+// and that malformed archives are refused
+func TestImportLibraryConflicts(t *testing.T) {
+	archiveService := setupArchiveService(t)
+	manifest := &models.LibraryManifest{
+		Version: models.LibraryArchiveVersion,
+		Documents: []*models.ArchivedDocument{
+			{Id: 1, Title: "Lost", FileName: "lost.pdf", File: "files/1/lost.pdf"},
+			{Id: 2, Title: "Tampered", FileName: "tampered.pdf", File: "files/2/tampered.pdf",
+				Metadata: models.DocumentMetadata{Sha256: "0000"}},
+			{Id: 3, Title: "Fake", FileName: "fake.pdf", File: "files/3/fake.pdf"},
+		},
+	}
+	files := map[string][]byte{
+		"files/2/tampered.pdf": fixtures.PDF(nil, "en", []string{"text"}),
+		"files/3/fake.pdf":     []byte("not a pdf"),
+	}
+
+	report := importLibrary(t, archiveService, 1, buildArchive(t, manifest, files))
+	assert.Empty(t, report.Imported)
+	assert.Len(t, report.Conflicts, 3)
+	for i, reason := range []string{models.ImportConflictMissingFile, models.ImportConflictMissingFile, models.ImportConflictRejected} {
+		assert.Equal(t, reason, report.Conflicts[i].Reason)
+	}
+	documents, err := archiveService.DocumentService.GetDocuments(1, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, documents)
+	trash, err := archiveService.DocumentService.GetTrash(1)
+	assert.NoError(t, err)
+	assert.Empty(t, trash, "rejected documents are purged")
+
+	manifest.Documents[0].Collections = []uint{7}
+	archive := buildArchive(t, manifest, files)
+	_, err = archiveService.ImportLibrary(1, bytes.NewReader(archive), int64(len(archive)))
+	assert.ErrorIs(t, err, services.ErrInvalidArchive)
+	_, err = archiveService.ImportLibrary(1, bytes.NewReader([]byte("not a zip")), 9)
+	assert.ErrorIs(t, err, services.ErrInvalidArchive)
+}
+
+// TestImportLibraryLimits tests that files exceeding the storage quota are rejected without being inflated
+// and that oversized manifests are refused
+func TestImportLibraryLimits(t *testing.T) {
+	archiveService := setupArchiveService(t)
+	allowed, err := archiveService.AllowedArchiveSize(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), allowed)
+	_, err = archiveService.DocumentService.QuotaService.SetQuota(1, 1000, 0)
+	assert.NoError(t, err)
+	allowed, err = archiveService.AllowedArchiveSize(1)
+	assert.NoError(t, err)
+	assert.Greater(t, allowed, int64(1000))
+
+	manifest := &models.LibraryManifest{
+		Version:   models.LibraryArchiveVersion,
+		Documents: []*models.ArchivedDocument{{Id: 1, Title: "Bomb", FileName: "bomb.pdf", File: "files/1/bomb.pdf"}},
+	}
+	archive := buildArchive(t, manifest, map[string][]byte{"files/1/bomb.pdf": make([]byte, 1<<20)})
+	assert.Less(t, len(archive), 1000*2, "the file compresses well below the quota")
+
+	report := importLibrary(t, archiveService, 1, archive)
+	assert.Empty(t, report.Imported)
+	assert.Len(t, report.Conflicts, 1)
+	assert.Equal(t, models.ImportConflictRejected, report.Conflicts[0].Reason)
+	assert.Contains(t, report.Conflicts[0].Detail, "exceeds the remaining 1000 bytes")
+
+	manifest.Documents[0].Notes = strings.Repeat("x", 9<<20)
+	archive = buildArchive(t, manifest, nil)
+	_, err = archiveService.ImportLibrary(1, bytes.NewReader(archive), int64(len(archive)))
+	assert.ErrorIs(t, err, services.ErrInvalidArchive)
+	assert.ErrorContains(t, err, "manifest.json exceeds")
+}
+
+// buildArchive creates a library archive with the manifest and the files
+func buildArchive(t *testing.T, manifest *models.LibraryManifest, files map[string][]byte) []byte {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		assert.NoError(t, err)
+		_, err = entry.Write(content)
+		assert.NoError(t, err)
+	}
+	entry, err := writer.Create("manifest.json")
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewEncoder(entry).Encode(manifest))
+	assert.NoError(t, writer.Close())
+	return archive.Bytes()
+}
@@ -0,0 +1,54 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBookmarks tests creating, editing and deleting bookmarks and that purged documents take their bookmarks along
+func TestBookmarks(t *testing.T) {
+	documentService := setupDocumentService(t)
+	db := documentService.DocumentRepository.DB
+	assert.NoError(t, db.Exec("PRAGMA foreign_keys = ON").Error)
+	bookmarkService := services.NewBookmarkService(repositories.NewBookmarkRepository(db), documentService.DocumentRepository)
+	id := createDocument(t, documentService, "Book")
+
+	_, err := bookmarkService.CreateBookmark(2, id, &requests.CreateBookmarkRequest{Location: "12"})
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	_, err = bookmarkService.CreateBookmark(1, id, &requests.CreateBookmarkRequest{Location: " "})
+	assert.ErrorIs(t, err, services.ErrInvalidBookmark)
+	first, err := bookmarkService.CreateBookmark(1, id, &requests.CreateBookmarkRequest{Location: "12", Label: " Map "})
+	assert.NoError(t, err)
+	assert.Equal(t, "Map", first.Label)
+	second, err := bookmarkService.CreateBookmark(1, id, &requests.CreateBookmarkRequest{
+		Location: "epubcfi(/6/4!/4/2/1:0)",
+	})
+	assert.NoError(t, err)
+
+	label := "Glossary"
+	updated, err := bookmarkService.UpdateBookmark(1, id, second.ID, &requests.UpdateBookmarkRequest{Label: &label})
+	assert.NoError(t, err)
+	assert.Equal(t, "epubcfi(/6/4!/4/2/1:0)", updated.Location)
+	_, err = bookmarkService.UpdateBookmark(2, id, second.ID, &requests.UpdateBookmarkRequest{Label: &label})
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	bookmarks, err := bookmarkService.GetBookmarks(1, id)
+	assert.NoError(t, err)
+	assert.Len(t, bookmarks, 2)
+	assert.Equal(t, "Glossary", bookmarks[1].Label)
+
+	assert.NoError(t, bookmarkService.DeleteBookmark(1, id, first.ID))
+	assert.ErrorIs(t, bookmarkService.DeleteBookmark(1, id, first.ID), services.ErrBookmarkNotFound)
+
+	assert.NoError(t, documentService.DeleteDocument(1, id))
+	_, err = documentService.PurgeTrash(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	var count int64
+	assert.NoError(t, db.Model(&models.Bookmark{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
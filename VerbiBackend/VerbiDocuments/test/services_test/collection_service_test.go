@@ -0,0 +1,141 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// setupCollectionService creates a CollectionService sharing an in-memory database with its DocumentService
+func setupCollectionService(t *testing.T) *services.CollectionService {
+	documentService := setupDocumentService(t)
+	return services.NewCollectionService(
+		repositories.NewCollectionRepository(documentService.DocumentRepository.DB),
+		documentService.DocumentRepository,
+		documentService,
+	)
+}
+
+// createDocument creates a document of user 1 and returns its id
+func createDocument(t *testing.T, documentService *services.DocumentService, title string) uint {
+	created, err := documentService.CreateDocument(1, title, "")
+	assert.NoError(t, err)
+	return created["documentId"].(uint)
+}
+
+// TestCollectionTree tests creating, renaming and moving nested collections
+func TestCollectionTree(t *testing.T) {
+	collectionService := setupCollectionService(t)
+
+	fiction, err := collectionService.CreateCollection(1, " Fiction ", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Fiction", fiction.Name)
+	classics, err := collectionService.CreateCollection(1, "Classics", &fiction.ID)
+	assert.NoError(t, err)
+	_, err = collectionService.CreateCollection(1, "Classics", nil)
+	assert.NoError(t, err)
+
+	_, err = collectionService.CreateCollection(1, "Classics", &fiction.ID)
+	assert.ErrorIs(t, err, services.ErrInvalidCollection)
+	_, err = collectionService.CreateCollection(2, "Other", &fiction.ID)
+	assert.ErrorIs(t, err, services.ErrInvalidCollection)
+	_, err = collectionService.UpdateCollection(1, fiction.ID, nil, &classics.ID)
+	assert.ErrorIs(t, err, services.ErrInvalidCollection)
+	_, err = collectionService.UpdateCollection(2, fiction.ID, nil, nil)
+	assert.ErrorIs(t, err, services.ErrCollectionNotFound)
+
+	name, root := "Old books", uint(0)
+	moved, err := collectionService.UpdateCollection(1, classics.ID, &name, &root)
+	assert.NoError(t, err)
+	assert.Nil(t, moved.ParentId)
+
+	collections, err := collectionService.GetCollections(1)
+	assert.NoError(t, err)
+	assert.Len(t, collections, 3)
+	assert.Equal(t, "Old books", collections[2].Name)
+}
+
+// TestCollectionDocuments tests adding documents to collections and filtering the library by them
+func TestCollectionDocuments(t *testing.T) {
+	collectionService := setupCollectionService(t)
+	documentService := collectionService.DocumentService
+	first := createDocument(t, documentService, "First")
+	createDocument(t, documentService, "Second")
+
+	collection, err := collectionService.CreateCollection(1, "Favourites", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, collectionService.AddDocument(1, collection.ID, first))
+	assert.NoError(t, collectionService.AddDocument(1, collection.ID, first))
+	assert.ErrorIs(t, collectionService.AddDocument(2, collection.ID, first), services.ErrCollectionNotFound)
+
+	documents, err := documentService.GetDocuments(1, &models.DocumentFilter{CollectionId: collection.ID})
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1)
+	assert.Equal(t, first, documents[0].ID)
+	assert.Len(t, documents[0].Collections, 1)
+
+	assert.NoError(t, collectionService.RemoveDocument(1, collection.ID, first))
+	documents, err = documentService.GetDocuments(1, &models.DocumentFilter{CollectionId: collection.ID})
+	assert.NoError(t, err)
+	assert.Empty(t, documents)
+}
+
+// TestDeleteCollection tests that deleting a collection deletes nested ones and keeps or trashes their documents
+func TestDeleteCollection(t *testing.T) {
+	collectionService := setupCollectionService(t)
+	documentService := collectionService.DocumentService
+	kept := createDocument(t, documentService, "Kept")
+	trashed := createDocument(t, documentService, "Trashed")
+
+	parent, err := collectionService.CreateCollection(1, "Parent", nil)
+	assert.NoError(t, err)
+	child, err := collectionService.CreateCollection(1, "Child", &parent.ID)
+	assert.NoError(t, err)
+	other, err := collectionService.CreateCollection(1, "Other", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, collectionService.AddDocument(1, other.ID, kept))
+	assert.NoError(t, collectionService.AddDocument(1, child.ID, trashed))
+
+	assert.ErrorIs(t, collectionService.DeleteCollection(1, other.ID, "burn"), services.ErrInvalidCollection)
+	assert.NoError(t, collectionService.DeleteCollection(1, other.ID, services.KeepDocuments))
+	assert.NoError(t, collectionService.DeleteCollection(1, parent.ID, services.TrashDocuments))
+
+	collections, err := collectionService.GetCollections(1)
+	assert.NoError(t, err)
+	assert.Empty(t, collections)
+
+	documents, err := documentService.GetDocuments(1, nil)
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1)
+	assert.Equal(t, kept, documents[0].ID)
+}
+
+// TestDeleteCollectionAtomically tests that a collection whose documents can not be moved to the trash is not deleted
+func TestDeleteCollectionAtomically(t *testing.T) {
+	collectionService := setupCollectionService(t)
+	documentId := createDocument(t, collectionService.DocumentService, "Book")
+	collection, err := collectionService.CreateCollection(1, "Shelf", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, collectionService.AddDocument(1, collection.ID, documentId))
+
+	db := collectionService.CollectionRepository.DB
+	assert.NoError(t, db.Callback().Update().Before("gorm:update").Register("fail_trash", func(tx *gorm.DB) {
+		if tx.Statement.Table == "documents" {
+			_ = tx.AddError(errors.New("storage unavailable"))
+		}
+	}))
+	assert.Error(t, collectionService.DeleteCollection(1, collection.ID, services.TrashDocuments))
+	assert.NoError(t, db.Callback().Update().Remove("fail_trash"))
+
+	collections, err := collectionService.GetCollections(1)
+	assert.NoError(t, err)
+	assert.Len(t, collections, 1)
+	document, err := collectionService.DocumentService.GetDocument(1, documentId)
+	assert.NoError(t, err)
+	assert.Len(t, document.Collections, 1)
+}
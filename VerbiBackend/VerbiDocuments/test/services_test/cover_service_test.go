@@ -0,0 +1,111 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/internal/thumbnails"
+	"VerbiDocuments/test/fixtures"
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupCoverService creates a CoverService with a document owned by user 1
+func setupCoverService(t *testing.T) (*services.CoverService, *models.Document) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.Document{}, &models.Share{}))
+
+	store, err := storage.NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+
+	repository := repositories.NewDocumentRepository(db)
+	document := &models.Document{UserId: 1, Title: "My book", Path: "/1/1"}
+	_, err = repository.CreateDocument(document)
+	assert.NoError(t, err)
+
+	return services.NewCoverService(repository, store), document
+}
+
+// readCover decodes a cover thumbnail returned by the service
+func readCover(t *testing.T, coverService *services.CoverService, document *models.Document, size thumbnails.Size) image.Image {
+	reader, info, err := coverService.GetCover(document.UserId, document.ID, size)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.Size)
+	cover, err := jpeg.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	return cover
+}
+
+// TestGenerateCoversFromEpub tests that thumbnails of the embedded EPUB cover are stored in all sizes
+func TestGenerateCoversFromEpub(t *testing.T) {
+	coverService, document := setupCoverService(t)
+	source := image.NewRGBA(image.Rect(0, 0, 300, 450))
+	for x := 0; x < 300; x++ {
+		for y := 0; y < 450; y++ {
+			source.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var cover bytes.Buffer
+	assert.NoError(t, png.Encode(&cover, source))
+	data := fixtures.EPUB("Title", "Author", "en", "2000", []fixtures.EpubChapter{{Title: "1", Text: "text"}}, cover.Bytes())
+	assert.NoError(t, coverService.BlobStore.Put("1/1/book.epub", bytes.NewReader(data), int64(len(data))))
+
+	document.FileName = "book.epub"
+	document.Metadata.MimeType = "application/epub+zip"
+	assert.NoError(t, coverService.GenerateCovers(document))
+
+	small := readCover(t, coverService, document, thumbnails.SizeSmall)
+	assert.Equal(t, image.Rect(0, 0, 160, 240), small.Bounds())
+	red, green, _, _ := small.At(80, 120).RGBA()
+	assert.Greater(t, red, uint32(0xf000))
+	assert.Less(t, green, uint32(0x1000))
+
+	large := readCover(t, coverService, document, thumbnails.SizeLarge)
+	assert.Equal(t, 480, large.Bounds().Dx())
+}
+
+// TestGetCoverGeneratesTitleCard tests that documents without an uploaded file get a title card on first request
+func TestGetCoverGeneratesTitleCard(t *testing.T) {
+	coverService, document := setupCoverService(t)
+
+	cover := readCover(t, coverService, document, thumbnails.SizeLarge)
+	assert.Equal(t, image.Rect(0, 0, 480, 720), cover.Bounds())
+
+	_, err := coverService.BlobStore.Stat("1/1/.cover-small.jpg")
+	assert.NoError(t, err)
+}
+
+// TestGetCoverChecksOwner tests that covers of other users' documents are not served
+func TestGetCoverChecksOwner(t *testing.T) {
+	coverService, document := setupCoverService(t)
+
+	_, _, err := coverService.GetCover(2, document.ID, thumbnails.SizeSmall)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+}
+
+// TestGetCoverDatabaseError tests that failures of the database are not reported as missing documents
+func TestGetCoverDatabaseError(t *testing.T) {
+	coverService, document := setupCoverService(t)
+	sqlDB, err := coverService.DocumentRepository.DB.DB()
+	assert.NoError(t, err)
+	assert.NoError(t, sqlDB.Close())
+
+	_, _, err = coverService.GetCover(1, document.ID, thumbnails.SizeSmall)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, services.ErrDocumentNotFound)
+}
@@ -0,0 +1,373 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/test/fixtures"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupDocumentService creates a DocumentService backed by an in-memory database and a deduplicating blob store
+// in a temporary directory
+func setupDocumentService(t *testing.T) *services.DocumentService {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(
+		&models.Document{},
+		&models.Tag{},
+		&models.Collection{},
+		&models.Share{},
+		&models.ShareLink{},
+		&models.ReadingState{},
+		&models.Annotation{},
+		&models.Bookmark{},
+		&models.DocumentPage{},
+		&models.DocumentVersion{},
+		&models.RetentionPolicy{},
+		&models.SftpCredentials{},
+		&models.IdempotencyKey{},
+		&models.StorageUsage{},
+		&models.StorageQuota{},
+		&models.ContentBlob{},
+		&models.BlobReference{},
+		&models.Job{},
+	))
+
+	local, err := storage.NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	store := storage.NewDedupBlobStore(local, repositories.NewBlobRepository(db))
+
+	documentRepository := repositories.NewDocumentRepository(db)
+	quotaService := services.NewQuotaService(repositories.NewQuotaRepository(db), 0, 0)
+	return services.NewDocumentService(
+		documentRepository,
+		repositories.NewSftpRepository(db),
+		repositories.NewIdempotencyRepository(db),
+		store,
+		services.NewProcessingService(
+			services.NewValidationService(store, nil, []string{extractors.MimeTypePdf, extractors.MimeTypeEpub}, 0, 0),
+			services.NewMetadataService(documentRepository, quotaService, store),
+			services.NewTextService(documentRepository, repositories.NewPageRepository(db), store),
+			services.NewCoverService(documentRepository, store),
+			services.NewVersionService(documentRepository, repositories.NewVersionRepository(db), store),
+			services.NewJobService(repositories.NewJobRepository(db)),
+		),
+		quotaService,
+	)
+}
+
+// TestCreateDocument tests that every creation saves a document and issues fresh credentials
+func TestCreateDocument(t *testing.T) {
+	documentService := setupDocumentService(t)
+
+	first, err := documentService.CreateDocument(1, "First", "")
+	assert.NoError(t, err)
+	second, err := documentService.CreateDocument(1, "Second", "")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first["documentId"], second["documentId"])
+	assert.Equal(t, "/1/2", second["path"])
+
+	documents, err := documentService.GetDocuments(1, nil)
+	assert.NoError(t, err)
+	assert.Len(t, documents, 2)
+
+	credentials, err := documentService.GetSftpCredentials(1)
+	assert.NoError(t, err)
+	assert.Equal(t, second["sftp"].(map[string]string)["username"], credentials.Username)
+}
+
+// TestCreateDocumentIdempotency tests that retries with the same idempotency key return the same document
+func TestCreateDocumentIdempotency(t *testing.T) {
+	documentService := setupDocumentService(t)
+
+	first, err := documentService.CreateDocument(1, "Book", "key")
+	assert.NoError(t, err)
+	retry, err := documentService.CreateDocument(1, "Book", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, first, retry)
+
+	other, err := documentService.CreateDocument(2, "Book", "key")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first["documentId"], other["documentId"])
+
+	_, err = documentService.CreateDocument(1, "Another book", "key")
+	assert.ErrorIs(t, err, services.ErrIdempotencyKeyReused)
+
+	documents, err := documentService.GetDocuments(1, nil)
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1)
+}
+
+// TestCreateDocumentRollback tests that a failed creation leaves no document behind
+func TestCreateDocumentRollback(t *testing.T) {
+	documentService := setupDocumentService(t)
+	assert.NoError(t, documentService.DocumentRepository.DB.Migrator().DropTable(&models.SftpCredentials{}))
+
+	_, err := documentService.CreateDocument(1, "Book", "key")
+	assert.Error(t, err)
+
+	documents, err := documentService.GetDocuments(1, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, documents)
+	_, err = documentService.IdempotencyRepository.GetKey(1, "key", time.Time{})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+// TestUpdateDocument tests that edits change only the given fields and bump the document version
+func TestUpdateDocument(t *testing.T) {
+	documentService := setupDocumentService(t)
+	created, err := documentService.CreateDocument(1, "Book", "")
+	assert.NoError(t, err)
+	id := created["documentId"].(uint)
+
+	title, notes := "  Renamed  ", "Read again"
+	document, err := documentService.UpdateDocument(1, id, 1, &requests.UpdateDocumentRequest{
+		Title: &title,
+		Notes: &notes,
+		Tags:  []string{"fiction", " classics ", "fiction", ""},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", document.Title)
+	assert.Equal(t, uint(2), document.Version)
+
+	document, err = documentService.UpdateDocument(1, id, 0, &requests.UpdateDocumentRequest{Tags: []string{"classics"}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), document.Version)
+
+	document, err = documentService.GetDocument(1, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", document.Title)
+	assert.Equal(t, "Read again", document.Notes)
+	assert.Len(t, document.Tags, 1)
+	assert.Equal(t, "classics", document.Tags[0].Name)
+	assert.Equal(t, uint(3), document.Version)
+}
+
+// TestUpdateDocumentChecksVersion tests that edits based on an outdated version and invalid edits are rejected
+func TestUpdateDocumentChecksVersion(t *testing.T) {
+	documentService := setupDocumentService(t)
+	created, err := documentService.CreateDocument(1, "Book", "")
+	assert.NoError(t, err)
+	id := created["documentId"].(uint)
+
+	author := "Author"
+	_, err = documentService.UpdateDocument(1, id, 1, &requests.UpdateDocumentRequest{Author: &author})
+	assert.NoError(t, err)
+	_, err = documentService.UpdateDocument(1, id, 1, &requests.UpdateDocumentRequest{Author: &author})
+	assert.ErrorIs(t, err, services.ErrVersionMismatch)
+
+	blank := " "
+	_, err = documentService.UpdateDocument(1, id, 0, &requests.UpdateDocumentRequest{Title: &blank})
+	assert.ErrorIs(t, err, services.ErrInvalidUpdate)
+	_, err = documentService.UpdateDocument(2, id, 0, &requests.UpdateDocumentRequest{Author: &author})
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+}
+
+// TestReplaceFile tests that a replacement file keeps the document id, is processed and removes the previous file
+func TestReplaceFile(t *testing.T) {
+	documentService := setupDocumentService(t)
+	created, err := documentService.CreateDocument(1, "Book", "")
+	assert.NoError(t, err)
+	id := created["documentId"].(uint)
+
+	first := fixtures.PDF(map[string]string{"Author": "First"}, "en", []string{"old"})
+	document, err := documentService.ReplaceFile(1, id, 1, "book.pdf", bytes.NewReader(first), int64(len(first)))
+	assert.NoError(t, err)
+	assert.Equal(t, "book.pdf", document.FileName)
+	assert.Equal(t, 1, document.Metadata.PageCount)
+
+	second := fixtures.PDF(nil, "en", []string{"new", "edition"})
+	_, err = documentService.ReplaceFile(1, id, 1, "edition.pdf", bytes.NewReader(second), -1)
+	assert.ErrorIs(t, err, services.ErrVersionMismatch)
+	document, err = documentService.ReplaceFile(1, id, document.Version, "edition.pdf", bytes.NewReader(second), -1)
+	assert.NoError(t, err)
+	assert.Equal(t, id, document.ID)
+	assert.Equal(t, "edition.pdf", document.FileName)
+	assert.Equal(t, 2, document.Metadata.PageCount)
+	assert.Equal(t, "First", document.Metadata.Author)
+
+	blobs, err := documentService.BlobStore.List(storage.DocumentPrefix(1, id))
+	assert.NoError(t, err)
+	var names []string
+	for _, blob := range blobs {
+		names = append(names, blob.Key)
+	}
+	assert.Contains(t, names, storage.DocumentKey(1, id, "edition.pdf"))
+	assert.NotContains(t, names, storage.DocumentKey(1, id, "book.pdf"))
+
+	for _, name := range []string{"", "preview.pdf", ".hidden", "dir/book.pdf"} {
+		_, err = documentService.ReplaceFile(1, id, 0, name, bytes.NewReader(first), int64(len(first)))
+		assert.ErrorIs(t, err, services.ErrInvalidUpdate)
+	}
+}
+
+// interceptingReader calls intercept before its first read
+type interceptingReader struct {
+	reader    *bytes.Reader
+	intercept func()
+}
+
+// Read calls intercept once and reads from the underlying reader
+func (r *interceptingReader) Read(p []byte) (int, error) {
+	if r.intercept != nil {
+		r.intercept()
+		r.intercept = nil
+	}
+	return r.reader.Read(p)
+}
+
+// TestReplaceFileConcurrently tests that of two replacements expecting the same version only the first one stores its file
+func TestReplaceFileConcurrently(t *testing.T) {
+	documentService := setupDocumentService(t)
+	id := createDocument(t, documentService, "Book")
+	document, err := documentService.GetDocument(1, id)
+	assert.NoError(t, err)
+
+	first := fixtures.PDF(nil, "en", []string{"first"})
+	second := fixtures.PDF(nil, "en", []string{"second", "edition"})
+	reader := &interceptingReader{reader: bytes.NewReader(first), intercept: func() {
+		// the second replacement arrives while the first one is being uploaded
+		_, err := documentService.ReplaceFile(1, id, document.Version, "book.pdf", bytes.NewReader(second), int64(len(second)))
+		assert.ErrorIs(t, err, services.ErrVersionMismatch)
+	}}
+	replaced, err := documentService.ReplaceFile(1, id, document.Version, "book.pdf", reader, int64(len(first)))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, replaced.Metadata.PageCount)
+	assert.Nil(t, reader.intercept)
+}
+
+// TestListDocuments tests that pages of the sorted and filtered list follow each other without gaps and repeats
+func TestListDocuments(t *testing.T) {
+	documentService := setupDocumentService(t)
+	db := documentService.DocumentRepository.DB
+	for i, title := range []string{"delta", "Alpha", "charlie", "Bravo", "echo"} {
+		created, err := documentService.CreateDocument(1, title, "")
+		assert.NoError(t, err)
+		assert.NoError(t, db.Model(&models.Document{}).Where("id = ?", created["documentId"]).Updates(map[string]interface{}{
+			"file_size":  (i % 2) * 100,
+			"mime_type":  []string{"application/pdf", "application/epub+zip"}[i%2],
+			"created_at": time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC),
+		}).Error)
+	}
+	_, err := documentService.CreateDocument(2, "Other", "")
+	assert.NoError(t, err)
+
+	list := func(query models.DocumentListQuery) []string {
+		var titles []string
+		for {
+			documents, total, next, err := documentService.ListDocuments(1, &query)
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, len(documents), query.Limit)
+			for _, document := range documents {
+				titles = append(titles, document.Title)
+			}
+			if next == "" {
+				assert.Equal(t, int64(len(titles)), total)
+				return titles
+			}
+			query.Cursor = next
+		}
+	}
+
+	assert.Equal(t, []string{"Alpha", "Bravo", "charlie", "delta", "echo"},
+		list(models.DocumentListQuery{Sort: models.SortByTitle, Limit: 2}))
+	assert.Equal(t, []string{"echo", "Bravo", "charlie", "Alpha", "delta"},
+		list(models.DocumentListQuery{Limit: 2}))
+	assert.Equal(t, []string{"delta", "charlie", "echo", "Alpha", "Bravo"},
+		list(models.DocumentListQuery{Sort: models.SortBySize, Order: "asc", Limit: 2}))
+	assert.Equal(t, []string{"Alpha", "Bravo"},
+		list(models.DocumentListQuery{Format: "epub", Sort: models.SortByTitle, Limit: 1}))
+
+	// titles the database and Go lower differently are neither skipped nor repeated
+	for _, title := range []string{"Ödipus", "zebra", "Ägypten"} {
+		_, err = documentService.CreateDocument(3, title, "")
+		assert.NoError(t, err)
+	}
+	var titles []string
+	query := models.DocumentListQuery{Sort: models.SortByTitle, Limit: 1}
+	for {
+		documents, _, next, err := documentService.ListDocuments(3, &query)
+		assert.NoError(t, err)
+		for _, document := range documents {
+			titles = append(titles, document.Title)
+		}
+		if next == "" {
+			break
+		}
+		query.Cursor = next
+	}
+	assert.ElementsMatch(t, []string{"Ödipus", "zebra", "Ägypten"}, titles)
+
+	first, _, next, err := documentService.ListDocuments(1, &models.DocumentListQuery{Limit: 3})
+	assert.NoError(t, err)
+	assert.Len(t, first, 3)
+	_, _, _, err = documentService.ListDocuments(1, &models.DocumentListQuery{Sort: models.SortByTitle, Cursor: next})
+	assert.ErrorIs(t, err, services.ErrInvalidListQuery)
+	for _, query := range []models.DocumentListQuery{
+		{Sort: "pages"}, {Order: "up"}, {DocumentFilter: models.DocumentFilter{Status: "abandoned"}}, {Format: "djvu"}, {Limit: services.MaxPageSize + 1}, {Cursor: "garbage"},
+	} {
+		_, _, _, err = documentService.ListDocuments(1, &query)
+		assert.ErrorIs(t, err, services.ErrInvalidListQuery)
+	}
+}
+
+// TestTrash tests that deleted documents leave the library, can be restored and are purged with their files
+func TestTrash(t *testing.T) {
+	documentService := setupDocumentService(t)
+	var ids []uint
+	for _, title := range []string{"First", "Second", "Third"} {
+		created, err := documentService.CreateDocument(1, title, "")
+		assert.NoError(t, err)
+		id := created["documentId"].(uint)
+		ids = append(ids, id)
+		assert.NoError(t, documentService.BlobStore.Put(storage.DocumentKey(1, id, "book.pdf"), bytes.NewReader([]byte("pdf")), 3))
+		assert.NoError(t, documentService.DeleteDocument(1, id))
+	}
+	assert.ErrorIs(t, documentService.DeleteDocument(1, ids[0]), services.ErrDocumentNotFound)
+
+	documents, err := documentService.GetDocuments(1, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, documents)
+	_, err = documentService.GetDocument(1, ids[0])
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	trash, err := documentService.GetTrash(1)
+	assert.NoError(t, err)
+	assert.Len(t, trash, 3)
+
+	_, err = documentService.RestoreDocument(2, ids[0])
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	document, err := documentService.RestoreDocument(1, ids[0])
+	assert.NoError(t, err)
+	assert.Nil(t, document.TrashedAt)
+	_, err = documentService.RestoreDocument(1, ids[0])
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+
+	purged, err := documentService.PurgeTrash(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = documentService.PurgeTrash(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	_, err = documentService.BlobStore.Stat(storage.DocumentKey(1, ids[1], "book.pdf"))
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+
+	assert.NoError(t, documentService.DeleteDocument(1, ids[0]))
+	purged, err = documentService.EmptyTrash(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	trash, err = documentService.GetTrash(1)
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+	_, err = documentService.BlobStore.Stat(storage.DocumentKey(1, ids[0], "book.pdf"))
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+}
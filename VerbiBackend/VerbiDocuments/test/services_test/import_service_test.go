@@ -0,0 +1,169 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/clients"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/test/fixtures"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startCatalogServer serves the fixture OPDS catalog under /opds/ and document files under /files/,
+// /files/missing responds with 404 Not Found and /files/unavailable with 503 Service Unavailable
+func startCatalogServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/opds/root.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml;profile=opds-catalog;kind=navigation")
+		_, _ = w.Write([]byte(fixtures.OpdsNavigationFeed))
+	})
+	mux.HandleFunc("/opds/fiction.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml;profile=opds-catalog;kind=acquisition")
+		_, _ = w.Write([]byte(fixtures.OpdsAcquisitionFeed))
+	})
+	mux.HandleFunc("/files/alice.epub", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/epub+zip")
+		chapters := []fixtures.EpubChapter{{Title: "Down the Rabbit-Hole", Text: "Alice was beginning to get very tired"}}
+		_, _ = w.Write(fixtures.EPUB("Alice", "Unknown", "de", "1865", chapters, nil))
+	})
+	mux.HandleFunc("/files/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write(fixtures.PDF(nil, "en", []string{"first", "second"}))
+	})
+	mux.HandleFunc("/files/large.pdf", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(fixtures.PDF(nil, "en", []string{string(bytes.Repeat([]byte("a"), 4096))}))
+	})
+	mux.HandleFunc("/files/fake.pdf", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not a pdf"))
+	})
+	mux.HandleFunc("/files/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// setupImportService creates an ImportService downloading at most 4 KiB from the local stand-in catalog server
+// without retry delays
+func setupImportService(t *testing.T) (*services.ImportService, *httptest.Server) {
+	documentService := setupDocumentService(t)
+	jobService := documentService.ProcessingService.JobService
+	jobService.RetryDelay = 0
+	downloader := clients.NewDownloadClient(5*time.Second, 4096, true)
+	return services.NewImportService(documentService, jobService, downloader), startCatalogServer(t)
+}
+
+// runImports runs the queued import jobs and the processing jobs they queue
+func runImports(t *testing.T, importService *services.ImportService) {
+	_, err := importService.JobService.RunPending(context.Background())
+	assert.NoError(t, err)
+}
+
+// TestImportUrl tests that a document imported from a URL is pending until its file is downloaded and processed
+func TestImportUrl(t *testing.T) {
+	importService, server := setupImportService(t)
+
+	document, job, err := importService.ImportUrl(1, &requests.ImportUrlRequest{Url: server.URL + "/files/download?id=3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "download", document.Title)
+	assert.Equal(t, models.ProcessingStatusPending, document.ProcessingStatus)
+	assert.Equal(t, models.JobTypeImportUrl, job.Type)
+	assert.Equal(t, document.ID, job.DocumentId)
+
+	runImports(t, importService)
+	document, err = importService.DocumentService.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusReady, document.ProcessingStatus)
+	assert.Equal(t, "download.pdf", document.FileName, "the extension comes from the content type")
+	assert.Equal(t, 2, document.Metadata.PageCount)
+
+	_, _, err = importService.ImportUrl(1, &requests.ImportUrlRequest{Url: "ftp://example.com/book.pdf"})
+	assert.ErrorIs(t, err, services.ErrInvalidImport)
+}
+
+// TestImportUrlFailures tests that documents whose download fails for good or on every attempt are rejected
+func TestImportUrlFailures(t *testing.T) {
+	importService, server := setupImportService(t)
+	importService.JobService.MaxAttempts = 2
+
+	for path, reason := range map[string]string{
+		"/files/missing":     "status 404",
+		"/files/large.pdf":   "too large",
+		"/files/fake.pdf":    "not allowed",
+		"/files/unavailable": "status 503",
+	} {
+		document, job, err := importService.ImportUrl(1, &requests.ImportUrlRequest{Url: server.URL + path, Title: path})
+		assert.NoError(t, err)
+		runImports(t, importService)
+
+		document, err = importService.DocumentService.GetDocument(1, document.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ProcessingStatusRejected, document.ProcessingStatus, path)
+		assert.Contains(t, document.RejectionReason, reason, path)
+		job, err = importService.JobService.GetJob(1, job.ID)
+		assert.NoError(t, err)
+		if path == "/files/unavailable" {
+			assert.Equal(t, models.JobStatusFailed, job.Status)
+			assert.Equal(t, 2, job.Attempts, "temporary failures are retried")
+		} else {
+			assert.Equal(t, 1, job.Attempts, path)
+		}
+	}
+}
+
+// TestOpdsCatalog tests browsing the catalog and importing a free publication with the metadata of its entry
+func TestOpdsCatalog(t *testing.T) {
+	importService, server := setupImportService(t)
+	ctx := context.Background()
+
+	root, err := importService.GetCatalog(ctx, server.URL+"/opds/root.xml")
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Catalog", root.Title)
+	assert.Len(t, root.Entries, 1)
+	assert.Equal(t, server.URL+"/opds/fiction.xml", root.Entries[0].Navigation)
+	assert.Equal(t, "Novels and stories", root.Entries[0].Summary)
+
+	fiction, err := importService.GetCatalog(ctx, root.Entries[0].Navigation)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/opds/fiction.xml?page=2", fiction.Next)
+	assert.Equal(t, server.URL+"/opds/root.xml", fiction.Up)
+	assert.Len(t, fiction.Entries, 2)
+	alice := fiction.Entries[0]
+	assert.Equal(t, []string{"Lewis Carroll"}, alice.Authors)
+	assert.Equal(t, "en", alice.Language)
+	assert.Equal(t, server.URL+"/covers/alice.jpg", alice.Cover)
+	assert.Len(t, alice.Acquisitions, 2)
+
+	request := &requests.AcquireEntryRequest{FeedUrl: fiction.Url, EntryId: alice.Id}
+	document, _, err := importService.AcquireEntry(ctx, 1, request)
+	assert.NoError(t, err)
+	assert.Equal(t, alice.Title, document.Title)
+	runImports(t, importService)
+	document, err = importService.DocumentService.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusReady, document.ProcessingStatus)
+	assert.Equal(t, "alice.epub", document.FileName, "EPUB is preferred")
+	assert.Equal(t, "Lewis Carroll", document.Metadata.Author, "the catalog metadata is kept")
+	assert.Equal(t, "en", document.Metadata.Language)
+
+	request.EntryId = "urn:book:paid"
+	_, _, err = importService.AcquireEntry(ctx, 1, request)
+	assert.ErrorIs(t, err, services.ErrInvalidImport)
+	request.EntryId = "urn:book:unknown"
+	_, _, err = importService.AcquireEntry(ctx, 1, request)
+	assert.ErrorIs(t, err, services.ErrEntryNotFound)
+	_, err = importService.GetCatalog(ctx, server.URL+"/files/download")
+	assert.ErrorIs(t, err, services.ErrCatalogUnavailable)
+
+	importService.Downloader = clients.NewDownloadClient(time.Second, 0, false)
+	_, err = importService.GetCatalog(ctx, server.URL+"/opds/root.xml")
+	assert.ErrorIs(t, err, services.ErrInvalidImport, "private addresses are not reached")
+}
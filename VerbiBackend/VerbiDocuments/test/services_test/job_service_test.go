@@ -0,0 +1,224 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupJobService creates a JobService backed by an in-memory database without retry delays
+func setupJobService(t *testing.T) *services.JobService {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	assert.NoError(t, db.AutoMigrate(&models.Job{}))
+
+	jobService := services.NewJobService(repositories.NewJobRepository(db))
+	jobService.RetryDelay = 0
+	return jobService
+}
+
+// TestJobRetries tests that failed attempts are retried until the job succeeds or runs out of attempts
+func TestJobRetries(t *testing.T) {
+	jobService := setupJobService(t)
+	jobService.MaxAttempts = 3
+	var calls atomic.Int32
+	jobService.Register("flaky", func(ctx context.Context, job *models.Job) error {
+		if calls.Add(1) < 3 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+	jobService.Register("broken", func(ctx context.Context, job *models.Job) error {
+		panic("broken handler")
+	})
+
+	flaky, err := jobService.Enqueue("flaky", 1, 1, nil)
+	assert.NoError(t, err)
+	broken, err := jobService.Enqueue("broken", 1, 1, nil)
+	assert.NoError(t, err)
+	ran, err := jobService.RunPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 6, ran)
+
+	flaky, err = jobService.GetJob(1, flaky.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusSucceeded, flaky.Status)
+	assert.Equal(t, 3, flaky.Attempts)
+	assert.NotNil(t, flaky.FinishedAt)
+
+	broken, err = jobService.GetJob(1, broken.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, broken.Status)
+	assert.Equal(t, 3, broken.Attempts)
+	assert.Contains(t, broken.LastError, "broken handler")
+
+	_, err = jobService.Enqueue("unknown", 1, 1, nil)
+	assert.Error(t, err)
+}
+
+// TestJobBackoff tests that a failed attempt is retried only after the retry delay
+func TestJobBackoff(t *testing.T) {
+	jobService := setupJobService(t)
+	jobService.RetryDelay = time.Minute
+	jobService.Register("failing", func(ctx context.Context, job *models.Job) error {
+		return errors.New("failure")
+	})
+
+	job, err := jobService.Enqueue("failing", 1, 1, nil)
+	assert.NoError(t, err)
+	ran, err := jobService.RunPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ran, "the retry is not due yet")
+
+	job, err = jobService.GetJob(1, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusQueued, job.Status)
+	assert.Equal(t, "failure", job.LastError)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), job.RunAt, 5*time.Second)
+}
+
+// TestCancelJob tests that canceled jobs do not run and running jobs are interrupted long before their lease ends
+func TestCancelJob(t *testing.T) {
+	jobService := setupJobService(t)
+	jobService.PollInterval = 10 * time.Millisecond
+	var interrupted atomic.Bool
+	jobService.Register("long", func(ctx context.Context, job *models.Job) error {
+		if job.DocumentId == 1 {
+			return errors.New("canceled jobs must not run")
+		}
+		_, err := jobService.CancelJob(job.UserId, job.ID)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			interrupted.Store(true)
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+
+	queued, err := jobService.Enqueue("long", 1, 1, nil)
+	assert.NoError(t, err)
+	_, err = jobService.CancelJob(2, queued.ID)
+	assert.ErrorIs(t, err, services.ErrJobNotFound)
+	queued, err = jobService.CancelJob(1, queued.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusCanceled, queued.Status)
+	_, err = jobService.CancelJob(1, queued.ID)
+	assert.ErrorIs(t, err, services.ErrJobFinished)
+
+	running, err := jobService.Enqueue("long", 1, 2, nil)
+	assert.NoError(t, err)
+	ran, err := jobService.RunPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	assert.True(t, interrupted.Load())
+
+	running, err = jobService.GetJob(1, running.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusCanceled, running.Status)
+	assert.Equal(t, 1, running.Attempts)
+}
+
+// TestJobConcurrency tests that workers run no more jobs of a type at once than its concurrency allows
+func TestJobConcurrency(t *testing.T) {
+	jobService := setupJobService(t)
+	jobService.PollInterval = 10 * time.Millisecond
+	jobService.Concurrency["slow"] = 2
+	var running, maxRunning atomic.Int32
+	jobService.Register("slow", func(ctx context.Context, job *models.Job) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			previous := maxRunning.Load()
+			if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+
+	var ids []uint
+	for i := 0; i < 6; i++ {
+		job, err := jobService.Enqueue("slow", 1, uint(i+1), nil)
+		assert.NoError(t, err)
+		ids = append(ids, job.ID)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobService.Start(ctx)
+
+	assert.Eventually(t, func() bool {
+		jobs, err := jobService.GetJobs(1, &models.JobListQuery{Status: models.JobStatusSucceeded})
+		return err == nil && len(jobs) == len(ids)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), maxRunning.Load())
+}
+
+// TestReleaseExpiredJobs tests that jobs of workers that stopped extending their leases are queued again
+// until they run out of attempts
+func TestReleaseExpiredJobs(t *testing.T) {
+	jobService := setupJobService(t)
+	jobService.MaxAttempts = 2
+	jobService.Register("lost", func(ctx context.Context, job *models.Job) error { return nil })
+	job, err := jobService.Enqueue("lost", 1, 1, nil)
+	assert.NoError(t, err)
+	repository := jobService.JobRepository
+
+	for _, status := range []string{models.JobStatusQueued, models.JobStatusFailed} {
+		_, err = repository.ClaimJob("lost", time.Now(), time.Now().Add(-time.Second))
+		assert.NoError(t, err)
+		released, err := repository.ReleaseExpiredJobs(time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), released)
+
+		job, err = jobService.GetJob(1, job.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, status, job.Status)
+		assert.Equal(t, "lease expired", job.LastError)
+	}
+}
+
+// TestStaleWorker tests that a worker whose lease expired does not change the job another worker claimed again
+func TestStaleWorker(t *testing.T) {
+	jobService := setupJobService(t)
+	jobService.Register("slow", func(ctx context.Context, job *models.Job) error { return nil })
+	job, err := jobService.Enqueue("slow", 1, 1, nil)
+	assert.NoError(t, err)
+	repository := jobService.JobRepository
+
+	stale, err := repository.ClaimJob("slow", time.Now(), time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	_, err = repository.ReleaseExpiredJobs(time.Now())
+	assert.NoError(t, err)
+	current, err := repository.ClaimJob("slow", time.Now(), time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, repository.ExtendLease(job.ID, stale.Attempts, time.Now().Add(time.Hour)), gorm.ErrRecordNotFound)
+	assert.NoError(t, repository.FinishJob(job.ID, stale.Attempts, models.JobStatusFailed, "stale", time.Now()))
+	assert.NoError(t, repository.RetryJob(job.ID, stale.Attempts, "stale", time.Now()))
+	running, err := repository.IsJobRunning(job.ID, current.Attempts)
+	assert.NoError(t, err)
+	assert.True(t, running)
+
+	assert.NoError(t, repository.FinishJob(job.ID, current.Attempts, models.JobStatusSucceeded, "", time.Now()))
+	job, err = jobService.GetJob(1, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusSucceeded, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+}
@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/test/fixtures"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupMetadataService creates a MetadataService with a document owned by user 1
+func setupMetadataService(t *testing.T) (*services.MetadataService, *models.Document) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.Document{}, &models.StorageUsage{}, &models.StorageQuota{}))
+
+	store, err := storage.NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+
+	repository := repositories.NewDocumentRepository(db)
+	document := &models.Document{UserId: 1, Title: "My book", Path: "/1/1"}
+	_, err = repository.CreateDocument(document)
+	assert.NoError(t, err)
+
+	quotaService := services.NewQuotaService(repositories.NewQuotaRepository(db), 0, 0)
+	return services.NewMetadataService(repository, quotaService, store), document
+}
+
+// TestProcessUpload tests that metadata of an uploaded file is saved to the document
+func TestProcessUpload(t *testing.T) {
+	metadataService, document := setupMetadataService(t)
+	data := fixtures.PDF(map[string]string{"Title": "Original", "Author": "Author"}, "en", []string{"page"})
+	assert.NoError(t, metadataService.BlobStore.Put("1/1/book.pdf", bytes.NewReader(data), int64(len(data))))
+
+	processed, err := metadataService.ProcessUpload("1/1/book.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, document.ID, processed.ID)
+
+	saved, err := metadataService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "My book", saved.Title)
+	assert.Equal(t, "book.pdf", saved.FileName)
+	assert.Equal(t, "Original", saved.Metadata.OriginalTitle)
+	assert.Equal(t, "Author", saved.Metadata.Author)
+	assert.Equal(t, 1, saved.Metadata.PageCount)
+	assert.Equal(t, int64(len(data)), saved.Metadata.FileSize)
+}
+
+// TestProcessUploadKeepsUserEdits tests that re-uploads do not overwrite metadata edited by the user
+func TestProcessUploadKeepsUserEdits(t *testing.T) {
+	metadataService, document := setupMetadataService(t)
+	document.Metadata.Author = "Edited author"
+	assert.NoError(t, metadataService.DocumentRepository.UpdateDocument(document))
+
+	data := fixtures.PDF(map[string]string{"Author": "Extracted author"}, "en", []string{"page", "page"})
+	assert.NoError(t, metadataService.BlobStore.Put("1/1/book.pdf", bytes.NewReader(data), int64(len(data))))
+	_, err := metadataService.ProcessUpload("1/1/book.pdf")
+	assert.NoError(t, err)
+
+	saved, err := metadataService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Edited author", saved.Metadata.Author)
+	assert.Equal(t, 2, saved.Metadata.PageCount)
+}
+
+// TestProcessUploadIgnoresForeignFiles tests that previews and files of other users' documents are skipped
+func TestProcessUploadIgnoresForeignFiles(t *testing.T) {
+	metadataService, document := setupMetadataService(t)
+	data := fixtures.PDF(nil, "en", []string{"page"})
+	assert.NoError(t, metadataService.BlobStore.Put("1/1/preview.pdf", bytes.NewReader(data), int64(len(data))))
+	assert.NoError(t, metadataService.BlobStore.Put("2/1/book.pdf", bytes.NewReader(data), int64(len(data))))
+
+	processed, err := metadataService.ProcessUpload("1/1/preview.pdf")
+	assert.NoError(t, err)
+	assert.Nil(t, processed)
+	_, err = metadataService.ProcessUpload("2/1/book.pdf")
+	assert.Error(t, err)
+
+	saved, err := metadataService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, saved.FileName)
+	assert.Zero(t, saved.Metadata.FileSize)
+}
@@ -0,0 +1,273 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/test/fixtures"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDocumentQuota tests that users cannot create more documents than their quota allows
+func TestDocumentQuota(t *testing.T) {
+	documentService := setupDocumentService(t)
+	documentService.QuotaService.DefaultQuota.MaxDocuments = 2
+
+	first := createDocument(t, documentService, "First")
+	createDocument(t, documentService, "Second")
+	_, err := documentService.CreateDocument(1, "Third", "")
+	assert.ErrorIs(t, err, services.ErrQuotaExceeded)
+	_, err = documentService.CreateDocument(2, "Other user", "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, documentService.DeleteDocument(1, first))
+	_, err = documentService.CreateDocument(1, "Third", "")
+	assert.ErrorIs(t, err, services.ErrQuotaExceeded, "documents in the trash count")
+
+	_, err = documentService.EmptyTrash(1)
+	assert.NoError(t, err)
+	_, err = documentService.CreateDocument(1, "Third", "")
+	assert.NoError(t, err)
+
+	usage, quota, err := documentService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), usage.Documents)
+	assert.Equal(t, int64(2), quota.MaxDocuments)
+}
+
+// TestStorageQuota tests that file sizes are accounted and uploads exceeding the quota are rejected
+func TestStorageQuota(t *testing.T) {
+	documentService := setupDocumentService(t)
+	id := createDocument(t, documentService, "Book")
+	data := fixtures.PDF(nil, "en", []string{"page"})
+	size := int64(len(data))
+
+	document, err := documentService.ReplaceFile(1, id, 0, "book.pdf", bytes.NewReader(data), size)
+	assert.NoError(t, err)
+	usage, _, err := documentService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, size, usage.Bytes)
+
+	documentService.QuotaService.DefaultQuota.MaxBytes = size + 10
+	document, err = documentService.ReplaceFile(1, id, document.Version, "book.pdf", bytes.NewReader(data), size)
+	assert.NoError(t, err, "the replaced file is not counted")
+
+	other := createDocument(t, documentService, "Other")
+	_, err = documentService.ReplaceFile(1, other, 0, "book.pdf", bytes.NewReader(data), size)
+	assert.ErrorIs(t, err, services.ErrQuotaExceeded)
+	_, err = documentService.ReplaceFile(1, other, 0, "book.pdf", bytes.NewReader(data), -1)
+	assert.ErrorIs(t, err, services.ErrQuotaExceeded, "content longer than the free space")
+	_, err = documentService.BlobStore.Stat(storage.DocumentKey(1, other, "book.pdf"))
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+
+	preview := storage.DocumentKey(1, id, services.PreviewFileName)
+	assert.NoError(t, documentService.BlobStore.Put(preview, bytes.NewReader([]byte("preview")), 7))
+	assert.NoError(t, documentService.QuotaService.AddBytes(1, 7), "side files are refunded with their document")
+	assert.NoError(t, documentService.DeleteDocument(1, id))
+	_, err = documentService.PurgeTrash(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	usage, _, err = documentService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Zero(t, usage.Bytes)
+	assert.Equal(t, int64(1), usage.Documents)
+	_, err = documentService.ReplaceFile(1, other, 0, "book.pdf", bytes.NewReader(data), size)
+	assert.NoError(t, err)
+}
+
+// TestQuotaOfSharedContent tests that users uploading identical files are each charged for the full size
+// although the content is stored once
+func TestQuotaOfSharedContent(t *testing.T) {
+	documentService := setupDocumentService(t)
+	underlying := documentService.BlobStore.(*storage.DedupBlobStore).Store
+	data := fixtures.PDF(nil, "en", []string{"page"})
+	size := int64(len(data))
+
+	var contents []int
+	for _, userId := range []uint{1, 2} {
+		created, err := documentService.CreateDocument(userId, "Classic", "")
+		assert.NoError(t, err)
+		_, err = documentService.ReplaceFile(userId, created["documentId"].(uint), 0, "classic.pdf", bytes.NewReader(data), size)
+		assert.NoError(t, err)
+
+		usage, _, err := documentService.QuotaService.GetUsage(userId)
+		assert.NoError(t, err)
+		assert.Equal(t, size, usage.Bytes)
+		stored, err := underlying.List(storage.ContentDirectory + "/")
+		assert.NoError(t, err)
+		contents = append(contents, len(stored))
+	}
+	assert.Equal(t, contents[0], contents[1], "files of the second user share the contents of the first")
+}
+
+// TestSetQuota tests that a quota of the user overrides the default one until it is reset
+func TestSetQuota(t *testing.T) {
+	documentService := setupDocumentService(t)
+	quotaService := documentService.QuotaService
+	quotaService.DefaultQuota.MaxDocuments = 1
+	createDocument(t, documentService, "First")
+
+	_, err := quotaService.SetQuota(1, 0, 3)
+	assert.NoError(t, err)
+	createDocument(t, documentService, "Second")
+	quota, err := quotaService.GetQuota(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), quota.MaxDocuments)
+
+	assert.NoError(t, quotaService.ResetQuota(1))
+	assert.ErrorIs(t, quotaService.ResetQuota(1), services.ErrQuotaNotFound)
+	_, err = documentService.CreateDocument(1, "Third", "")
+	assert.ErrorIs(t, err, services.ErrQuotaExceeded)
+}
+
+// TestSftpQuota tests that sftp uploads exceeding the quota are not stored
+func TestSftpQuota(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	sftpService.QuotaService.DefaultQuota.MaxBytes = 10
+	document := createSftpDocument(t, sftpService, 1, "Book")
+	client := setupSftpClient(t, sftpService, 1)
+	key := storage.DocumentKey(1, document.ID, "book.pdf")
+
+	file, err := client.Create("/" + key)
+	assert.NoError(t, err)
+	_, err = file.Write(bytes.Repeat([]byte("x"), 20))
+	assert.Error(t, err)
+	_ = file.Close()
+	_, err = store.Stat(key)
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+}
+
+// TestSftpPreviewUpload tests that the preview uploaded next to the content of a document is stored and charged
+func TestSftpPreviewUpload(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	document := createSftpDocument(t, sftpService, 1, "Book")
+	client := setupSftpClient(t, sftpService, 1)
+	data := fixtures.PDF(nil, "en", []string{"text"})
+	preview := fixtures.PDF(nil, "en", []string{"preview"})
+
+	uploadSftpFile(t, client, "/"+storage.DocumentKey(1, document.ID, "book.pdf"), data)
+	uploadSftpFile(t, client, "/"+storage.DocumentKey(1, document.ID, services.PreviewFileName), preview)
+	_, err := sftpService.ProcessingService.JobService.RunPending(context.Background())
+	assert.NoError(t, err)
+
+	info, err := store.Stat(storage.DocumentKey(1, document.ID, services.PreviewFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(preview)), info.Size)
+	processed, err := sftpService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "book.pdf", processed.FileName)
+	usage, _, err := sftpService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)+len(preview)), usage.Bytes)
+}
+
+// TestSftpQuotaSideFiles tests that side files are charged when they are stored and refunded when they are removed,
+// and that other files next to the content of a document, in deeper directories or of missing documents
+// can not be written over sftp, so they can not bypass the quota
+func TestSftpQuotaSideFiles(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	data := fixtures.PDF(nil, "en", []string{"text"})
+	sftpService.QuotaService.DefaultQuota.MaxBytes = int64(len(data)) + 10
+	document := createSftpDocument(t, sftpService, 1, "Book")
+	client := setupSftpClient(t, sftpService, 1)
+	temp := "/" + storage.DocumentKey(1, document.ID, ".book.pdf.part")
+	key := storage.DocumentKey(1, document.ID, "book.pdf")
+	uploadSftpFile(t, client, temp, data)
+	assert.NoError(t, client.Rename(temp, "/"+key))
+	_, err := sftpService.ProcessingService.JobService.RunPending(context.Background())
+	assert.NoError(t, err)
+	usage, _, err := sftpService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), usage.Bytes, "the temporary file is refunded when it is renamed")
+
+	preview := "/" + storage.DocumentKey(1, document.ID, services.PreviewFileName)
+	uploadSftpFile(t, client, preview, []byte("preview"))
+	file, err := client.Create(preview)
+	assert.NoError(t, err)
+	_, err = file.Write(bytes.Repeat([]byte("x"), 20))
+	assert.Error(t, err, "a side file must fit into the quota")
+	_ = file.Close()
+	usage, _, err = sftpService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)+7), usage.Bytes)
+	assert.NoError(t, client.Remove(preview))
+	usage, _, err = sftpService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), usage.Bytes)
+
+	for _, name := range []string{".cover-small.jpg", ".versions", "extra/book.pdf"} {
+		side := storage.DocumentKey(1, document.ID, name)
+		_, err = client.Create("/" + side)
+		assert.Error(t, err, name)
+		assert.Error(t, client.Rename("/"+key, "/"+side), name)
+	}
+	missing := storage.DocumentKey(1, document.ID+1, "book.pdf")
+	_, err = client.Create("/" + missing)
+	assert.Error(t, err, "documents that do not exist have no directory to write to")
+	_, err = store.Stat(missing)
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+	_, err = store.Stat(key)
+	assert.NoError(t, err)
+}
+
+// TestSftpQuotaNewName tests that a file uploaded under a new name replaces the current file of the document
+// instead of being stored next to it
+func TestSftpQuotaNewName(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	document := createSftpDocument(t, sftpService, 1, "Book")
+	client := setupSftpClient(t, sftpService, 1)
+	jobService := sftpService.ProcessingService.JobService
+	first := fixtures.PDF(nil, "en", []string{"first"})
+	second := fixtures.PDF(nil, "en", []string{"second", "longer"})
+
+	uploadSftpFile(t, client, "/"+storage.DocumentKey(1, document.ID, "book.pdf"), first)
+	_, err := jobService.RunPending(context.Background())
+	assert.NoError(t, err)
+	uploadSftpFile(t, client, "/"+storage.DocumentKey(1, document.ID, "renamed.pdf"), second)
+	_, err = jobService.RunPending(context.Background())
+	assert.NoError(t, err)
+
+	processed, err := sftpService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed.pdf", processed.FileName)
+	_, err = store.Stat(storage.DocumentKey(1, document.ID, "book.pdf"))
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+	usage, _, err := sftpService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(second)), usage.Bytes)
+}
+
+// TestSftpQuotaRefused tests that an upload that no longer fits into the quota when it is processed
+// is rejected and deleted without being retried
+func TestSftpQuotaRefused(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	data := fixtures.PDF(nil, "en", []string{"text"})
+	sftpService.QuotaService.DefaultQuota.MaxBytes = int64(len(data))
+	document := createSftpDocument(t, sftpService, 1, "Book")
+	client := setupSftpClient(t, sftpService, 1)
+	key := storage.DocumentKey(1, document.ID, "book.pdf")
+	uploadSftpFile(t, client, "/"+key, data)
+
+	sftpService.QuotaService.DefaultQuota.MaxBytes = 10
+	jobService := sftpService.ProcessingService.JobService
+	_, err := jobService.RunPending(context.Background())
+	assert.NoError(t, err)
+
+	rejected, err := sftpService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusRejected, rejected.ProcessingStatus)
+	assert.Contains(t, rejected.RejectionReason, services.ErrQuotaExceeded.Error())
+	assert.Empty(t, rejected.FileName)
+	_, err = store.Stat(key)
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+	usage, _, err := sftpService.QuotaService.GetUsage(1)
+	assert.NoError(t, err)
+	assert.Zero(t, usage.Bytes)
+	jobs, err := jobService.GetJobs(1, &models.JobListQuery{Status: models.JobStatusQueued})
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+}
@@ -0,0 +1,162 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupReadingService creates a ReadingService sharing an in-memory database with its DocumentService
+func setupReadingService(t *testing.T) (*services.ReadingService, *services.DocumentService) {
+	documentService := setupDocumentService(t)
+	readingService := services.NewReadingService(
+		repositories.NewReadingStateRepository(documentService.DocumentRepository.DB),
+		documentService.DocumentRepository,
+	)
+	return readingService, documentService
+}
+
+// TestReadingState tests that the latest reading state saved by any device wins
+func TestReadingState(t *testing.T) {
+	readingService, documentService := setupReadingService(t)
+	id := createDocument(t, documentService, "Book")
+	base := time.Now().Add(-time.Hour).UTC()
+
+	state, err := readingService.GetReadingState(1, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReadingStatusToRead, state.Status)
+	_, err = readingService.GetReadingState(2, id)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	_, err = readingService.UpdateReadingState(2, id, &requests.UpdateReadingStateRequest{UpdatedAt: base})
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	_, err = readingService.UpdateReadingState(1, id, &requests.UpdateReadingStateRequest{Status: "abandoned", UpdatedAt: base})
+	assert.ErrorIs(t, err, services.ErrInvalidReadingState)
+
+	opened := base.Add(time.Minute)
+	phone, err := readingService.UpdateReadingState(1, id, &requests.UpdateReadingStateRequest{
+		Location:     "12",
+		Percentage:   10,
+		LastOpenedAt: &opened,
+		UpdatedAt:    base.Add(2 * time.Minute),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "12", phone.Location)
+	assert.Equal(t, models.ReadingStatusReading, phone.Status)
+
+	stale, err := readingService.UpdateReadingState(1, id, &requests.UpdateReadingStateRequest{
+		Location:   "3",
+		Percentage: 2,
+		UpdatedAt:  base.Add(time.Minute),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "12", stale.Location)
+
+	tablet, err := readingService.UpdateReadingState(1, id, &requests.UpdateReadingStateRequest{
+		Location:   "40",
+		Percentage: 100,
+		Status:     models.ReadingStatusFinished,
+		UpdatedAt:  base.Add(3 * time.Minute),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "40", tablet.Location)
+	assert.Equal(t, models.ReadingStatusFinished, tablet.Status)
+	assert.True(t, opened.Equal(*tablet.LastOpenedAt))
+
+	future, err := readingService.UpdateReadingState(1, id, &requests.UpdateReadingStateRequest{
+		Location:  "41",
+		UpdatedAt: time.Now().Add(24 * time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.True(t, future.UpdatedAt.Before(time.Now().Add(time.Second)))
+	latest, err := readingService.UpdateReadingState(1, id, &requests.UpdateReadingStateRequest{
+		Location:  "42",
+		UpdatedAt: time.Now().Add(time.Second),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "42", latest.Location)
+
+	states, err := readingService.GetReadingStates(1, nil)
+	assert.NoError(t, err)
+	assert.Len(t, states, 1)
+	states, err = readingService.GetReadingStates(1, &latest.SyncedAt)
+	assert.NoError(t, err)
+	assert.Empty(t, states)
+
+	// a device with a clock behind the server still syncs the changes stored after the last state it got
+	other := createDocument(t, documentService, "Other book")
+	lagging, err := readingService.UpdateReadingState(1, other, &requests.UpdateReadingStateRequest{
+		Location:  "7",
+		UpdatedAt: base,
+	})
+	assert.NoError(t, err)
+	assert.True(t, lagging.UpdatedAt.Before(latest.UpdatedAt))
+	states, err = readingService.GetReadingStates(1, &latest.SyncedAt)
+	assert.NoError(t, err)
+	assert.Len(t, states, 1)
+	assert.Equal(t, other, states[0].DocumentId)
+	states, err = readingService.GetReadingStates(1, &lagging.SyncedAt)
+	assert.NoError(t, err)
+	assert.Empty(t, states)
+	assert.NoError(t, documentService.DeleteDocument(1, other))
+	assert.NoError(t, documentService.DeleteDocument(1, id))
+	states, err = readingService.GetReadingStates(1, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, states)
+}
+
+// TestListDocumentsByReading tests sorting the document list by last opened time and filtering it by reading status
+func TestListDocumentsByReading(t *testing.T) {
+	readingService, documentService := setupReadingService(t)
+	base := time.Now().Add(-time.Hour).UTC()
+	ids := make(map[string]uint)
+	for _, title := range []string{"Unread", "Old", "Recent", "Done", "Untouched"} {
+		ids[title] = createDocument(t, documentService, title)
+	}
+	save := func(title, status string, opened time.Duration) {
+		lastOpenedAt := base.Add(opened)
+		_, err := readingService.UpdateReadingState(1, ids[title], &requests.UpdateReadingStateRequest{
+			Status:       status,
+			LastOpenedAt: &lastOpenedAt,
+			UpdatedAt:    lastOpenedAt,
+		})
+		assert.NoError(t, err)
+	}
+	save("Old", models.ReadingStatusReading, time.Minute)
+	save("Recent", models.ReadingStatusReading, 3*time.Minute)
+	save("Done", models.ReadingStatusFinished, 2*time.Minute)
+	save("Unread", models.ReadingStatusToRead, 0)
+
+	list := func(query models.DocumentListQuery) []string {
+		var titles []string
+		for {
+			documents, _, next, err := documentService.ListDocuments(1, &query)
+			assert.NoError(t, err)
+			for _, document := range documents {
+				titles = append(titles, document.Title)
+			}
+			if next == "" {
+				return titles
+			}
+			query.Cursor = next
+		}
+	}
+
+	assert.Equal(t, []string{"Recent", "Done", "Old", "Unread", "Untouched"},
+		list(models.DocumentListQuery{Sort: models.SortByOpened, Limit: 2}))
+	assert.Equal(t, []string{"Untouched", "Unread", "Old", "Done", "Recent"},
+		list(models.DocumentListQuery{Sort: models.SortByOpened, Order: "asc", Limit: 1}))
+	assert.Equal(t, []string{"Recent", "Old"},
+		list(models.DocumentListQuery{DocumentFilter: models.DocumentFilter{Status: models.ReadingStatusReading}, Sort: models.SortByOpened}))
+	assert.ElementsMatch(t, []string{"Unread", "Untouched"},
+		list(models.DocumentListQuery{DocumentFilter: models.DocumentFilter{Status: models.ReadingStatusToRead}}))
+
+	documents, _, _, err := documentService.ListDocuments(1, &models.DocumentListQuery{Sort: models.SortByTitle, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "Done", documents[0].Title)
+	assert.Equal(t, models.ReadingStatusFinished, documents[0].ReadingState.Status)
+}
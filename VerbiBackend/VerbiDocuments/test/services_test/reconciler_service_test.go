@@ -0,0 +1,109 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupReconcilerService creates a ReconcilerService over a storage with an intact document, a document without its file,
+// a file of a deleted document and a file outside of document directories
+func setupReconcilerService(t *testing.T) (*services.ReconcilerService, string) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.Document{}))
+
+	root := t.TempDir()
+	store, err := storage.NewLocalBlobStore(root)
+	assert.NoError(t, err)
+
+	repository := repositories.NewDocumentRepository(db)
+	for _, document := range []*models.Document{
+		{UserId: 1, Title: "Intact", Path: "/1/1", FileName: "book.pdf"},
+		{UserId: 1, Title: "Missing", Path: "/1/2", FileName: "book.pdf"},
+		{UserId: 2, Title: "Not uploaded", Path: "/2/3"},
+	} {
+		_, err := repository.CreateDocument(document)
+		assert.NoError(t, err)
+	}
+
+	for _, key := range []string{"1/1/book.pdf", "1/1/notes/a.txt", "1/9/book.pdf", "2/1/book.pdf", "2/stray.pdf"} {
+		assert.NoError(t, store.Put(key, strings.NewReader("content"), 7))
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range []string{"1/9/book.pdf", "2/stray.pdf"} {
+		assert.NoError(t, os.Chtimes(filepath.Join(root, key), old, old))
+	}
+
+	return services.NewReconcilerService(repository, store, 24*time.Hour), root
+}
+
+// orphanKeys returns keys of the reported orphan files
+func orphanKeys(report *models.ReconcileReport) []string {
+	keys := make([]string, 0, len(report.Orphans))
+	for _, orphan := range report.Orphans {
+		keys = append(keys, orphan.Key)
+	}
+	return keys
+}
+
+// TestReconcileReport tests that inconsistencies are reported without changes when repair is disabled
+func TestReconcileReport(t *testing.T) {
+	reconciler, root := setupReconcilerService(t)
+	assert.Nil(t, reconciler.LastReport())
+
+	report, err := reconciler.Reconcile(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.DocumentsChecked)
+	assert.Equal(t, 5, report.BlobsChecked)
+	assert.ElementsMatch(t, []string{"1/9/book.pdf", "2/1/book.pdf", "2/stray.pdf"}, orphanKeys(report))
+	assert.Len(t, report.MissingFiles, 1)
+	assert.Equal(t, uint(2), report.MissingFiles[0].DocumentId)
+	assert.False(t, report.MissingFiles[0].MarkedBroken)
+	assert.Same(t, report, reconciler.LastReport())
+
+	_, err = os.Stat(filepath.Join(root, "1/9/book.pdf"))
+	assert.NoError(t, err)
+	document, err := reconciler.DocumentRepository.GetDocument(1, 2)
+	assert.NoError(t, err)
+	assert.False(t, document.Broken)
+}
+
+// TestReconcileRepair tests that old orphans are deleted, recent ones are kept and documents without files are marked broken
+func TestReconcileRepair(t *testing.T) {
+	reconciler, root := setupReconcilerService(t)
+
+	report, err := reconciler.Reconcile(true)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	for _, orphan := range report.Orphans {
+		assert.Equal(t, orphan.Key != "2/1/book.pdf", orphan.Deleted, orphan.Key)
+	}
+	assert.True(t, report.MissingFiles[0].MarkedBroken)
+
+	_, err = os.Stat(filepath.Join(root, "1/9/book.pdf"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(root, "2/1/book.pdf"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(root, "1/1/notes/a.txt"))
+	assert.NoError(t, err)
+	document, err := reconciler.DocumentRepository.GetDocument(1, 2)
+	assert.NoError(t, err)
+	assert.True(t, document.Broken)
+
+	assert.NoError(t, reconciler.BlobStore.Put("1/2/book.pdf", strings.NewReader("content"), 7))
+	report, err = reconciler.Reconcile(true)
+	assert.NoError(t, err)
+	assert.Empty(t, report.MissingFiles)
+	assert.Equal(t, []uint{2}, report.Restored)
+}
@@ -0,0 +1,74 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// setupSearchService creates a TextService on top of a mocked Postgres connection
+func setupSearchService(t *testing.T) (*services.TextService, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	return services.NewTextService(repositories.NewDocumentRepository(db), repositories.NewPageRepository(db), nil), mock
+}
+
+// TestSearch tests that search is scoped to the user's documents and hits are ranked by Postgres
+func TestSearch(t *testing.T) {
+	textService, mock := setupSearchService(t)
+	rows := sqlmock.NewRows([]string{"document_id", "title", "page_number", "chapter", "rank", "snippet"}).
+		AddRow(3, "Anna Karenina", 12, "Часть первая", 0.6, "все <mark>счастливые</mark> семьи").
+		AddRow(5, "War and Peace", 1, "", 0.2, "<mark>happy</mark> families")
+	mock.ExpectQuery(`websearch_to_tsquery\('russian', \$2\).*WHERE d.user_id = \$3`).
+		WithArgs(sqlmock.AnyArg(), `"счастливые семьи" or happy`, 7, 20, 0).
+		WillReturnRows(rows)
+
+	hits, err := textService.Search(7, ` "счастливые семьи" or happy `, 20, 0)
+	assert.NoError(t, err)
+	assert.Len(t, hits, 2)
+	assert.Equal(t, uint(3), hits[0].DocumentId)
+	assert.Equal(t, 12, hits[0].PageNumber)
+	assert.Equal(t, "Часть первая", hits[0].Chapter)
+	assert.Equal(t, "все <mark>счастливые</mark> семьи", hits[0].Snippet)
+	assert.Equal(t, "War and Peace", hits[1].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSearchSkipsTrash tests that pages of documents in the trash are filtered out by the query
+func TestSearchSkipsTrash(t *testing.T) {
+	textService, mock := setupSearchService(t)
+	rows := sqlmock.NewRows([]string{"document_id", "title", "page_number", "chapter", "rank", "snippet"})
+	mock.ExpectQuery(`WHERE d.user_id = \$3 AND d.trashed_at IS NULL AND`).
+		WithArgs(sqlmock.AnyArg(), "trashed", 7, 20, 0).
+		WillReturnRows(rows)
+
+	hits, err := textService.Search(7, "trashed", 20, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, hits)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSearchRejectsInvalidParameters tests that invalid searches do not reach the database
+func TestSearchRejectsInvalidParameters(t *testing.T) {
+	textService, mock := setupSearchService(t)
+
+	_, err := textService.Search(7, "   ", 20, 0)
+	assert.ErrorIs(t, err, services.ErrInvalidSearch)
+	_, err = textService.Search(7, "happy", 0, 0)
+	assert.ErrorIs(t, err, services.ErrInvalidSearch)
+	_, err = textService.Search(7, "happy", services.MaxSearchHits+1, 0)
+	assert.ErrorIs(t, err, services.ErrInvalidSearch)
+	_, err = textService.Search(7, "happy", 20, -1)
+	assert.ErrorIs(t, err, services.ErrInvalidSearch)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,369 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/test/fixtures"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// pipe joins a reader and a writer into a single connection end
+type pipe struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// setupSftpClient serves the blob store to the given user over in-memory pipes and connects a client
+func setupSftpClient(t *testing.T, sftpService *services.SftpService, userId uint) *sftp.Client {
+	return connectSftpClient(t, sftpService.OpenSession(userId))
+}
+
+// connectSftpClient serves the sftp session over in-memory pipes and connects a client
+func connectSftpClient(t *testing.T, session *services.SftpSession) *sftp.Client {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	server := sftp.NewRequestServer(pipe{serverReader, serverWriter}, session.Handlers)
+	go func() { _ = server.Serve() }()
+
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	return client
+}
+
+// setupSftpService creates an SftpService backed by an in-memory database and a temporary local blob store
+func setupSftpService(t *testing.T) (*services.SftpService, *storage.LocalBlobStore) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(
+		&models.Document{},
+		&models.Share{},
+		&models.DocumentPage{},
+		&models.DocumentVersion{},
+		&models.RetentionPolicy{},
+		&models.SftpCredentials{},
+		&models.StorageUsage{},
+		&models.StorageQuota{},
+		&models.Job{},
+	))
+
+	store, err := storage.NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	documentRepository := repositories.NewDocumentRepository(db)
+	quotaService := services.NewQuotaService(repositories.NewQuotaRepository(db), 0, 0)
+	processingService := services.NewProcessingService(
+		services.NewValidationService(store, nil, []string{extractors.MimeTypePdf, extractors.MimeTypeEpub}, 0, 0),
+		services.NewMetadataService(documentRepository, quotaService, store),
+		services.NewTextService(documentRepository, repositories.NewPageRepository(db), store),
+		services.NewCoverService(documentRepository, store),
+		services.NewVersionService(documentRepository, repositories.NewVersionRepository(db), store),
+		services.NewJobService(repositories.NewJobRepository(db)),
+	)
+	return services.NewSftpService(
+		repositories.NewSftpRepository(db),
+		documentRepository,
+		repositories.NewShareRepository(db),
+		store,
+		processingService,
+		quotaService,
+	), store
+}
+
+// createSftpDocument saves a document of the user with the title, whose directory files can be uploaded to
+func createSftpDocument(t *testing.T, sftpService *services.SftpService, userId uint, title string) *models.Document {
+	document := &models.Document{UserId: userId, Title: title, Path: fmt.Sprintf("/%d/%s", userId, title)}
+	_, err := sftpService.DocumentRepository.CreateDocument(document)
+	assert.NoError(t, err)
+	return document
+}
+
+// uploadSftpFile writes the data to the file at the path over sftp
+func uploadSftpFile(t *testing.T, client *sftp.Client, path string, data []byte) {
+	file, err := client.Create(path)
+	assert.NoError(t, err)
+	_, err = file.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+}
+
+// TestSftpAuthenticate tests checking of temporary credentials
+func TestSftpAuthenticate(t *testing.T) {
+	sftpService, _ := setupSftpService(t)
+	assert.NoError(t, sftpService.SftpRepository.SaveSftpCredentials(7, "user", "secret"))
+
+	userId, ok := sftpService.Authenticate("user", "secret")
+	assert.True(t, ok)
+	assert.Equal(t, uint(7), userId)
+
+	_, ok = sftpService.Authenticate("user", "wrong")
+	assert.False(t, ok)
+
+	_, ok = sftpService.Authenticate("unknown", "secret")
+	assert.False(t, ok)
+}
+
+// TestSftpUploadAndDownload tests that uploaded files land in the blob store and can be read once they are validated
+func TestSftpUploadAndDownload(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	// files are uploaded to the second document of the user
+	createSftpDocument(t, sftpService, 1, "First")
+	createSftpDocument(t, sftpService, 1, "Second")
+	client := setupSftpClient(t, sftpService, 1)
+
+	content := fixtures.PDF(nil, "en", []string{"content"})
+	file, err := client.Create("/1/2/book.pdf")
+	assert.NoError(t, err)
+	_, err = file.Write(content)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	info, err := store.Stat("1/2/book.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size)
+
+	_, err = client.Open("/1/2/book.pdf")
+	assert.Error(t, err, "files of pending documents are not served")
+	_, err = sftpService.ProcessingService.JobService.RunPending(context.Background())
+	assert.NoError(t, err)
+
+	file, err = client.Open("/1/2/book.pdf")
+	assert.NoError(t, err)
+	data, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.NoError(t, file.Close())
+
+	entries, err := client.ReadDir("/1")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "2", entries[0].Name())
+	assert.True(t, entries[0].IsDir())
+
+	stat, err := client.Stat("/1/3")
+	assert.NoError(t, err)
+	assert.True(t, stat.IsDir())
+}
+
+// TestSftpRenameAndRemove tests file commands
+func TestSftpRenameAndRemove(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	// files are uploaded to the second document of the user
+	createSftpDocument(t, sftpService, 1, "First")
+	createSftpDocument(t, sftpService, 1, "Second")
+	client := setupSftpClient(t, sftpService, 1)
+
+	file, err := client.Create("/1/2/draft.pdf")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	assert.NoError(t, client.Rename("/1/2/draft.pdf", "/1/2/book.pdf"))
+	_, err = store.Stat("1/2/draft.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+	_, err = store.Stat("1/2/book.pdf")
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.Remove("/1/2/book.pdf"))
+	_, err = store.Stat("1/2/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+
+	assert.Error(t, client.Remove("/1/2/book.pdf"))
+}
+
+// TestSftpConfinement tests that users can not reach other users' directories
+func TestSftpConfinement(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	assert.NoError(t, store.Put("2/5/book.pdf", io.LimitReader(nil, 0), 0))
+	client := setupSftpClient(t, sftpService, 1)
+
+	_, err := client.Open("/2/5/book.pdf")
+	assert.Error(t, err)
+
+	_, err = client.Create("/2/5/other.pdf")
+	assert.Error(t, err)
+
+	_, err = client.Open("/1/../2/5/book.pdf")
+	assert.Error(t, err)
+
+	entries, err := client.ReadDir("/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "1", entries[0].Name())
+}
+
+// TestSftpVersionsAreReadOnly tests that stored versions can be downloaded but not changed
+func TestSftpVersionsAreReadOnly(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	assert.NoError(t, store.Put("1/2/.versions/abc", strings.NewReader("old"), 3))
+	client := setupSftpClient(t, sftpService, 1)
+
+	file, err := client.Open("/1/2/.versions/abc")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	_, err = client.Create("/1/2/.versions/abc")
+	assert.Error(t, err)
+	assert.Error(t, client.Remove("/1/2/.versions/abc"))
+	assert.Error(t, client.Rename("/1/2/.versions/abc", "/1/2/book.pdf"))
+	assert.Error(t, client.RemoveDirectory("/1/2/.versions"))
+
+	_, err = store.Stat("1/2/.versions/abc")
+	assert.NoError(t, err)
+}
+
+// TestSftpRemoveDirectoryKeepsVersions tests that removing a document directory deletes its files but not its versions
+func TestSftpRemoveDirectoryKeepsVersions(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	assert.NoError(t, store.Put("1/2/book.pdf", strings.NewReader("new"), 3))
+	assert.NoError(t, store.Put("1/2/.versions/abc", strings.NewReader("old"), 3))
+	client := setupSftpClient(t, sftpService, 1)
+
+	assert.NoError(t, client.RemoveDirectory("/1/2"))
+	_, err := store.Stat("1/2/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+	_, err = store.Stat("1/2/.versions/abc")
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.RemoveDirectory("/1"))
+	_, err = store.Stat("1/2/.versions/abc")
+	assert.NoError(t, err)
+}
+
+// TestSftpHidesTrash tests that directories of documents in the trash can't be seen or changed
+func TestSftpHidesTrash(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	trashed := &models.Document{UserId: 1, Title: "Trashed", Path: "/1/1"}
+	kept := &models.Document{UserId: 1, Title: "Kept", Path: "/1/2"}
+	for _, document := range []*models.Document{trashed, kept} {
+		_, err := sftpService.DocumentRepository.CreateDocument(document)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Put(storage.DocumentKey(1, document.ID, "book.pdf"), strings.NewReader("pdf"), 3))
+	}
+	assert.NoError(t, sftpService.DocumentRepository.TrashDocument(1, trashed.ID, time.Now()))
+	client := setupSftpClient(t, sftpService, 1)
+
+	entries, err := client.ReadDir("/1")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "2", entries[0].Name())
+
+	_, err = client.Open("/1/1/book.pdf")
+	assert.Error(t, err)
+	_, err = client.Create("/1/1/other.pdf")
+	assert.Error(t, err)
+	assert.Error(t, client.Rename("/1/2/book.pdf", "/1/1/book.pdf"))
+
+	file, err := client.Open("/1/2/book.pdf")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+}
+
+// TestSftpSharedDocuments tests that documents shared with the user can be read at their paths but not changed
+func TestSftpSharedDocuments(t *testing.T) {
+	sftpService, store := setupSftpService(t)
+	var documents []*models.Document
+	for _, title := range []string{"Shared", "Private"} {
+		document := &models.Document{UserId: 2, Title: title, Path: "/2/" + title}
+		_, err := sftpService.DocumentRepository.CreateDocument(document)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Put(storage.DocumentKey(2, document.ID, "book.pdf"), strings.NewReader("pdf"), 3))
+		documents = append(documents, document)
+	}
+	shared, private := documents[0], documents[1]
+	assert.NoError(t, sftpService.ShareRepository.SaveShare(&models.Share{
+		DocumentId: shared.ID, OwnerId: 2, UserId: 1, Username: "reader", Permission: models.PermissionRead,
+	}))
+	client := setupSftpClient(t, sftpService, 1)
+
+	entries, err := client.ReadDir("/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "2", entries[1].Name())
+	entries, err = client.ReadDir("/2")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	sharedFile := "/" + storage.DocumentKey(2, shared.ID, "book.pdf")
+	file, err := client.Open(sharedFile)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+	_, err = client.Open("/" + storage.DocumentKey(2, private.ID, "book.pdf"))
+	assert.Error(t, err)
+
+	_, err = client.Create("/" + storage.DocumentKey(2, shared.ID, "other.pdf"))
+	assert.Error(t, err)
+	assert.Error(t, client.Remove(sharedFile))
+	assert.Error(t, client.Rename(sharedFile, "/1/3/book.pdf"))
+	_, err = store.Stat(strings.TrimPrefix(sharedFile, "/"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sftpService.DocumentRepository.TrashDocument(2, shared.ID, time.Now()))
+	_, err = client.Open(sharedFile)
+	assert.Error(t, err)
+}
+
+// TestSftpUploadCompleted tests that files closed after writing emit upload.completed events which queue processing
+func TestSftpUploadCompleted(t *testing.T) {
+	sftpService, _ := setupSftpService(t)
+	document := &models.Document{UserId: 1, Title: "Book", Path: "/1/1"}
+	_, err := sftpService.DocumentRepository.CreateDocument(document)
+	assert.NoError(t, err)
+	jobService := sftpService.ProcessingService.JobService
+
+	session := sftpService.OpenSession(1)
+	client := connectSftpClient(t, session)
+	data := fixtures.PDF(nil, "en", []string{"first", "second"})
+	file, err := client.Create("/1/1/draft.pdf")
+	assert.NoError(t, err)
+	_, err = file.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+	assert.NoError(t, client.Rename("/1/1/draft.pdf", "/1/1/book.pdf"))
+	file, err = client.Create("/1/1/empty.pdf")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	assert.Equal(t, int64(2), session.Uploads(), "files closed without writing emit no event")
+
+	pending, err := sftpService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusPending, pending.ProcessingStatus)
+	jobs, err := jobService.GetJobs(1, &models.JobListQuery{DocumentId: document.ID})
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	_, err = jobService.RunPending(context.Background())
+	assert.NoError(t, err)
+	queued, err := jobService.GetJobs(1, &models.JobListQuery{Status: models.JobStatusQueued})
+	assert.NoError(t, err)
+	assert.Empty(t, queued, "the job of the renamed file is not retried")
+	processed, err := sftpService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusReady, processed.ProcessingStatus)
+	assert.Equal(t, "book.pdf", processed.FileName)
+	assert.Equal(t, 2, processed.Metadata.PageCount)
+	sum := sha256.Sum256(data)
+	assert.Equal(t, int64(len(data)), processed.Metadata.FileSize)
+	assert.Equal(t, hex.EncodeToString(sum[:]), processed.Metadata.Sha256)
+	pages, err := sftpService.ProcessingService.TextService.PageRepository.CountPages(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pages)
+}
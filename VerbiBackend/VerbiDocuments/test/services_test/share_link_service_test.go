@@ -0,0 +1,184 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupShareLinkService creates a ShareLinkService sharing an in-memory database with its DocumentService
+// and a document of user 1 with an uploaded file and extracted text of three pages
+func setupShareLinkService(t *testing.T) (*services.ShareLinkService, *services.DocumentService, uint) {
+	documentService := setupDocumentService(t)
+	id := createDocument(t, documentService, "Book")
+	assert.NoError(t, documentService.BlobStore.Put(storage.DocumentKey(1, id, "book.pdf"), bytes.NewReader([]byte("pdf")), 3))
+	document, err := documentService.DocumentRepository.GetDocument(1, id)
+	assert.NoError(t, err)
+	document.FileName = "book.pdf"
+	document.Metadata.PageCount = 3
+	document.ProcessingStatus = models.ProcessingStatusReady
+	assert.NoError(t, documentService.DocumentRepository.UpdateDocument(document))
+
+	db := documentService.DocumentRepository.DB
+	pageRepository := repositories.NewPageRepository(db)
+	assert.NoError(t, pageRepository.ReplacePages(1, id, []*models.DocumentPage{
+		{Number: 1, Text: "first"},
+		{Number: 2, Text: "second"},
+		{Number: 3, Text: "third"},
+	}))
+
+	shareLinkService := services.NewShareLinkService(
+		repositories.NewShareLinkRepository(db),
+		documentService.DocumentRepository,
+		pageRepository,
+		documentService.BlobStore,
+		[]byte("secret"),
+	)
+	return shareLinkService, documentService, id
+}
+
+// openLinkFile opens the link and returns the served file content
+func openLinkFile(t *testing.T, shareLinkService *services.ShareLinkService, token, password string) (string, error) {
+	content, err := shareLinkService.OpenLink(token, password)
+	if err != nil {
+		return "", err
+	}
+	defer content.File.Close()
+	data, err := io.ReadAll(content.File)
+	assert.NoError(t, err)
+	return string(data), nil
+}
+
+// TestShareLink tests creating, opening and revoking a public link to a document
+func TestShareLink(t *testing.T) {
+	shareLinkService, _, id := setupShareLinkService(t)
+
+	_, err := shareLinkService.CreateLink(2, id, &requests.CreateShareLinkRequest{})
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	link, err := shareLinkService.CreateLink(1, id, &requests.CreateShareLinkRequest{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, link.Token)
+	assert.False(t, link.HasPassword)
+
+	data, err := openLinkFile(t, shareLinkService, link.Token, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "pdf", data)
+	_, err = openLinkFile(t, shareLinkService, link.Token+"x", "")
+	assert.ErrorIs(t, err, services.ErrLinkNotFound)
+	_, err = openLinkFile(t, shareLinkService, "forged."+link.Token[len(link.Token)-10:], "")
+	assert.ErrorIs(t, err, services.ErrLinkNotFound)
+
+	links, err := shareLinkService.GetLinks(1, id)
+	assert.NoError(t, err)
+	assert.Len(t, links, 1)
+	assert.Equal(t, link.Token, links[0].Token)
+	assert.Equal(t, 1, links[0].Downloads)
+	assert.NotNil(t, links[0].LastDownloadedAt)
+	_, err = shareLinkService.GetLinks(2, id)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+
+	assert.ErrorIs(t, shareLinkService.RevokeLink(2, id, link.ID), services.ErrLinkNotFound)
+	assert.NoError(t, shareLinkService.RevokeLink(1, id, link.ID))
+	_, err = openLinkFile(t, shareLinkService, link.Token, "")
+	assert.ErrorIs(t, err, services.ErrLinkNotFound)
+	links, err = shareLinkService.GetLinks(1, id)
+	assert.NoError(t, err)
+	assert.NotNil(t, links[0].RevokedAt)
+}
+
+// TestShareLinkPending tests that links do not serve files of pending documents, which may not be validated yet
+func TestShareLinkPending(t *testing.T) {
+	shareLinkService, documentService, id := setupShareLinkService(t)
+	link, err := shareLinkService.CreateLink(1, id, &requests.CreateShareLinkRequest{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, documentService.DocumentRepository.SetProcessingStatus(1, id, models.ProcessingStatusPending, ""))
+	_, err = openLinkFile(t, shareLinkService, link.Token, "")
+	assert.ErrorIs(t, err, services.ErrLinkNotFound)
+
+	assert.NoError(t, documentService.DocumentRepository.SetProcessingStatus(1, id, models.ProcessingStatusReady, ""))
+	data, err := openLinkFile(t, shareLinkService, link.Token, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "pdf", data)
+}
+
+// TestShareLinkRestrictions tests password, expiry and download limit of public links
+func TestShareLinkRestrictions(t *testing.T) {
+	shareLinkService, documentService, id := setupShareLinkService(t)
+
+	past := time.Now().Add(-time.Hour)
+	_, err := shareLinkService.CreateLink(1, id, &requests.CreateShareLinkRequest{ExpiresAt: &past})
+	assert.ErrorIs(t, err, services.ErrInvalidLink)
+
+	protected, err := shareLinkService.CreateLink(1, id, &requests.CreateShareLinkRequest{Password: "open sesame", MaxDownloads: 2})
+	assert.NoError(t, err)
+	assert.True(t, protected.HasPassword)
+	_, err = openLinkFile(t, shareLinkService, protected.Token, "")
+	assert.ErrorIs(t, err, services.ErrLinkPassword)
+	_, err = openLinkFile(t, shareLinkService, protected.Token, "open sesame")
+	assert.NoError(t, err)
+	_, err = openLinkFile(t, shareLinkService, protected.Token, "open sesame")
+	assert.NoError(t, err)
+	_, err = openLinkFile(t, shareLinkService, protected.Token, "open sesame")
+	assert.ErrorIs(t, err, services.ErrLinkExpired)
+
+	future := time.Now().Add(50 * time.Millisecond)
+	expiring, err := shareLinkService.CreateLink(1, id, &requests.CreateShareLinkRequest{ExpiresAt: &future})
+	assert.NoError(t, err)
+	_, err = openLinkFile(t, shareLinkService, expiring.Token, "")
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = openLinkFile(t, shareLinkService, expiring.Token, "")
+	assert.ErrorIs(t, err, services.ErrLinkExpired)
+
+	link, err := shareLinkService.CreateLink(1, id, &requests.CreateShareLinkRequest{})
+	assert.NoError(t, err)
+	assert.NoError(t, documentService.DeleteDocument(1, id))
+	_, err = openLinkFile(t, shareLinkService, link.Token, "")
+	assert.ErrorIs(t, err, services.ErrLinkNotFound)
+}
+
+// TestShareLinkPages tests links serving the text of a page range
+func TestShareLinkPages(t *testing.T) {
+	shareLinkService, _, id := setupShareLinkService(t)
+
+	for _, req := range []*requests.CreateShareLinkRequest{
+		{PageFrom: 2},
+		{PageFrom: 3, PageTo: 2},
+		{PageFrom: 1, PageTo: 4},
+	} {
+		_, err := shareLinkService.CreateLink(1, id, req)
+		assert.ErrorIs(t, err, services.ErrInvalidLink)
+	}
+
+	link, err := shareLinkService.CreateLink(1, id, &requests.CreateShareLinkRequest{PageFrom: 2, PageTo: 3})
+	assert.NoError(t, err)
+	assert.True(t, link.TextOnly)
+	_, err = shareLinkService.OpenLink(link.Token, "")
+	assert.ErrorIs(t, err, services.ErrLinkNotFound, "text only links do not serve the file")
+	content, err := shareLinkService.OpenLinkText(link.Token, "")
+	assert.NoError(t, err)
+	assert.Nil(t, content.File)
+	assert.Equal(t, "Book", content.Document.Title)
+	assert.Len(t, content.Pages, 2)
+	assert.Equal(t, "second", content.Pages[0].Text)
+
+	whole, err := shareLinkService.CreateLink(1, id, &requests.CreateShareLinkRequest{})
+	assert.NoError(t, err)
+	assert.False(t, whole.TextOnly)
+	_, err = shareLinkService.OpenLinkText(whole.Token, "")
+	assert.ErrorIs(t, err, services.ErrLinkNotFound)
+	links, err := shareLinkService.GetLinks(1, id)
+	assert.NoError(t, err)
+	for _, stored := range links {
+		assert.Equal(t, map[uint]int{link.ID: 1, whole.ID: 0}[stored.ID], stored.Downloads, "refused requests are not counted")
+	}
+}
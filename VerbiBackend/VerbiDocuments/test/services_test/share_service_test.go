@@ -0,0 +1,123 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/models/requests"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/test/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupShareService creates a ShareService sharing an in-memory database with its DocumentService.
+// The user directory knows users 1 "owner" and 2 "reader" with email reader@example.com
+func setupShareService(t *testing.T) (*services.ShareService, *services.DocumentService) {
+	documentService := setupDocumentService(t)
+	owner := &models.UserInfo{UserId: 1, Username: "owner"}
+	reader := &models.UserInfo{UserId: 2, Username: "reader"}
+	directory := &mocks.MockUserDirectory{Users: map[string]*models.UserInfo{
+		"owner":              owner,
+		"reader":             reader,
+		"reader@example.com": reader,
+	}}
+	shareService := services.NewShareService(
+		repositories.NewShareRepository(documentService.DocumentRepository.DB),
+		documentService.DocumentRepository,
+		directory,
+	)
+	return shareService, documentService
+}
+
+// TestShareDocument tests granting, changing and revoking access to a document
+func TestShareDocument(t *testing.T) {
+	shareService, documentService := setupShareService(t)
+	id := createDocument(t, documentService, "Book")
+	notes := "private"
+	_, err := documentService.UpdateDocument(1, id, 0, &requests.UpdateDocumentRequest{Notes: &notes, Tags: []string{"mine"}})
+	assert.NoError(t, err)
+
+	_, err = documentService.GetReadableDocument(2, id)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+
+	share, err := shareService.ShareDocument(1, id, "reader", models.PermissionRead)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), share.UserId)
+	assert.Equal(t, "reader", share.Username)
+	again, err := shareService.ShareDocument(1, id, " reader@example.com ", models.PermissionAnnotate)
+	assert.NoError(t, err)
+	assert.Equal(t, share.ID, again.ID)
+	assert.Equal(t, models.PermissionAnnotate, again.Permission)
+
+	shares, err := shareService.GetShares(1, id)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 1)
+	_, err = shareService.GetShares(2, id)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+
+	document, err := documentService.GetReadableDocument(2, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Book", document.Title)
+	assert.Empty(t, document.Notes)
+	assert.Empty(t, document.Tags)
+	shared, err := shareService.GetSharedWithMe(2)
+	assert.NoError(t, err)
+	assert.Len(t, shared, 1)
+	assert.Equal(t, id, shared[0].Document.ID)
+	assert.Empty(t, shared[0].Document.Notes)
+
+	title := "Stolen"
+	_, err = documentService.UpdateDocument(2, id, 0, &requests.UpdateDocumentRequest{Title: &title})
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	assert.ErrorIs(t, documentService.DeleteDocument(2, id), services.ErrDocumentNotFound)
+
+	assert.ErrorIs(t, shareService.RevokeShare(2, id, share.ID), services.ErrShareNotFound)
+	assert.NoError(t, shareService.RevokeShare(1, id, share.ID))
+	_, err = documentService.GetReadableDocument(2, id)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	shared, err = shareService.GetSharedWithMe(2)
+	assert.NoError(t, err)
+	assert.Empty(t, shared)
+}
+
+// TestShareDocumentValidation tests that documents can't be shared with unknown users, their owners or other permissions
+func TestShareDocumentValidation(t *testing.T) {
+	shareService, documentService := setupShareService(t)
+	id := createDocument(t, documentService, "Book")
+
+	_, err := shareService.ShareDocument(1, id, "nobody", models.PermissionRead)
+	assert.ErrorIs(t, err, services.ErrUserNotFound)
+	_, err = shareService.ShareDocument(1, id, "owner", models.PermissionRead)
+	assert.ErrorIs(t, err, services.ErrInvalidShare)
+	_, err = shareService.ShareDocument(1, id, "reader", "write")
+	assert.ErrorIs(t, err, services.ErrInvalidShare)
+	_, err = shareService.ShareDocument(2, id, "owner", models.PermissionRead)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+
+	_, err = shareService.ShareDocument(1, id, "reader", models.PermissionRead)
+	assert.NoError(t, err)
+	assert.NoError(t, documentService.DeleteDocument(1, id))
+	shared, err := shareService.GetSharedWithMe(2)
+	assert.NoError(t, err)
+	assert.Empty(t, shared)
+}
+
+// TestShareDocumentLookupLimit tests that users can only look up a limited number of other users per window
+func TestShareDocumentLookupLimit(t *testing.T) {
+	shareService, documentService := setupShareService(t)
+	shareService.LookupLimit = 2
+	id := createDocument(t, documentService, "Book")
+	other := createDocument(t, documentService, "Other")
+
+	_, err := shareService.ShareDocument(1, id, "nobody", models.PermissionRead)
+	assert.ErrorIs(t, err, services.ErrUserNotFound)
+	_, err = shareService.ShareDocument(1, id, "reader", models.PermissionRead)
+	assert.NoError(t, err)
+	_, err = shareService.ShareDocument(1, other, "reader", models.PermissionRead)
+	assert.ErrorIs(t, err, services.ErrTooManyLookups)
+
+	shareService.LookupWindow = 0
+	_, err = shareService.ShareDocument(1, other, "reader", models.PermissionRead)
+	assert.NoError(t, err, "lookups outside the window are not counted")
+}
@@ -0,0 +1,75 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTagService creates a TagService sharing an in-memory database with its DocumentService
+func setupTagService(t *testing.T) *services.TagService {
+	documentService := setupDocumentService(t)
+	return services.NewTagService(
+		repositories.NewTagRepository(documentService.DocumentRepository.DB),
+		documentService.DocumentRepository,
+		documentService,
+	)
+}
+
+// TestTags tests creating, renaming and deleting tags with unique names
+func TestTags(t *testing.T) {
+	tagService := setupTagService(t)
+
+	fiction, err := tagService.CreateTag(1, " fiction ")
+	assert.NoError(t, err)
+	assert.Equal(t, "fiction", fiction.Name)
+	_, err = tagService.CreateTag(1, "fiction")
+	assert.ErrorIs(t, err, services.ErrInvalidTag)
+	_, err = tagService.CreateTag(2, "fiction")
+	assert.NoError(t, err)
+	poetry, err := tagService.CreateTag(1, "poetry")
+	assert.NoError(t, err)
+
+	_, err = tagService.RenameTag(1, poetry.ID, "fiction")
+	assert.ErrorIs(t, err, services.ErrInvalidTag)
+	renamed, err := tagService.RenameTag(1, poetry.ID, "verse")
+	assert.NoError(t, err)
+	assert.Equal(t, "verse", renamed.Name)
+	_, err = tagService.RenameTag(2, poetry.ID, "other")
+	assert.ErrorIs(t, err, services.ErrTagNotFound)
+
+	assert.NoError(t, tagService.DeleteTag(1, fiction.ID))
+	assert.ErrorIs(t, tagService.DeleteTag(1, fiction.ID), services.ErrTagNotFound)
+
+	tags, err := tagService.GetTags(1)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "verse", tags[0].Name)
+}
+
+// TestTagDocuments tests tagging documents and filtering the library by tags
+func TestTagDocuments(t *testing.T) {
+	tagService := setupTagService(t)
+	documentService := tagService.DocumentService
+	first := createDocument(t, documentService, "First")
+	createDocument(t, documentService, "Second")
+
+	tag, err := tagService.CreateTag(1, "fiction")
+	assert.NoError(t, err)
+	assert.NoError(t, tagService.AddDocument(1, tag.ID, first))
+	assert.NoError(t, tagService.AddDocument(1, tag.ID, first))
+	assert.ErrorIs(t, tagService.AddDocument(2, tag.ID, first), services.ErrTagNotFound)
+
+	documents, err := documentService.GetDocuments(1, &models.DocumentFilter{TagId: tag.ID})
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1)
+	assert.Equal(t, "fiction", documents[0].Tags[0].Name)
+
+	assert.NoError(t, tagService.RemoveDocument(1, tag.ID, first))
+	documents, err = documentService.GetDocuments(1, &models.DocumentFilter{TagId: tag.ID})
+	assert.NoError(t, err)
+	assert.Empty(t, documents)
+}
@@ -0,0 +1,132 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/extractors"
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/test/fixtures"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupProcessingService creates a ProcessingService with a document owned by user 1
+func setupProcessingService(t *testing.T) (*services.ProcessingService, *models.Document) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec("PRAGMA foreign_keys = ON").Error)
+	assert.NoError(t, db.AutoMigrate(&models.Document{}, &models.Share{}, &models.DocumentPage{}, &models.DocumentVersion{}, &models.RetentionPolicy{}, &models.StorageUsage{}, &models.StorageQuota{}, &models.Job{}))
+
+	store, err := storage.NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+
+	documentRepository := repositories.NewDocumentRepository(db)
+	document := &models.Document{UserId: 1, Title: "My book", Path: "/1/1"}
+	_, err = documentRepository.CreateDocument(document)
+	assert.NoError(t, err)
+
+	return services.NewProcessingService(
+		services.NewValidationService(store, nil, []string{extractors.MimeTypePdf, extractors.MimeTypeEpub}, 0, 0),
+		services.NewMetadataService(documentRepository, services.NewQuotaService(repositories.NewQuotaRepository(db), 0, 0), store),
+		services.NewTextService(documentRepository, repositories.NewPageRepository(db), store),
+		services.NewCoverService(documentRepository, store),
+		services.NewVersionService(documentRepository, repositories.NewVersionRepository(db), store),
+		services.NewJobService(repositories.NewJobRepository(db)),
+	), document
+}
+
+// processUpload processes the file uploaded by user 1 and runs the jobs it queued
+func processUpload(t *testing.T, processingService *services.ProcessingService, key string) {
+	_, err := processingService.ProcessUpload(key, 1)
+	assert.NoError(t, err)
+	_, err = processingService.JobService.RunPending(context.Background())
+	assert.NoError(t, err)
+}
+
+// TestGetText tests that the text of an uploaded file is indexed by pages
+func TestGetText(t *testing.T) {
+	processingService, document := setupProcessingService(t)
+	textService := processingService.TextService
+	data := fixtures.PDF(nil, "en", []string{"first", "second", "third"})
+	assert.NoError(t, textService.BlobStore.Put("1/1/book.pdf", bytes.NewReader(data), int64(len(data))))
+	processUpload(t, processingService, "1/1/book.pdf")
+
+	pages, count, err := textService.GetText(1, document.ID, 2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, 2, pages[0].Number)
+	assert.Equal(t, "second", pages[0].Text)
+	assert.Equal(t, 7, pages[0].StartOffset)
+	assert.Equal(t, "third", pages[1].Text)
+}
+
+// TestGetTextReplacesPages tests that re-uploads replace the previously extracted text
+func TestGetTextReplacesPages(t *testing.T) {
+	processingService, document := setupProcessingService(t)
+	textService := processingService.TextService
+	first := fixtures.PDF(nil, "en", []string{"old", "old"})
+	second := fixtures.PDF(nil, "en", []string{"new"})
+
+	assert.NoError(t, textService.BlobStore.Put("1/1/book.pdf", bytes.NewReader(first), int64(len(first))))
+	processUpload(t, processingService, "1/1/book.pdf")
+	assert.NoError(t, textService.BlobStore.Put("1/1/book.pdf", bytes.NewReader(second), int64(len(second))))
+	processUpload(t, processingService, "1/1/book.pdf")
+
+	pages, count, err := textService.GetText(1, document.ID, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Len(t, pages, 1)
+	assert.Equal(t, "new", pages[0].Text)
+}
+
+// TestGetTextChecksAccess tests that pages of other users' documents and invalid ranges are rejected
+func TestGetTextChecksAccess(t *testing.T) {
+	processingService, document := setupProcessingService(t)
+	textService := processingService.TextService
+
+	_, _, err := textService.GetText(2, document.ID, 1, 1)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+	_, _, err = textService.GetText(1, document.ID, 3, 2)
+	assert.Error(t, err)
+	_, _, err = textService.GetText(1, document.ID, 1, services.MaxTextPages+1)
+	assert.Error(t, err)
+}
+
+// TestDeleteDocumentDeletesPages tests that pages are deleted together with their document
+func TestDeleteDocumentDeletesPages(t *testing.T) {
+	processingService, document := setupProcessingService(t)
+	textService := processingService.TextService
+	data := fixtures.PDF(nil, "en", []string{"page"})
+	assert.NoError(t, textService.BlobStore.Put("1/1/book.pdf", bytes.NewReader(data), int64(len(data))))
+	processUpload(t, processingService, "1/1/book.pdf")
+
+	assert.NoError(t, textService.DocumentRepository.DeleteDocument(1, document.ID))
+
+	count, err := textService.PageRepository.CountPages(1, document.ID)
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+// TestGetTextErrors tests that documents without extracted text and failures of the database are told apart
+// from missing documents
+func TestGetTextErrors(t *testing.T) {
+	processingService, document := setupProcessingService(t)
+	textService := processingService.TextService
+
+	_, _, err := textService.GetText(1, document.ID, 1, 1)
+	assert.ErrorIs(t, err, services.ErrTextNotExtracted)
+
+	sqlDB, err := textService.DocumentRepository.DB.DB()
+	assert.NoError(t, err)
+	assert.NoError(t, sqlDB.Close())
+	_, _, err = textService.GetText(1, document.ID, 1, 1)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, services.ErrDocumentNotFound)
+}
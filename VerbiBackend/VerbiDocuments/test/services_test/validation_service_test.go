@@ -0,0 +1,186 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/test/fixtures"
+	"VerbiDocuments/test/mocks"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// replaceFile uploads the data as the file of the user's document
+func replaceFile(documentService *services.DocumentService, id uint, name string, data []byte) (*models.Document, error) {
+	return documentService.ReplaceFile(1, id, 0, name, bytes.NewReader(data), int64(len(data)))
+}
+
+// TestProcessingStatus tests that documents are pending until their file is accepted
+func TestProcessingStatus(t *testing.T) {
+	documentService := setupDocumentService(t)
+	id := createDocument(t, documentService, "Book")
+
+	document, err := documentService.GetDocument(1, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusPending, document.ProcessingStatus)
+
+	document, err = replaceFile(documentService, id, "book.epub", fixtures.EPUB("Book", "Author", "en", "2000", []fixtures.EpubChapter{{Title: "1", Text: "text"}}, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusReady, document.ProcessingStatus)
+	assert.Empty(t, document.RejectionReason)
+}
+
+// TestRejectUpload tests that files failing validation are deleted with a clear reason on the document
+func TestRejectUpload(t *testing.T) {
+	documentService := setupDocumentService(t)
+	validationService := documentService.ProcessingService.ValidationService
+	validationService.MaxPageCount = 2
+	validationService.MaxFileSize = 4096
+	id := createDocument(t, documentService, "Book")
+
+	for _, test := range []struct {
+		data   []byte
+		reason string
+	}{
+		{[]byte("just some plain text"), "files of type text/plain are not allowed"},
+		{[]byte{}, "file is empty"},
+		{[]byte("%PDF-1.4\ngarbage\n%%EOF\n"), "malformed document"},
+		{fixtures.EncryptedPDF([]string{"secret"}), "document is encrypted or DRM protected"},
+		{fixtures.PDF(nil, "en", []string{"1", "2", "3"}), "document of 3 pages exceeds the limit of 2 pages"},
+		{append([]byte("%PDF-1.4\n"), make([]byte, 5000)...), "file of 5009 bytes exceeds the limit of 4096 bytes"},
+	} {
+		_, err := replaceFile(documentService, id, "book.pdf", test.data)
+		assert.ErrorIs(t, err, services.ErrFileRejected, test.reason)
+		assert.ErrorContains(t, err, test.reason)
+
+		document, err := documentService.GetDocument(1, id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ProcessingStatusRejected, document.ProcessingStatus)
+		assert.Contains(t, document.RejectionReason, test.reason)
+		assert.Empty(t, document.FileName)
+		_, err = documentService.BlobStore.Stat(storage.DocumentKey(1, id, "book.pdf"))
+		assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+	}
+}
+
+// TestRejectReplacement tests that a rejected file uploaded under the name of the current file does not replace it
+func TestRejectReplacement(t *testing.T) {
+	documentService := setupDocumentService(t)
+	id := createDocument(t, documentService, "Book")
+	original := fixtures.PDF(nil, "en", []string{"original"})
+	_, err := replaceFile(documentService, id, "book.pdf", original)
+	assert.NoError(t, err)
+
+	_, err = replaceFile(documentService, id, "book.pdf", fixtures.EncryptedPDF([]string{"secret"}))
+	assert.ErrorIs(t, err, services.ErrFileRejected)
+
+	document, err := documentService.GetDocument(1, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusRejected, document.ProcessingStatus)
+	assert.Equal(t, "book.pdf", document.FileName)
+	reader, err := documentService.BlobStore.Get(storage.DocumentKey(1, id, "book.pdf"))
+	assert.NoError(t, err)
+	defer reader.Close()
+	stored, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, original, stored)
+}
+
+// TestScanUpload tests that infected files are rejected and files the scanner failed on are not
+func TestScanUpload(t *testing.T) {
+	documentService := setupDocumentService(t)
+	scanner := &mocks.StubScanner{Signature: "EICAR", Threat: "Eicar-Test-Signature"}
+	documentService.ProcessingService.ValidationService.Scanner = scanner
+	id := createDocument(t, documentService, "Book")
+
+	_, err := replaceFile(documentService, id, "book.pdf", fixtures.PDF(nil, "en", []string{"clean"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, scanner.Scanned)
+
+	_, err = replaceFile(documentService, id, "infected.pdf", fixtures.PDF(nil, "en", []string{"EICAR"}))
+	assert.ErrorIs(t, err, services.ErrFileRejected)
+	assert.ErrorContains(t, err, "malware detected: Eicar-Test-Signature")
+	_, err = documentService.BlobStore.Stat(storage.DocumentKey(1, id, "infected.pdf"))
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+
+	clean, err := documentService.BlobStore.Get(storage.DocumentKey(1, id, "book.pdf"))
+	assert.NoError(t, err)
+	original, err := io.ReadAll(clean)
+	assert.NoError(t, err)
+	clean.Close()
+
+	scanner.Err = errors.New("connection refused")
+	_, err = replaceFile(documentService, id, "book.pdf", fixtures.PDF(nil, "en", []string{"unknown"}))
+	assert.ErrorContains(t, err, "connection refused")
+	assert.NotErrorIs(t, err, services.ErrFileRejected)
+	_, err = replaceFile(documentService, id, "other.pdf", fixtures.PDF(nil, "en", []string{"unknown"}))
+	assert.ErrorContains(t, err, "connection refused")
+
+	document, err := documentService.GetDocument(1, id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusRejected, document.ProcessingStatus, "the status from before the upload is back")
+	assert.Equal(t, "book.pdf", document.FileName)
+	reader, err := documentService.BlobStore.Get(storage.DocumentKey(1, id, "book.pdf"))
+	assert.NoError(t, err)
+	defer reader.Close()
+	stored, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, original, stored, "the unscanned file does not replace the current one")
+	_, err = documentService.BlobStore.Stat(storage.DocumentKey(1, id, "other.pdf"))
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+}
+
+// TestScanFailureIsRetried tests that a processing job is retried without rejecting the file when the scanner fails
+func TestScanFailureIsRetried(t *testing.T) {
+	processingService, document := setupProcessingService(t)
+	processingService.ValidationService.Scanner = &mocks.StubScanner{Err: errors.New("scanner timed out")}
+	data := fixtures.PDF(nil, "en", []string{"text"})
+	assert.NoError(t, processingService.TextService.BlobStore.Put("1/1/book.pdf", bytes.NewReader(data), int64(len(data))))
+
+	job, err := processingService.UploadCompleted(&models.UploadEvent{UserId: 1, DocumentId: document.ID, UploaderId: 1, FileName: "book.pdf"})
+	assert.NoError(t, err)
+	_, err = processingService.JobService.RunPending(context.Background())
+	assert.NoError(t, err)
+
+	job, err = processingService.JobService.GetJob(1, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusQueued, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Contains(t, job.LastError, "scanner timed out")
+	stored, err := processingService.MetadataService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusPending, stored.ProcessingStatus)
+	_, err = processingService.TextService.BlobStore.Stat("1/1/book.pdf")
+	assert.NoError(t, err)
+}
+
+// TestScanFailureOnLastAttempt tests that a file the scanner keeps failing on is rejected after the last attempt
+func TestScanFailureOnLastAttempt(t *testing.T) {
+	processingService, document := setupProcessingService(t)
+	processingService.ValidationService.Scanner = &mocks.StubScanner{Err: errors.New("scanner timed out")}
+	processingService.JobService.MaxAttempts = 2
+	processingService.JobService.RetryDelay = 0
+	data := fixtures.PDF(nil, "en", []string{"text"})
+	assert.NoError(t, processingService.TextService.BlobStore.Put("1/1/book.pdf", bytes.NewReader(data), int64(len(data))))
+
+	job, err := processingService.UploadCompleted(&models.UploadEvent{UserId: 1, DocumentId: document.ID, UploaderId: 1, FileName: "book.pdf"})
+	assert.NoError(t, err)
+	_, err = processingService.JobService.RunPending(context.Background())
+	assert.NoError(t, err)
+
+	job, err = processingService.JobService.GetJob(1, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	stored, err := processingService.MetadataService.DocumentRepository.GetDocument(1, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ProcessingStatusRejected, stored.ProcessingStatus)
+	assert.Contains(t, stored.RejectionReason, "file could not be validated")
+	_, err = processingService.TextService.BlobStore.Stat("1/1/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+}
@@ -0,0 +1,115 @@
+package services_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/services"
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/test/fixtures"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadEditions creates a document of user 1 and uploads a distinct PDF file for every edition
+func uploadEditions(t *testing.T, documentService *services.DocumentService, editions ...string) uint {
+	created, err := documentService.CreateDocument(1, "Book", "")
+	assert.NoError(t, err)
+	id := created["documentId"].(uint)
+
+	for _, edition := range editions {
+		data := fixtures.PDF(nil, "en", []string{edition})
+		_, err = documentService.ReplaceFile(1, id, 0, "book.pdf", bytes.NewReader(data), int64(len(data)))
+		assert.NoError(t, err)
+	}
+	return id
+}
+
+// TestRecordVersion tests that every upload of new content is recorded as a downloadable version
+func TestRecordVersion(t *testing.T) {
+	documentService := setupDocumentService(t)
+	versionService := documentService.ProcessingService.VersionService
+	id := uploadEditions(t, documentService, "first", "first", "second")
+
+	versions, err := versionService.GetVersions(1, id)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].Number)
+	assert.True(t, versions[0].Current)
+	assert.False(t, versions[1].Current)
+	assert.Equal(t, uint(1), versions[1].UploadedBy)
+	assert.Equal(t, "book.pdf", versions[1].FileName)
+
+	content, version, err := versionService.OpenVersion(1, id, 1)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(content)
+	assert.NoError(t, err)
+	assert.NoError(t, content.Close())
+	assert.Equal(t, fixtures.PDF(nil, "en", []string{"first"}), data)
+	assert.Equal(t, int64(len(data)), version.Size)
+
+	_, _, err = versionService.OpenVersion(2, id, 1)
+	assert.ErrorIs(t, err, services.ErrVersionNotFound)
+	_, err = versionService.GetVersions(2, id)
+	assert.ErrorIs(t, err, services.ErrDocumentNotFound)
+}
+
+// TestRestoreVersion tests that a restored version becomes the current file and the newest version sharing the content
+func TestRestoreVersion(t *testing.T) {
+	documentService := setupDocumentService(t)
+	versionService := documentService.ProcessingService.VersionService
+	id := uploadEditions(t, documentService, "first", "second")
+
+	document, err := documentService.RestoreVersion(1, id, 0, 1)
+	assert.NoError(t, err)
+
+	versions, err := versionService.GetVersions(1, id)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 3)
+	assert.True(t, versions[0].Current)
+	assert.Equal(t, versions[2].Sha256, versions[0].Sha256)
+	assert.Equal(t, versions[0].Sha256, document.Metadata.Sha256)
+
+	blobs, err := documentService.BlobStore.List(storage.DocumentPrefix(1, id) + storage.VersionsDirectory + "/")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 2)
+
+	_, err = documentService.RestoreVersion(1, id, 0, 7)
+	assert.ErrorIs(t, err, services.ErrVersionNotFound)
+}
+
+// TestRetentionPolicy tests that versions beyond the count and age limits are deleted together with their content
+func TestRetentionPolicy(t *testing.T) {
+	documentService := setupDocumentService(t)
+	versionService := documentService.ProcessingService.VersionService
+	id := uploadEditions(t, documentService, "first", "second", "third", "fourth")
+
+	policy, err := versionService.GetRetentionPolicy(1)
+	assert.NoError(t, err)
+	assert.Equal(t, services.DefaultKeepVersions, policy.KeepVersions)
+
+	assert.NoError(t, versionService.SetRetentionPolicy(&models.RetentionPolicy{UserId: 1, KeepVersions: 3}))
+	versions, err := versionService.GetVersions(1, id)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, 2, versions[2].Number)
+
+	_, err = documentService.BlobStore.Stat(storage.VersionKey(1, id, versions[0].Sha256))
+	assert.NoError(t, err)
+	blobs, err := documentService.BlobStore.List(storage.DocumentPrefix(1, id) + storage.VersionsDirectory + "/")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 3)
+
+	assert.NoError(t, versionService.SetRetentionPolicy(&models.RetentionPolicy{UserId: 1, KeepDays: 30}))
+	old := time.Now().AddDate(0, 0, -31)
+	db := versionService.VersionRepository.DB
+	assert.NoError(t, db.Model(&models.DocumentVersion{}).Where("document_id = ?", id).Update("created_at", old).Error)
+
+	assert.NoError(t, versionService.PruneAllVersions())
+	versions, err = versionService.GetVersions(1, id)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+	assert.True(t, versions[0].Current)
+}
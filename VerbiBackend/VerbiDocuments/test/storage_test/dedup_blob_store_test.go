@@ -0,0 +1,162 @@
+package storage_test
+
+import (
+	"VerbiDocuments/internal/models"
+	"VerbiDocuments/internal/repositories"
+	"VerbiDocuments/internal/storage"
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupDedupStore creates a DedupBlobStore on top of a LocalBlobStore in a temporary directory
+func setupDedupStore(t *testing.T) (*storage.DedupBlobStore, *storage.LocalBlobStore) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.ContentBlob{}, &models.BlobReference{}))
+	// every connection to an in-memory database opens a new one, so concurrent callers share a single connection
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	local := setupLocalStore(t)
+	return storage.NewDedupBlobStore(local, repositories.NewBlobRepository(db)), local
+}
+
+// readBlob reads the whole object stored under the key
+func readBlob(t *testing.T, store *storage.DedupBlobStore, key string) string {
+	reader, err := store.Get(key)
+	assert.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+// countContents returns the number of contents physically stored in the underlying store
+func countContents(t *testing.T, local *storage.LocalBlobStore) int {
+	contents, err := local.List(storage.ContentDirectory + "/")
+	assert.NoError(t, err)
+	return len(contents)
+}
+
+// TestDedupSharedContent tests that identical objects share one copy that is deleted with its last reference
+func TestDedupSharedContent(t *testing.T) {
+	store, local := setupDedupStore(t)
+
+	assert.NoError(t, store.Put("1/1/book.pdf", bytes.NewReader([]byte("classic")), 7))
+	assert.NoError(t, store.Put("2/5/copy.pdf", bytes.NewReader([]byte("classic")), -1))
+	assert.Equal(t, 1, countContents(t, local))
+	assert.Equal(t, "classic", readBlob(t, store, "2/5/copy.pdf"))
+
+	info, err := store.Stat("1/1/book.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, "1/1/book.pdf", info.Key)
+	assert.Equal(t, int64(7), info.Size)
+
+	assert.NoError(t, store.Delete("1/1/book.pdf"))
+	_, err = store.Stat("1/1/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+	assert.Equal(t, "classic", readBlob(t, store, "2/5/copy.pdf"))
+
+	assert.NoError(t, store.Delete("2/5/copy.pdf"))
+	assert.Zero(t, countContents(t, local))
+}
+
+// TestDedupReplace tests that overwriting a key releases its previous content
+func TestDedupReplace(t *testing.T) {
+	store, local := setupDedupStore(t)
+
+	assert.NoError(t, store.Put("1/1/book.pdf", bytes.NewReader([]byte("first")), -1))
+	assert.NoError(t, store.Put("1/1/book.pdf", bytes.NewReader([]byte("second")), -1))
+	assert.Equal(t, "second", readBlob(t, store, "1/1/book.pdf"))
+	assert.Equal(t, 1, countContents(t, local))
+
+	assert.Error(t, store.Put("1/1/book.pdf", bytes.NewReader([]byte("third")), 10))
+	assert.Equal(t, "second", readBlob(t, store, "1/1/book.pdf"))
+}
+
+// slowDeleteStore delays deletes of the underlying store to widen the window of concurrent writes
+type slowDeleteStore struct {
+	*storage.LocalBlobStore
+}
+
+// Delete waits before deleting the object
+func (s slowDeleteStore) Delete(key string) error {
+	time.Sleep(20 * time.Millisecond)
+	return s.LocalBlobStore.Delete(key)
+}
+
+// TestDedupConcurrentRelease tests that content released by one key while another key stores it again is not lost
+func TestDedupConcurrentRelease(t *testing.T) {
+	store, local := setupDedupStore(t)
+	store.Store = slowDeleteStore{local}
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, store.Put("1/1/book.pdf", bytes.NewReader([]byte("classic")), -1))
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.Delete("1/1/book.pdf"))
+		}()
+		go func() {
+			defer wg.Done()
+			time.Sleep(5 * time.Millisecond)
+			assert.NoError(t, store.Put("2/5/copy.pdf", bytes.NewReader([]byte("classic")), -1))
+		}()
+		wg.Wait()
+
+		assert.Equal(t, "classic", readBlob(t, store, "2/5/copy.pdf"))
+		assert.NoError(t, store.Delete("2/5/copy.pdf"))
+		assert.Zero(t, countContents(t, local))
+	}
+}
+
+// TestDedupList tests that listing combines deduplicated and older objects and hides the content directory
+func TestDedupList(t *testing.T) {
+	store, local := setupDedupStore(t)
+
+	assert.NoError(t, local.Put("1/1/old.pdf", bytes.NewReader([]byte("old")), -1))
+	assert.NoError(t, store.Put("1/1/new.pdf", bytes.NewReader([]byte("new")), -1))
+	assert.NoError(t, store.Put("1_1/other.pdf", bytes.NewReader([]byte("other")), -1))
+
+	blobs, err := store.List("1/")
+	assert.NoError(t, err)
+	keys := make([]string, 0, len(blobs))
+	for _, blob := range blobs {
+		keys = append(keys, blob.Key)
+	}
+	assert.Equal(t, []string{"1/1/new.pdf", "1/1/old.pdf"}, keys)
+
+	blobs, err = store.List("")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 3)
+
+	_, err = store.Get(storage.ContentDirectory + "/ab/abc")
+	assert.Error(t, err)
+}
+
+// TestDeduplicate tests that objects written before deduplication are moved into the content directory
+func TestDeduplicate(t *testing.T) {
+	store, local := setupDedupStore(t)
+
+	assert.NoError(t, local.Put("1/1/book.pdf", bytes.NewReader([]byte("classic")), -1))
+	assert.NoError(t, local.Put("2/3/book.pdf", bytes.NewReader([]byte("classic")), -1))
+	assert.Equal(t, "classic", readBlob(t, store, "1/1/book.pdf"))
+
+	moved, err := store.Deduplicate()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, moved)
+	assert.Equal(t, 1, countContents(t, local))
+	_, err = local.Stat("1/1/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+	assert.Equal(t, "classic", readBlob(t, store, "2/3/book.pdf"))
+}
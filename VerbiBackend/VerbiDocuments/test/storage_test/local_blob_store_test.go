@@ -0,0 +1,115 @@
+package storage_test
+
+import (
+	"VerbiDocuments/internal/storage"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupLocalStore creates a LocalBlobStore in a temporary directory
+func setupLocalStore(t *testing.T) *storage.LocalBlobStore {
+	store, err := storage.NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	return store
+}
+
+// TestLocalPutGet tests writing and reading an object
+func TestLocalPutGet(t *testing.T) {
+	store := setupLocalStore(t)
+
+	err := store.Put("1/2/book.pdf", bytes.NewReader([]byte("content")), 7)
+	assert.NoError(t, err)
+
+	reader, err := store.Get("1/2/book.pdf")
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+
+	buffer := make([]byte, 4)
+	_, err = reader.ReadAt(buffer, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "onte", string(buffer))
+}
+
+// TestLocalPutSizeMismatch tests that a truncated upload does not replace the object
+func TestLocalPutSizeMismatch(t *testing.T) {
+	store := setupLocalStore(t)
+
+	err := store.Put("1/2/book.pdf", bytes.NewReader([]byte("content")), 10)
+	assert.Error(t, err)
+
+	_, err = store.Stat("1/2/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+}
+
+// TestLocalStat tests object info retrieval
+func TestLocalStat(t *testing.T) {
+	store := setupLocalStore(t)
+
+	_, err := store.Stat("1/2/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+
+	err = store.Put("1/2/book.pdf", bytes.NewReader([]byte("content")), -1)
+	assert.NoError(t, err)
+
+	info, err := store.Stat("/1/2/book.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, "1/2/book.pdf", info.Key)
+	assert.Equal(t, int64(7), info.Size)
+
+	_, err = store.Stat("1/2")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+}
+
+// TestLocalListAndDelete tests listing by prefix and deletion with cleanup of empty directories
+func TestLocalListAndDelete(t *testing.T) {
+	store := setupLocalStore(t)
+
+	for _, key := range []string{"1/2/book.pdf", "1/2/preview.pdf", "1/3/book.epub", "10/4/book.pdf"} {
+		assert.NoError(t, store.Put(key, bytes.NewReader([]byte(key)), -1))
+	}
+
+	blobs, err := store.List("1/")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 3)
+	assert.Equal(t, "1/2/book.pdf", blobs[0].Key)
+
+	blobs, err = store.List("1/2/pre")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 1)
+
+	blobs, err = store.List("5/")
+	assert.NoError(t, err)
+	assert.Empty(t, blobs)
+
+	err = storage.DeletePrefix(store, storage.DocumentPrefix(1, 2))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(store.Root, "1", "2"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = store.Delete("1/3/missing.pdf")
+	assert.NoError(t, err)
+
+	blobs, err = store.List("")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 2)
+}
+
+// TestLocalInvalidKey tests that keys can not escape the storage root
+func TestLocalInvalidKey(t *testing.T) {
+	store := setupLocalStore(t)
+
+	err := store.Put("../outside", bytes.NewReader(nil), 0)
+	assert.Error(t, err)
+
+	_, err = store.Get("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package storage_test
+
+import (
+	"VerbiDocuments/internal/storage"
+	"VerbiDocuments/test/mocks"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupS3Store starts an in-memory S3-compatible server and connects an S3BlobStore to it
+func setupS3Store(t *testing.T) *storage.S3BlobStore {
+	server := mocks.NewMockS3Server()
+	t.Cleanup(server.Close)
+
+	store, err := storage.NewS3BlobStore(server.Endpoint(), "access", "secret", "documents", false)
+	assert.NoError(t, err)
+	return store
+}
+
+// TestS3PutGet tests uploading and streaming an object
+func TestS3PutGet(t *testing.T) {
+	store := setupS3Store(t)
+
+	err := store.Put("1/2/book.pdf", bytes.NewReader([]byte("content")), 7)
+	assert.NoError(t, err)
+
+	reader, err := store.Get("/1/2/book.pdf")
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+
+	buffer := make([]byte, 4)
+	_, err = reader.ReadAt(buffer, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "onte", string(buffer))
+}
+
+// TestS3Stat tests object info retrieval
+func TestS3Stat(t *testing.T) {
+	store := setupS3Store(t)
+
+	_, err := store.Stat("1/2/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+
+	_, err = store.Get("1/2/book.pdf")
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+
+	err = store.Put("1/2/book.pdf", strings.NewReader("content"), -1)
+	assert.NoError(t, err)
+
+	info, err := store.Stat("1/2/book.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), info.Size)
+}
+
+// TestS3ListAndDelete tests listing by prefix and deletion
+func TestS3ListAndDelete(t *testing.T) {
+	store := setupS3Store(t)
+
+	for _, key := range []string{"1/2/book.pdf", "1/2/preview.pdf", "1/3/book.epub", "10/4/book.pdf"} {
+		assert.NoError(t, store.Put(key, strings.NewReader(key), int64(len(key))))
+	}
+
+	blobs, err := store.List("1/")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 3)
+
+	err = storage.DeletePrefix(store, storage.DocumentPrefix(1, 2))
+	assert.NoError(t, err)
+
+	blobs, err = store.List("1/")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 1)
+	assert.Equal(t, "1/3/book.epub", blobs[0].Key)
+
+	err = store.Delete("1/3/missing.pdf")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,50 @@
+package thumbnails_test
+
+import (
+	"VerbiDocuments/internal/thumbnails"
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEncode tests scaling of covers to thumbnail widths keeping the aspect ratio
+func TestEncode(t *testing.T) {
+	cover := image.NewRGBA(image.Rect(0, 0, 600, 900))
+
+	data, err := thumbnails.Encode(cover, thumbnails.SizeSmall)
+	assert.NoError(t, err)
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 160, config.Width)
+	assert.Equal(t, 240, config.Height)
+
+	data, err = thumbnails.Encode(cover, thumbnails.SizeLarge)
+	assert.NoError(t, err)
+	config, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 480, config.Width)
+}
+
+// TestTitleCard tests that title cards are drawn in the book proportions and differ by title
+func TestTitleCard(t *testing.T) {
+	first, err := thumbnails.TitleCard("Война и мир, очень длинное название книги, которое не помещается в одну строку", "Лев Толстой")
+	assert.NoError(t, err)
+	assert.Equal(t, 3*first.Bounds().Dx(), 2*first.Bounds().Dy())
+
+	second, err := thumbnails.TitleCard("Anna Karenina", "")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.At(0, 0), second.At(0, 0))
+}
+
+// TestParseSize tests validation of thumbnail size names
+func TestParseSize(t *testing.T) {
+	size, err := thumbnails.ParseSize("large")
+	assert.NoError(t, err)
+	assert.Equal(t, thumbnails.SizeLarge, size)
+
+	_, err = thumbnails.ParseSize("huge")
+	assert.Error(t, err)
+}
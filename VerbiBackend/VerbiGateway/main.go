@@ -1,23 +1,22 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"strconv"
 	"strings"
 )
 
 var services = map[string]string{
-	"auth":      "http://192.168.0.32:8080/api/v1/auth",
-	"profile":   "http://192.168.0.32:8080/api/v1/profile",
-	"documents": "http://192.168.0.32:8081/api/v1/documents",
-	"llm":       "http://192.168.0.32:8082/api/v1/llm",
+	"auth":        "http://192.168.0.32:8080/api/v1/auth",
+	"profile":     "http://192.168.0.32:8080/api/v1/profile",
+	"documents":   "http://192.168.0.32:8081/api/v1/documents",
+	"collections": "http://192.168.0.32:8081/api/v1/collections",
+	"tags":        "http://192.168.0.32:8081/api/v1/tags",
+	"links":       "http://192.168.0.32:8081/api/v1/links",
+	"llm":         "http://192.168.0.32:8082/api/v1/llm",
 }
 
 func NewProxy(target string) *httputil.ReverseProxy {
@@ -45,37 +44,6 @@ func validateToken(r *http.Request) (uint, bool) {
 	return result.UserID, true
 }
 
-func handleDocuments(w http.ResponseWriter, r *http.Request, userID uint) {
-	proxy := NewProxy(services["documents"])
-
-	if r.Method == "POST" {
-		var body map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		body["user_id"] = userID
-		modifiedBody, _ := json.Marshal(body)
-		r.Body = io.NopCloser(bytes.NewReader(modifiedBody))
-		r.ContentLength = int64(len(modifiedBody))
-		r.URL.Path = ""
-	}
-
-	if r.Method == "GET" || r.Method == "DELETE" {
-		log.Printf(r.URL.Path)
-		pathParts := strings.Split(r.URL.Path, "/")
-		if len(pathParts) == 4 {
-			r.URL.Path = fmt.Sprintf("/%s", strconv.Itoa(int(userID)))
-			log.Printf(r.URL.Path)
-		} else if len(pathParts) == 5 {
-			r.URL.Path = fmt.Sprintf("/%s?userId=1", pathParts[4])
-			log.Printf(r.URL.Path)
-		}
-	}
-
-	proxy.ServeHTTP(w, r)
-}
-
 func main() {
 	proxies := make(map[string]*httputil.ReverseProxy)
 	for name, addr := range services {
@@ -97,17 +65,13 @@ func main() {
 			return
 		}
 
-		if service == "documents" || service == "llm" {
-			userID, valid := validateToken(r)
-			if !valid {
+		// VerbiDocuments verifies the forwarded access token itself and takes the user from it.
+		// Public share links are opened by people without a Verbi account
+		if service != "auth" && service != "profile" && service != "links" {
+			if _, valid := validateToken(r); !valid {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
-
-			if service == "documents" {
-				handleDocuments(w, r, userID)
-				return
-			}
 		}
 
 		r.URL.Path = "/" + strings.Join(pathParts[4:], "/")